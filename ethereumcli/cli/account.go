@@ -0,0 +1,135 @@
+package cli
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"golang/ethereumcli/codec"
+	"golang/ethereumcli/units"
+	"math/big"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+type balanceResult struct {
+	Address string `json:"address"`
+	Block   string `json:"block"`
+	Balance string `json:"balance"`
+	Unit    string `json:"unit"`
+	Wei     string `json:"wei"`
+}
+
+func runBalance(ctx context.Context, e *env, fs *flag.FlagSet, args []string) error {
+	blockArg := fs.String("block", "latest", "block number or tag: latest, pending, earliest, safe, finalized")
+	unit := fs.String("unit", "ether", "unit to show balances in: wei, gwei or ether")
+	if err := parseFlags(fs, args, 1, -1); err != nil {
+		return err
+	}
+	decimals, ok := units.Lookup(*unit)
+	if !ok {
+		return fmt.Errorf("unknown unit %q: want wei, gwei or ether", *unit)
+	}
+	block, err := parseBlock(*blockArg)
+	if err != nil {
+		return err
+	}
+	addrs, err := parseAddresses(fs.Args())
+	if err != nil {
+		return err
+	}
+	if err := e.connect(ctx); err != nil {
+		return err
+	}
+	number, err := block.resolve(ctx, e)
+	if err != nil {
+		return err
+	}
+
+	results := make([]balanceResult, 0, len(addrs))
+	for _, addr := range addrs {
+		var wei *big.Int
+		if block.pending() {
+			wei, err = e.client.PendingBalanceAt(ctx, addr)
+		} else {
+			wei, err = e.client.BalanceAt(ctx, addr, number)
+		}
+		if err != nil {
+			return fmt.Errorf("balance of %s: %w", addr.Hex(), err)
+		}
+		results = append(results, balanceResult{
+			Address: addr.Hex(),
+			Block:   block.label(number),
+			Balance: units.Format(wei, decimals),
+			Unit:    strings.ToLower(*unit),
+			Wei:     wei.String(),
+		})
+	}
+	if e.json {
+		return e.writeJSON(results)
+	}
+	tw := tabwriter.NewWriter(e.out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ADDRESS\tBLOCK\tBALANCE")
+	for _, r := range results {
+		fmt.Fprintf(tw, "%s\t%s\t%s %s\n", r.Address, r.Block, r.Balance, r.Unit)
+	}
+	return tw.Flush()
+}
+
+type nonceResult struct {
+	Address string `json:"address"`
+	Block   string `json:"block"`
+	Nonce   uint64 `json:"nonce"`
+}
+
+func runNonce(ctx context.Context, e *env, fs *flag.FlagSet, args []string) error {
+	blockArg := fs.String("block", "latest", "block number or tag: latest, pending, earliest, safe, finalized")
+	if err := parseFlags(fs, args, 1, 1); err != nil {
+		return err
+	}
+	block, err := parseBlock(*blockArg)
+	if err != nil {
+		return err
+	}
+	addr, err := codec.ParseAddress(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	if err := e.connect(ctx); err != nil {
+		return err
+	}
+	number, err := block.resolve(ctx, e)
+	if err != nil {
+		return err
+	}
+	var nonce uint64
+	if block.pending() {
+		nonce, err = e.client.PendingNonceAt(ctx, addr)
+	} else {
+		nonce, err = e.client.NonceAt(ctx, addr, number)
+	}
+	if err != nil {
+		return fmt.Errorf("nonce of %s: %w", addr.Hex(), err)
+	}
+	r := nonceResult{Address: addr.Hex(), Block: block.label(number), Nonce: nonce}
+	if e.json {
+		return e.writeJSON(r)
+	}
+	tw := tabwriter.NewWriter(e.out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ADDRESS\tBLOCK\tNONCE")
+	fmt.Fprintf(tw, "%s\t%s\t%d\n", r.Address, r.Block, r.Nonce)
+	return tw.Flush()
+}
+
+func parseAddresses(args []string) ([]common.Address, error) {
+	addrs := make([]common.Address, 0, len(args))
+	for _, a := range args {
+		addr, err := codec.ParseAddress(a)
+		if err != nil {
+			return nil, err
+		}
+		addrs = append(addrs, addr)
+	}
+	return addrs, nil
+}
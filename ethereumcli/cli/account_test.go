@@ -0,0 +1,71 @@
+package cli
+
+import (
+	"encoding/json"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	alice = "0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed"
+	bob   = "0xfB6916095ca1df60bB79Ce92cE3Ea74c37c5d359"
+)
+
+func TestBalance(t *testing.T) {
+	n := newFakeNode()
+	n.balances[common.HexToAddress(alice)], _ = new(big.Int).SetString("1500000000000000000", 10)
+	n.balances[common.HexToAddress(bob)] = big.NewInt(42)
+	n.pendingDelta = 1_000_000_000
+	ep := serve(t, n)
+
+	out, err := run(t, "--rpc", ep.http, "balance", alice, strings.ToLower(bob))
+	require.NoError(t, err)
+	assert.Regexp(t, alice+`\s+42\s+1.5 ether\n`, out)
+	assert.Regexp(t, bob+`\s+42\s+0.000000000000000042 ether\n`, out, "addresses are shown checksummed")
+	assert.Equal(t, []string{"0x2a", "0x2a"}, n.stateBlocks, "latest is pinned to one block")
+
+	out, err = run(t, "--rpc", ep.http, "--output", "json", "balance", "-unit", "gwei", "-block", "pending", bob)
+	require.NoError(t, err)
+	var results []balanceResult
+	require.NoError(t, json.Unmarshal([]byte(out), &results))
+	assert.Equal(t, []balanceResult{{Address: bob, Block: "pending", Balance: "1.000000042", Unit: "gwei", Wei: "1000000042"}}, results)
+
+	n.stateBlocks = nil
+	_, err = run(t, "--rpc", ep.http, "balance", "-block", "safe", bob)
+	require.NoError(t, err)
+	_, err = run(t, "--rpc", ep.http, "balance", "-block", "finalized", bob)
+	require.NoError(t, err)
+	_, err = run(t, "--rpc", ep.http, "balance", "-block", "0x10", bob)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"0x28", "0x20", "0x10"}, n.stateBlocks)
+
+	_, err = run(t, "--rpc", ep.http, "balance", "-unit", "finney", bob)
+	assert.ErrorContains(t, err, `unknown unit "finney"`)
+	_, err = run(t, "--rpc", ep.http, "balance", "-block", "soon", bob)
+	assert.ErrorContains(t, err, `invalid block "soon"`)
+}
+
+func TestNonce(t *testing.T) {
+	n := newFakeNode()
+	n.nonces[common.HexToAddress(alice)] = 7
+	n.pendingDelta = 2
+	ep := serve(t, n)
+
+	out, err := run(t, "--rpc", ep.http, "nonce", alice)
+	require.NoError(t, err)
+	assert.Regexp(t, alice+`\s+42\s+7\n`, out)
+
+	out, err = run(t, "--rpc", ep.http, "--output", "json", "nonce", "-block", "pending", alice)
+	require.NoError(t, err)
+	var r nonceResult
+	require.NoError(t, json.Unmarshal([]byte(out), &r))
+	assert.Equal(t, nonceResult{Address: alice, Block: "pending", Nonce: 9}, r)
+
+	_, err = run(t, "--rpc", ep.http, "nonce", alice, bob)
+	assert.ErrorIs(t, err, ErrUsage)
+}
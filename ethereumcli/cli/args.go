@@ -0,0 +1,95 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// blockRef is a parsed block argument: a number or one of the tags
+// latest, pending, safe and finalized. earliest is block 0.
+type blockRef struct {
+	tag    string
+	number *big.Int
+}
+
+func parseBlock(s string) (blockRef, error) {
+	switch s := strings.ToLower(s); s {
+	case "", "latest":
+		return blockRef{tag: "latest"}, nil
+	case "pending", "safe", "finalized":
+		return blockRef{tag: s}, nil
+	case "earliest":
+		return blockRef{number: big.NewInt(0)}, nil
+	}
+	if strings.HasPrefix(s, "0x") {
+		n, err := hexutil.DecodeBig(s)
+		if err != nil {
+			return blockRef{}, fmt.Errorf("invalid block %q: %w", s, err)
+		}
+		return blockRef{number: n}, nil
+	}
+	n, err := strconv.ParseUint(s, 10, 64)
+	if err != nil {
+		return blockRef{}, fmt.Errorf("invalid block %q: want a number or latest, pending, earliest, safe or finalized", s)
+	}
+	return blockRef{number: new(big.Int).SetUint64(n)}, nil
+}
+
+// pending reports whether b names the pending state, which ethclient
+// reads with separate methods.
+func (b blockRef) pending() bool { return b.tag == "pending" }
+
+// resolve pins b to a block number so that several queries read the same
+// state. The pending block has no number and resolves to nil.
+func (b blockRef) resolve(ctx context.Context, e *env) (*big.Int, error) {
+	if b.number != nil || b.pending() {
+		return b.number, nil
+	}
+	var head *types.Header
+	if err := e.rpc.CallContext(ctx, &head, "eth_getBlockByNumber", b.tag, false); err != nil {
+		return nil, fmt.Errorf("%s block: %w", b.tag, err)
+	}
+	if head == nil {
+		return nil, fmt.Errorf("the node has no %s block", b.tag)
+	}
+	return head.Number, nil
+}
+
+// label is how a query's block is shown once resolved.
+func (b blockRef) label(resolved *big.Int) string {
+	if resolved == nil {
+		return b.tag
+	}
+	return resolved.String()
+}
+
+// parseHash accepts a 0x-prefixed 32-byte hash.
+func parseHash(s string) (common.Hash, error) {
+	b, err := hexutil.Decode(s)
+	if err != nil || len(b) != common.HashLength {
+		return common.Hash{}, fmt.Errorf("%q: want a 0x-prefixed 32-byte hash", s)
+	}
+	return common.BytesToHash(b), nil
+}
+
+// isHash reports whether s looks like a hash rather than a block number.
+func isHash(s string) bool {
+	return len(s) == 2+2*common.HashLength && strings.HasPrefix(s, "0x")
+}
+
+// stringList is a flag that may be given several times.
+type stringList []string
+
+func (l *stringList) String() string { return strings.Join(*l, ",") }
+
+func (l *stringList) Set(s string) error {
+	*l = append(*l, s)
+	return nil
+}
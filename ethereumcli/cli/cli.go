@@ -0,0 +1,288 @@
+// Package cli implements the ethereumcli commands.
+package cli
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"golang/ethereumcli/rpcpool"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// DefaultRPC is used when neither --rpc nor ETH_RPC_URL is set.
+const DefaultRPC = "https://cloudflare-eth.com"
+
+// RPCEnv names the environment variable holding the default endpoint.
+const RPCEnv = "ETH_RPC_URL"
+
+// KeystoreEnv names the environment variable holding the keystore
+// directory; the default is geth's, ~/.ethereum/keystore.
+const KeystoreEnv = "ETH_KEYSTORE"
+
+// ErrUsage is returned for unknown commands and bad flags; the usage has
+// already been printed.
+var ErrUsage = errors.New("usage error")
+
+type command struct {
+	name    string
+	args    string
+	summary string
+	// untimed commands may wait on the chain and apply --timeout to
+	// their RPC calls themselves.
+	untimed bool
+	run     func(ctx context.Context, e *env, fs *flag.FlagSet, args []string) error
+}
+
+var commands = []command{
+	{name: "info", args: "", summary: "print chain, sync and client information", run: runInfo},
+	{name: "balance", args: "[-block <n|tag>] [-unit wei|gwei|ether] <address>...", summary: "print account balances", run: runBalance},
+	{name: "nonce", args: "[-block <n|tag>] <address>", summary: "print an account's transaction count", run: runNonce},
+	{name: "block", args: "[-full] <number|hash|tag>", summary: "print a block header and its transactions", run: runBlock},
+	{name: "tx", args: "<hash>", summary: "print a transaction", run: runTx},
+	{name: "receipt", args: "<hash>", summary: "print a transaction receipt, its gas usage and logs", run: runReceipt},
+	{name: "send", args: "-from <address> -to <address> -value <amount> [flags]", summary: "sign and broadcast an EIP-1559 transfer", untimed: true, run: runSend},
+	{name: "token info", args: "<contract>", summary: "print an ERC-20 token's name, symbol, decimals and supply", run: runTokenInfo},
+	{name: "token balance", args: "<contract> <address>...", summary: "print token balances", run: runTokenBalance},
+	{name: "token allowance", args: "<contract> <owner> <spender>", summary: "print how much spender may transfer from owner", run: runTokenAllowance},
+	{name: "token transfer", args: "-from <address> [flags] <contract> <to> <amount>", summary: "transfer tokens", untimed: true, run: runTokenTransfer},
+	{name: "token approve", args: "-from <address> [flags] <contract> <spender> <amount|max>", summary: "let spender transfer tokens from an account", untimed: true, run: runTokenApprove},
+	{name: "call", args: "-abi <file> -to <contract> [-from <address>] [-block <n|tag>] <method> [args...]", summary: "call a contract method without sending a transaction", run: runCall},
+	{name: "transact", args: "-abi <file> -to <contract> -from <address> [-value <amount>] [flags] <method> [args...]", summary: "send a transaction calling a contract method", untimed: true, run: runTransact},
+	{name: "decode-input", args: "-abi <file> <calldata>", summary: "decode transaction call data", run: runDecodeInput},
+	{name: "decode-log", args: "-abi <file> [-data <hex>] <topic>...", summary: "decode an event log", run: runDecodeLog},
+	{name: "index", args: "-db <file> [-address <contract>]... [-event <name|signature|topic>]... [-abi <file>] [flags]", summary: "store contract logs in a local database and keep it up to date", untimed: true, run: runIndex},
+	{name: "watch heads", args: "[-count <n>] [-poll <interval>]", summary: "print new blocks as they arrive", untimed: true, run: runWatchHeads},
+	{name: "watch logs", args: "[-address <contract>]... [-topic <topic|signature>]... [-from <n>] [-count <n>] [-poll <interval>]", summary: "print matching logs as they arrive", untimed: true, run: runWatchLogs},
+	{name: "watch pending", args: "[-count <n>] [-poll <interval>]", summary: "print the hashes of transactions entering the pool", untimed: true, run: runWatchPending},
+	{name: "endpoints", args: "", summary: "check the head, latency and agreement of each --rpc endpoint", run: runEndpoints},
+	{name: "wallet new", args: "[-password-file <file>] [-mnemonic] [-words <n>] [-path <path>]", summary: "create a key in the keystore", run: runWalletNew},
+	{name: "wallet import", args: "[-password-file <file>] [-path <path>] [<hexkey|mnemonic>]", summary: "add a private key or mnemonic-derived key to the keystore", run: runWalletImport},
+	{name: "wallet list", args: "", summary: "list the keystore's accounts", run: runWalletList},
+	{name: "wallet export", args: "[-password-file <file>] [-private-key] <address>", summary: "print an account's encrypted key file or private key", run: runWalletExport},
+}
+
+// env is what a command runs with. rpc and client are nil until connect;
+// pool is set once a list of endpoints has been dialed.
+type env struct {
+	endpoint string
+	rpc      *rpc.Client
+	client   *ethclient.Client
+	pool     *rpcpool.Pool
+	keystore string
+	timeout  time.Duration
+	// json selects JSON output over tables.
+	json bool
+	// in buffers stdin, which passwords and secrets are read from unless
+	// it is a terminal.
+	in     *bufio.Reader
+	stdin  io.Reader
+	out    io.Writer
+	errOut io.Writer
+}
+
+// Run parses the global flags in args, dials the endpoint and executes
+// one command. Passwords and secrets are read from in, results go to out,
+// prompts, usage and flag errors to errOut.
+func Run(ctx context.Context, args []string, in io.Reader, out, errOut io.Writer) error {
+	global := flag.NewFlagSet("ethereumcli", flag.ContinueOnError)
+	global.SetOutput(errOut)
+	global.Usage = func() { usage(errOut, global) }
+	defaultRPC := os.Getenv(RPCEnv)
+	if defaultRPC == "" {
+		defaultRPC = DefaultRPC
+	}
+	endpoint := global.String("rpc", defaultRPC, "JSON-RPC endpoint: http(s)://, ws(s):// or an IPC socket path; several comma-separated http(s) URLs make a failover pool (env "+RPCEnv+")")
+	keystoreDir := global.String("keystore", defaultKeystore(), "keystore directory (env "+KeystoreEnv+")")
+	timeout := global.Duration("timeout", 10*time.Second, "give up on a command's RPC calls after this long")
+	output := global.String("output", "table", "output format: table or json")
+	if err := global.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return nil
+		}
+		return ErrUsage
+	}
+	if *output != "table" && *output != "json" {
+		fmt.Fprintf(errOut, "invalid -output %q: want table or json\n", *output)
+		return ErrUsage
+	}
+
+	cmd, rest := lookup(global.Args())
+	if cmd == nil {
+		global.Usage()
+		return ErrUsage
+	}
+	fs := flag.NewFlagSet("ethereumcli "+cmd.name, flag.ContinueOnError)
+	fs.SetOutput(errOut)
+	fs.Usage = func() {
+		fmt.Fprintf(errOut, "usage: ethereumcli [global flags] %s %s\n", cmd.name, cmd.args)
+		fs.PrintDefaults()
+	}
+
+	if !cmd.untimed {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, *timeout)
+		defer cancel()
+	}
+	e := &env{
+		endpoint: *endpoint,
+		keystore: *keystoreDir,
+		timeout:  *timeout,
+		json:     *output == "json",
+		in:       bufio.NewReader(in),
+		stdin:    in,
+		out:      out,
+		errOut:   errOut,
+	}
+	defer e.close()
+	err := cmd.run(ctx, e, fs, rest)
+	if errors.Is(err, flag.ErrHelp) {
+		return nil
+	}
+	return err
+}
+
+func defaultKeystore() string {
+	if dir := os.Getenv(KeystoreEnv); dir != "" {
+		return dir
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "keystore"
+	}
+	return filepath.Join(home, ".ethereum", "keystore")
+}
+
+// connect dials the endpoint. Commands call it once their arguments are
+// known to be valid.
+func (e *env) connect(ctx context.Context) error {
+	c, err := e.dial(ctx)
+	if err != nil {
+		return err
+	}
+	e.rpc, e.client = c, ethclient.NewClient(c)
+	return nil
+}
+
+// dial connects to the endpoint. A comma-separated list of http(s) URLs
+// is a pool that fails over between them; it is created on the first
+// dial, checked in the background and closed with the env.
+func (e *env) dial(ctx context.Context) (*rpc.Client, error) {
+	if !strings.Contains(e.endpoint, ",") {
+		return Dial(ctx, e.endpoint)
+	}
+	if e.pool == nil {
+		p, err := dialPool(ctx, e.endpoint)
+		if err != nil {
+			return nil, err
+		}
+		p.Start(context.Background())
+		e.pool = p
+	}
+	return e.pool.Client()
+}
+
+func (e *env) close() {
+	if e.rpc != nil {
+		e.rpc.Close()
+	}
+	if e.pool != nil {
+		e.pool.Close()
+	}
+}
+
+// Dial connects to an HTTP, WebSocket or IPC endpoint. Anything without a
+// URL scheme is taken as the path of an IPC socket.
+func Dial(ctx context.Context, endpoint string) (*rpc.Client, error) {
+	scheme, _, hasScheme := strings.Cut(endpoint, "://")
+	if hasScheme {
+		switch scheme {
+		case "http", "https", "ws", "wss":
+		default:
+			return nil, fmt.Errorf("unsupported RPC endpoint %q: want http(s)://, ws(s):// or an IPC path", endpoint)
+		}
+	}
+	c, err := rpc.DialContext(ctx, endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("connect to %s: %w", endpoint, err)
+	}
+	return c, nil
+}
+
+// dialPool creates a pool of the comma-separated endpoints and checks
+// them. If none is healthy the pool is closed, but still returned so that
+// its status can be shown.
+func dialPool(ctx context.Context, endpoints string) (*rpcpool.Pool, error) {
+	urls := strings.Split(endpoints, ",")
+	for i := range urls {
+		urls[i] = strings.TrimSpace(urls[i])
+	}
+	p, err := rpcpool.New(urls, rpcpool.Config{})
+	if err != nil {
+		return nil, err
+	}
+	if err := p.Check(ctx); err != nil {
+		p.Close()
+		return p, fmt.Errorf("connect to %s: %w", endpoints, err)
+	}
+	return p, nil
+}
+
+// writeJSON prints v as indented JSON.
+func (e *env) writeJSON(v any) error {
+	enc := json.NewEncoder(e.out)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+// lookup matches the longest command name at the start of args.
+func lookup(args []string) (*command, []string) {
+	var best *command
+	for i := range commands {
+		words := strings.Fields(commands[i].name)
+		if len(args) >= len(words) && strings.Join(args[:len(words)], " ") == commands[i].name {
+			if best == nil || len(words) > len(strings.Fields(best.name)) {
+				best = &commands[i]
+			}
+		}
+	}
+	if best == nil {
+		return nil, nil
+	}
+	return best, args[len(strings.Fields(best.name)):]
+}
+
+func usage(w io.Writer, global *flag.FlagSet) {
+	fmt.Fprintln(w, "usage: ethereumcli [global flags] <command> [flags]")
+	fmt.Fprintln(w, "\nCommands:")
+	for _, c := range commands {
+		fmt.Fprintf(w, "  %-16s %s\n", c.name, c.summary)
+	}
+	fmt.Fprintln(w, "\nGlobal flags:")
+	global.PrintDefaults()
+}
+
+// parseFlags parses args and checks the number of positional arguments;
+// max < 0 means no upper bound.
+func parseFlags(fs *flag.FlagSet, args []string, min, max int) error {
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return err
+		}
+		return ErrUsage
+	}
+	if n := fs.NArg(); n < min || (max >= 0 && n > max) {
+		fs.Usage()
+		return ErrUsage
+	}
+	return nil
+}
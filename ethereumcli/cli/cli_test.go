@@ -0,0 +1,54 @@
+package cli
+
+import (
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInfoOverEveryTransport(t *testing.T) {
+	ep := serve(t, newFakeNode())
+	for name, endpoint := range map[string]string{"http": ep.http, "ws": ep.ws, "ipc": ep.ipc} {
+		out, err := run(t, "--rpc", endpoint, "info")
+		require.NoError(t, err, name)
+		assert.Regexp(t, `Client\s+Fake/v1.0.0`, out, name)
+		assert.Regexp(t, `Chain ID\s+1337\n`, out, name)
+		assert.Regexp(t, `Network ID\s+1337\n`, out, name)
+		assert.Regexp(t, `Latest block\s+42 \(2023-11-14T22:13:20Z, `, out, name)
+		assert.Regexp(t, `Gas price\s+1.5 gwei`, out, name)
+		assert.Regexp(t, `Syncing\s+no`, out, name)
+	}
+}
+
+func TestInfoSyncing(t *testing.T) {
+	n := newFakeNode()
+	n.syncing = map[string]hexutil.Uint64{"startingBlock": 0, "currentBlock": 250, "highestBlock": 1000}
+	ep := serve(t, n)
+	out, err := run(t, "--rpc", ep.http, "info")
+	require.NoError(t, err)
+	assert.Contains(t, out, "yes, block 250 of 1000 (25.0%)")
+}
+
+func TestRPCFromEnvironment(t *testing.T) {
+	ep := serve(t, newFakeNode())
+	t.Setenv(RPCEnv, ep.http)
+	out, err := run(t, "info")
+	require.NoError(t, err)
+	assert.Contains(t, out, ep.http)
+}
+
+func TestUsage(t *testing.T) {
+	out, err := run(t, "frobnicate")
+	assert.ErrorIs(t, err, ErrUsage)
+	assert.Contains(t, out, "info")
+	assert.Contains(t, out, "-rpc")
+
+	_, err = run(t, "--rpc", "ftp://example.com", "info")
+	assert.ErrorContains(t, err, "unsupported RPC endpoint")
+
+	out, err = run(t, "--rpc", "ws://127.0.0.1:1", "info", "extra")
+	assert.ErrorIs(t, err, ErrUsage)
+	assert.Contains(t, out, "usage: ethereumcli [global flags] info")
+}
@@ -0,0 +1,288 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"golang/ethereumcli/codec"
+	"golang/ethereumcli/units"
+	"io"
+	"text/tabwriter"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// callResult is the JSON output of call.
+type callResult struct {
+	Contract string        `json:"contract"`
+	Method   string        `json:"method"`
+	Block    string        `json:"block"`
+	Outputs  []codec.Field `json:"outputs"`
+}
+
+// contractCall is a method call parsed from the command line.
+type contractCall struct {
+	abi    abi.ABI
+	method abi.Method
+	data   []byte
+}
+
+// parseCall loads the ABI, finds the method named by args[0] and encodes
+// the remaining args as its arguments.
+func parseCall(abiFile string, args []string) (*contractCall, error) {
+	a, err := codec.LoadABI(abiFile)
+	if err != nil {
+		return nil, err
+	}
+	m, err := codec.FindMethod(a, args[0])
+	if err != nil {
+		return nil, err
+	}
+	values, err := codec.ParseArgs(m.Inputs, args[1:])
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", m.Sig, err)
+	}
+	packed, err := m.Inputs.Pack(values...)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", m.Sig, err)
+	}
+	return &contractCall{abi: a, method: m, data: append(m.ID[:4:4], packed...)}, nil
+}
+
+// revertError replaces err with the decoded revert reason if the node
+// returned revert data along with it.
+func revertError(a *abi.ABI, err error) error {
+	var de rpc.DataError
+	if !errors.As(err, &de) {
+		return err
+	}
+	s, _ := de.ErrorData().(string)
+	data, decodeErr := hexutil.Decode(s)
+	if decodeErr != nil || len(data) == 0 {
+		return err
+	}
+	return fmt.Errorf("execution reverted: %s", codec.RevertReason(a, data))
+}
+
+func runCall(ctx context.Context, e *env, fs *flag.FlagSet, args []string) error {
+	abiFile := fs.String("abi", "", "JSON ABI or build artifact of the contract")
+	toFlag := fs.String("to", "", "contract address")
+	fromFlag := fs.String("from", "", "address to call from")
+	blockFlag := fs.String("block", "latest", "block number or tag to call at")
+	if err := parseFlags(fs, args, 1, -1); err != nil {
+		return err
+	}
+	if *abiFile == "" || *toFlag == "" {
+		fs.Usage()
+		return ErrUsage
+	}
+	to, err := codec.ParseAddress(*toFlag)
+	if err != nil {
+		return err
+	}
+	msg := ethereum.CallMsg{To: &to}
+	if *fromFlag != "" {
+		if msg.From, err = codec.ParseAddress(*fromFlag); err != nil {
+			return err
+		}
+	}
+	block, err := parseBlock(*blockFlag)
+	if err != nil {
+		return err
+	}
+	call, err := parseCall(*abiFile, fs.Args())
+	if err != nil {
+		return err
+	}
+	msg.Data = call.data
+	if err := e.connect(ctx); err != nil {
+		return err
+	}
+
+	number, err := block.resolve(ctx, e)
+	if err != nil {
+		return err
+	}
+	var out []byte
+	if block.pending() {
+		out, err = e.client.PendingCallContract(ctx, msg)
+	} else {
+		out, err = e.client.CallContract(ctx, msg, number)
+	}
+	if err != nil {
+		return revertError(&call.abi, err)
+	}
+	if len(out) == 0 && len(call.method.Outputs) > 0 {
+		return fmt.Errorf("%s returned no data; is %s a contract with this ABI?", call.method.Sig, to.Hex())
+	}
+	values, err := call.method.Outputs.Unpack(out)
+	if err != nil {
+		return fmt.Errorf("decode %s result: %w", call.method.Sig, err)
+	}
+	r := callResult{
+		Contract: to.Hex(),
+		Method:   call.method.Sig,
+		Block:    block.label(number),
+		Outputs:  codec.Fields(call.method.Outputs, values),
+	}
+	if e.json {
+		return e.writeJSON(r)
+	}
+	if len(r.Outputs) == 1 {
+		_, err := fmt.Fprintln(e.out, r.Outputs[0].Text)
+		return err
+	}
+	return printFields(e.out, r.Outputs)
+}
+
+func runTransact(ctx context.Context, e *env, fs *flag.FlagSet, args []string) error {
+	f := addTxFlags(fs)
+	abiFile := fs.String("abi", "", "JSON ABI or build artifact of the contract")
+	toFlag := fs.String("to", "", "contract address")
+	valueFlag := fs.String("value", "0", "ether to send with a payable method, in -unit")
+	unitFlag := fs.String("unit", "ether", "unit of -value: wei, gwei or ether")
+	if err := parseFlags(fs, args, 1, -1); err != nil {
+		return err
+	}
+	if *abiFile == "" || *toFlag == "" {
+		fs.Usage()
+		return ErrUsage
+	}
+	if err := f.parse(fs); err != nil {
+		return err
+	}
+	to, err := codec.ParseAddress(*toFlag)
+	if err != nil {
+		return err
+	}
+	f.req.To = &to
+	decimals, ok := units.Lookup(*unitFlag)
+	if !ok {
+		return fmt.Errorf("unknown unit %q", *unitFlag)
+	}
+	if f.req.Value, err = units.Parse(*valueFlag, decimals); err != nil {
+		return err
+	}
+	call, err := parseCall(*abiFile, fs.Args())
+	if err != nil {
+		return err
+	}
+	if call.method.IsConstant() {
+		return fmt.Errorf("%s is %s and changes no state; use call", call.method.Sig, call.method.StateMutability)
+	}
+	if f.req.Value.Sign() > 0 && !call.method.IsPayable() {
+		return fmt.Errorf("%s is not payable; drop -value", call.method.Sig)
+	}
+	f.req.Data = call.data
+
+	if err := e.unlock(f); err != nil {
+		return err
+	}
+	rpcCtx, cancel := e.rpcContext(ctx)
+	defer cancel()
+	if err := e.connect(rpcCtx); err != nil {
+		return err
+	}
+	return revertError(&call.abi, e.transact(ctx, f))
+}
+
+func runDecodeInput(ctx context.Context, e *env, fs *flag.FlagSet, args []string) error {
+	abiFile := fs.String("abi", "", "JSON ABI or build artifact to decode with")
+	if err := parseFlags(fs, args, 1, 1); err != nil {
+		return err
+	}
+	if *abiFile == "" {
+		fs.Usage()
+		return ErrUsage
+	}
+	data, err := hexutil.Decode(fs.Arg(0))
+	if err != nil {
+		return fmt.Errorf("invalid call data: %v", err)
+	}
+	a, err := codec.LoadABI(*abiFile)
+	if err != nil {
+		return err
+	}
+	call, err := codec.DecodeInput(a, data)
+	if err != nil {
+		return err
+	}
+	if e.json {
+		return e.writeJSON(call)
+	}
+	tw := tabwriter.NewWriter(e.out, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "Method\t%s\n", call.Method)
+	fmt.Fprintf(tw, "Selector\t%s\n", call.Selector)
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	if len(call.Arguments) == 0 {
+		return nil
+	}
+	fmt.Fprintln(e.out)
+	return printFields(e.out, call.Arguments)
+}
+
+func runDecodeLog(ctx context.Context, e *env, fs *flag.FlagSet, args []string) error {
+	abiFile := fs.String("abi", "", "JSON ABI or build artifact to decode with")
+	dataFlag := fs.String("data", "0x", "hex log data")
+	if err := parseFlags(fs, args, 1, 4); err != nil {
+		return err
+	}
+	if *abiFile == "" {
+		fs.Usage()
+		return ErrUsage
+	}
+	topics := make([]common.Hash, fs.NArg())
+	for i, arg := range fs.Args() {
+		var err error
+		if topics[i], err = parseHash(arg); err != nil {
+			return fmt.Errorf("topic %d: %v", i, err)
+		}
+	}
+	data, err := hexutil.Decode(*dataFlag)
+	if err != nil {
+		return fmt.Errorf("invalid -data: %v", err)
+	}
+	a, err := codec.LoadABI(*abiFile)
+	if err != nil {
+		return err
+	}
+	ev, err := codec.DecodeLog(a, topics, data)
+	if err != nil {
+		return err
+	}
+	if e.json {
+		return e.writeJSON(ev)
+	}
+	tw := tabwriter.NewWriter(e.out, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "Event\t%s\n", ev.Event)
+	fmt.Fprintf(tw, "Topic\t%s\n", ev.Topic)
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	fmt.Fprintln(e.out)
+	return printFields(e.out, ev.Arguments)
+}
+
+// printFields prints decoded values as a table; unnamed ones are numbered.
+func printFields(w io.Writer, fields []codec.Field) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "NAME\tTYPE\tVALUE")
+	for i, f := range fields {
+		name, typ := f.Name, f.Type
+		if name == "" {
+			name = fmt.Sprintf("#%d", i)
+		}
+		if f.Indexed {
+			typ += " indexed"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", name, typ, f.Text)
+	}
+	return tw.Flush()
+}
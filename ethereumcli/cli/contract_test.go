@@ -0,0 +1,195 @@
+package cli
+
+import (
+	"encoding/json"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const vaultABI = `[
+	{"type":"function","name":"balanceOf","stateMutability":"view","inputs":[{"name":"owner","type":"address"}],"outputs":[{"name":"","type":"uint256"}]},
+	{"type":"function","name":"getReserves","stateMutability":"view","inputs":[],"outputs":[{"name":"reserve0","type":"uint112"},{"name":"reserve1","type":"uint112"},{"name":"updated","type":"uint32"}]},
+	{"type":"function","name":"deposit","stateMutability":"payable","inputs":[{"name":"legs","type":"tuple[]","components":[{"name":"to","type":"address"},{"name":"amount","type":"uint256"}]},{"name":"memo","type":"bytes"}],"outputs":[]},
+	{"type":"function","name":"withdraw","stateMutability":"nonpayable","inputs":[{"name":"amount","type":"uint256"}],"outputs":[]},
+	{"type":"event","name":"Deposit","inputs":[{"name":"from","type":"address","indexed":true},{"name":"amount","type":"uint256","indexed":false}],"anonymous":false},
+	{"type":"error","name":"InsufficientBalance","inputs":[{"name":"have","type":"uint256"},{"name":"want","type":"uint256"}]}
+]`
+
+var vaultAddr = common.HexToAddress("0x000000000000000000000000000000000000Fa17")
+
+// fakeVault writes the vault ABI as a build artifact and makes n answer
+// its calls.
+func fakeVault(t *testing.T, n *fakeNode) (abiFile string, parsed abi.ABI) {
+	parsed, err := abi.JSON(strings.NewReader(vaultABI))
+	require.NoError(t, err)
+	abiFile = filepath.Join(t.TempDir(), "Vault.json")
+	require.NoError(t, os.WriteFile(abiFile, []byte(`{"contractName":"Vault","abi":`+vaultABI+`}`), 0o600))
+
+	pack := func(method string, args ...interface{}) string {
+		in, err := parsed.Pack(method, args...)
+		require.NoError(t, err)
+		return hexutil.Encode(in)
+	}
+	out, err := parsed.Methods["balanceOf"].Outputs.Pack(big.NewInt(1500))
+	require.NoError(t, err)
+	n.calls[pack("balanceOf", common.HexToAddress(bob))] = out
+	out, err = parsed.Methods["getReserves"].Outputs.Pack(big.NewInt(7), big.NewInt(9), uint32(1_700_000_000))
+	require.NoError(t, err)
+	n.calls[pack("getReserves")] = out
+
+	reason, err := abi.Arguments{{Type: mustABIType(t, "string")}}.Pack("not owner")
+	require.NoError(t, err)
+	n.reverts[pack("balanceOf", common.HexToAddress(alice))] = append(crypto.Keccak256([]byte("Error(string)"))[:4], reason...)
+	custom, err := parsed.Errors["InsufficientBalance"].Inputs.Pack(big.NewInt(1), big.NewInt(5))
+	require.NoError(t, err)
+	id := parsed.Errors["InsufficientBalance"].ID
+	n.reverts[pack("withdraw", big.NewInt(5))] = append(id[:4:4], custom...)
+	return abiFile, parsed
+}
+
+func mustABIType(t *testing.T, name string) abi.Type {
+	typ, err := abi.NewType(name, "", nil)
+	require.NoError(t, err)
+	return typ
+}
+
+func TestCall(t *testing.T) {
+	n := newFakeNode()
+	abiFile, _ := fakeVault(t, n)
+	ep := serve(t, n)
+	call := func(args ...string) (string, error) {
+		return run(t, append([]string{"--rpc", ep.http}, args...)...)
+	}
+
+	out, err := call("call", "-abi", abiFile, "-to", vaultAddr.Hex(), "balanceOf", bob)
+	require.NoError(t, err)
+	assert.Equal(t, "1500\n", out)
+
+	out, err = call("call", "-abi", abiFile, "-to", vaultAddr.Hex(), "getReserves")
+	require.NoError(t, err)
+	assert.Regexp(t, `reserve0\s+uint112\s+7\n`, out)
+	assert.Regexp(t, `updated\s+uint32\s+1700000000\n`, out)
+
+	out, err = call("--output", "json", "call", "-abi", abiFile, "-to", vaultAddr.Hex(), "-block", "pending", "getReserves")
+	require.NoError(t, err)
+	var r callResult
+	require.NoError(t, json.Unmarshal([]byte(out), &r))
+	assert.Equal(t, "getReserves()", r.Method)
+	assert.Equal(t, "pending", r.Block)
+	require.Len(t, r.Outputs, 3)
+	assert.Equal(t, "9", r.Outputs[1].Value)
+
+	_, err = call("call", "-abi", abiFile, "-to", vaultAddr.Hex(), "balanceOf", alice)
+	assert.EqualError(t, err, "execution reverted: not owner")
+	_, err = call("call", "-abi", abiFile, "-to", vaultAddr.Hex(), "withdraw", "5")
+	assert.EqualError(t, err, "execution reverted: InsufficientBalance(1, 5)")
+}
+
+func TestCallErrors(t *testing.T) {
+	abiFile, _ := fakeVault(t, newFakeNode())
+	// Nothing listens here: every case must fail before dialing.
+	for _, tc := range []struct {
+		args []string
+		want string
+	}{
+		{[]string{"-to", vaultAddr.Hex(), "balanceOf", bob}, "usage error"},
+		{[]string{"-abi", abiFile, "balanceOf", bob}, "usage error"},
+		{[]string{"-abi", abiFile, "-to", vaultAddr.Hex()}, "usage error"},
+		{[]string{"-abi", abiFile, "-to", vaultAddr.Hex(), "mint"}, `no method "mint"`},
+		{[]string{"-abi", abiFile, "-to", vaultAddr.Hex(), "balanceOf"}, "want 1 arguments, got 0"},
+		{[]string{"-abi", abiFile, "-to", vaultAddr.Hex(), "balanceOf", "0x1234"}, `argument owner (address): "0x1234": address must be 40 hex digits`},
+		{[]string{"-abi", filepath.Join(t.TempDir(), "missing.json"), "-to", vaultAddr.Hex(), "getReserves"}, "no such file"},
+	} {
+		_, err := run(t, append([]string{"--rpc", "http://127.0.0.1:1", "call"}, tc.args...)...)
+		assert.ErrorContains(t, err, tc.want, "%v", tc.args)
+	}
+}
+
+func TestTransact(t *testing.T) {
+	ks := testKeystore(t)
+	pwFile := filepath.Join(t.TempDir(), "password")
+	require.NoError(t, os.WriteFile(pwFile, []byte("pw\n"), 0o600))
+	_, err := run(t, "--keystore", ks, "wallet", "import", "-password-file", pwFile, testKey)
+	require.NoError(t, err)
+
+	n := newFakeNode()
+	abiFile, parsed := fakeVault(t, n)
+	ep := serve(t, n)
+	transact := func(args ...string) (string, error) {
+		return run(t, append([]string{"--rpc", ep.http, "--keystore", ks, "transact", "-abi", abiFile,
+			"-to", vaultAddr.Hex(), "-from", testAddress, "-password-file", pwFile}, args...)...)
+	}
+
+	_, err = transact("-value", "0.5", "deposit", "[("+bob+", 10), ("+alice+", 0x20)]", "0xc0ffee")
+	require.NoError(t, err)
+	require.Len(t, n.sent, 1)
+	tx := n.sent[0]
+	assert.Equal(t, vaultAddr, *tx.To())
+	assert.Equal(t, "500000000000000000", tx.Value().String())
+	type leg struct {
+		To     common.Address
+		Amount *big.Int
+	}
+	want, err := parsed.Pack("deposit", []leg{
+		{common.HexToAddress(bob), big.NewInt(10)},
+		{common.HexToAddress(alice), big.NewInt(32)},
+	}, []byte{0xc0, 0xff, 0xee})
+	require.NoError(t, err)
+	assert.Equal(t, want, tx.Data())
+
+	_, err = transact("withdraw", "5")
+	assert.EqualError(t, err, "execution reverted: InsufficientBalance(1, 5)")
+	_, err = transact("-value", "1", "withdraw", "1")
+	assert.ErrorContains(t, err, "withdraw(uint256) is not payable")
+	_, err = transact("balanceOf", bob)
+	assert.ErrorContains(t, err, "balanceOf(address) is view and changes no state; use call")
+	assert.Len(t, n.sent, 1)
+}
+
+func TestDecode(t *testing.T) {
+	abiFile, parsed := fakeVault(t, newFakeNode())
+	data, err := parsed.Pack("withdraw", big.NewInt(5))
+	require.NoError(t, err)
+
+	out, err := run(t, "decode-input", "-abi", abiFile, hexutil.Encode(data))
+	require.NoError(t, err)
+	assert.Regexp(t, `Method\s+withdraw\(uint256\)\n`, out)
+	assert.Regexp(t, `Selector\s+0x2e1a7d4d\n`, out)
+	assert.Regexp(t, `amount\s+uint256\s+5\n`, out)
+
+	ev := parsed.Events["Deposit"]
+	logData, err := ev.Inputs.NonIndexed().Pack(big.NewInt(42))
+	require.NoError(t, err)
+	out, err = run(t, "--output", "json", "decode-log", "-abi", abiFile, "-data", hexutil.Encode(logData),
+		ev.ID.Hex(), common.HexToAddress(bob).Hash().Hex())
+	require.NoError(t, err)
+	var decoded struct {
+		Event     string
+		Arguments []struct {
+			Name    string
+			Indexed bool
+			Value   string
+		}
+	}
+	require.NoError(t, json.Unmarshal([]byte(out), &decoded))
+	assert.Equal(t, "Deposit(address,uint256)", decoded.Event)
+	require.Len(t, decoded.Arguments, 2)
+	assert.Equal(t, bob, decoded.Arguments[0].Value)
+	assert.True(t, decoded.Arguments[0].Indexed)
+	assert.Equal(t, "42", decoded.Arguments[1].Value)
+
+	_, err = run(t, "decode-input", "-abi", abiFile, "0xdeadbeef")
+	assert.ErrorContains(t, err, "no method with selector 0xdeadbeef")
+	_, err = run(t, "decode-log", "-abi", abiFile, "0x01")
+	assert.ErrorContains(t, err, "topic 0")
+}
@@ -0,0 +1,38 @@
+package cli
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"text/tabwriter"
+	"time"
+)
+
+// runEndpoints checks the --rpc endpoints like a pool would before its
+// first call. It fails if none of them is healthy.
+func runEndpoints(ctx context.Context, e *env, fs *flag.FlagSet, args []string) error {
+	if err := parseFlags(fs, args, 0, 0); err != nil {
+		return err
+	}
+	p, err := dialPool(ctx, e.endpoint)
+	if p == nil {
+		return err
+	}
+	defer p.Close()
+	status := p.Status()
+	if e.json {
+		if err := e.writeJSON(status); err != nil {
+			return err
+		}
+	} else {
+		tw := tabwriter.NewWriter(e.out, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "ENDPOINT\tSTATE\tHEAD\tLATENCY\tERROR")
+		for _, s := range status {
+			fmt.Fprintf(tw, "%s\t%s\t%d\t%s\t%s\n", s.URL, s.State, s.Head, s.Latency.Round(time.Millisecond), s.Error)
+		}
+		if err := tw.Flush(); err != nil {
+			return err
+		}
+	}
+	return err
+}
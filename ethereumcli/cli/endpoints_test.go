@@ -0,0 +1,54 @@
+package cli
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEndpoints(t *testing.T) {
+	a, b := serve(t, newFakeNode()), serve(t, newFakeNode())
+	forked := newFakeNode()
+	forked.head.Time++
+	c := serve(t, forked)
+	down := "http://127.0.0.1:1"
+	pool := strings.Join([]string{down, a.http, b.http, c.http}, ",")
+
+	out, err := run(t, "--rpc", pool, "endpoints")
+	require.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	require.Len(t, lines, 5)
+	assert.Regexp(t, `^ENDPOINT\s+STATE\s+HEAD\s+LATENCY\s+ERROR$`, lines[0])
+	assert.Regexp(t, `^`+down+`\s+down\s+0\s+0s\s+.*connection refused`, lines[1])
+	assert.Regexp(t, `^`+a.http+`\s+healthy\s+42\s+\S+\s*$`, lines[2])
+	assert.Regexp(t, `^`+b.http+`\s+healthy\s+42\s`, lines[3])
+	assert.Regexp(t, `^`+c.http+`\s+diverged\s+42\s+\S+\s+block 42 is 0x`, lines[4])
+
+	out, err = run(t, "--rpc", pool, "--output", "json", "endpoints")
+	require.NoError(t, err)
+	var status []map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(out), &status))
+	require.Len(t, status, 4)
+	assert.Equal(t, "healthy", status[1]["state"])
+	assert.Equal(t, float64(42), status[1]["head"])
+
+	// Commands go to a healthy endpoint.
+	out, err = run(t, "--rpc", down+", "+a.http, "info")
+	require.NoError(t, err)
+	assert.Regexp(t, `Chain ID\s+1337\n`, out)
+
+	out, err = run(t, "--rpc", down+","+a.http, "watch", "heads", "-count", "1", "-poll", "10ms")
+	require.NoError(t, err)
+	assert.Regexp(t, `^42  0x`, out)
+
+	out, err = run(t, "--rpc", down+",http://127.0.0.1:2", "endpoints")
+	assert.ErrorContains(t, err, "no healthy RPC endpoint")
+	assert.Contains(t, out, "http://127.0.0.1:2  down")
+	_, err = run(t, "--rpc", down+",http://127.0.0.1:2", "info")
+	assert.ErrorContains(t, err, "connect to "+down+",http://127.0.0.1:2: no healthy RPC endpoint")
+	_, err = run(t, "--rpc", a.http+","+a.ws, "info")
+	assert.ErrorContains(t, err, "is not an http(s) URL")
+}
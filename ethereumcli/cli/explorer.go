@@ -0,0 +1,227 @@
+package cli
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"golang/ethereumcli/explorer"
+	"golang/ethereumcli/units"
+	"io"
+	"math/big"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+func runBlock(ctx context.Context, e *env, fs *flag.FlagSet, args []string) error {
+	full := fs.Bool("full", false, "show every transaction instead of just their hashes")
+	if err := parseFlags(fs, args, 1, 1); err != nil {
+		return err
+	}
+	ref := fs.Arg(0)
+	var block blockRef
+	if !isHash(ref) {
+		var err error
+		if block, err = parseBlock(ref); err != nil {
+			return err
+		}
+	}
+	if err := e.connect(ctx); err != nil {
+		return err
+	}
+
+	var b *explorer.Block
+	if isHash(ref) {
+		hash, err := parseHash(ref)
+		if err != nil {
+			return err
+		}
+		if b, err = explorer.BlockByHash(ctx, e.client, hash, *full); err != nil {
+			return err
+		}
+	} else {
+		number, err := block.resolve(ctx, e)
+		if err != nil {
+			return err
+		}
+		if block.pending() {
+			number = big.NewInt(-1)
+		}
+		if b, err = explorer.BlockByNumber(ctx, e.client, number, *full); err != nil {
+			return err
+		}
+	}
+	if e.json {
+		return e.writeJSON(b)
+	}
+	return printBlock(e.out, b)
+}
+
+func runTx(ctx context.Context, e *env, fs *flag.FlagSet, args []string) error {
+	if err := parseFlags(fs, args, 1, 1); err != nil {
+		return err
+	}
+	hash, err := parseHash(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	if err := e.connect(ctx); err != nil {
+		return err
+	}
+	tx, err := explorer.TransactionByHash(ctx, e.client, hash)
+	if err != nil {
+		return err
+	}
+	if e.json {
+		return e.writeJSON(tx)
+	}
+	tw := tabwriter.NewWriter(e.out, 0, 0, 2, ' ', 0)
+	printTransaction(tw, tx)
+	return tw.Flush()
+}
+
+func runReceipt(ctx context.Context, e *env, fs *flag.FlagSet, args []string) error {
+	if err := parseFlags(fs, args, 1, 1); err != nil {
+		return err
+	}
+	hash, err := parseHash(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	if err := e.connect(ctx); err != nil {
+		return err
+	}
+	r, err := explorer.ReceiptByHash(ctx, e.client, hash)
+	if err != nil {
+		return err
+	}
+	if e.json {
+		return e.writeJSON(r)
+	}
+	return printReceipt(e.out, r)
+}
+
+func printBlock(w io.Writer, b *explorer.Block) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "Number\t%d\n", b.Number)
+	fmt.Fprintf(tw, "Hash\t%s\n", b.Hash)
+	fmt.Fprintf(tw, "Parent\t%s\n", b.ParentHash)
+	fmt.Fprintf(tw, "Time\t%s\n", b.Time.Format(time.RFC3339))
+	fmt.Fprintf(tw, "Miner\t%s\n", b.Miner)
+	fmt.Fprintf(tw, "Gas used\t%s\n", gasUsage(b.GasUsed, b.GasLimit))
+	if b.BaseFee != "" {
+		fmt.Fprintf(tw, "Base fee\t%s gwei\n", formatWei(b.BaseFee, units.Gwei))
+	}
+	fmt.Fprintf(tw, "Size\t%d bytes\n", b.Size)
+	fmt.Fprintf(tw, "Transactions\t%d\n", len(b.TxHashes)+len(b.Transactions))
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	for _, h := range b.TxHashes {
+		fmt.Fprintf(w, "  %s\n", h)
+	}
+	if len(b.Transactions) == 0 {
+		return nil
+	}
+	fmt.Fprintln(w)
+	tw = tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "INDEX\tHASH\tTYPE\tFROM\tTO\tVALUE")
+	for _, tx := range b.Transactions {
+		to := tx.To
+		if to == "" {
+			to = "(create)"
+		}
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%s\t%s ether\n", *tx.Index, tx.Hash, tx.TypeName, tx.From, to, formatWei(tx.Value, units.Ether))
+	}
+	return tw.Flush()
+}
+
+func printTransaction(tw *tabwriter.Writer, tx *explorer.Transaction) {
+	fmt.Fprintf(tw, "Hash\t%s\n", tx.Hash)
+	fmt.Fprintf(tw, "Type\t%s (%d)\n", tx.TypeName, tx.Type)
+	switch {
+	case tx.Pending:
+		fmt.Fprintf(tw, "Status\tpending\n")
+	case tx.BlockNumber != nil:
+		fmt.Fprintf(tw, "Block\t%d (%s), index %d\n", *tx.BlockNumber, tx.BlockHash, *tx.Index)
+	}
+	if tx.ChainID != "" {
+		fmt.Fprintf(tw, "Chain ID\t%s\n", tx.ChainID)
+	}
+	fmt.Fprintf(tw, "From\t%s\n", tx.From)
+	if tx.To != "" {
+		fmt.Fprintf(tw, "To\t%s\n", tx.To)
+	} else {
+		fmt.Fprintf(tw, "To\t(contract creation)\n")
+	}
+	fmt.Fprintf(tw, "Nonce\t%d\n", tx.Nonce)
+	fmt.Fprintf(tw, "Value\t%s ether\n", formatWei(tx.Value, units.Ether))
+	fmt.Fprintf(tw, "Gas limit\t%d\n", tx.Gas)
+	if tx.GasPrice != "" {
+		fmt.Fprintf(tw, "Gas price\t%s gwei\n", formatWei(tx.GasPrice, units.Gwei))
+	} else {
+		fmt.Fprintf(tw, "Max fee\t%s gwei\n", formatWei(tx.MaxFeePerGas, units.Gwei))
+		fmt.Fprintf(tw, "Max priority fee\t%s gwei\n", formatWei(tx.MaxPriorityFeePerGas, units.Gwei))
+	}
+	for _, t := range tx.AccessList {
+		fmt.Fprintf(tw, "Access list\t%s (%d storage keys)\n", t.Address, len(t.StorageKeys))
+	}
+	fmt.Fprintf(tw, "Input\t%s\n", describeInput(tx.Input))
+}
+
+func printReceipt(w io.Writer, r *explorer.Receipt) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "Transaction\t%s\n", r.TxHash)
+	fmt.Fprintf(tw, "Status\t%s\n", r.Status)
+	fmt.Fprintf(tw, "Block\t%d (%s), index %d\n", r.BlockNumber, r.BlockHash, r.Index)
+	fmt.Fprintf(tw, "From\t%s\n", r.From)
+	if r.ContractAddress != "" {
+		fmt.Fprintf(tw, "Created\t%s\n", r.ContractAddress)
+	} else {
+		fmt.Fprintf(tw, "To\t%s\n", r.To)
+	}
+	fmt.Fprintf(tw, "Gas used\t%s\n", gasUsage(r.GasUsed, r.GasLimit))
+	fmt.Fprintf(tw, "Cumulative gas\t%d\n", r.CumulativeGasUsed)
+	fmt.Fprintf(tw, "Gas price\t%s gwei\n", formatWei(r.EffectiveGasPrice, units.Gwei))
+	fmt.Fprintf(tw, "Fee\t%s ether\n", formatWei(r.Fee, units.Ether))
+	fmt.Fprintf(tw, "Logs\t%d\n", len(r.Logs))
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	for _, l := range r.Logs {
+		fmt.Fprintf(w, "  [%d] %s\n", l.Index, l.Address)
+		for i, t := range l.Topics {
+			fmt.Fprintf(w, "      topic%d  %s\n", i, t)
+		}
+		fmt.Fprintf(w, "      data    %s\n", l.Data)
+	}
+	return nil
+}
+
+func gasUsage(used, limit uint64) string {
+	if limit == 0 {
+		return fmt.Sprint(used)
+	}
+	return fmt.Sprintf("%d of %d (%.2f%%)", used, limit, float64(used)/float64(limit)*100)
+}
+
+// formatWei formats a decimal string of wei in the given unit.
+func formatWei(wei string, decimals int) string {
+	v, ok := new(big.Int).SetString(wei, 10)
+	if !ok {
+		return wei
+	}
+	return units.Format(v, decimals)
+}
+
+// describeInput summarises call data: its size and function selector.
+func describeInput(input string) string {
+	data := strings.TrimPrefix(input, "0x")
+	if data == "" {
+		return "none"
+	}
+	if len(data) < 8 {
+		return fmt.Sprintf("%d bytes", len(data)/2)
+	}
+	return fmt.Sprintf("%d bytes, selector 0x%s", len(data)/2, data[:8])
+}
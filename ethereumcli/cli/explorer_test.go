@@ -0,0 +1,64 @@
+package cli
+
+import (
+	"bytes"
+	"golang/ethereumcli/explorer"
+	"strings"
+	"testing"
+	"text/tabwriter"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExplorerArguments(t *testing.T) {
+	// Arguments are checked before dialing, so the endpoint is never used.
+	for _, args := range [][]string{
+		{"tx", "0x1234"},
+		{"receipt", "deadbeef"},
+		{"block", "soon"},
+	} {
+		_, err := run(t, append([]string{"--rpc", "http://127.0.0.1:1"}, args...)...)
+		require.Error(t, err, args)
+		assert.NotContains(t, err.Error(), "connection refused", args)
+	}
+	_, err := run(t, "tx")
+	assert.ErrorIs(t, err, ErrUsage)
+}
+
+func TestPrintTransaction(t *testing.T) {
+	block, index := uint64(7), uint(2)
+	tx := &explorer.Transaction{
+		Hash: "0xabc", Type: 2, TypeName: "eip1559", BlockNumber: &block, BlockHash: "0xdef", Index: &index,
+		From: alice, Value: "1500000000000000000", Gas: 21000,
+		MaxFeePerGas: "30000000000", MaxPriorityFeePerGas: "1500000000",
+		Input: "0xa9059cbb0000",
+	}
+	var buf bytes.Buffer
+	tw := tabwriter.NewWriter(&buf, 0, 0, 2, ' ', 0)
+	printTransaction(tw, tx)
+	require.NoError(t, tw.Flush())
+	out := buf.String()
+	assert.Contains(t, out, "eip1559 (2)")
+	assert.Contains(t, out, "7 (0xdef), index 2")
+	assert.Regexp(t, `To\s+\(contract creation\)`, out)
+	assert.Regexp(t, `Value\s+1.5 ether`, out)
+	assert.Regexp(t, `Max priority fee\s+1.5 gwei`, out)
+	assert.Contains(t, out, "6 bytes, selector 0xa9059cbb")
+	assert.NotContains(t, out, "Gas price")
+}
+
+func TestPrintReceipt(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, printReceipt(&buf, &explorer.Receipt{
+		TxHash: "0xabc", Status: "failed", From: alice, To: bob,
+		GasLimit: 40000, GasUsed: 30000, EffectiveGasPrice: "2000000000", Fee: "60000000000000",
+		Logs: []explorer.Log{{Index: 3, Address: bob, Topics: []string{"0x01"}, Data: "0x"}},
+	}))
+	out := buf.String()
+	assert.Regexp(t, `Status\s+failed`, out)
+	assert.Contains(t, out, "30000 of 40000 (75.00%)")
+	assert.Regexp(t, `Fee\s+0.00006 ether`, out)
+	assert.Contains(t, out, "  [3] "+bob)
+	assert.Equal(t, 1, strings.Count(out, "topic0"))
+}
@@ -0,0 +1,127 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"golang/ethereumcli/codec"
+	"golang/ethereumcli/indexer"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+func runIndex(ctx context.Context, e *env, fs *flag.FlagSet, args []string) error {
+	dbFile := fs.String("db", "", "SQLite database to store logs in; indexing resumes from its checkpoint")
+	abiFile := fs.String("abi", "", "JSON ABI or build artifact to decode events with")
+	var addressFlags, eventFlags stringList
+	fs.Var(&addressFlags, "address", "contract to index; repeatable")
+	fs.Var(&eventFlags, "event", "event to index: a name from -abi, a signature or a 0x topic; repeatable")
+	from := fs.Uint64("from", 0, "first block to index into a new database")
+	confirmations := fs.Uint64("confirmations", 0, "stay this many blocks behind the head")
+	chunk := fs.Uint64("chunk", indexer.DefaultChunk, "largest block range to ask eth_getLogs for")
+	poll := fs.Duration("poll", indexer.DefaultPollInterval, "how often to look for new blocks when the node cannot push new heads")
+	once := fs.Bool("once", false, "index up to the head, then exit")
+	if err := parseFlags(fs, args, 0, 0); err != nil {
+		return err
+	}
+	if *dbFile == "" || len(addressFlags)+len(eventFlags) == 0 {
+		fs.Usage()
+		return ErrUsage
+	}
+	if *chunk == 0 || *poll <= 0 {
+		return fmt.Errorf("-chunk and -poll must be positive")
+	}
+	addresses, err := parseAddresses(addressFlags)
+	if err != nil {
+		return err
+	}
+	var contractABI *abi.ABI
+	if *abiFile != "" {
+		a, err := codec.LoadABI(*abiFile)
+		if err != nil {
+			return err
+		}
+		contractABI = &a
+	}
+	var topics [][]common.Hash
+	if len(eventFlags) > 0 {
+		var topic0 []common.Hash
+		for _, ev := range eventFlags {
+			t, err := eventTopic(contractABI, ev)
+			if err != nil {
+				return err
+			}
+			topic0 = append(topic0, t)
+		}
+		topics = [][]common.Hash{topic0}
+	}
+
+	rpcCtx, cancel := e.rpcContext(ctx)
+	defer cancel()
+	if err := e.connect(rpcCtx); err != nil {
+		return err
+	}
+	store, err := indexer.OpenStore(*dbFile)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+	ix, err := indexer.New(ctx, e.client, store, indexer.Config{
+		Addresses:     addresses,
+		Topics:        topics,
+		ABI:           contractABI,
+		From:          *from,
+		Confirmations: *confirmations,
+		Chunk:         *chunk,
+		PollInterval:  *poll,
+		Timeout:       e.timeout,
+		Report:        func(p indexer.Progress) { e.printProgress(p, *poll) },
+	})
+	if err != nil {
+		return err
+	}
+	if *once {
+		return ix.Sync(ctx)
+	}
+	return ix.Run(ctx)
+}
+
+// eventTopic resolves an -event flag: a 0x topic, an event signature
+// such as Transfer(address,address,uint256) or an event name in a.
+func eventTopic(a *abi.ABI, s string) (common.Hash, error) {
+	switch {
+	case strings.HasPrefix(s, "0x"):
+		return parseHash(s)
+	case strings.Contains(s, "("):
+		return crypto.Keccak256Hash([]byte(strings.ReplaceAll(s, " ", ""))), nil
+	case a == nil:
+		return common.Hash{}, fmt.Errorf("-event %s: event names need -abi; give a signature or topic instead", s)
+	}
+	ev, ok := a.Events[s]
+	if !ok {
+		return common.Hash{}, fmt.Errorf("-event %s: the ABI has no such event", s)
+	}
+	return ev.ID, nil
+}
+
+// printProgress prints what the indexer did: a line of text, or a JSON
+// object per line with --output json.
+func (e *env) printProgress(p indexer.Progress, poll time.Duration) {
+	if e.json {
+		json.NewEncoder(e.out).Encode(p)
+		return
+	}
+	switch p.Kind {
+	case indexer.KindLogs:
+		fmt.Fprintf(e.out, "indexed blocks %d-%d: %d logs (head %d)\n", p.From, p.To, p.Logs, p.Head)
+	case indexer.KindReorg:
+		fmt.Fprintf(e.out, "reorg: rolled back blocks %d-%d (head %d)\n", p.From, p.To, p.Head)
+	case indexer.KindPoll:
+		fmt.Fprintf(e.out, "the node cannot push new heads; polling every %s\n", poll)
+	}
+}
@@ -0,0 +1,89 @@
+package cli
+
+import (
+	"context"
+	"golang/ethereumcli/erc20"
+	"golang/ethereumcli/indexer"
+	"math/big"
+	"path/filepath"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const erc20ABI = "../erc20/ERC20.abi"
+
+func TestIndex(t *testing.T) {
+	parsed, err := erc20.ERC20MetaData.GetAbi()
+	require.NoError(t, err)
+	transfer := func(block uint64, amount int64) types.Log {
+		data, err := parsed.Events["Transfer"].Inputs.NonIndexed().Pack(big.NewInt(amount))
+		require.NoError(t, err)
+		return types.Log{
+			Address:     tokenAddr,
+			Topics:      []common.Hash{parsed.Events["Transfer"].ID, common.HexToAddress(alice).Hash(), common.HexToAddress(bob).Hash()},
+			Data:        data,
+			BlockNumber: block,
+			TxHash:      common.Hash{byte(block)},
+		}
+	}
+	n := newFakeNode()
+	n.logs = []types.Log{transfer(10, 5), transfer(40, 7)}
+	ep := serve(t, n)
+	db := filepath.Join(t.TempDir(), "index.db")
+	index := func(args ...string) (string, error) {
+		return run(t, append([]string{"--rpc", ep.http, "index", "-db", db, "-address", tokenAddr.Hex(),
+			"-abi", erc20ABI, "-event", "Transfer", "-once"}, args...)...)
+	}
+
+	out, err := index("-chunk", "20")
+	require.NoError(t, err)
+	assert.Equal(t, "indexed blocks 0-19: 1 logs (head 42)\n"+
+		"indexed blocks 20-39: 0 logs (head 42)\n"+
+		"indexed blocks 40-42: 1 logs (head 42)\n", out)
+
+	// A later run resumes from the checkpoint.
+	// The fake derives every block from the head, so only the number may
+	// change or blocks 0-42 get new hashes.
+	n.head = &types.Header{Number: big.NewInt(45), Time: n.head.Time, Difficulty: big.NewInt(0)}
+	n.logs = append(n.logs, transfer(44, 9))
+	out, err = run(t, "--rpc", ep.http, "--output", "json", "index", "-db", db, "-address", tokenAddr.Hex(),
+		"-abi", erc20ABI, "-event", "Transfer(address, address, uint256)", "-once")
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"kind":"logs","from":43,"to":45,"logs":1,"head":45}`, out)
+
+	store, err := indexer.OpenStore(db)
+	require.NoError(t, err)
+	defer store.Close()
+	logs, err := store.Logs(context.Background(), 0, 100)
+	require.NoError(t, err)
+	require.Len(t, logs, 3)
+	assert.Equal(t, "Transfer(address,address,uint256)", logs[2].Event)
+	assert.Equal(t, bob, logs[2].Args[1].Value)
+	assert.Equal(t, "9", logs[2].Args[2].Value)
+
+	_, err = index("-from", "5")
+	assert.ErrorIs(t, err, indexer.ErrFilterChanged)
+}
+
+func TestIndexErrors(t *testing.T) {
+	db := filepath.Join(t.TempDir(), "index.db")
+	for _, tc := range []struct {
+		args []string
+		want string
+	}{
+		{[]string{"-address", tokenAddr.Hex()}, "usage error"},
+		{[]string{"-db", db}, "usage error"},
+		{[]string{"-db", db, "-event", "Transfer"}, "event names need -abi"},
+		{[]string{"-db", db, "-abi", erc20ABI, "-event", "Mint"}, "the ABI has no such event"},
+		{[]string{"-db", db, "-event", "0x1234"}, "want a 0x-prefixed 32-byte hash"},
+		{[]string{"-db", db, "-address", "0x1234"}, "address must be 40 hex digits"},
+		{[]string{"-db", db, "-address", tokenAddr.Hex(), "-chunk", "0"}, "must be positive"},
+	} {
+		_, err := run(t, append([]string{"--rpc", "http://127.0.0.1:1", "index"}, tc.args...)...)
+		assert.ErrorContains(t, err, tc.want, "%v", tc.args)
+	}
+}
@@ -0,0 +1,85 @@
+package cli
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"golang/ethereumcli/units"
+	"math/big"
+	"text/tabwriter"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// chainInfo is what `info` reports about the endpoint.
+type chainInfo struct {
+	ClientVersion string
+	ChainID       *big.Int
+	NetworkID     *big.Int
+	Head          *types.Header
+	GasPrice      *big.Int
+	// Sync is nil when the node is not syncing.
+	Sync *ethereum.SyncProgress
+}
+
+func getChainInfo(ctx context.Context, e *env) (*chainInfo, error) {
+	var info chainInfo
+	var err error
+	if err = e.rpc.CallContext(ctx, &info.ClientVersion, "web3_clientVersion"); err != nil {
+		return nil, fmt.Errorf("client version: %w", err)
+	}
+	if info.ChainID, err = e.client.ChainID(ctx); err != nil {
+		return nil, fmt.Errorf("chain ID: %w", err)
+	}
+	if info.NetworkID, err = e.client.NetworkID(ctx); err != nil {
+		return nil, fmt.Errorf("network ID: %w", err)
+	}
+	if info.Head, err = e.client.HeaderByNumber(ctx, nil); err != nil {
+		return nil, fmt.Errorf("latest block: %w", err)
+	}
+	if info.GasPrice, err = e.client.SuggestGasPrice(ctx); err != nil {
+		return nil, fmt.Errorf("gas price: %w", err)
+	}
+	if info.Sync, err = e.client.SyncProgress(ctx); err != nil {
+		return nil, fmt.Errorf("sync status: %w", err)
+	}
+	return &info, nil
+}
+
+func runInfo(ctx context.Context, e *env, fs *flag.FlagSet, args []string) error {
+	if err := parseFlags(fs, args, 0, 0); err != nil {
+		return err
+	}
+	if err := e.connect(ctx); err != nil {
+		return err
+	}
+	info, err := getChainInfo(ctx, e)
+	if err != nil {
+		return err
+	}
+	tw := tabwriter.NewWriter(e.out, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "Endpoint\t%s\n", e.endpoint)
+	fmt.Fprintf(tw, "Client\t%s\n", info.ClientVersion)
+	fmt.Fprintf(tw, "Chain ID\t%s\n", info.ChainID)
+	fmt.Fprintf(tw, "Network ID\t%s\n", info.NetworkID)
+	blockTime := time.Unix(int64(info.Head.Time), 0)
+	fmt.Fprintf(tw, "Latest block\t%s (%s, %s ago)\n", info.Head.Number, blockTime.UTC().Format(time.RFC3339),
+		time.Since(blockTime).Round(time.Second))
+	fmt.Fprintf(tw, "Gas price\t%s gwei\n", units.Format(info.GasPrice, units.Gwei))
+	fmt.Fprintf(tw, "Syncing\t%s\n", syncStatus(info.Sync))
+	return tw.Flush()
+}
+
+func syncStatus(p *ethereum.SyncProgress) string {
+	if p == nil {
+		return "no"
+	}
+	s := fmt.Sprintf("yes, block %d of %d", p.CurrentBlock, p.HighestBlock)
+	if p.HighestBlock > p.StartingBlock {
+		pct := float64(p.CurrentBlock-p.StartingBlock) / float64(p.HighestBlock-p.StartingBlock) * 100
+		s += fmt.Sprintf(" (%.1f%%)", pct)
+	}
+	return s
+}
@@ -0,0 +1,269 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"math/big"
+	"net"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeNode is a JSON-RPC stand-in for an Ethereum node. Its methods are
+// served under the eth, net and web3 namespaces.
+type fakeNode struct {
+	chainID  int64
+	head     *types.Header
+	gasPrice int64
+	// syncing is returned by eth_syncing; nil means not syncing.
+	syncing map[string]hexutil.Uint64
+	// balances and nonces are per address, the same in every block;
+	// pendingDelta is added for the pending block.
+	balances     map[common.Address]*big.Int
+	nonces       map[common.Address]uint64
+	pendingDelta int64
+	// stateBlocks records the block argument of every state query.
+	stateBlocks []string
+	// baseFee and tips make up eth_feeHistory: every block has the same
+	// base fee and pays tips at each requested percentile.
+	baseFee int64
+	tips    int64
+	// sent holds the transactions of eth_sendRawTransaction.
+	sent []*types.Transaction
+	// code is returned by eth_getCode; calls maps the hex call data of
+	// eth_call to its result, whatever the contract. Call data in reverts
+	// makes eth_call and eth_estimateGas revert with the given data.
+	code    map[common.Address]hexutil.Bytes
+	calls   map[string]hexutil.Bytes
+	reverts map[string]hexutil.Bytes
+	// logs are returned by eth_getLogs, filtered by block range only.
+	logs []types.Log
+}
+
+func newFakeNode() *fakeNode {
+	return &fakeNode{
+		chainID:  1337,
+		head:     &types.Header{Number: big.NewInt(42), Time: 1_700_000_000, Difficulty: big.NewInt(0)},
+		gasPrice: 1_500_000_000,
+		baseFee:  1_000_000_000,
+		tips:     2_000_000_000,
+		balances: map[common.Address]*big.Int{},
+		nonces:   map[common.Address]uint64{},
+		code:     map[common.Address]hexutil.Bytes{},
+		calls:    map[string]hexutil.Bytes{},
+		reverts:  map[string]hexutil.Bytes{},
+	}
+}
+
+// The safe and finalized blocks trail the head like they do on mainnet.
+const (
+	safeDepth      = 2
+	finalizedDepth = 10
+)
+
+type ethAPI struct{ n *fakeNode }
+
+func (a ethAPI) ChainId() *hexutil.Big { return (*hexutil.Big)(big.NewInt(a.n.chainID)) }
+
+func (a ethAPI) BlockNumber() hexutil.Uint64 { return hexutil.Uint64(a.n.head.Number.Uint64()) }
+
+func (a ethAPI) GasPrice() *hexutil.Big { return (*hexutil.Big)(big.NewInt(a.n.gasPrice)) }
+
+func (a ethAPI) Syncing() interface{} {
+	if a.n.syncing == nil {
+		return false
+	}
+	return a.n.syncing
+}
+
+func (a ethAPI) GetBlockByNumber(number rpc.BlockNumber, full bool) *types.Header {
+	head := a.n.head.Number.Int64()
+	switch number {
+	case rpc.LatestBlockNumber, rpc.PendingBlockNumber:
+		return a.n.head
+	case rpc.SafeBlockNumber:
+		number = rpc.BlockNumber(head - safeDepth)
+	case rpc.FinalizedBlockNumber:
+		number = rpc.BlockNumber(head - finalizedDepth)
+	}
+	if number.Int64() > head {
+		return nil
+	}
+	h := types.CopyHeader(a.n.head)
+	h.Number = big.NewInt(number.Int64())
+	return h
+}
+
+func (a ethAPI) GetBalance(addr common.Address, block rpc.BlockNumber) *hexutil.Big {
+	a.n.stateBlocks = append(a.n.stateBlocks, blockText(block))
+	b := new(big.Int)
+	if v := a.n.balances[addr]; v != nil {
+		b.Set(v)
+	}
+	if block == rpc.PendingBlockNumber {
+		b.Add(b, big.NewInt(a.n.pendingDelta))
+	}
+	return (*hexutil.Big)(b)
+}
+
+func (a ethAPI) GetTransactionCount(addr common.Address, block rpc.BlockNumber) hexutil.Uint64 {
+	a.n.stateBlocks = append(a.n.stateBlocks, blockText(block))
+	n := a.n.nonces[addr]
+	if block == rpc.PendingBlockNumber {
+		n += uint64(a.n.pendingDelta)
+	}
+	return hexutil.Uint64(n)
+}
+
+type feeHistory struct {
+	OldestBlock  *hexutil.Big     `json:"oldestBlock"`
+	Reward       [][]*hexutil.Big `json:"reward"`
+	BaseFee      []*hexutil.Big   `json:"baseFeePerGas"`
+	GasUsedRatio []float64        `json:"gasUsedRatio"`
+}
+
+func (a ethAPI) FeeHistory(count hexutil.Uint, last rpc.BlockNumber, percentiles []float64) feeHistory {
+	h := feeHistory{OldestBlock: (*hexutil.Big)(new(big.Int).Sub(a.n.head.Number, big.NewInt(int64(count)-1)))}
+	for i := 0; i <= int(count); i++ {
+		h.BaseFee = append(h.BaseFee, (*hexutil.Big)(big.NewInt(a.n.baseFee)))
+		if i == int(count) {
+			break
+		}
+		h.GasUsedRatio = append(h.GasUsedRatio, 0.5)
+		var rewards []*hexutil.Big
+		for range percentiles {
+			rewards = append(rewards, (*hexutil.Big)(big.NewInt(a.n.tips)))
+		}
+		h.Reward = append(h.Reward, rewards)
+	}
+	return h
+}
+
+func (a ethAPI) EstimateGas(call map[string]interface{}) (hexutil.Uint64, error) {
+	data, _ := call["data"].(string)
+	if input, ok := call["input"].(string); ok {
+		data = input
+	}
+	if revert, ok := a.n.reverts[data]; ok {
+		return 0, fakeRevert(revert)
+	}
+	if data != "" {
+		return 50_000, nil
+	}
+	return 21_000, nil
+}
+
+func (a ethAPI) GetCode(addr common.Address, block rpc.BlockNumber) hexutil.Bytes {
+	return a.n.code[addr]
+}
+
+func (a ethAPI) Call(call map[string]interface{}, block rpc.BlockNumber) (hexutil.Bytes, error) {
+	data, _ := call["data"].(string)
+	if input, ok := call["input"].(string); ok {
+		data = input
+	}
+	if out, ok := a.n.calls[data]; ok {
+		return out, nil
+	}
+	if revert, ok := a.n.reverts[data]; ok {
+		return nil, fakeRevert(revert)
+	}
+	return nil, errors.New("execution reverted")
+}
+
+// fakeRevert is how geth reports a revert: code 3 with the revert data.
+type fakeRevert hexutil.Bytes
+
+func (fakeRevert) Error() string { return "execution reverted" }
+
+func (fakeRevert) ErrorCode() int { return 3 }
+
+func (e fakeRevert) ErrorData() interface{} { return hexutil.Bytes(e).String() }
+
+func (a ethAPI) GetLogs(crit struct {
+	FromBlock rpc.BlockNumber `json:"fromBlock"`
+	ToBlock   rpc.BlockNumber `json:"toBlock"`
+}) []types.Log {
+	logs := []types.Log{}
+	for _, l := range a.n.logs {
+		if l.BlockNumber >= uint64(crit.FromBlock) && l.BlockNumber <= uint64(crit.ToBlock) {
+			logs = append(logs, l)
+		}
+	}
+	return logs
+}
+
+func (a ethAPI) SendRawTransaction(raw hexutil.Bytes) (common.Hash, error) {
+	tx := new(types.Transaction)
+	if err := tx.UnmarshalBinary(raw); err != nil {
+		return common.Hash{}, err
+	}
+	a.n.sent = append(a.n.sent, tx)
+	return tx.Hash(), nil
+}
+
+type netAPI struct{ n *fakeNode }
+
+func (a netAPI) Version() string { return big.NewInt(a.n.chainID).String() }
+
+type web3API struct{}
+
+func (web3API) ClientVersion() string { return "Fake/v1.0.0" }
+
+// endpoints serves n over HTTP, WebSocket and IPC.
+type endpoints struct {
+	http, ws, ipc string
+}
+
+func serve(t *testing.T, n *fakeNode) endpoints {
+	t.Helper()
+	srv := rpc.NewServer()
+	require.NoError(t, srv.RegisterName("eth", ethAPI{n}))
+	require.NoError(t, srv.RegisterName("net", netAPI{n}))
+	require.NoError(t, srv.RegisterName("web3", web3API{}))
+	t.Cleanup(srv.Stop)
+
+	httpSrv := httptest.NewServer(srv)
+	t.Cleanup(httpSrv.Close)
+	wsSrv := httptest.NewServer(srv.WebsocketHandler([]string{"*"}))
+	t.Cleanup(wsSrv.Close)
+
+	ipc := filepath.Join(t.TempDir(), "node.ipc")
+	l, err := net.Listen("unix", ipc)
+	require.NoError(t, err)
+	go srv.ServeListener(l)
+	t.Cleanup(func() { l.Close() })
+
+	return endpoints{
+		http: httpSrv.URL,
+		ws:   "ws" + strings.TrimPrefix(wsSrv.URL, "http"),
+		ipc:  ipc,
+	}
+}
+
+func run(t *testing.T, args ...string) (string, error) {
+	t.Helper()
+	return runWithInput(t, "", args...)
+}
+
+// runWithInput runs the CLI with stdin holding input.
+func runWithInput(t *testing.T, input string, args ...string) (string, error) {
+	t.Helper()
+	var out, errOut bytes.Buffer
+	err := Run(context.Background(), args, strings.NewReader(input), &out, &errOut)
+	return out.String() + errOut.String(), err
+}
+
+func blockText(bn rpc.BlockNumber) string {
+	b, _ := bn.MarshalText()
+	return string(b)
+}
@@ -0,0 +1,68 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"golang.org/x/term"
+)
+
+// password returns the first line of file or, without one, prompts for a
+// password. New passwords are asked for twice on a terminal.
+func (e *env) password(file, prompt string, confirm bool) (string, error) {
+	if file != "" {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return "", fmt.Errorf("read password: %w", err)
+		}
+		line, _, _ := strings.Cut(string(data), "\n")
+		return strings.TrimSuffix(line, "\r"), nil
+	}
+	pw, err := e.readSecret(prompt)
+	if err != nil {
+		return "", err
+	}
+	if confirm && pw == "" {
+		return "", errors.New("refusing to encrypt a key with an empty password")
+	}
+	if confirm && e.terminal() != nil {
+		again, err := e.readSecret("Repeat password: ")
+		if err != nil {
+			return "", err
+		}
+		if again != pw {
+			return "", errors.New("passwords do not match")
+		}
+	}
+	return pw, nil
+}
+
+// readSecret reads a line from stdin. On a terminal it prompts and turns
+// echo off.
+func (e *env) readSecret(prompt string) (string, error) {
+	if f := e.terminal(); f != nil {
+		fmt.Fprint(e.errOut, prompt)
+		b, err := term.ReadPassword(int(f.Fd()))
+		fmt.Fprintln(e.errOut)
+		return string(b), err
+	}
+	line, err := e.in.ReadString('\n')
+	switch {
+	case errors.Is(err, io.EOF) && line == "":
+		return "", fmt.Errorf("no input for %q; use a terminal or -password-file", strings.TrimSuffix(prompt, ": "))
+	case err != nil && !errors.Is(err, io.EOF):
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// terminal returns stdin if it is a terminal.
+func (e *env) terminal() *os.File {
+	if f, ok := e.stdin.(*os.File); ok && term.IsTerminal(int(f.Fd())) {
+		return f
+	}
+	return nil
+}
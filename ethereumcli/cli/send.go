@@ -0,0 +1,271 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"golang/ethereumcli/codec"
+	"golang/ethereumcli/explorer"
+	"golang/ethereumcli/sender"
+	"golang/ethereumcli/units"
+	"math/big"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// pollInterval is how often send checks for confirmations; tests lower it.
+var pollInterval = 2 * time.Second
+
+// sendResult is the JSON output of send.
+type sendResult struct {
+	Hash                 string            `json:"hash"`
+	From                 string            `json:"from"`
+	To                   string            `json:"to"`
+	Value                string            `json:"value"`
+	Nonce                uint64            `json:"nonce"`
+	Gas                  uint64            `json:"gas"`
+	MaxFeePerGas         string            `json:"maxFeePerGas"`
+	MaxPriorityFeePerGas string            `json:"maxPriorityFeePerGas"`
+	MaxCost              string            `json:"maxCost"`
+	Sent                 bool              `json:"sent"`
+	Raw                  string            `json:"raw,omitempty"`
+	Receipt              *explorer.Receipt `json:"receipt,omitempty"`
+}
+
+// txFlags are the flags of commands that send a transaction. parse fills
+// in req from them; the command adds the recipient, value and data.
+type txFlags struct {
+	from          *string
+	speed         *string
+	maxFee        *string
+	tip           *string
+	gas           *uint64
+	nonce         *string
+	confirmations *uint64
+	wait          *time.Duration
+	dryRun        *bool
+	passwordFile  *string
+
+	req      sender.Request
+	password string
+}
+
+func addTxFlags(fs *flag.FlagSet) *txFlags {
+	return &txFlags{
+		from:          fs.String("from", "", "keystore account to send from"),
+		speed:         fs.String("speed", "normal", "fee preset: slow, normal or fast"),
+		maxFee:        fs.String("max-fee", "", "max fee per gas in gwei, instead of the preset's"),
+		tip:           fs.String("priority-fee", "", "max priority fee per gas in gwei, instead of the preset's"),
+		gas:           fs.Uint64("gas", 0, "gas limit (default: estimated)"),
+		nonce:         fs.String("nonce", "", "nonce (default: the account's pending nonce)"),
+		confirmations: fs.Uint64("confirmations", 0, "wait until the transaction has this many confirmations"),
+		wait:          fs.Duration("wait", 5*time.Minute, "give up waiting for confirmations after this long"),
+		dryRun:        fs.Bool("dry-run", false, "print the signed raw transaction instead of broadcasting it"),
+		passwordFile:  fs.String("password-file", "", "read the password from the first line of this file instead of prompting"),
+	}
+}
+
+func (f *txFlags) parse(fs *flag.FlagSet) error {
+	if *f.from == "" {
+		fs.Usage()
+		return ErrUsage
+	}
+	var err error
+	if f.req.From, err = codec.ParseAddress(*f.from); err != nil {
+		return err
+	}
+	f.req.Gas = *f.gas
+	if f.req.Speed, err = sender.ParseSpeed(*f.speed); err != nil {
+		return err
+	}
+	if f.req.MaxFee, err = parseGwei("max-fee", *f.maxFee); err != nil {
+		return err
+	}
+	if f.req.Tip, err = parseGwei("priority-fee", *f.tip); err != nil {
+		return err
+	}
+	if *f.nonce != "" {
+		n, err := strconv.ParseUint(*f.nonce, 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid -nonce %q", *f.nonce)
+		}
+		f.req.Nonce = &n
+	}
+	return nil
+}
+
+// unlock checks that the keystore holds the sender and asks for its
+// password. It comes before any RPC call so a slow typist doesn't run
+// into --timeout.
+func (e *env) unlock(f *txFlags) error {
+	if _, err := e.wallet().Find(f.req.From); err != nil {
+		return err
+	}
+	var err error
+	f.password, err = e.password(*f.passwordFile, "Password for "+f.req.From.Hex()+": ", false)
+	return err
+}
+
+// rpcContext bounds the RPC calls of an untimed command by --timeout.
+func (e *env) rpcContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(ctx, e.timeout)
+}
+
+func runSend(ctx context.Context, e *env, fs *flag.FlagSet, args []string) error {
+	f := addTxFlags(fs)
+	toFlag := fs.String("to", "", "recipient address")
+	valueFlag := fs.String("value", "0", "amount to send, in -unit")
+	unitFlag := fs.String("unit", "ether", "unit of -value: wei, gwei or ether")
+	dataFlag := fs.String("data", "", "hex call data")
+	if err := parseFlags(fs, args, 0, 0); err != nil {
+		return err
+	}
+	if *toFlag == "" {
+		fs.Usage()
+		return ErrUsage
+	}
+	if err := f.parse(fs); err != nil {
+		return err
+	}
+	to, err := codec.ParseAddress(*toFlag)
+	if err != nil {
+		return err
+	}
+	f.req.To = &to
+	decimals, ok := units.Lookup(*unitFlag)
+	if !ok {
+		return fmt.Errorf("unknown unit %q", *unitFlag)
+	}
+	if f.req.Value, err = units.Parse(*valueFlag, decimals); err != nil {
+		return err
+	}
+	if *dataFlag != "" {
+		if f.req.Data, err = hexutil.Decode(*dataFlag); err != nil {
+			return fmt.Errorf("invalid -data: %v", err)
+		}
+	}
+
+	if err := e.unlock(f); err != nil {
+		return err
+	}
+	rpcCtx, cancel := e.rpcContext(ctx)
+	defer cancel()
+	if err := e.connect(rpcCtx); err != nil {
+		return err
+	}
+	return e.transact(ctx, f)
+}
+
+// transact builds and signs f.req, then prints it for a dry run or
+// broadcasts it and waits for the confirmations asked for. The env must
+// be connected and unlocked.
+func (e *env) transact(ctx context.Context, f *txFlags) error {
+	rpcCtx, cancel := e.rpcContext(ctx)
+	defer cancel()
+	unsigned, err := sender.Build(rpcCtx, e.client, f.req)
+	if err != nil {
+		return err
+	}
+	tx, err := e.wallet().SignTx(f.req.From, f.password, types.NewTx(unsigned), unsigned.ChainID)
+	if err != nil {
+		return err
+	}
+	result := sendResult{
+		Hash:                 tx.Hash().Hex(),
+		From:                 f.req.From.Hex(),
+		To:                   f.req.To.Hex(),
+		Value:                tx.Value().String(),
+		Nonce:                tx.Nonce(),
+		Gas:                  tx.Gas(),
+		MaxFeePerGas:         tx.GasFeeCap().String(),
+		MaxPriorityFeePerGas: tx.GasTipCap().String(),
+		MaxCost:              sender.MaxCost(unsigned).String(),
+	}
+	if *f.dryRun {
+		raw, err := tx.MarshalBinary()
+		if err != nil {
+			return err
+		}
+		result.Raw = hexutil.Encode(raw)
+		return e.printSend(result)
+	}
+
+	if err := e.client.SendTransaction(rpcCtx, tx); err != nil {
+		return fmt.Errorf("broadcast: %w", err)
+	}
+	result.Sent = true
+	if *f.confirmations == 0 {
+		return e.printSend(result)
+	}
+	if !e.json {
+		fmt.Fprintf(e.errOut, "Sent %s; waiting for %d confirmations\n", result.Hash, *f.confirmations)
+	}
+	waitCtx, cancelWait := context.WithTimeout(ctx, *f.wait)
+	defer cancelWait()
+	if _, err := sender.Wait(waitCtx, e.client, tx.Hash(), *f.confirmations, pollInterval); err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			e.printSend(result)
+			return fmt.Errorf("transaction sent but not confirmed within %s", *f.wait)
+		}
+		return err
+	}
+	rpcCtx, cancel = e.rpcContext(ctx)
+	defer cancel()
+	if result.Receipt, err = explorer.ReceiptByHash(rpcCtx, e.client, tx.Hash()); err != nil {
+		return err
+	}
+	if err := e.printSend(result); err != nil {
+		return err
+	}
+	if result.Receipt.Status != "success" {
+		return fmt.Errorf("transaction %s failed", result.Hash)
+	}
+	return nil
+}
+
+func (e *env) printSend(r sendResult) error {
+	if e.json {
+		return e.writeJSON(r)
+	}
+	tw := tabwriter.NewWriter(e.out, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "Hash\t%s\n", r.Hash)
+	fmt.Fprintf(tw, "From\t%s\n", r.From)
+	fmt.Fprintf(tw, "To\t%s\n", r.To)
+	fmt.Fprintf(tw, "Value\t%s ether\n", formatWei(r.Value, units.Ether))
+	fmt.Fprintf(tw, "Nonce\t%d\n", r.Nonce)
+	fmt.Fprintf(tw, "Gas limit\t%d\n", r.Gas)
+	fmt.Fprintf(tw, "Max fee\t%s gwei\n", formatWei(r.MaxFeePerGas, units.Gwei))
+	fmt.Fprintf(tw, "Max priority fee\t%s gwei\n", formatWei(r.MaxPriorityFeePerGas, units.Gwei))
+	fmt.Fprintf(tw, "Max cost\t%s ether\n", formatWei(r.MaxCost, units.Ether))
+	if r.Raw != "" {
+		fmt.Fprintf(tw, "Raw\t%s\n", r.Raw)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	switch {
+	case r.Receipt != nil:
+		fmt.Fprintln(e.out)
+		return printReceipt(e.out, r.Receipt)
+	case r.Raw != "":
+		fmt.Fprintln(e.out, "\nNot broadcast (dry run).")
+	}
+	return nil
+}
+
+// parseGwei parses an optional fee flag given in gwei.
+func parseGwei(name, s string) (*big.Int, error) {
+	if s == "" {
+		return nil, nil
+	}
+	v, err := units.Parse(s, units.Gwei)
+	if err != nil {
+		return nil, fmt.Errorf("invalid -%s: %v", name, err)
+	}
+	return v, nil
+}
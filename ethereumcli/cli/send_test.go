@@ -0,0 +1,93 @@
+package cli
+
+import (
+	"encoding/json"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSend(t *testing.T) {
+	ks := testKeystore(t)
+	pwFile := filepath.Join(t.TempDir(), "password")
+	require.NoError(t, os.WriteFile(pwFile, []byte("hunter2\n"), 0o600))
+	_, err := run(t, "--keystore", ks, "wallet", "import", "-password-file", pwFile, testKey)
+	require.NoError(t, err)
+
+	n := newFakeNode()
+	n.nonces[common.HexToAddress(testAddress)] = 4
+	ep := serve(t, n)
+	send := func(args ...string) (string, error) {
+		return run(t, append([]string{"--rpc", ep.http, "--keystore", ks, "--output", "json", "send",
+			"-from", testAddress, "-to", bob, "-password-file", pwFile}, args...)...)
+	}
+
+	out, err := send("-value", "1.5", "-speed", "fast", "-dry-run")
+	require.NoError(t, err)
+	var result sendResult
+	require.NoError(t, json.Unmarshal([]byte(out), &result))
+	assert.False(t, result.Sent)
+	assert.Empty(t, n.sent, "dry runs are not broadcast")
+
+	raw, err := hexutil.Decode(result.Raw)
+	require.NoError(t, err)
+	tx := new(types.Transaction)
+	require.NoError(t, tx.UnmarshalBinary(raw))
+	assert.Equal(t, uint8(types.DynamicFeeTxType), tx.Type())
+	from, err := types.Sender(types.LatestSignerForChainID(big.NewInt(1337)), tx)
+	require.NoError(t, err)
+	assert.Equal(t, testAddress, from.Hex())
+	assert.Equal(t, bob, tx.To().Hex())
+	assert.Equal(t, "1500000000000000000", tx.Value().String())
+	assert.Equal(t, uint64(4), tx.Nonce())
+	assert.Equal(t, uint64(21000), tx.Gas())
+	assert.Equal(t, int64(2_000_000_000), tx.GasTipCap().Int64())
+	assert.Equal(t, int64(4_000_000_000), tx.GasFeeCap().Int64(), "twice the base fee plus the tip")
+	assert.Equal(t, tx.Hash().Hex(), result.Hash)
+
+	out, err = send("-value", "100", "-unit", "gwei", "-data", "0x1234", "-max-fee", "7.5", "-nonce", "9")
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal([]byte(out), &result))
+	assert.True(t, result.Sent)
+	require.Len(t, n.sent, 1)
+	tx = n.sent[0]
+	assert.Equal(t, result.Hash, tx.Hash().Hex())
+	assert.Equal(t, uint64(9), tx.Nonce())
+	assert.Equal(t, uint64(50_000), tx.Gas())
+	assert.Equal(t, int64(7_500_000_000), tx.GasFeeCap().Int64())
+	assert.Equal(t, "100000000000", tx.Value().String())
+	assert.Equal(t, "0x1234", hexutil.Encode(tx.Data()))
+}
+
+func TestSendErrors(t *testing.T) {
+	ks := testKeystore(t)
+	_, err := runWithInput(t, "pw\n", "--keystore", ks, "wallet", "import", testKey)
+	require.NoError(t, err)
+	n := newFakeNode()
+	ep := serve(t, n)
+	for _, tc := range []struct {
+		args    []string
+		wantErr string
+	}{
+		{[]string{"-to", bob}, "usage error"},
+		{[]string{"-from", testAddress, "-to", bob, "-value", "1.5", "-unit", "wei"}, "more than 0 decimals"},
+		{[]string{"-from", testAddress, "-to", bob, "-speed", "warp"}, `unknown speed "warp"`},
+		{[]string{"-from", testAddress, "-to", bob, "-max-fee", "x"}, "invalid -max-fee"},
+		{[]string{"-from", testAddress, "-to", bob, "-data", "zz"}, "invalid -data"},
+		{[]string{"-from", bob, "-to", testAddress}, "no such account"},
+		{[]string{"-from", testAddress, "-to", bob, "-max-fee", "1", "-priority-fee", "2"}, "exceeds max fee"},
+	} {
+		_, err := runWithInput(t, "pw\n", append([]string{"--rpc", ep.http, "--keystore", ks, "send"}, tc.args...)...)
+		assert.ErrorContains(t, err, tc.wantErr, tc.args)
+	}
+	_, err = runWithInput(t, "wrong\n", "--rpc", ep.http, "--keystore", ks, "send", "-from", testAddress, "-to", bob)
+	assert.ErrorContains(t, err, "could not decrypt")
+	assert.Empty(t, n.sent)
+}
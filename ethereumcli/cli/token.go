@@ -0,0 +1,181 @@
+package cli
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"golang/ethereumcli/codec"
+	"golang/ethereumcli/erc20"
+	"math/big"
+	"text/tabwriter"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// tokenAmount is the JSON output of token balance and allowance.
+type tokenAmount struct {
+	Token   string `json:"token"`
+	Owner   string `json:"owner"`
+	Spender string `json:"spender,omitempty"`
+	Amount  string `json:"amount"`
+	Raw     string `json:"raw"`
+	Symbol  string `json:"symbol"`
+}
+
+// openToken connects, binds the token at addr and reads its metadata.
+func (e *env) openToken(ctx context.Context, addr common.Address) (*erc20.Token, erc20.Metadata, error) {
+	if err := e.connect(ctx); err != nil {
+		return nil, erc20.Metadata{}, err
+	}
+	token, err := erc20.Open(ctx, addr, e.client)
+	if err != nil {
+		return nil, erc20.Metadata{}, err
+	}
+	m, err := token.Metadata(ctx)
+	return token, m, err
+}
+
+func runTokenInfo(ctx context.Context, e *env, fs *flag.FlagSet, args []string) error {
+	if err := parseFlags(fs, args, 1, 1); err != nil {
+		return err
+	}
+	addr, err := codec.ParseAddress(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	_, m, err := e.openToken(ctx, addr)
+	if err != nil {
+		return err
+	}
+	if e.json {
+		return e.writeJSON(m)
+	}
+	supply, _ := new(big.Int).SetString(m.TotalSupply, 10)
+	tw := tabwriter.NewWriter(e.out, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "Address\t%s\n", m.Address)
+	fmt.Fprintf(tw, "Name\t%s\n", m.Name)
+	fmt.Fprintf(tw, "Symbol\t%s\n", m.Symbol)
+	fmt.Fprintf(tw, "Decimals\t%d\n", m.Decimals)
+	fmt.Fprintf(tw, "Total supply\t%s %s\n", erc20.FormatAmount(supply, m.Decimals), m.Symbol)
+	return tw.Flush()
+}
+
+func runTokenBalance(ctx context.Context, e *env, fs *flag.FlagSet, args []string) error {
+	if err := parseFlags(fs, args, 2, -1); err != nil {
+		return err
+	}
+	addr, err := codec.ParseAddress(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	owners, err := parseAddresses(fs.Args()[1:])
+	if err != nil {
+		return err
+	}
+	token, m, err := e.openToken(ctx, addr)
+	if err != nil {
+		return err
+	}
+	var results []tokenAmount
+	for _, owner := range owners {
+		balance, err := token.BalanceOf(ctx, owner)
+		if err != nil {
+			return fmt.Errorf("balance of %s: %w", owner.Hex(), err)
+		}
+		results = append(results, tokenAmount{
+			Token: m.Address, Owner: owner.Hex(), Symbol: m.Symbol,
+			Amount: erc20.FormatAmount(balance, m.Decimals), Raw: balance.String(),
+		})
+	}
+	if e.json {
+		return e.writeJSON(results)
+	}
+	tw := tabwriter.NewWriter(e.out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ADDRESS\tBALANCE")
+	for _, r := range results {
+		fmt.Fprintf(tw, "%s\t%s %s\n", r.Owner, r.Amount, r.Symbol)
+	}
+	return tw.Flush()
+}
+
+func runTokenAllowance(ctx context.Context, e *env, fs *flag.FlagSet, args []string) error {
+	if err := parseFlags(fs, args, 3, 3); err != nil {
+		return err
+	}
+	addrs, err := parseAddresses(fs.Args())
+	if err != nil {
+		return err
+	}
+	token, m, err := e.openToken(ctx, addrs[0])
+	if err != nil {
+		return err
+	}
+	allowance, err := token.Allowance(ctx, addrs[1], addrs[2])
+	if err != nil {
+		return err
+	}
+	r := tokenAmount{
+		Token: m.Address, Owner: addrs[1].Hex(), Spender: addrs[2].Hex(), Symbol: m.Symbol,
+		Amount: erc20.FormatAmount(allowance, m.Decimals), Raw: allowance.String(),
+	}
+	if e.json {
+		return e.writeJSON(r)
+	}
+	_, err = fmt.Fprintf(e.out, "%s %s\n", r.Amount, r.Symbol)
+	return err
+}
+
+func runTokenTransfer(ctx context.Context, e *env, fs *flag.FlagSet, args []string) error {
+	return tokenTransact(ctx, e, fs, args, "transfer")
+}
+
+func runTokenApprove(ctx context.Context, e *env, fs *flag.FlagSet, args []string) error {
+	return tokenTransact(ctx, e, fs, args, "approve")
+}
+
+// tokenTransact sends transfer(to, amount) or approve(spender, amount) to
+// the token.
+func tokenTransact(ctx context.Context, e *env, fs *flag.FlagSet, args []string, method string) error {
+	f := addTxFlags(fs)
+	if err := parseFlags(fs, args, 3, 3); err != nil {
+		return err
+	}
+	if err := f.parse(fs); err != nil {
+		return err
+	}
+	addrs, err := parseAddresses(fs.Args()[:2])
+	if err != nil {
+		return err
+	}
+	if err := e.unlock(f); err != nil {
+		return err
+	}
+
+	rpcCtx, cancel := e.rpcContext(ctx)
+	defer cancel()
+	token, m, err := e.openToken(rpcCtx, addrs[0])
+	if err != nil {
+		return err
+	}
+	amount, err := erc20.ParseAmount(fs.Arg(2), m.Decimals)
+	if err != nil {
+		return err
+	}
+	if method == "transfer" {
+		balance, err := token.BalanceOf(rpcCtx, f.req.From)
+		if err != nil {
+			return err
+		}
+		if balance.Cmp(amount) < 0 {
+			return fmt.Errorf("insufficient balance: %s has %s %s", f.req.From.Hex(), erc20.FormatAmount(balance, m.Decimals), m.Symbol)
+		}
+		f.req.Data, err = erc20.TransferData(addrs[1], amount)
+	} else {
+		f.req.Data, err = erc20.ApproveData(addrs[1], amount)
+	}
+	if err != nil {
+		return err
+	}
+	f.req.To = &token.Address
+	return e.transact(ctx, f)
+}
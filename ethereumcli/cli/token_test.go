@@ -0,0 +1,113 @@
+package cli
+
+import (
+	"encoding/json"
+	"golang/ethereumcli/erc20"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var tokenAddr = common.HexToAddress("0x00000000000000000000000000000000000070cE")
+
+// fakeToken makes n answer the calls of a USDC-like token.
+func fakeToken(t *testing.T, n *fakeNode) {
+	parsed, err := erc20.ERC20MetaData.GetAbi()
+	require.NoError(t, err)
+	answer := func(method string, result interface{}, args ...interface{}) {
+		in, err := parsed.Pack(method, args...)
+		require.NoError(t, err)
+		out, err := parsed.Methods[method].Outputs.Pack(result)
+		require.NoError(t, err)
+		n.calls[hexutil.Encode(in)] = out
+	}
+	n.code[tokenAddr] = []byte{0x60, 0x00}
+	answer("name", "USD Coin")
+	answer("symbol", "USDC")
+	answer("decimals", uint8(6))
+	answer("totalSupply", big.NewInt(42_000_000_000_000))
+	answer("balanceOf", big.NewInt(1_500_000), common.HexToAddress(testAddress))
+	answer("balanceOf", big.NewInt(0), common.HexToAddress(bob))
+	answer("allowance", erc20.MaxAmount, common.HexToAddress(testAddress), common.HexToAddress(bob))
+}
+
+func TestTokenReads(t *testing.T) {
+	n := newFakeNode()
+	fakeToken(t, n)
+	ep := serve(t, n)
+
+	out, err := run(t, "--rpc", ep.http, "token", "info", tokenAddr.Hex())
+	require.NoError(t, err)
+	assert.Regexp(t, `Symbol\s+USDC`, out)
+	assert.Regexp(t, `Total supply\s+42000000 USDC`, out)
+
+	out, err = run(t, "--rpc", ep.http, "token", "balance", tokenAddr.Hex(), testAddress, bob)
+	require.NoError(t, err)
+	assert.Regexp(t, testAddress+`\s+1.5 USDC`, out)
+	assert.Regexp(t, bob+`\s+0 USDC`, out)
+
+	out, err = run(t, "--rpc", ep.http, "--output", "json", "token", "allowance", tokenAddr.Hex(), testAddress, bob)
+	require.NoError(t, err)
+	var allowance tokenAmount
+	require.NoError(t, json.Unmarshal([]byte(out), &allowance))
+	assert.Equal(t, "max", allowance.Amount)
+	assert.Equal(t, erc20.MaxAmount.String(), allowance.Raw)
+
+	_, err = run(t, "--rpc", ep.http, "token", "info", bob)
+	assert.ErrorIs(t, err, erc20.ErrNoContract)
+}
+
+func TestTokenTransactions(t *testing.T) {
+	ks := testKeystore(t)
+	pwFile := filepath.Join(t.TempDir(), "password")
+	require.NoError(t, os.WriteFile(pwFile, []byte("pw\n"), 0o600))
+	_, err := run(t, "--keystore", ks, "wallet", "import", "-password-file", pwFile, testKey)
+	require.NoError(t, err)
+
+	n := newFakeNode()
+	fakeToken(t, n)
+	ep := serve(t, n)
+	token := func(args ...string) (string, error) {
+		return run(t, append([]string{"--rpc", ep.http, "--keystore", ks, "token"}, args...)...)
+	}
+
+	_, err = token("transfer", "-from", testAddress, "-password-file", pwFile, tokenAddr.Hex(), bob, "1.25")
+	require.NoError(t, err)
+	_, err = token("approve", "-from", testAddress, "-password-file", pwFile, tokenAddr.Hex(), bob, "max")
+	require.NoError(t, err)
+	require.Len(t, n.sent, 2)
+
+	parsed, err := erc20.ERC20MetaData.GetAbi()
+	require.NoError(t, err)
+	for i, want := range []struct {
+		method string
+		amount *big.Int
+	}{
+		{"transfer", big.NewInt(1_250_000)},
+		{"approve", erc20.MaxAmount},
+	} {
+		tx := n.sent[i]
+		assert.Equal(t, tokenAddr, *tx.To())
+		assert.Zero(t, tx.Value().Sign())
+		method, err := parsed.MethodById(tx.Data()[:4])
+		require.NoError(t, err)
+		assert.Equal(t, want.method, method.Name)
+		args, err := method.Inputs.Unpack(tx.Data()[4:])
+		require.NoError(t, err)
+		assert.Equal(t, []interface{}{common.HexToAddress(bob), want.amount}, args)
+	}
+
+	_, err = token("transfer", "-from", testAddress, "-password-file", pwFile, tokenAddr.Hex(), bob, "2")
+	assert.ErrorContains(t, err, "insufficient balance: "+testAddress+" has 1.5 USDC")
+	_, err = token("transfer", "-from", testAddress, "-password-file", pwFile, tokenAddr.Hex(), bob, "0.0000001")
+	assert.ErrorContains(t, err, "more than 6 decimals")
+	_, err = token("transfer", "-from", testAddress, tokenAddr.Hex(), bob)
+	assert.ErrorIs(t, err, ErrUsage)
+	assert.Len(t, n.sent, 2)
+}
@@ -0,0 +1,244 @@
+package cli
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"errors"
+	"flag"
+	"fmt"
+	"golang/ethereumcli/codec"
+	"golang/ethereumcli/wallet"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// Scrypt cost of new key files; tests lower it.
+var scryptN, scryptP = keystore.StandardScryptN, keystore.StandardScryptP
+
+// walletAccount is the JSON output of the wallet commands.
+type walletAccount struct {
+	Address    string `json:"address"`
+	File       string `json:"file"`
+	Path       string `json:"path,omitempty"`
+	Mnemonic   string `json:"mnemonic,omitempty"`
+	PrivateKey string `json:"privateKey,omitempty"`
+}
+
+func (e *env) wallet() *wallet.Wallet {
+	return wallet.Open(e.keystore, scryptN, scryptP)
+}
+
+func runWalletNew(ctx context.Context, e *env, fs *flag.FlagSet, args []string) error {
+	passwordFile := fs.String("password-file", "", "read the password from the first line of this file instead of prompting")
+	mnemonic := fs.Bool("mnemonic", false, "generate a BIP-39 mnemonic and derive the key from it")
+	words := fs.Int("words", 12, "mnemonic length: 12, 15, 18, 21 or 24 words")
+	pathFlag := fs.String("path", "", "BIP-44 derivation path (default "+wallet.DefaultPath.String()+")")
+	if err := parseFlags(fs, args, 0, 0); err != nil {
+		return err
+	}
+	if !*mnemonic && (isSet(fs, "words") || isSet(fs, "path")) {
+		return errors.New("-words and -path need -mnemonic")
+	}
+	path, err := parsePath(*pathFlag)
+	if err != nil {
+		return err
+	}
+	var phrase string
+	if *mnemonic {
+		if phrase, err = wallet.NewMnemonic(*words); err != nil {
+			return err
+		}
+	}
+	password, err := e.password(*passwordFile, "Password for the new key: ", true)
+	if err != nil {
+		return err
+	}
+
+	w := e.wallet()
+	result := walletAccount{}
+	var a accounts.Account
+	if *mnemonic {
+		key, err := wallet.DeriveKey(phrase, "", path)
+		if err != nil {
+			return err
+		}
+		if a, err = w.Import(key, password); err != nil {
+			return err
+		}
+		result.Path, result.Mnemonic = path.String(), phrase
+	} else if a, err = w.New(password); err != nil {
+		return err
+	}
+	result.Address, result.File = a.Address.Hex(), a.URL.Path
+	if e.json {
+		return e.writeJSON(result)
+	}
+	printWalletAccount(e, result)
+	if phrase != "" {
+		fmt.Fprintln(e.out, "\nThe mnemonic is not stored in the keystore. Write it down and keep it secret;")
+		fmt.Fprintln(e.out, "anyone who has it controls the account.")
+	}
+	return nil
+}
+
+func runWalletImport(ctx context.Context, e *env, fs *flag.FlagSet, args []string) error {
+	passwordFile := fs.String("password-file", "", "read the password from the first line of this file instead of prompting")
+	pathFlag := fs.String("path", "", "BIP-44 derivation path for mnemonics (default "+wallet.DefaultPath.String()+")")
+	if err := parseFlags(fs, args, 0, -1); err != nil {
+		return err
+	}
+	path, err := parsePath(*pathFlag)
+	if err != nil {
+		return err
+	}
+	// Reading the secret from stdin keeps it out of the shell history.
+	secret := strings.Join(fs.Args(), " ")
+	if secret == "" {
+		if secret, err = e.readSecret("Private key or mnemonic: "); err != nil {
+			return err
+		}
+	}
+	key, isMnemonic, err := parseSecret(secret, path)
+	if err != nil {
+		return err
+	}
+	if !isMnemonic && isSet(fs, "path") {
+		return errors.New("-path only applies to mnemonics")
+	}
+	password, err := e.password(*passwordFile, "Password for the imported key: ", true)
+	if err != nil {
+		return err
+	}
+	a, err := e.wallet().Import(key, password)
+	if err != nil {
+		return err
+	}
+	result := walletAccount{Address: a.Address.Hex(), File: a.URL.Path}
+	if isMnemonic {
+		result.Path = path.String()
+	}
+	if e.json {
+		return e.writeJSON(result)
+	}
+	printWalletAccount(e, result)
+	return nil
+}
+
+func runWalletList(ctx context.Context, e *env, fs *flag.FlagSet, args []string) error {
+	if err := parseFlags(fs, args, 0, 0); err != nil {
+		return err
+	}
+	accts := e.wallet().Accounts()
+	if e.json {
+		results := make([]walletAccount, 0, len(accts))
+		for _, a := range accts {
+			results = append(results, walletAccount{Address: a.Address.Hex(), File: a.URL.Path})
+		}
+		return e.writeJSON(results)
+	}
+	if len(accts) == 0 {
+		fmt.Fprintf(e.out, "No accounts in %s\n", e.keystore)
+		return nil
+	}
+	tw := tabwriter.NewWriter(e.out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ADDRESS\tFILE")
+	for _, a := range accts {
+		fmt.Fprintf(tw, "%s\t%s\n", a.Address.Hex(), a.URL.Path)
+	}
+	return tw.Flush()
+}
+
+func runWalletExport(ctx context.Context, e *env, fs *flag.FlagSet, args []string) error {
+	passwordFile := fs.String("password-file", "", "read the password from the first line of this file instead of prompting")
+	privateKey := fs.Bool("private-key", false, "print the unencrypted private key instead of the key file")
+	if err := parseFlags(fs, args, 1, 1); err != nil {
+		return err
+	}
+	addr, err := codec.ParseAddress(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	w := e.wallet()
+	a, err := w.Find(addr)
+	if err != nil {
+		return err
+	}
+	password, err := e.password(*passwordFile, "Password: ", false)
+	if err != nil {
+		return err
+	}
+	if !*privateKey {
+		data, err := w.ExportJSON(addr, password)
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(e.out, "%s\n", data)
+		return err
+	}
+	key, err := w.PrivateKey(addr, password)
+	if err != nil {
+		return err
+	}
+	hexKey := hexutil.Encode(crypto.FromECDSA(key))
+	if e.json {
+		return e.writeJSON(walletAccount{Address: addr.Hex(), File: a.URL.Path, PrivateKey: hexKey})
+	}
+	_, err = fmt.Fprintln(e.out, hexKey)
+	return err
+}
+
+func printWalletAccount(e *env, a walletAccount) {
+	tw := tabwriter.NewWriter(e.out, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "Address\t%s\n", a.Address)
+	fmt.Fprintf(tw, "Key file\t%s\n", a.File)
+	if a.Path != "" {
+		fmt.Fprintf(tw, "Path\t%s\n", a.Path)
+	}
+	if a.Mnemonic != "" {
+		fmt.Fprintf(tw, "Mnemonic\t%s\n", a.Mnemonic)
+	}
+	tw.Flush()
+}
+
+// parsePath parses a BIP-44 derivation path; empty means the default.
+func parsePath(s string) (accounts.DerivationPath, error) {
+	if s == "" {
+		return wallet.DefaultPath, nil
+	}
+	path, err := accounts.ParseDerivationPath(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid derivation path %q: %v", s, err)
+	}
+	return path, nil
+}
+
+// parseSecret reads a hex private key, or derives one from a mnemonic at
+// path.
+func parseSecret(s string, path accounts.DerivationPath) (key *ecdsa.PrivateKey, isMnemonic bool, err error) {
+	s = strings.TrimSpace(s)
+	if len(strings.Fields(s)) > 1 {
+		key, err = wallet.DeriveKey(s, "", path)
+		return key, true, err
+	}
+	key, err = crypto.HexToECDSA(strings.TrimPrefix(s, "0x"))
+	if err != nil {
+		return nil, false, errors.New("invalid private key: want 64 hex digits or a mnemonic")
+	}
+	return key, false, nil
+}
+
+// isSet reports whether the flag was given on the command line.
+func isSet(fs *flag.FlagSet, name string) bool {
+	set := false
+	fs.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
+	return set
+}
@@ -0,0 +1,99 @@
+package cli
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testMnemonic = "abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about"
+	// testKey is the key testMnemonic derives at m/44'/60'/0'/0/0.
+	testKey     = "0x1ab42cc412b618bdea3a599e3c9bae199ebf030895b039e9db1e30dafb12b727"
+	testAddress = "0x9858EfFD232B4033E47d90003D41EC34EcaEda94"
+)
+
+// testKeystore returns a keystore directory and switches to cheap scrypt
+// parameters for the test.
+func testKeystore(t *testing.T) string {
+	n, p := scryptN, scryptP
+	scryptN, scryptP = keystore.LightScryptN, keystore.LightScryptP
+	t.Cleanup(func() { scryptN, scryptP = n, p })
+	return filepath.Join(t.TempDir(), "keystore")
+}
+
+func TestWallet(t *testing.T) {
+	ks := testKeystore(t)
+	pwFile := filepath.Join(t.TempDir(), "password")
+	require.NoError(t, os.WriteFile(pwFile, []byte("hunter2\nignored\n"), 0o600))
+
+	out, err := run(t, "--keystore", ks, "wallet", "list")
+	require.NoError(t, err)
+	assert.Equal(t, "No accounts in "+ks+"\n", out)
+
+	out, err = run(t, append([]string{"--keystore", ks, "wallet", "import", "-password-file", pwFile}, strings.Fields(testMnemonic)...)...)
+	require.NoError(t, err)
+	assert.Regexp(t, `Address\s+`+testAddress, out)
+	assert.Regexp(t, `Path\s+m/44'/60'/0'/0/0`, out)
+
+	// The key and password come from stdin, one line each.
+	_, err = runWithInput(t, testKey+"\nhunter2\n", "--keystore", ks, "wallet", "import")
+	assert.ErrorContains(t, err, "account already exists")
+	out, err = runWithInput(t, testMnemonic+"\nsecond\n", "--keystore", ks, "--output", "json", "wallet", "import", "-path", "m/44'/60'/0'/0/1")
+	require.NoError(t, err)
+	var imported walletAccount
+	require.NoError(t, json.Unmarshal([]byte(out), &imported))
+	assert.Equal(t, "0x6Fac4D18c912343BF86fa7049364Dd4E424Ab9C0", imported.Address)
+
+	out, err = runWithInput(t, "third\n", "--keystore", ks, "--output", "json", "wallet", "new", "-mnemonic", "-words", "24")
+	require.NoError(t, err)
+	var created walletAccount
+	require.NoError(t, json.Unmarshal([]byte(out), &created))
+	assert.Len(t, strings.Fields(created.Mnemonic), 24)
+	assert.FileExists(t, created.File)
+
+	_, err = runWithInput(t, "fourth\n", "--keystore", ks, "wallet", "new")
+	require.NoError(t, err)
+	out, err = run(t, "--keystore", ks, "wallet", "list")
+	require.NoError(t, err)
+	assert.Equal(t, 5, strings.Count(out, "\n"), "header and four accounts")
+	assert.Contains(t, out, imported.Address)
+
+	out, err = run(t, "--keystore", ks, "wallet", "export", "-password-file", pwFile, "-private-key", strings.ToLower(testAddress))
+	require.NoError(t, err)
+	assert.Equal(t, testKey+"\n", out)
+	out, err = runWithInput(t, "second\n", "--keystore", ks, "wallet", "export", imported.Address)
+	require.NoError(t, err)
+	key, err := keystore.DecryptKey([]byte(out), "second")
+	require.NoError(t, err)
+	assert.Equal(t, imported.Address, key.Address.Hex())
+	_, err = runWithInput(t, "wrong\n", "--keystore", ks, "wallet", "export", imported.Address)
+	assert.ErrorIs(t, err, keystore.ErrDecrypt)
+}
+
+func TestWalletErrors(t *testing.T) {
+	ks := testKeystore(t)
+	for _, tc := range []struct {
+		input   string
+		args    []string
+		wantErr string
+	}{
+		{"", []string{"wallet", "import"}, "no input"},
+		{"pw\n", []string{"wallet", "import", "0x1234"}, "want 64 hex digits or a mnemonic"},
+		{"pw\n", []string{"wallet", "import", "-path", "m/44'/60'/0'/0/1", testKey}, "-path only applies to mnemonics"},
+		{"pw\n", []string{"wallet", "import", "abandon", "abandon"}, "invalid mnemonic"},
+		{"\n", []string{"wallet", "import", testKey}, "empty password"},
+		{"pw\n", []string{"wallet", "new", "-words", "24"}, "need -mnemonic"},
+		{"pw\n", []string{"wallet", "new", "-mnemonic", "-path", "m/x"}, "invalid derivation path"},
+		{"pw\n", []string{"wallet", "export", testAddress}, "no such account"},
+	} {
+		_, err := runWithInput(t, tc.input, append([]string{"--keystore", ks}, tc.args...)...)
+		assert.ErrorContains(t, err, tc.wantErr, tc.args)
+	}
+}
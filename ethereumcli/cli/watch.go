@@ -0,0 +1,164 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"golang/ethereumcli/units"
+	"golang/ethereumcli/watch"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// errDone ends a watch once -count events were printed.
+var errDone = errors.New("done")
+
+// watchFlags are the flags every watch command has.
+type watchFlags struct {
+	count *uint64
+	poll  *time.Duration
+}
+
+func addWatchFlags(fs *flag.FlagSet) *watchFlags {
+	return &watchFlags{
+		count: fs.Uint64("count", 0, "exit after this many events (default: run until interrupted)"),
+		poll:  fs.Duration("poll", watch.DefaultPollInterval, "how often to poll when the endpoint cannot push (HTTP)"),
+	}
+}
+
+// watchConfig redials the endpoint and reports connection changes on
+// stderr, which keeps stdout clean NDJSON.
+func (e *env) watchConfig(f *watchFlags) (watch.Config, error) {
+	if *f.poll <= 0 {
+		return watch.Config{}, fmt.Errorf("-poll must be positive")
+	}
+	return watch.Config{
+		Dial:         e.dial,
+		PollInterval: *f.poll,
+		Timeout:      e.timeout,
+		Status: func(s watch.Status) {
+			switch s.State {
+			case watch.Subscribed:
+				fmt.Fprintf(e.errOut, "subscribed to %s\n", e.endpoint)
+			case watch.Polling:
+				fmt.Fprintf(e.errOut, "polling %s every %s\n", e.endpoint, *f.poll)
+			case watch.Disconnected:
+				fmt.Fprintf(e.errOut, "%v; reconnecting in %s\n", s.Err, s.Retry)
+			}
+		},
+	}, nil
+}
+
+// emitter prints one event per line, as JSON with --output json, and
+// stops the watch after -count events.
+func emitter[T any](e *env, f *watchFlags, text func(T) string) func(T) error {
+	enc := json.NewEncoder(e.out)
+	var n uint64
+	return func(v T) error {
+		var err error
+		if e.json {
+			err = enc.Encode(v)
+		} else {
+			_, err = fmt.Fprintln(e.out, text(v))
+		}
+		if err != nil {
+			return err
+		}
+		if n++; *f.count > 0 && n >= *f.count {
+			return errDone
+		}
+		return nil
+	}
+}
+
+// finish maps the end of a watch cut short by -count to success.
+func finish(err error) error {
+	if errors.Is(err, errDone) {
+		return nil
+	}
+	return err
+}
+
+func runWatchHeads(ctx context.Context, e *env, fs *flag.FlagSet, args []string) error {
+	f := addWatchFlags(fs)
+	if err := parseFlags(fs, args, 0, 0); err != nil {
+		return err
+	}
+	cfg, err := e.watchConfig(f)
+	if err != nil {
+		return err
+	}
+	return finish(watch.Heads(ctx, cfg, emitter(e, f, func(h watch.Head) string {
+		line := fmt.Sprintf("%d  %s  %s  gas %s", h.Number, h.Hash, h.Time.Format(time.RFC3339), gasUsage(h.GasUsed, h.GasLimit))
+		if h.BaseFee != "" {
+			line += fmt.Sprintf("  base fee %s gwei", formatWei(h.BaseFee, units.Gwei))
+		}
+		return line
+	})))
+}
+
+func runWatchLogs(ctx context.Context, e *env, fs *flag.FlagSet, args []string) error {
+	f := addWatchFlags(fs)
+	var addressFlags, topicFlags stringList
+	fs.Var(&addressFlags, "address", "contract whose logs to show; repeatable")
+	fs.Var(&topicFlags, "topic", "first topic to match, as a 0x topic or event signature; repeatable")
+	from := fs.String("from", "", "first show the logs since this block number")
+	if err := parseFlags(fs, args, 0, 0); err != nil {
+		return err
+	}
+	q := ethereum.FilterQuery{}
+	var err error
+	if q.Addresses, err = parseAddresses(addressFlags); err != nil {
+		return err
+	}
+	if len(topicFlags) > 0 {
+		var topic0 []common.Hash
+		for _, s := range topicFlags {
+			if !strings.HasPrefix(s, "0x") && !strings.Contains(s, "(") {
+				return fmt.Errorf("-topic %s: want a 0x topic or an event signature", s)
+			}
+			t, err := eventTopic(nil, s)
+			if err != nil {
+				return err
+			}
+			topic0 = append(topic0, t)
+		}
+		q.Topics = [][]common.Hash{topic0}
+	}
+	if *from != "" {
+		n, ok := new(big.Int).SetString(*from, 10)
+		if !ok || n.Sign() < 0 || !n.IsUint64() {
+			return fmt.Errorf("invalid -from %q: want a block number", *from)
+		}
+		q.FromBlock = n
+	}
+	cfg, err := e.watchConfig(f)
+	if err != nil {
+		return err
+	}
+	return finish(watch.Logs(ctx, cfg, q, emitter(e, f, func(l watch.Log) string {
+		line := fmt.Sprintf("%d/%d  %s  %s  topics [%s]  data %s", l.BlockNumber, l.Index, l.TxHash, l.Address, strings.Join(l.Topics, " "), l.Data)
+		if l.Removed {
+			line += "  (removed by reorg)"
+		}
+		return line
+	})))
+}
+
+func runWatchPending(ctx context.Context, e *env, fs *flag.FlagSet, args []string) error {
+	f := addWatchFlags(fs)
+	if err := parseFlags(fs, args, 0, 0); err != nil {
+		return err
+	}
+	cfg, err := e.watchConfig(f)
+	if err != nil {
+		return err
+	}
+	return finish(watch.PendingTxs(ctx, cfg, emitter(e, f, func(p watch.Pending) string { return p.Hash })))
+}
@@ -0,0 +1,62 @@
+package cli
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWatch(t *testing.T) {
+	n := newFakeNode()
+	topic := common.HexToHash("0xddf252ad1be2c89b69c2b068fc378daa952ba7f163c4a11628f55a4df523b3ef")
+	n.logs = []types.Log{
+		{Address: tokenAddr, Topics: []common.Hash{topic}, Data: []byte{0x2a}, BlockNumber: 10, TxHash: common.Hash{0x0a}},
+		{Address: tokenAddr, Topics: []common.Hash{topic}, BlockNumber: 40, TxHash: common.Hash{0x28}, Index: 3},
+	}
+	ep := serve(t, n)
+
+	out, err := run(t, "--rpc", ep.http, "watch", "heads", "-count", "1", "-poll", "10ms")
+	require.NoError(t, err)
+	// run appends stderr, where status lines go, to stdout.
+	assert.Regexp(t, `^42  0x[0-9a-f]{64}  2023-11-14T22:13:20Z  gas 0\npolling `+ep.http+` every 10ms\n$`, out)
+
+	out, err = run(t, "--rpc", ep.http, "--output", "json", "watch", "logs", "-address", tokenAddr.Hex(),
+		"-topic", "Transfer(address,address,uint256)", "-from", "0", "-count", "2", "-poll", "10ms")
+	require.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	require.Len(t, lines, 3)
+	assert.Equal(t, "polling "+ep.http+" every 10ms", lines[2])
+	var first, second map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(lines[0]), &first))
+	require.NoError(t, json.Unmarshal([]byte(lines[1]), &second))
+	assert.Equal(t, float64(10), first["block_number"])
+	assert.Equal(t, "0x2a", first["data"])
+	assert.Equal(t, []interface{}{topic.Hex()}, first["topics"])
+	assert.Equal(t, float64(3), second["index"])
+
+	out, err = run(t, "--rpc", ep.http, "watch", "logs", "-from", "20", "-count", "1", "-poll", "10ms")
+	require.NoError(t, err)
+	assert.Contains(t, out, "40/3  "+common.Hash{0x28}.Hex()+"  "+tokenAddr.Hex()+"  topics ["+topic.Hex()+"]  data 0x\n")
+}
+
+func TestWatchErrors(t *testing.T) {
+	for _, tc := range []struct {
+		args []string
+		want string
+	}{
+		{[]string{"heads", "extra"}, "usage error"},
+		{[]string{"heads", "-poll", "0s"}, "-poll must be positive"},
+		{[]string{"logs", "-from", "latest"}, `invalid -from "latest"`},
+		{[]string{"logs", "-topic", "Transfer"}, "want a 0x topic or an event signature"},
+		{[]string{"logs", "-address", "0x12"}, "address must be 40 hex digits"},
+		{[]string{"pending", "-count", "x"}, "usage error"},
+	} {
+		_, err := run(t, append([]string{"--rpc", "http://127.0.0.1:1", "watch"}, tc.args...)...)
+		assert.ErrorContains(t, err, tc.want, "%v", tc.args)
+	}
+}
@@ -0,0 +1,205 @@
+package codec
+
+import (
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testABI = `[
+	{"type":"function","name":"transfer","stateMutability":"nonpayable","inputs":[{"name":"to","type":"address"},{"name":"amount","type":"uint256"}],"outputs":[{"name":"","type":"bool"}]},
+	{"type":"function","name":"transfer","stateMutability":"nonpayable","inputs":[{"name":"to","type":"address"},{"name":"amount","type":"uint256"},{"name":"memo","type":"bytes"}],"outputs":[]},
+	{"type":"function","name":"submit","stateMutability":"payable","inputs":[
+		{"name":"order","type":"tuple","components":[{"name":"maker","type":"address"},{"name":"amounts","type":"uint128[]"},{"name":"salt","type":"bytes32"}]},
+		{"name":"flags","type":"bool[2]"},
+		{"name":"tags","type":"string[]"},
+		{"name":"delta","type":"int8"}
+	],"outputs":[]},
+	{"type":"event","name":"Tagged","inputs":[{"name":"from","type":"address","indexed":true},{"name":"tag","type":"string","indexed":true},{"name":"amount","type":"uint256","indexed":false}],"anonymous":false},
+	{"type":"error","name":"Insufficient","inputs":[{"name":"have","type":"uint256"},{"name":"want","type":"uint256"}]}
+]`
+
+const testAddr = "0x9858EfFD232B4033E47d90003D41EC34EcaEda94"
+
+func loadTestABI(t *testing.T) abi.ABI {
+	a, err := abi.JSON(strings.NewReader(testABI))
+	require.NoError(t, err)
+	return a
+}
+
+func TestLoadABI(t *testing.T) {
+	dir := t.TempDir()
+	bare, artifact, bad := filepath.Join(dir, "bare.json"), filepath.Join(dir, "artifact.json"), filepath.Join(dir, "bad.json")
+	require.NoError(t, os.WriteFile(bare, []byte(testABI), 0o600))
+	require.NoError(t, os.WriteFile(artifact, []byte(`{"contractName":"T","abi":`+testABI+`,"bytecode":"0x"}`), 0o600))
+	require.NoError(t, os.WriteFile(bad, []byte(`{"bytecode":"0x"}`), 0o600))
+
+	for _, path := range []string{bare, artifact} {
+		a, err := LoadABI(path)
+		if assert.NoError(t, err, path) {
+			assert.Len(t, a.Methods, 3, path)
+		}
+	}
+	_, err := LoadABI(bad)
+	assert.ErrorContains(t, err, `"abi" field`)
+}
+
+func TestFindMethod(t *testing.T) {
+	a := loadTestABI(t)
+	m, err := FindMethod(a, "submit")
+	require.NoError(t, err)
+	assert.Equal(t, "submit((address,uint128[],bytes32),bool[2],string[],int8)", m.Sig)
+
+	m, err = FindMethod(a, "transfer(address,uint256,bytes)")
+	require.NoError(t, err)
+	assert.Len(t, m.Inputs, 3)
+
+	_, err = FindMethod(a, "transfer")
+	assert.ErrorContains(t, err, "overloaded; use one of")
+	_, err = FindMethod(a, "mint")
+	assert.ErrorContains(t, err, `no method "mint"`)
+}
+
+func TestParseArgs(t *testing.T) {
+	a := loadTestABI(t)
+	m, err := FindMethod(a, "submit")
+	require.NoError(t, err)
+	salt := "0x" + strings.Repeat("ab", 32)
+	args, err := ParseArgs(m.Inputs, []string{
+		"(" + testAddr + ", [1, 0x10, 340282366920938463463374607431768211455], " + salt + ")",
+		"[true,false]",
+		`["a,b", "c\"d", plain]`,
+		"-128",
+	})
+	require.NoError(t, err)
+	data, err := a.Pack("submit", args...)
+	require.NoError(t, err)
+
+	call, err := DecodeInput(a, data)
+	require.NoError(t, err)
+	assert.Equal(t, m.Sig, call.Method)
+	assert.Equal(t, "0x"+common.Bytes2Hex(m.ID), call.Selector)
+	require.Len(t, call.Arguments, 4)
+	assert.Equal(t, "("+testAddr+", [1, 16, 340282366920938463463374607431768211455], "+salt+")", call.Arguments[0].Text)
+	assert.Equal(t, map[string]interface{}{
+		"maker":   testAddr,
+		"amounts": []interface{}{"1", "16", "340282366920938463463374607431768211455"},
+		"salt":    salt,
+	}, call.Arguments[0].Value)
+	assert.Equal(t, "[true, false]", call.Arguments[1].Text)
+	assert.Equal(t, []interface{}{"a,b", `c"d`, "plain"}, call.Arguments[2].Value)
+	assert.Equal(t, "-128", call.Arguments[3].Text)
+
+	m, err = FindMethod(a, "transfer(address,uint256,bytes)")
+	require.NoError(t, err)
+	args, err = ParseArgs(m.Inputs, []string{strings.ToLower(testAddr), "0xff", "0xdeadbeef"})
+	require.NoError(t, err)
+	assert.Equal(t, []interface{}{common.HexToAddress(testAddr), big.NewInt(255), []byte{0xde, 0xad, 0xbe, 0xef}}, args)
+}
+
+func TestParseArgsErrors(t *testing.T) {
+	a := loadTestABI(t)
+	submit, err := FindMethod(a, "submit")
+	require.NoError(t, err)
+	ok := []string{"(" + testAddr + ",[],0x" + strings.Repeat("00", 32) + ")", "[true,true]", "[]", "0"}
+	for i, tc := range []struct {
+		arg  int
+		in   string
+		want string
+	}{
+		{0, "(" + testAddr + ",[])", "want 3 tuple fields, got 2"},
+		{0, "(" + testAddr + ",[-1],0x" + strings.Repeat("00", 32) + ")", "out of range for uint128"},
+		{0, "(" + testAddr + ",[],0x00)", "invalid bytes32"},
+		{0, "(" + strings.Replace(testAddr, "E", "e", 1) + ",[],0x" + strings.Repeat("00", 32) + ")", "EIP-55 checksum"},
+		{1, "[true]", "want 2 elements, got 1"},
+		{1, "[yes,no]", `invalid bool "yes"`},
+		{2, `["unterminated]`, "unbalanced"},
+		{2, "a,b", "want [...]"},
+		{3, "128", "out of range for int8"},
+		{3, "1.5", "invalid integer"},
+	} {
+		in := append([]string(nil), ok...)
+		in[tc.arg] = tc.in
+		_, err := ParseArgs(submit.Inputs, in)
+		assert.ErrorContains(t, err, tc.want, "case %d", i)
+	}
+	_, err = ParseArgs(submit.Inputs, ok[:3])
+	assert.ErrorContains(t, err, "want 4 arguments, got 3")
+}
+
+func TestDecodeLog(t *testing.T) {
+	a := loadTestABI(t)
+	ev := a.Events["Tagged"]
+	amount, err := ev.Inputs.NonIndexed().Pack(big.NewInt(42))
+	require.NoError(t, err)
+	tagHash := crypto.Keccak256Hash([]byte("vip"))
+	topics := []common.Hash{ev.ID, common.HexToAddress(testAddr).Hash(), tagHash}
+
+	got, err := DecodeLog(a, topics, amount)
+	require.NoError(t, err)
+	assert.Equal(t, "Tagged(address,string,uint256)", got.Event)
+	assert.Equal(t, []Field{
+		{Name: "from", Type: "address", Indexed: true, Value: testAddr, Text: testAddr},
+		{Name: "tag", Type: "string", Indexed: true, Value: tagHash.Hex(), Text: tagHash.Hex()},
+		{Name: "amount", Type: "uint256", Value: "42", Text: "42"},
+	}, got.Arguments)
+
+	_, err = DecodeLog(a, topics[:2], amount)
+	assert.ErrorContains(t, err, "2 indexed arguments, the log 2 topics")
+	_, err = DecodeLog(a, []common.Hash{{1}}, nil)
+	assert.ErrorContains(t, err, "no event with topic")
+	_, err = DecodeLog(a, nil, nil)
+	assert.ErrorContains(t, err, "no topics")
+}
+
+func TestRevertReason(t *testing.T) {
+	a := loadTestABI(t)
+	word := func(n int64) []byte { return common.LeftPadBytes(big.NewInt(n).Bytes(), 32) }
+	reason, err := (abi.Arguments{{Type: mustType(t, "string")}}).Pack("not owner")
+	require.NoError(t, err)
+	id := a.Errors["Insufficient"].ID
+	custom := append(append(id[:4:4], word(1)...), word(5)...)
+
+	assert.Equal(t, "not owner", RevertReason(nil, append(errorSelector[:4:4], reason...)))
+	assert.Equal(t, "panic 0x11: arithmetic overflow or underflow", RevertReason(nil, append(panicSelector[:4:4], word(0x11)...)))
+	assert.Equal(t, "panic 0x99", RevertReason(nil, append(panicSelector[:4:4], word(0x99)...)))
+	assert.Equal(t, "Insufficient(1, 5)", RevertReason(&a, custom))
+	assert.Equal(t, "unknown error "+"0x"+common.Bytes2Hex(custom), RevertReason(nil, custom))
+	assert.Equal(t, "", RevertReason(&a, nil))
+}
+
+func mustType(t *testing.T, name string) abi.Type {
+	typ, err := abi.NewType(name, "", nil)
+	require.NoError(t, err)
+	return typ
+}
+
+const checksummed = "0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed"
+
+func TestParseAddress(t *testing.T) {
+	for in, wantErr := range map[string]string{
+		checksummed:                                  "",
+		strings.ToLower(checksummed):                 "",
+		"0x" + strings.ToUpper(checksummed[2:]):      "",
+		"0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAeD": "invalid EIP-55 checksum, expected " + checksummed,
+		"vitalik.eth":                                "ENS names are not supported",
+		checksummed[2:]:                              "must start with 0x",
+		checksummed[:40]:                             "40 hex digits",
+	} {
+		addr, err := ParseAddress(in)
+		if wantErr != "" {
+			assert.ErrorContains(t, err, wantErr, in)
+			continue
+		}
+		require.NoError(t, err, in)
+		assert.Equal(t, checksummed, addr.Hex(), in)
+	}
+}
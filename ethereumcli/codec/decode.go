@@ -0,0 +1,276 @@
+package codec
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math/big"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// Field is one decoded argument or return value. Value is the
+// JSON-friendly form: integers are decimal strings, bytes 0x hex and
+// tuples objects keyed by component name; Text is how tables show it.
+type Field struct {
+	Name    string      `json:"name,omitempty"`
+	Type    string      `json:"type"`
+	Indexed bool        `json:"indexed,omitempty"`
+	Value   interface{} `json:"value"`
+	Text    string      `json:"-"`
+}
+
+// Fields pairs unpacked values with their arguments.
+func Fields(args abi.Arguments, values []interface{}) []Field {
+	fields := make([]Field, len(args))
+	for i, arg := range args {
+		fields[i] = Field{
+			Name:    arg.Name,
+			Type:    arg.Type.String(),
+			Indexed: arg.Indexed,
+			Value:   JSONValue(arg.Type, values[i]),
+			Text:    Format(arg.Type, values[i]),
+		}
+	}
+	return fields
+}
+
+// Call is decoded call data.
+type Call struct {
+	Method    string  `json:"method"`
+	Selector  string  `json:"selector"`
+	Arguments []Field `json:"arguments"`
+}
+
+// DecodeInput decodes call data by its function selector.
+func DecodeInput(a abi.ABI, data []byte) (*Call, error) {
+	if len(data) < 4 {
+		return nil, fmt.Errorf("call data is %d bytes, too short for a selector", len(data))
+	}
+	m, err := a.MethodById(data[:4])
+	if err != nil {
+		return nil, fmt.Errorf("the ABI has no method with selector %s", hexutil.Encode(data[:4]))
+	}
+	values, err := m.Inputs.Unpack(data[4:])
+	if err != nil {
+		return nil, fmt.Errorf("decode %s arguments: %w", m.Sig, err)
+	}
+	return &Call{Method: m.Sig, Selector: hexutil.Encode(m.ID), Arguments: Fields(m.Inputs, values)}, nil
+}
+
+// Event is a decoded log.
+type Event struct {
+	Event     string  `json:"event"`
+	Topic     string  `json:"topic"`
+	Arguments []Field `json:"arguments"`
+}
+
+// DecodeLog decodes a log by its first topic. Indexed arguments of
+// dynamic types are stored as their hash, which is shown as bytes32.
+func DecodeLog(a abi.ABI, topics []common.Hash, data []byte) (*Event, error) {
+	if len(topics) == 0 {
+		return nil, errors.New("the log has no topics; anonymous events cannot be decoded")
+	}
+	ev, err := a.EventByID(topics[0])
+	if err != nil {
+		return nil, fmt.Errorf("the ABI has no event with topic %s", topics[0])
+	}
+	nonIndexed := ev.Inputs.NonIndexed()
+	values, err := nonIndexed.Unpack(data)
+	if err != nil {
+		return nil, fmt.Errorf("decode %s data: %w", ev.Sig, err)
+	}
+	indexed := len(ev.Inputs) - len(nonIndexed)
+	if len(topics) != indexed+1 {
+		return nil, fmt.Errorf("%s has %d indexed arguments, the log %d topics", ev.Sig, indexed, len(topics))
+	}
+
+	fields := make([]Field, 0, len(ev.Inputs))
+	topic, value := 1, 0
+	for _, arg := range ev.Inputs {
+		if !arg.Indexed {
+			fields = append(fields, Fields(abi.Arguments{arg}, values[value:value+1])...)
+			value++
+			continue
+		}
+		t := arg.Type
+		var v interface{} = topics[topic]
+		if isDynamic(t) {
+			t, _ = abi.NewType("bytes32", "", nil)
+		} else {
+			unpacked, err := abi.Arguments{{Type: t}}.Unpack(topics[topic].Bytes())
+			if err != nil {
+				return nil, fmt.Errorf("decode %s topic %d: %w", ev.Sig, topic, err)
+			}
+			v = unpacked[0]
+		}
+		if _, ok := v.(common.Hash); ok {
+			v = [32]byte(v.(common.Hash))
+		}
+		fields = append(fields, Field{
+			Name:    arg.Name,
+			Type:    arg.Type.String(),
+			Indexed: true,
+			Value:   JSONValue(t, v),
+			Text:    Format(t, v),
+		})
+		topic++
+	}
+	return &Event{Event: ev.Sig, Topic: topics[0].Hex(), Arguments: fields}, nil
+}
+
+func isDynamic(t abi.Type) bool {
+	switch t.T {
+	case abi.StringTy, abi.BytesTy, abi.SliceTy, abi.ArrayTy, abi.TupleTy:
+		return true
+	}
+	return false
+}
+
+var (
+	errorSelector = crypto.Keccak256([]byte("Error(string)"))[:4]
+	panicSelector = crypto.Keccak256([]byte("Panic(uint256)"))[:4]
+)
+
+// panicReasons describes Solidity's panic codes.
+var panicReasons = map[uint64]string{
+	0x00: "generic panic",
+	0x01: "assertion failed",
+	0x11: "arithmetic overflow or underflow",
+	0x12: "division or modulo by zero",
+	0x21: "invalid enum value",
+	0x22: "corrupt storage byte array",
+	0x31: "pop on empty array",
+	0x32: "array index out of bounds",
+	0x41: "out of memory",
+	0x51: "call to an uninitialised function",
+}
+
+// RevertReason decodes the data of a reverted call: an Error(string)
+// reason, a Panic(uint256) code or, if a is non-nil, one of its custom
+// errors. Anything else is shown as hex.
+func RevertReason(a *abi.ABI, data []byte) string {
+	if len(data) == 0 {
+		return ""
+	}
+	if len(data) >= 4 {
+		switch {
+		case bytes.Equal(data[:4], errorSelector):
+			if reason, err := abi.UnpackRevert(data); err == nil {
+				return reason
+			}
+		case bytes.Equal(data[:4], panicSelector) && len(data) == 36:
+			code := new(big.Int).SetBytes(data[4:])
+			if reason, ok := panicReasons[code.Uint64()]; ok && code.IsUint64() {
+				return fmt.Sprintf("panic 0x%x: %s", code, reason)
+			}
+			return fmt.Sprintf("panic 0x%x", code)
+		}
+		if a != nil {
+			for _, e := range a.Errors {
+				if !bytes.Equal(data[:4], e.ID[:4]) {
+					continue
+				}
+				if v, err := e.Unpack(data); err == nil {
+					values, _ := v.([]interface{})
+					args := make([]string, len(values))
+					for i := range values {
+						args[i] = Format(e.Inputs[i].Type, values[i])
+					}
+					return e.Name + "(" + strings.Join(args, ", ") + ")"
+				}
+			}
+		}
+	}
+	return "unknown error " + hexutil.Encode(data)
+}
+
+// Format renders a decoded value for a table: integers in decimal,
+// bytes in hex, addresses checksummed, arrays in brackets and tuples in
+// parentheses. Strings are quoted only inside arrays and tuples.
+func Format(t abi.Type, v interface{}) string {
+	if t.T == abi.StringTy {
+		s, _ := v.(string)
+		return s
+	}
+	return format(t, reflect.ValueOf(v))
+}
+
+func format(t abi.Type, v reflect.Value) string {
+	switch t.T {
+	case abi.StringTy:
+		return strconv.Quote(v.String())
+	case abi.SliceTy, abi.ArrayTy:
+		elems := make([]string, v.Len())
+		for i := range elems {
+			elems[i] = format(*t.Elem, v.Index(i))
+		}
+		return "[" + strings.Join(elems, ", ") + "]"
+	case abi.TupleTy:
+		elems := make([]string, len(t.TupleElems))
+		for i := range elems {
+			elems[i] = format(*t.TupleElems[i], v.Field(i))
+		}
+		return "(" + strings.Join(elems, ", ") + ")"
+	}
+	return fmt.Sprint(scalar(t, v))
+}
+
+// JSONValue converts a decoded value to the form Field.Value holds.
+func JSONValue(t abi.Type, v interface{}) interface{} {
+	return jsonValue(t, reflect.ValueOf(v))
+}
+
+func jsonValue(t abi.Type, v reflect.Value) interface{} {
+	switch t.T {
+	case abi.SliceTy, abi.ArrayTy:
+		elems := make([]interface{}, v.Len())
+		for i := range elems {
+			elems[i] = jsonValue(*t.Elem, v.Index(i))
+		}
+		return elems
+	case abi.TupleTy:
+		obj := make(map[string]interface{}, len(t.TupleElems))
+		for i, name := range t.TupleRawNames {
+			if name == "" {
+				name = strconv.Itoa(i)
+			}
+			obj[name] = jsonValue(*t.TupleElems[i], v.Field(i))
+		}
+		return obj
+	}
+	return scalar(t, v)
+}
+
+// scalar converts a value of an elementary type; integers become decimal
+// strings so that JSON consumers do not lose precision.
+func scalar(t abi.Type, v reflect.Value) interface{} {
+	switch t.T {
+	case abi.IntTy, abi.UintTy:
+		switch n := v.Interface().(type) {
+		case *big.Int:
+			return n.String()
+		default:
+			return fmt.Sprint(n)
+		}
+	case abi.BoolTy:
+		return v.Bool()
+	case abi.StringTy:
+		return v.String()
+	case abi.AddressTy:
+		return v.Interface().(common.Address).Hex()
+	case abi.BytesTy:
+		return hexutil.Encode(v.Bytes())
+	case abi.FixedBytesTy, abi.FunctionTy:
+		b := make([]byte, v.Len())
+		reflect.Copy(reflect.ValueOf(b), v)
+		return hexutil.Encode(b)
+	}
+	return fmt.Sprint(v.Interface())
+}
@@ -0,0 +1,272 @@
+// Package codec converts command-line strings to ABI values and decodes
+// return data, call data, logs and revert reasons for display.
+package codec
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// LoadABI reads a JSON ABI, either bare or as the "abi" field of a
+// Hardhat or Foundry build artifact.
+func LoadABI(path string) (abi.ABI, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return abi.ABI{}, err
+	}
+	var artifact struct {
+		ABI json.RawMessage `json:"abi"`
+	}
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '{' {
+		if err := json.Unmarshal(trimmed, &artifact); err != nil || artifact.ABI == nil {
+			return abi.ABI{}, fmt.Errorf("%s: want a JSON ABI array or an object with an \"abi\" field", path)
+		}
+		data = artifact.ABI
+	}
+	parsed, err := abi.JSON(bytes.NewReader(data))
+	if err != nil {
+		return abi.ABI{}, fmt.Errorf("%s: %w", path, err)
+	}
+	return parsed, nil
+}
+
+// FindMethod looks a method up by name or, for overloaded methods, by
+// signature such as "transfer(address,uint256)".
+func FindMethod(a abi.ABI, name string) (abi.Method, error) {
+	var matches []abi.Method
+	for _, m := range a.Methods {
+		if m.Sig == name || m.RawName == name {
+			matches = append(matches, m)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return abi.Method{}, fmt.Errorf("the ABI has no method %q", name)
+	case 1:
+		return matches[0], nil
+	}
+	sigs := make([]string, len(matches))
+	for i, m := range matches {
+		sigs[i] = m.Sig
+	}
+	return abi.Method{}, fmt.Errorf("%q is overloaded; use one of %s", name, strings.Join(sigs, ", "))
+}
+
+// ParseArgs parses one string per argument into the Go values
+// abi.Arguments.Pack expects.
+func ParseArgs(args abi.Arguments, values []string) ([]interface{}, error) {
+	if len(values) != len(args) {
+		return nil, fmt.Errorf("want %d arguments, got %d", len(args), len(values))
+	}
+	out := make([]interface{}, len(args))
+	for i, arg := range args {
+		v, err := ParseValue(arg.Type, values[i])
+		if err != nil {
+			name := arg.Name
+			if name == "" {
+				name = "#" + strconv.Itoa(i)
+			}
+			return nil, fmt.Errorf("argument %s (%s): %w", name, arg.Type, err)
+		}
+		out[i] = v
+	}
+	return out, nil
+}
+
+// ParseValue parses s as a value of type t. Integers are decimal or 0x
+// hex, bytes are 0x hex, strings may be double-quoted, and arrays and
+// tuples are written [a,b,...] and (a,b,...).
+func ParseValue(t abi.Type, s string) (interface{}, error) {
+	v, err := parseValue(t, strings.TrimSpace(s))
+	if err != nil {
+		return nil, err
+	}
+	return v.Interface(), nil
+}
+
+var bigType = reflect.TypeOf(&big.Int{})
+
+func parseValue(t abi.Type, s string) (reflect.Value, error) {
+	typ := t.GetType()
+	switch t.T {
+	case abi.IntTy, abi.UintTy:
+		n, err := parseInt(t, s)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		if typ == bigType {
+			return reflect.ValueOf(n), nil
+		}
+		v := reflect.New(typ).Elem()
+		if t.T == abi.UintTy {
+			v.SetUint(n.Uint64())
+		} else {
+			v.SetInt(n.Int64())
+		}
+		return v, nil
+	case abi.BoolTy:
+		switch s {
+		case "true":
+			return reflect.ValueOf(true), nil
+		case "false":
+			return reflect.ValueOf(false), nil
+		}
+		return reflect.Value{}, fmt.Errorf("invalid bool %q", s)
+	case abi.StringTy:
+		if strings.HasPrefix(s, `"`) {
+			unquoted, err := strconv.Unquote(s)
+			if err != nil {
+				return reflect.Value{}, fmt.Errorf("invalid quoted string %s", s)
+			}
+			s = unquoted
+		}
+		return reflect.ValueOf(s), nil
+	case abi.AddressTy:
+		addr, err := ParseAddress(s)
+		return reflect.ValueOf(addr), err
+	case abi.BytesTy:
+		b, err := hexutil.Decode(s)
+		if err != nil {
+			return reflect.Value{}, fmt.Errorf("invalid bytes %q: want 0x-prefixed hex", s)
+		}
+		return reflect.ValueOf(b), nil
+	case abi.FixedBytesTy:
+		b, err := hexutil.Decode(s)
+		if err != nil || len(b) != t.Size {
+			return reflect.Value{}, fmt.Errorf("invalid bytes%d %q: want 0x and %d hex digits", t.Size, s, 2*t.Size)
+		}
+		v := reflect.New(typ).Elem()
+		reflect.Copy(v, reflect.ValueOf(b))
+		return v, nil
+	case abi.SliceTy, abi.ArrayTy:
+		elems, err := splitList(s, '[', ']')
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		var v reflect.Value
+		if t.T == abi.ArrayTy {
+			if len(elems) != t.Size {
+				return reflect.Value{}, fmt.Errorf("want %d elements, got %d", t.Size, len(elems))
+			}
+			v = reflect.New(typ).Elem()
+		} else {
+			v = reflect.MakeSlice(typ, len(elems), len(elems))
+		}
+		for i, e := range elems {
+			ev, err := parseValue(*t.Elem, e)
+			if err != nil {
+				return reflect.Value{}, fmt.Errorf("element %d: %w", i, err)
+			}
+			v.Index(i).Set(ev)
+		}
+		return v, nil
+	case abi.TupleTy:
+		elems, err := splitList(s, '(', ')')
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		if len(elems) != len(t.TupleElems) {
+			return reflect.Value{}, fmt.Errorf("want %d tuple fields, got %d", len(t.TupleElems), len(elems))
+		}
+		v := reflect.New(typ).Elem()
+		for i, e := range elems {
+			fv, err := parseValue(*t.TupleElems[i], e)
+			if err != nil {
+				return reflect.Value{}, fmt.Errorf("field %s: %w", t.TupleRawNames[i], err)
+			}
+			v.Field(i).Set(fv)
+		}
+		return v, nil
+	}
+	return reflect.Value{}, fmt.Errorf("arguments of type %s are not supported", t)
+}
+
+func parseInt(t abi.Type, s string) (*big.Int, error) {
+	digits, base := s, 10
+	if strings.HasPrefix(s, "0x") || strings.HasPrefix(s, "0X") {
+		digits, base = s[2:], 16
+	}
+	n, ok := new(big.Int).SetString(digits, base)
+	if !ok {
+		return nil, fmt.Errorf("invalid integer %q", s)
+	}
+	min, max := new(big.Int), new(big.Int).Lsh(big.NewInt(1), uint(t.Size))
+	if t.T == abi.IntTy {
+		max.Rsh(max, 1)
+		min.Neg(max)
+	}
+	if n.Cmp(min) < 0 || n.Cmp(max) >= 0 {
+		return nil, fmt.Errorf("%s is out of range for %s", s, t)
+	}
+	return n, nil
+}
+
+// ParseAddress accepts a 0x-prefixed hex address. Mixed-case addresses
+// must carry a valid EIP-55 checksum; all-lowercase or all-uppercase ones
+// carry none and are accepted as they are. ENS names are not resolved.
+func ParseAddress(s string) (common.Address, error) {
+	if strings.Contains(s, ".") {
+		return common.Address{}, fmt.Errorf("%q: ENS names are not supported, use a 0x address", s)
+	}
+	if !strings.HasPrefix(s, "0x") && !strings.HasPrefix(s, "0X") {
+		return common.Address{}, fmt.Errorf("%q: address must start with 0x", s)
+	}
+	if !common.IsHexAddress(s) {
+		return common.Address{}, fmt.Errorf("%q: address must be 40 hex digits", s)
+	}
+	addr := common.HexToAddress(s)
+	hex := s[2:]
+	if hex != strings.ToLower(hex) && hex != strings.ToUpper(hex) && hex != addr.Hex()[2:] {
+		return common.Address{}, fmt.Errorf("%q: invalid EIP-55 checksum, expected %s", s, addr.Hex())
+	}
+	return addr, nil
+}
+
+// splitList splits "[a, b, c]" into its top-level elements, leaving
+// nested lists and quoted strings whole.
+func splitList(s string, open, close byte) ([]string, error) {
+	if len(s) < 2 || s[0] != open || s[len(s)-1] != close {
+		return nil, fmt.Errorf("invalid list %q: want %c...%c", s, open, close)
+	}
+	inner := strings.TrimSpace(s[1 : len(s)-1])
+	if inner == "" {
+		return nil, nil
+	}
+	var elems []string
+	depth, start, quoted := 0, 0, false
+	for i := 0; i < len(inner); i++ {
+		c := inner[i]
+		switch {
+		case quoted && c == '\\':
+			i++
+		case c == '"':
+			quoted = !quoted
+		case quoted:
+		case c == '[' || c == '(':
+			depth++
+		case c == ']' || c == ')':
+			depth--
+			if depth < 0 {
+				return nil, fmt.Errorf("unbalanced %q", s)
+			}
+		case c == ',' && depth == 0:
+			elems = append(elems, strings.TrimSpace(inner[start:i]))
+			start = i + 1
+		}
+	}
+	if depth != 0 || quoted {
+		return nil, errors.New("unbalanced brackets or quotes in " + strconv.Quote(s))
+	}
+	return append(elems, strings.TrimSpace(inner[start:])), nil
+}
@@ -0,0 +1,130 @@
+//go:generate go run github.com/ethereum/go-ethereum/cmd/abigen@v1.10.26 --abi ERC20.abi --pkg erc20 --type ERC20 --out bindings.go
+
+// Package erc20 reads and writes ERC-20 tokens through the abigen
+// bindings in bindings.go.
+package erc20
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"golang/ethereumcli/units"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/math"
+)
+
+// ErrNoContract is returned by Open when there is no code at the address.
+var ErrNoContract = errors.New("no contract at address")
+
+// MaxAmount is the largest amount, used for unlimited approvals.
+var MaxAmount = math.MaxBig256
+
+// Metadata describes a token.
+type Metadata struct {
+	Address     string `json:"address"`
+	Name        string `json:"name"`
+	Symbol      string `json:"symbol"`
+	Decimals    uint8  `json:"decimals"`
+	TotalSupply string `json:"totalSupply"`
+}
+
+// Token is a deployed ERC-20 contract.
+type Token struct {
+	Address  common.Address
+	contract *ERC20
+}
+
+// Open binds the token at address.
+func Open(ctx context.Context, address common.Address, backend bind.ContractBackend) (*Token, error) {
+	code, err := backend.CodeAt(ctx, address, nil)
+	if err != nil {
+		return nil, err
+	}
+	if len(code) == 0 {
+		return nil, fmt.Errorf("%s: %w", address.Hex(), ErrNoContract)
+	}
+	contract, err := NewERC20(address, backend)
+	if err != nil {
+		return nil, err
+	}
+	return &Token{Address: address, contract: contract}, nil
+}
+
+// Metadata reads the token's name, symbol, decimals and total supply.
+// Name and symbol are optional in ERC-20 and left empty when they can't
+// be read; decimals is optional too, but amounts are meaningless without
+// it.
+func (t *Token) Metadata(ctx context.Context) (Metadata, error) {
+	opts := &bind.CallOpts{Context: ctx}
+	m := Metadata{Address: t.Address.Hex()}
+	var err error
+	if m.Decimals, err = t.Decimals(ctx); err != nil {
+		return m, err
+	}
+	supply, err := t.contract.TotalSupply(opts)
+	if err != nil {
+		return m, fmt.Errorf("totalSupply: %w", err)
+	}
+	m.TotalSupply = supply.String()
+	m.Name, _ = t.contract.Name(opts)
+	m.Symbol, _ = t.contract.Symbol(opts)
+	return m, nil
+}
+
+// Decimals returns the number of decimals amounts are shown with.
+func (t *Token) Decimals(ctx context.Context) (uint8, error) {
+	d, err := t.contract.Decimals(&bind.CallOpts{Context: ctx})
+	if err != nil {
+		return 0, fmt.Errorf("%s does not look like an ERC-20 token: decimals: %w", t.Address.Hex(), err)
+	}
+	return d, nil
+}
+
+// BalanceOf returns owner's balance in the token's smallest unit.
+func (t *Token) BalanceOf(ctx context.Context, owner common.Address) (*big.Int, error) {
+	return t.contract.BalanceOf(&bind.CallOpts{Context: ctx}, owner)
+}
+
+// Allowance returns how much spender may still transfer from owner.
+func (t *Token) Allowance(ctx context.Context, owner, spender common.Address) (*big.Int, error) {
+	return t.contract.Allowance(&bind.CallOpts{Context: ctx}, owner, spender)
+}
+
+// TransferData is the call data of transfer(to, amount).
+func TransferData(to common.Address, amount *big.Int) ([]byte, error) {
+	return pack("transfer", to, amount)
+}
+
+// ApproveData is the call data of approve(spender, amount).
+func ApproveData(spender common.Address, amount *big.Int) ([]byte, error) {
+	return pack("approve", spender, amount)
+}
+
+func pack(method string, args ...interface{}) ([]byte, error) {
+	parsed, err := ERC20MetaData.GetAbi()
+	if err != nil {
+		return nil, err
+	}
+	return parsed.Pack(method, args...)
+}
+
+// ParseAmount parses a decimal amount of a token with the given decimals.
+// "max" is MaxAmount.
+func ParseAmount(s string, decimals uint8) (*big.Int, error) {
+	if strings.EqualFold(s, "max") {
+		return new(big.Int).Set(MaxAmount), nil
+	}
+	return units.Parse(s, int(decimals))
+}
+
+// FormatAmount formats an amount of a token with the given decimals.
+func FormatAmount(amount *big.Int, decimals uint8) string {
+	if amount != nil && amount.Cmp(MaxAmount) == 0 {
+		return "max"
+	}
+	return units.Format(amount, int(decimals))
+}
@@ -0,0 +1,161 @@
+package erc20
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind/backends"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testChain struct {
+	*backends.SimulatedBackend
+	t     *testing.T
+	keys  []*ecdsa.PrivateKey
+	token common.Address
+}
+
+// newTestChain funds accounts and deploys the test token from the first.
+func newTestChain(t *testing.T, accounts int) *testChain {
+	c := &testChain{t: t}
+	alloc := core.GenesisAlloc{}
+	for i := 0; i < accounts; i++ {
+		key, err := crypto.GenerateKey()
+		require.NoError(t, err)
+		c.keys = append(c.keys, key)
+		alloc[crypto.PubkeyToAddress(key.PublicKey)] = core.GenesisAccount{Balance: big.NewInt(params.Ether)}
+	}
+	c.SimulatedBackend = backends.NewSimulatedBackend(alloc, 30_000_000)
+	t.Cleanup(func() { c.Close() })
+
+	parsed, err := ERC20MetaData.GetAbi()
+	require.NoError(t, err)
+	addr, _, _, err := bind.DeployContract(c.opts(0), *parsed, testTokenCode(), c)
+	require.NoError(t, err)
+	c.Commit()
+	c.token = addr
+	return c
+}
+
+func (c *testChain) addr(i int) common.Address {
+	return crypto.PubkeyToAddress(c.keys[i].PublicKey)
+}
+
+func (c *testChain) opts(i int) *bind.TransactOpts {
+	opts, err := bind.NewKeyedTransactorWithChainID(c.keys[i], big.NewInt(1337))
+	require.NoError(c.t, err)
+	return opts
+}
+
+// mined commits tx and checks it succeeded.
+func (c *testChain) mined(tx *types.Transaction, err error) *types.Receipt {
+	c.t.Helper()
+	require.NoError(c.t, err)
+	c.Commit()
+	receipt, err := c.TransactionReceipt(context.Background(), tx.Hash())
+	require.NoError(c.t, err)
+	require.Equal(c.t, types.ReceiptStatusSuccessful, receipt.Status)
+	return receipt
+}
+
+func TestMetadata(t *testing.T) {
+	ctx := context.Background()
+	c := newTestChain(t, 1)
+	token, err := Open(ctx, c.token, c)
+	require.NoError(t, err)
+	m, err := token.Metadata(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, Metadata{
+		Address:     c.token.Hex(),
+		Name:        testName,
+		Symbol:      testSymbol,
+		Decimals:    testDecimals,
+		TotalSupply: testSupply.String(),
+	}, m)
+
+	_, err = Open(ctx, c.addr(0), c)
+	assert.ErrorIs(t, err, ErrNoContract)
+}
+
+func TestTransferAndAllowance(t *testing.T) {
+	ctx := context.Background()
+	c := newTestChain(t, 3)
+	alice, bob, carol := c.addr(0), c.addr(1), c.addr(2)
+	token, err := Open(ctx, c.token, c)
+	require.NoError(t, err)
+	binding, err := NewERC20(c.token, c)
+	require.NoError(t, err)
+
+	amount, err := ParseAmount("2.5", testDecimals)
+	require.NoError(t, err)
+	receipt := c.mined(binding.Transfer(c.opts(0), bob, amount))
+	require.Len(t, receipt.Logs, 1)
+	ev, err := binding.ParseTransfer(*receipt.Logs[0])
+	require.NoError(t, err)
+	assert.Equal(t, alice, ev.From)
+	assert.Equal(t, bob, ev.To)
+	assert.Equal(t, amount, ev.Value)
+
+	balance, err := token.BalanceOf(ctx, bob)
+	require.NoError(t, err)
+	assert.Equal(t, "2.5", FormatAmount(balance, testDecimals))
+	balance, err = token.BalanceOf(ctx, alice)
+	require.NoError(t, err)
+	assert.Equal(t, "999997.5", FormatAmount(balance, testDecimals))
+
+	// Bob lets Carol spend 1 TST, and she spends 0.4 of it.
+	one, _ := ParseAmount("1", testDecimals)
+	receipt = c.mined(binding.Approve(c.opts(1), carol, one))
+	approval, err := binding.ParseApproval(*receipt.Logs[0])
+	require.NoError(t, err)
+	assert.Equal(t, bob, approval.Owner)
+	assert.Equal(t, carol, approval.Spender)
+	c.mined(binding.TransferFrom(c.opts(2), bob, carol, big.NewInt(400_000)))
+	allowance, err := token.Allowance(ctx, bob, carol)
+	require.NoError(t, err)
+	assert.Equal(t, "0.6", FormatAmount(allowance, testDecimals))
+	balance, err = token.BalanceOf(ctx, carol)
+	require.NoError(t, err)
+	assert.Equal(t, int64(400_000), balance.Int64())
+
+	// Overspending the allowance or the balance reverts.
+	opts := c.opts(2)
+	opts.GasLimit = 100_000
+	tx, err := binding.TransferFrom(opts, bob, carol, one)
+	require.NoError(t, err)
+	c.Commit()
+	failed, err := c.TransactionReceipt(ctx, tx.Hash())
+	require.NoError(t, err)
+	assert.Equal(t, types.ReceiptStatusFailed, failed.Status)
+	_, err = binding.Transfer(c.opts(1), carol, testSupply)
+	assert.Error(t, err, "gas estimation fails on a revert")
+
+	// The packed call data is what the binding sends.
+	data, err := TransferData(carol, big.NewInt(1))
+	require.NoError(t, err)
+	opts = c.opts(1)
+	opts.NoSend = true
+	tx, err = binding.Transfer(opts, carol, big.NewInt(1))
+	require.NoError(t, err)
+	assert.Equal(t, tx.Data(), data)
+}
+
+func TestAmounts(t *testing.T) {
+	max, err := ParseAmount("MAX", 18)
+	require.NoError(t, err)
+	assert.Equal(t, "max", FormatAmount(max, 18))
+	_, err = ParseAmount("0.0000001", 6)
+	assert.ErrorContains(t, err, "more than 6 decimals")
+	v, err := ParseAmount("12", 0)
+	require.NoError(t, err)
+	assert.Equal(t, "12", FormatAmount(v, 0))
+}
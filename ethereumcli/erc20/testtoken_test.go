@@ -0,0 +1,200 @@
+package erc20
+
+import (
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// The test token is a minimal ERC-20 assembled here so the tests don't
+// need solc. Its constructor mints testSupply to the deployer. Balances
+// are stored at the owner's address, allowances at
+// keccak256(owner . spender) and the total supply at 2^160. It has no
+// overflow checks beyond what a fixed supply makes unnecessary.
+const (
+	testName     = "Test Token"
+	testSymbol   = "TST"
+	testDecimals = 6
+)
+
+var (
+	testSupply    = new(big.Int).Mul(big.NewInt(1_000_000), big.NewInt(1_000_000))
+	supplySlot    = new(big.Int).Lsh(big.NewInt(1), 160).Bytes()
+	addressMask   = common.HexToAddress("0xffffffffffffffffffffffffffffffffffffffff").Bytes()
+	transferTopic = crypto.Keccak256([]byte("Transfer(address,address,uint256)"))
+	approvalTopic = crypto.Keccak256([]byte("Approval(address,address,uint256)"))
+)
+
+// asm is a tiny EVM assembler with labels. Jump targets are pushed as
+// two-byte immediates and patched by bytes.
+type asm struct {
+	code   []byte
+	labels map[string]int
+	fixups map[int]string
+}
+
+func newAsm() *asm {
+	return &asm{labels: map[string]int{}, fixups: map[int]string{}}
+}
+
+func (a *asm) op(ops ...vm.OpCode) *asm {
+	for _, op := range ops {
+		a.code = append(a.code, byte(op))
+	}
+	return a
+}
+
+// push pushes v with the shortest PUSH that fits it.
+func (a *asm) push(v []byte) *asm {
+	v = new(big.Int).SetBytes(v).Bytes()
+	if len(v) == 0 {
+		v = []byte{0}
+	}
+	a.code = append(a.code, byte(vm.PUSH1)+byte(len(v)-1))
+	a.code = append(a.code, v...)
+	return a
+}
+
+func (a *asm) pushInt(v int64) *asm {
+	return a.push(big.NewInt(v).Bytes())
+}
+
+func (a *asm) pushLabel(name string) *asm {
+	a.code = append(a.code, byte(vm.PUSH2))
+	a.fixups[len(a.code)] = name
+	a.code = append(a.code, 0, 0)
+	return a
+}
+
+func (a *asm) label(name string) *asm {
+	a.labels[name] = len(a.code)
+	return a.op(vm.JUMPDEST)
+}
+
+func (a *asm) jump(name string) *asm {
+	return a.pushLabel(name).op(vm.JUMP)
+}
+
+func (a *asm) jumpi(name string) *asm {
+	return a.pushLabel(name).op(vm.JUMPI)
+}
+
+// arg pushes the address or word argument at index i.
+func (a *asm) arg(i int64, isAddress bool) *asm {
+	a.pushInt(4 + 32*i).op(vm.CALLDATALOAD)
+	if isAddress {
+		a.push(addressMask).op(vm.AND)
+	}
+	return a
+}
+
+func (a *asm) bytes() []byte {
+	for at, name := range a.fixups {
+		target, ok := a.labels[name]
+		if !ok {
+			panic("undefined label " + name)
+		}
+		a.code[at], a.code[at+1] = byte(target>>8), byte(target)
+	}
+	return a.code
+}
+
+// returnString returns s ABI-encoded; s must fit in one word.
+func (a *asm) returnString(s string) *asm {
+	a.pushInt(32).pushInt(0).op(vm.MSTORE)
+	a.pushInt(int64(len(s))).pushInt(32).op(vm.MSTORE)
+	a.push(common.RightPadBytes([]byte(s), 32)).pushInt(64).op(vm.MSTORE)
+	return a.pushInt(96).pushInt(0).op(vm.RETURN)
+}
+
+func testTokenRuntime() []byte {
+	a := newAsm()
+	methods := []struct {
+		signature string
+		label     string
+	}{
+		{"name()", "name"},
+		{"symbol()", "symbol"},
+		{"decimals()", "decimals"},
+		{"totalSupply()", "totalSupply"},
+		{"balanceOf(address)", "balanceOf"},
+		{"allowance(address,address)", "allowance"},
+		{"transfer(address,uint256)", "transfer"},
+		{"approve(address,uint256)", "approve"},
+		{"transferFrom(address,address,uint256)", "transferFrom"},
+	}
+	a.pushInt(0).op(vm.CALLDATALOAD).pushInt(0xe0).op(vm.SHR)
+	for _, m := range methods {
+		a.op(vm.DUP1).push(crypto.Keccak256([]byte(m.signature))[:4]).op(vm.EQ).jumpi(m.label)
+	}
+	a.label("revert").pushInt(0).op(vm.DUP1, vm.REVERT)
+
+	a.label("returnWord") // value
+	a.pushInt(0).op(vm.MSTORE).pushInt(32).pushInt(0).op(vm.RETURN)
+	a.label("returnTrue")
+	a.pushInt(1).jump("returnWord")
+
+	a.label("name").returnString(testName)
+	a.label("symbol").returnString(testSymbol)
+	a.label("decimals").pushInt(testDecimals).jump("returnWord")
+	a.label("totalSupply").push(supplySlot).op(vm.SLOAD).jump("returnWord")
+	a.label("balanceOf").arg(0, true).op(vm.SLOAD).jump("returnWord")
+
+	a.label("allowance")
+	a.arg(0, true).pushInt(0).op(vm.MSTORE)
+	a.arg(1, true).pushInt(32).op(vm.MSTORE)
+	a.pushInt(64).pushInt(0).op(vm.KECCAK256, vm.SLOAD).jump("returnWord")
+
+	a.label("transfer")
+	a.pushLabel("returnTrue").arg(1, false).arg(0, true).op(vm.CALLER).jump("move")
+
+	a.label("approve")
+	a.arg(1, false)                                                  // amount
+	a.op(vm.CALLER).pushInt(0).op(vm.MSTORE)                         // mem[0] = owner
+	a.arg(0, true).pushInt(32).op(vm.MSTORE)                         // mem[32] = spender
+	a.op(vm.DUP1).pushInt(64).pushInt(0).op(vm.KECCAK256, vm.SSTORE) // amount
+	a.pushInt(64).op(vm.MSTORE)
+	a.pushInt(32).op(vm.MLOAD).pushInt(0).op(vm.MLOAD).push(approvalTopic).pushInt(32).pushInt(64).op(vm.LOG3)
+	a.jump("returnTrue")
+
+	a.label("transferFrom")
+	a.arg(0, true).pushInt(0).op(vm.MSTORE)
+	a.op(vm.CALLER).pushInt(32).op(vm.MSTORE)
+	a.pushInt(64).pushInt(0).op(vm.KECCAK256)     // key
+	a.op(vm.DUP1, vm.SLOAD).arg(2, false)         // amount allowed key
+	a.op(vm.DUP2, vm.DUP2, vm.GT).jumpi("revert") // amount allowed key
+	a.op(vm.SWAP1, vm.SUB, vm.SWAP1, vm.SSTORE)
+	a.pushLabel("returnTrue").arg(2, false).arg(1, true).arg(0, true).jump("move")
+
+	// move takes from, to, amount and a return label off the stack,
+	// moves the tokens and logs Transfer.
+	a.label("move")                                                  // from to amount ret
+	a.op(vm.DUP1, vm.SLOAD, vm.DUP4, vm.DUP2, vm.LT).jumpi("revert") // balance from to amount ret
+	a.op(vm.DUP4, vm.SWAP1, vm.SUB, vm.DUP2, vm.SSTORE)              // from to amount ret
+	a.op(vm.DUP2, vm.SLOAD, vm.DUP4, vm.ADD, vm.DUP3, vm.SSTORE)
+	a.op(vm.DUP3).pushInt(0).op(vm.MSTORE)
+	a.op(vm.DUP2, vm.DUP2).push(transferTopic).pushInt(32).pushInt(0).op(vm.LOG3)
+	a.op(vm.POP, vm.POP, vm.POP, vm.JUMP)
+	return a.bytes()
+}
+
+// testTokenCode is the test token's creation code.
+func testTokenCode() []byte {
+	runtime := testTokenRuntime()
+	a := newAsm()
+	a.push(testSupply.Bytes()).op(vm.DUP1, vm.CALLER, vm.SSTORE)
+	a.push(supplySlot).op(vm.SSTORE)
+	a.push(testSupply.Bytes()).pushInt(0).op(vm.MSTORE)
+	a.op(vm.CALLER).pushInt(0).push(transferTopic).pushInt(32).pushInt(0).op(vm.LOG3)
+	// The runtime code follows this constructor, whose length is fixed.
+	a.code = append(a.code, byte(vm.PUSH2), byte(len(runtime)>>8), byte(len(runtime)))
+	offset := len(a.code)
+	a.code = append(a.code, byte(vm.PUSH2), 0, 0)
+	a.pushInt(0).op(vm.CODECOPY)
+	a.code = append(a.code, byte(vm.PUSH2), byte(len(runtime)>>8), byte(len(runtime)))
+	a.pushInt(0).op(vm.RETURN)
+	a.code[offset+1], a.code[offset+2] = byte(len(a.code)>>8), byte(len(a.code))
+	return append(a.code, runtime...)
+}
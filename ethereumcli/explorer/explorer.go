@@ -0,0 +1,313 @@
+// Package explorer reads blocks, transactions and receipts from a node and
+// turns them into views fit for printing or JSON output.
+package explorer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// Backend is the part of ethclient.Client the explorer reads from. The
+// simulated backend of go-ethereum implements it too.
+type Backend interface {
+	BlockByHash(ctx context.Context, hash common.Hash) (*types.Block, error)
+	BlockByNumber(ctx context.Context, number *big.Int) (*types.Block, error)
+	HeaderByHash(ctx context.Context, hash common.Hash) (*types.Header, error)
+	TransactionByHash(ctx context.Context, hash common.Hash) (tx *types.Transaction, isPending bool, err error)
+	TransactionReceipt(ctx context.Context, hash common.Hash) (*types.Receipt, error)
+}
+
+// Names of the transaction types.
+var typeNames = map[uint8]string{
+	types.LegacyTxType:     "legacy",
+	types.AccessListTxType: "eip2930",
+	types.DynamicFeeTxType: "eip1559",
+}
+
+// Block is a block header with its transaction hashes or, when fetched
+// in full, its transactions. Amounts are decimal strings of wei.
+type Block struct {
+	Number       uint64        `json:"number"`
+	Hash         string        `json:"hash"`
+	ParentHash   string        `json:"parent_hash"`
+	Time         time.Time     `json:"time"`
+	Miner        string        `json:"miner"`
+	GasUsed      uint64        `json:"gas_used"`
+	GasLimit     uint64        `json:"gas_limit"`
+	BaseFee      string        `json:"base_fee,omitempty"`
+	Size         uint64        `json:"size"`
+	TxHashes     []string      `json:"tx_hashes,omitempty"`
+	Transactions []Transaction `json:"transactions,omitempty"`
+}
+
+// Transaction is a decoded transaction of any type. Fee fields that do
+// not apply to the type are left out.
+type Transaction struct {
+	Hash                 string        `json:"hash"`
+	Type                 uint8         `json:"type"`
+	TypeName             string        `json:"type_name"`
+	ChainID              string        `json:"chain_id,omitempty"`
+	From                 string        `json:"from"`
+	To                   string        `json:"to,omitempty"`
+	Nonce                uint64        `json:"nonce"`
+	Value                string        `json:"value"`
+	Gas                  uint64        `json:"gas"`
+	GasPrice             string        `json:"gas_price,omitempty"`
+	MaxFeePerGas         string        `json:"max_fee_per_gas,omitempty"`
+	MaxPriorityFeePerGas string        `json:"max_priority_fee_per_gas,omitempty"`
+	AccessList           []AccessTuple `json:"access_list,omitempty"`
+	Input                string        `json:"input"`
+	Pending              bool          `json:"pending"`
+	// Inclusion, when the transaction is mined and its receipt was read.
+	BlockNumber *uint64 `json:"block_number,omitempty"`
+	BlockHash   string  `json:"block_hash,omitempty"`
+	Index       *uint   `json:"index,omitempty"`
+}
+
+type AccessTuple struct {
+	Address     string   `json:"address"`
+	StorageKeys []string `json:"storage_keys"`
+}
+
+// Receipt is the outcome of a mined transaction.
+type Receipt struct {
+	TxHash            string `json:"tx_hash"`
+	Type              uint8  `json:"type"`
+	Status            string `json:"status"`
+	BlockNumber       uint64 `json:"block_number"`
+	BlockHash         string `json:"block_hash"`
+	Index             uint   `json:"index"`
+	From              string `json:"from"`
+	To                string `json:"to,omitempty"`
+	ContractAddress   string `json:"contract_address,omitempty"`
+	GasLimit          uint64 `json:"gas_limit"`
+	GasUsed           uint64 `json:"gas_used"`
+	CumulativeGasUsed uint64 `json:"cumulative_gas_used"`
+	EffectiveGasPrice string `json:"effective_gas_price"`
+	// Fee is GasUsed times EffectiveGasPrice.
+	Fee  string `json:"fee"`
+	Logs []Log  `json:"logs"`
+}
+
+type Log struct {
+	Index   uint     `json:"index"`
+	Address string   `json:"address"`
+	Topics  []string `json:"topics"`
+	Data    string   `json:"data"`
+}
+
+// BlockByNumber fetches a block; a nil number is the latest block.
+func BlockByNumber(ctx context.Context, b Backend, number *big.Int, full bool) (*Block, error) {
+	block, err := b.BlockByNumber(ctx, number)
+	if err != nil {
+		return nil, notFound(err, "block %s", blockName(number))
+	}
+	return newBlock(block, full)
+}
+
+func BlockByHash(ctx context.Context, b Backend, hash common.Hash, full bool) (*Block, error) {
+	block, err := b.BlockByHash(ctx, hash)
+	if err != nil {
+		return nil, notFound(err, "block %s", hash.Hex())
+	}
+	return newBlock(block, full)
+}
+
+// TransactionByHash fetches a transaction and, once it is mined, where it
+// was included.
+func TransactionByHash(ctx context.Context, b Backend, hash common.Hash) (*Transaction, error) {
+	tx, pending, err := b.TransactionByHash(ctx, hash)
+	if err != nil {
+		return nil, notFound(err, "transaction %s", hash.Hex())
+	}
+	out, err := newTransaction(tx)
+	if err != nil {
+		return nil, err
+	}
+	out.Pending = pending
+	if pending {
+		return out, nil
+	}
+	receipt, err := b.TransactionReceipt(ctx, hash)
+	if err != nil {
+		return nil, notFound(err, "receipt of %s", hash.Hex())
+	}
+	n := receipt.BlockNumber.Uint64()
+	idx := receipt.TransactionIndex
+	out.BlockNumber, out.BlockHash, out.Index = &n, receipt.BlockHash.Hex(), &idx
+	return out, nil
+}
+
+// ReceiptByHash fetches the receipt of a mined transaction together with
+// the transaction and the block's base fee, which the effective gas price
+// of EIP-1559 transactions depends on.
+func ReceiptByHash(ctx context.Context, b Backend, hash common.Hash) (*Receipt, error) {
+	receipt, err := b.TransactionReceipt(ctx, hash)
+	if err != nil {
+		return nil, notFound(err, "receipt of %s", hash.Hex())
+	}
+	tx, _, err := b.TransactionByHash(ctx, hash)
+	if err != nil {
+		return nil, notFound(err, "transaction %s", hash.Hex())
+	}
+	header, err := b.HeaderByHash(ctx, receipt.BlockHash)
+	if err != nil {
+		return nil, notFound(err, "block %s", receipt.BlockHash.Hex())
+	}
+	from, err := sender(tx)
+	if err != nil {
+		return nil, err
+	}
+	price := EffectiveGasPrice(tx, header.BaseFee)
+	out := &Receipt{
+		TxHash:            receipt.TxHash.Hex(),
+		Type:              receipt.Type,
+		Status:            "success",
+		BlockNumber:       receipt.BlockNumber.Uint64(),
+		BlockHash:         receipt.BlockHash.Hex(),
+		Index:             receipt.TransactionIndex,
+		From:              from.Hex(),
+		GasLimit:          tx.Gas(),
+		GasUsed:           receipt.GasUsed,
+		CumulativeGasUsed: receipt.CumulativeGasUsed,
+		EffectiveGasPrice: price.String(),
+		Fee:               new(big.Int).Mul(price, new(big.Int).SetUint64(receipt.GasUsed)).String(),
+		Logs:              []Log{},
+	}
+	if receipt.Status != types.ReceiptStatusSuccessful {
+		out.Status = "failed"
+	}
+	if tx.To() != nil {
+		out.To = tx.To().Hex()
+	}
+	if receipt.ContractAddress != (common.Address{}) {
+		out.ContractAddress = receipt.ContractAddress.Hex()
+	}
+	for _, l := range receipt.Logs {
+		topics := make([]string, len(l.Topics))
+		for i, t := range l.Topics {
+			topics[i] = t.Hex()
+		}
+		out.Logs = append(out.Logs, Log{Index: l.Index, Address: l.Address.Hex(), Topics: topics, Data: hexutil.Encode(l.Data)})
+	}
+	return out, nil
+}
+
+// EffectiveGasPrice is what a transaction paid per gas in a block with
+// the given base fee: the gas price of legacy and EIP-2930 transactions,
+// and the base fee plus the capped tip of EIP-1559 ones.
+func EffectiveGasPrice(tx *types.Transaction, baseFee *big.Int) *big.Int {
+	if tx.Type() != types.DynamicFeeTxType || baseFee == nil {
+		return new(big.Int).Set(tx.GasPrice())
+	}
+	price := new(big.Int).Add(baseFee, tx.GasTipCap())
+	if price.Cmp(tx.GasFeeCap()) > 0 {
+		price.Set(tx.GasFeeCap())
+	}
+	return price
+}
+
+func newBlock(block *types.Block, full bool) (*Block, error) {
+	out := &Block{
+		Number:     block.NumberU64(),
+		Hash:       block.Hash().Hex(),
+		ParentHash: block.ParentHash().Hex(),
+		Time:       time.Unix(int64(block.Time()), 0).UTC(),
+		Miner:      block.Coinbase().Hex(),
+		GasUsed:    block.GasUsed(),
+		GasLimit:   block.GasLimit(),
+		Size:       uint64(block.Size()),
+	}
+	if block.BaseFee() != nil {
+		out.BaseFee = block.BaseFee().String()
+	}
+	for i, tx := range block.Transactions() {
+		if !full {
+			out.TxHashes = append(out.TxHashes, tx.Hash().Hex())
+			continue
+		}
+		t, err := newTransaction(tx)
+		if err != nil {
+			return nil, err
+		}
+		n, idx := out.Number, uint(i)
+		t.BlockNumber, t.BlockHash, t.Index = &n, out.Hash, &idx
+		out.Transactions = append(out.Transactions, *t)
+	}
+	return out, nil
+}
+
+func newTransaction(tx *types.Transaction) (*Transaction, error) {
+	from, err := sender(tx)
+	if err != nil {
+		return nil, err
+	}
+	out := &Transaction{
+		Hash:     tx.Hash().Hex(),
+		Type:     tx.Type(),
+		TypeName: typeNames[tx.Type()],
+		From:     from.Hex(),
+		Nonce:    tx.Nonce(),
+		Value:    tx.Value().String(),
+		Gas:      tx.Gas(),
+		Input:    hexutil.Encode(tx.Data()),
+	}
+	if tx.Protected() {
+		out.ChainID = tx.ChainId().String()
+	}
+	if tx.To() != nil {
+		out.To = tx.To().Hex()
+	}
+	if tx.Type() == types.DynamicFeeTxType {
+		out.MaxFeePerGas = tx.GasFeeCap().String()
+		out.MaxPriorityFeePerGas = tx.GasTipCap().String()
+	} else {
+		out.GasPrice = tx.GasPrice().String()
+	}
+	for _, t := range tx.AccessList() {
+		keys := make([]string, len(t.StorageKeys))
+		for i, k := range t.StorageKeys {
+			keys[i] = k.Hex()
+		}
+		out.AccessList = append(out.AccessList, AccessTuple{Address: t.Address.Hex(), StorageKeys: keys})
+	}
+	return out, nil
+}
+
+// sender recovers the signer of tx. Legacy transactions from before
+// EIP-155 carry no chain ID and are checked with the Homestead rules.
+func sender(tx *types.Transaction) (common.Address, error) {
+	var signer types.Signer = types.HomesteadSigner{}
+	if tx.Protected() {
+		signer = types.LatestSignerForChainID(tx.ChainId())
+	}
+	from, err := types.Sender(signer, tx)
+	if err != nil {
+		return common.Address{}, fmt.Errorf("recover sender of %s: %w", tx.Hash().Hex(), err)
+	}
+	return from, nil
+}
+
+func blockName(number *big.Int) string {
+	if number == nil {
+		return "latest"
+	}
+	return number.String()
+}
+
+// notFound words ethereum.NotFound for the object that was looked up.
+func notFound(err error, format string, args ...any) error {
+	what := fmt.Sprintf(format, args...)
+	if errors.Is(err, ethereum.NotFound) {
+		return fmt.Errorf("%s not found", what)
+	}
+	return fmt.Errorf("%s: %w", what, err)
+}
@@ -0,0 +1,136 @@
+package explorer
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind/backends"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	chainID = big.NewInt(1337)
+	gwei    = big.NewInt(params.GWei)
+	// logTopic is emitted by logInitCode.
+	logTopic = common.HexToHash("0x1111111111111111111111111111111111111111111111111111111111111111")
+	// logInitCode stores 42 in memory and emits it with logTopic, then
+	// deploys an empty contract.
+	logInitCode = append(append([]byte{0x60, 0x2a, 0x60, 0x00, 0x52, 0x7f}, logTopic.Bytes()...), 0x60, 0x20, 0x60, 0x00, 0xa1, 0x00)
+)
+
+type chain struct {
+	t       *testing.T
+	backend *backends.SimulatedBackend
+	key     *ecdsa.PrivateKey
+	from    common.Address
+	nonce   uint64
+}
+
+func newChain(t *testing.T) *chain {
+	key, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	from := crypto.PubkeyToAddress(key.PublicKey)
+	backend := backends.NewSimulatedBackend(core.GenesisAlloc{
+		from: {Balance: new(big.Int).Mul(big.NewInt(100), big.NewInt(params.Ether))},
+	}, 30_000_000)
+	t.Cleanup(func() { backend.Close() })
+	return &chain{t: t, backend: backend, key: key, from: from}
+}
+
+func (c *chain) send(data types.TxData) *types.Transaction {
+	c.t.Helper()
+	tx, err := types.SignNewTx(c.key, types.LatestSignerForChainID(chainID), data)
+	require.NoError(c.t, err)
+	require.NoError(c.t, c.backend.SendTransaction(context.Background(), tx))
+	c.nonce++
+	return tx
+}
+
+func TestExplorer(t *testing.T) {
+	ctx := context.Background()
+	c := newChain(t)
+	to := common.HexToAddress("0x00000000000000000000000000000000000000AA")
+	price := new(big.Int).Mul(big.NewInt(10), gwei)
+
+	legacy := c.send(&types.LegacyTx{Nonce: c.nonce, To: &to, Value: big.NewInt(1), Gas: 21000, GasPrice: price})
+	accessList := c.send(&types.AccessListTx{ChainID: chainID, Nonce: c.nonce, To: &to, Value: big.NewInt(2), Gas: 30000, GasPrice: price,
+		AccessList: types.AccessList{{Address: to, StorageKeys: []common.Hash{{1}}}}})
+	dynamic := c.send(&types.DynamicFeeTx{ChainID: chainID, Nonce: c.nonce, Value: big.NewInt(0), Gas: 100000,
+		GasTipCap: big.NewInt(2 * params.GWei), GasFeeCap: price, Data: logInitCode})
+	c.backend.Commit()
+
+	block, err := BlockByNumber(ctx, c.backend, nil, true)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(1), block.Number)
+	require.Len(t, block.Transactions, 3)
+	assert.Equal(t, []string{"legacy", "eip2930", "eip1559"},
+		[]string{block.Transactions[0].TypeName, block.Transactions[1].TypeName, block.Transactions[2].TypeName})
+	assert.NotEmpty(t, block.BaseFee)
+
+	byHash, err := BlockByHash(ctx, c.backend, common.HexToHash(block.Hash), false)
+	require.NoError(t, err)
+	assert.Equal(t, []string{legacy.Hash().Hex(), accessList.Hash().Hex(), dynamic.Hash().Hex()}, byHash.TxHashes)
+
+	tx, err := TransactionByHash(ctx, c.backend, accessList.Hash())
+	require.NoError(t, err)
+	assert.Equal(t, c.from.Hex(), tx.From)
+	assert.Equal(t, to.Hex(), tx.To)
+	assert.Equal(t, "2", tx.Value)
+	assert.Equal(t, "10000000000", tx.GasPrice)
+	assert.Equal(t, "1337", tx.ChainID)
+	require.Len(t, tx.AccessList, 1)
+	assert.Equal(t, common.Hash{1}.Hex(), tx.AccessList[0].StorageKeys[0])
+	require.NotNil(t, tx.Index)
+	assert.Equal(t, uint(1), *tx.Index)
+	assert.Equal(t, uint64(1), *tx.BlockNumber)
+
+	tx, err = TransactionByHash(ctx, c.backend, dynamic.Hash())
+	require.NoError(t, err)
+	assert.Empty(t, tx.GasPrice)
+	assert.Equal(t, "2000000000", tx.MaxPriorityFeePerGas)
+	assert.Empty(t, tx.To)
+
+	receipt, err := ReceiptByHash(ctx, c.backend, legacy.Hash())
+	require.NoError(t, err)
+	assert.Equal(t, "success", receipt.Status)
+	assert.Equal(t, uint64(21000), receipt.GasUsed)
+	assert.Equal(t, "10000000000", receipt.EffectiveGasPrice)
+	assert.Equal(t, "210000000000000", receipt.Fee)
+	assert.Empty(t, receipt.Logs)
+
+	receipt, err = ReceiptByHash(ctx, c.backend, dynamic.Hash())
+	require.NoError(t, err)
+	assert.Equal(t, crypto.CreateAddress(c.from, 2).Hex(), receipt.ContractAddress)
+	baseFee, _ := new(big.Int).SetString(block.BaseFee, 10)
+	assert.Equal(t, new(big.Int).Add(baseFee, big.NewInt(2*params.GWei)).String(), receipt.EffectiveGasPrice)
+	require.Len(t, receipt.Logs, 1)
+	assert.Equal(t, []string{logTopic.Hex()}, receipt.Logs[0].Topics)
+	assert.Equal(t, "0x000000000000000000000000000000000000000000000000000000000000002a", receipt.Logs[0].Data)
+	assert.Equal(t, receipt.ContractAddress, receipt.Logs[0].Address)
+	assert.Equal(t, uint(0), receipt.Logs[0].Index)
+}
+
+func TestNotFound(t *testing.T) {
+	ctx := context.Background()
+	c := newChain(t)
+	_, err := TransactionByHash(ctx, c.backend, common.Hash{1})
+	assert.EqualError(t, err, "transaction "+common.Hash{1}.Hex()+" not found")
+	_, err = BlockByNumber(ctx, c.backend, big.NewInt(99), false)
+	assert.ErrorContains(t, err, "block 99")
+}
+
+func TestEffectiveGasPrice(t *testing.T) {
+	tx := types.NewTx(&types.DynamicFeeTx{GasTipCap: big.NewInt(5), GasFeeCap: big.NewInt(12)})
+	assert.Equal(t, int64(10), EffectiveGasPrice(tx, big.NewInt(5)).Int64())
+	assert.Equal(t, int64(12), EffectiveGasPrice(tx, big.NewInt(9)).Int64(), "capped at the fee cap")
+	legacy := types.NewTx(&types.LegacyTx{GasPrice: big.NewInt(7)})
+	assert.Equal(t, int64(7), EffectiveGasPrice(legacy, big.NewInt(5)).Int64())
+}
@@ -0,0 +1,334 @@
+// Package indexer copies the logs of chosen contracts and topics into a
+// local database, following the chain head and undoing reorgs.
+package indexer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"golang/ethereumcli/codec"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// Defaults for Config.
+const (
+	DefaultChunk        = 2000
+	DefaultPollInterval = 12 * time.Second
+)
+
+// growAfter is how many ranges in a row must succeed before a chunk that
+// was halved is doubled again.
+const growAfter = 4
+
+// Backend is the part of ethclient.Client the indexer uses.
+type Backend interface {
+	HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error)
+	FilterLogs(ctx context.Context, q ethereum.FilterQuery) ([]types.Log, error)
+	SubscribeNewHead(ctx context.Context, ch chan<- *types.Header) (ethereum.Subscription, error)
+}
+
+// Config says what to index and how.
+type Config struct {
+	// Addresses and Topics filter logs like eth_getLogs does; at least
+	// one should be set.
+	Addresses []common.Address
+	Topics    [][]common.Hash
+	// ABI, if set, decodes the events it knows.
+	ABI *abi.ABI
+	// From is the first block indexed into an empty database.
+	From uint64
+	// Confirmations keeps the indexer this many blocks behind the head.
+	Confirmations uint64
+	// Chunk is the largest block range asked for at once; ranges are
+	// halved while the node refuses them.
+	Chunk uint64
+	// PollInterval is how often Run looks for new blocks when the node
+	// cannot push new heads.
+	PollInterval time.Duration
+	// Timeout bounds each RPC call; zero means no limit.
+	Timeout time.Duration
+	// Report, if set, is told of every indexed range and reorg.
+	Report func(Progress)
+}
+
+// Progress kinds.
+const (
+	KindLogs  = "logs"
+	KindReorg = "reorg"
+	KindPoll  = "poll"
+)
+
+// Progress reports what the indexer did. For KindLogs, blocks From
+// through To were indexed; for KindReorg they were rolled back. KindPoll
+// means Run polls because the node cannot push new heads.
+type Progress struct {
+	Kind string `json:"kind"`
+	From uint64 `json:"from"`
+	To   uint64 `json:"to"`
+	Logs int    `json:"logs"`
+	Head uint64 `json:"head"`
+}
+
+// Indexer fills a Store from a Backend.
+type Indexer struct {
+	b      Backend
+	store  *Store
+	cfg    Config
+	chunk  uint64
+	streak int
+}
+
+// New returns an indexer writing to store. It checks that store was not
+// indexed with a different filter.
+func New(ctx context.Context, b Backend, store *Store, cfg Config) (*Indexer, error) {
+	if cfg.Chunk == 0 {
+		cfg.Chunk = DefaultChunk
+	}
+	if cfg.PollInterval == 0 {
+		cfg.PollInterval = DefaultPollInterval
+	}
+	filter, err := json.Marshal(struct {
+		Addresses []common.Address `json:"addresses"`
+		Topics    [][]common.Hash  `json:"topics"`
+		From      uint64           `json:"from"`
+	}{cfg.Addresses, cfg.Topics, cfg.From})
+	if err != nil {
+		return nil, err
+	}
+	if err := store.CheckFilter(ctx, string(filter)); err != nil {
+		return nil, err
+	}
+	return &Indexer{b: b, store: store, cfg: cfg, chunk: cfg.Chunk}, nil
+}
+
+// Run indexes up to the head, then keeps up with it until ctx is done,
+// on new-head notifications if the node sends them and by polling
+// otherwise. A failed sync ends Run; the next one resumes from the
+// checkpoint.
+func (ix *Indexer) Run(ctx context.Context) error {
+	heads := make(chan *types.Header, 16)
+	var subErr <-chan error
+	var tick <-chan time.Time
+	poll := func() {
+		t := time.NewTicker(ix.cfg.PollInterval)
+		go func() {
+			<-ctx.Done()
+			t.Stop()
+		}()
+		tick = t.C
+		ix.report(Progress{Kind: KindPoll})
+	}
+	if sub, err := ix.b.SubscribeNewHead(ctx, heads); err == nil {
+		defer sub.Unsubscribe()
+		subErr = sub.Err()
+	} else {
+		poll()
+	}
+
+	for {
+		if err := ix.Sync(ctx); err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-heads:
+			for len(heads) > 0 {
+				<-heads
+			}
+		case <-tick:
+		case <-subErr:
+			subErr = nil
+			poll()
+		}
+	}
+}
+
+// Sync indexes up to the head less Config.Confirmations, first rolling
+// back blocks that are no longer canonical.
+func (ix *Indexer) Sync(ctx context.Context) error {
+	head, err := ix.header(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if head == nil || head.Number.Uint64() < ix.cfg.Confirmations {
+		return nil
+	}
+	headNumber := head.Number.Uint64()
+	target := headNumber - ix.cfg.Confirmations
+
+	for {
+		next := ix.cfg.From
+		cp, ok, err := ix.store.Checkpoint(ctx)
+		if err != nil {
+			return err
+		}
+		if ok {
+			canonical, err := ix.canonical(ctx, cp)
+			if err != nil {
+				return err
+			}
+			if !canonical {
+				if err := ix.rollback(ctx, cp, headNumber); err != nil {
+					return err
+				}
+				continue
+			}
+			next = cp.Number + 1
+		}
+		if next > target {
+			return nil
+		}
+
+		end := next + ix.chunk - 1
+		if end > target || end < next {
+			end = target
+		}
+		before, err := ix.header(ctx, new(big.Int).SetUint64(end))
+		if err != nil {
+			return err
+		}
+		if before == nil {
+			return fmt.Errorf("the node has no block %d", end)
+		}
+		logs, err := ix.filter(ctx, next, end)
+		if err != nil {
+			if ctx.Err() != nil || ix.chunk == 1 {
+				return fmt.Errorf("logs of blocks %d-%d: %w", next, end, err)
+			}
+			ix.chunk /= 2
+			ix.streak = 0
+			continue
+		}
+		// Logs from a chain that reorganised while they were fetched would
+		// not match the hash checked afterwards; fetch them again.
+		after, err := ix.header(ctx, new(big.Int).SetUint64(end))
+		if err != nil {
+			return err
+		}
+		if after == nil || after.Hash() != before.Hash() {
+			continue
+		}
+		if err := ix.store.Save(ctx, logs, Block{Number: end, Hash: after.Hash()}); err != nil {
+			return err
+		}
+		ix.report(Progress{Kind: KindLogs, From: next, To: end, Logs: len(logs), Head: headNumber})
+		if ix.streak++; ix.streak >= growAfter && ix.chunk < ix.cfg.Chunk {
+			ix.chunk *= 2
+			if ix.chunk > ix.cfg.Chunk {
+				ix.chunk = ix.cfg.Chunk
+			}
+			ix.streak = 0
+		}
+	}
+}
+
+// filter fetches and decodes the logs of blocks from through to.
+func (ix *Indexer) filter(ctx context.Context, from, to uint64) ([]Log, error) {
+	ctx, cancel := ix.rpcContext(ctx)
+	defer cancel()
+	raw, err := ix.b.FilterLogs(ctx, ethereum.FilterQuery{
+		FromBlock: new(big.Int).SetUint64(from),
+		ToBlock:   new(big.Int).SetUint64(to),
+		Addresses: ix.cfg.Addresses,
+		Topics:    ix.cfg.Topics,
+	})
+	if err != nil {
+		return nil, err
+	}
+	logs := make([]Log, 0, len(raw))
+	for _, r := range raw {
+		if r.Removed {
+			continue
+		}
+		l := Log{
+			BlockNumber: r.BlockNumber, BlockHash: r.BlockHash, TxHash: r.TxHash, TxIndex: r.TxIndex,
+			Index: r.Index, Address: r.Address, Topics: r.Topics, Data: r.Data,
+		}
+		if ix.cfg.ABI != nil {
+			if ev, err := codec.DecodeLog(*ix.cfg.ABI, r.Topics, r.Data); err == nil {
+				l.Event, l.Args = ev.Event, ev.Arguments
+			}
+		}
+		logs = append(logs, l)
+	}
+	return logs, nil
+}
+
+// rollback finds the newest stored block still on the chain and drops
+// everything after it. If none is left the index starts over.
+func (ix *Indexer) rollback(ctx context.Context, cp Block, head uint64) error {
+	below := cp.Number
+	for {
+		blocks, err := ix.store.Blocks(ctx, below, 64)
+		if err != nil {
+			return err
+		}
+		for _, b := range blocks {
+			canonical, err := ix.canonical(ctx, b)
+			if err != nil {
+				return err
+			}
+			if canonical {
+				b := b
+				if err := ix.store.Rewind(ctx, &b); err != nil {
+					return err
+				}
+				ix.report(Progress{Kind: KindReorg, From: b.Number + 1, To: cp.Number, Head: head})
+				return nil
+			}
+		}
+		if len(blocks) == 0 || blocks[len(blocks)-1].Number == 0 {
+			break
+		}
+		below = blocks[len(blocks)-1].Number - 1
+	}
+	if err := ix.store.Rewind(ctx, nil); err != nil {
+		return err
+	}
+	ix.report(Progress{Kind: KindReorg, From: ix.cfg.From, To: cp.Number, Head: head})
+	return nil
+}
+
+// canonical reports whether b is still part of the chain.
+func (ix *Indexer) canonical(ctx context.Context, b Block) (bool, error) {
+	h, err := ix.header(ctx, new(big.Int).SetUint64(b.Number))
+	if err != nil {
+		return false, err
+	}
+	return h != nil && h.Number.Uint64() == b.Number && h.Hash() == b.Hash, nil
+}
+
+// header returns the header of a block, or nil if the node has none.
+func (ix *Indexer) header(ctx context.Context, number *big.Int) (*types.Header, error) {
+	ctx, cancel := ix.rpcContext(ctx)
+	defer cancel()
+	h, err := ix.b.HeaderByNumber(ctx, number)
+	if errors.Is(err, ethereum.NotFound) {
+		return nil, nil
+	}
+	return h, err
+}
+
+func (ix *Indexer) rpcContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if ix.cfg.Timeout == 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, ix.cfg.Timeout)
+}
+
+func (ix *Indexer) report(p Progress) {
+	if ix.cfg.Report != nil {
+		ix.cfg.Report(p)
+	}
+}
@@ -0,0 +1,307 @@
+package indexer
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"errors"
+	"math/big"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind/backends"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const pingABI = `[{"type":"event","name":"Ping","inputs":[{"name":"from","type":"address","indexed":true},{"name":"value","type":"uint256","indexed":false}],"anonymous":false}]`
+
+var pingTopic = crypto.Keccak256Hash([]byte("Ping(address,uint256)"))
+
+// pingCode is the creation code of a contract that answers every call
+// with LOG2(Ping, caller) carrying the call data.
+func pingCode() []byte {
+	runtime := []byte{
+		0x36, 0x60, 0x00, 0x60, 0x00, 0x37, // CALLDATACOPY(0, 0, CALLDATASIZE)
+		0x33, // CALLER
+		0x7f, // PUSH32 Ping
+	}
+	runtime = append(runtime, pingTopic.Bytes()...)
+	runtime = append(runtime,
+		0x36, 0x60, 0x00, 0xa2, // LOG2(0, CALLDATASIZE, Ping, caller)
+		0x00, // STOP
+	)
+	n := byte(len(runtime))
+	init := []byte{
+		0x60, n, 0x60, 0x0e, 0x60, 0x00, 0x39, // CODECOPY(0, 14, n)
+		0x60, n, 0x60, 0x00, 0xf3, // RETURN(0, n)
+		0x00, 0x00, // padding up to offset 14
+	}
+	return append(init, runtime...)
+}
+
+type chain struct {
+	*backends.SimulatedBackend
+	t        *testing.T
+	key      *ecdsa.PrivateKey
+	contract common.Address
+}
+
+func newChain(t *testing.T) *chain {
+	key, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	alloc := core.GenesisAlloc{crypto.PubkeyToAddress(key.PublicKey): {Balance: big.NewInt(params.Ether)}}
+	c := &chain{SimulatedBackend: backends.NewSimulatedBackend(alloc, 30_000_000), t: t, key: key}
+	t.Cleanup(func() { c.Close() })
+
+	tx := c.send(nil, pingCode())
+	c.Commit()
+	receipt, err := c.TransactionReceipt(context.Background(), tx.Hash())
+	require.NoError(t, err)
+	require.Equal(t, types.ReceiptStatusSuccessful, receipt.Status)
+	c.contract = receipt.ContractAddress
+	return c
+}
+
+func (c *chain) send(to *common.Address, data []byte) *types.Transaction {
+	c.t.Helper()
+	ctx := context.Background()
+	nonce, err := c.PendingNonceAt(ctx, crypto.PubkeyToAddress(c.key.PublicKey))
+	require.NoError(c.t, err)
+	tx, err := types.SignNewTx(c.key, types.LatestSignerForChainID(big.NewInt(1337)), &types.DynamicFeeTx{
+		ChainID: big.NewInt(1337), Nonce: nonce, To: to, Gas: 200_000,
+		GasTipCap: big.NewInt(1), GasFeeCap: big.NewInt(10 * params.GWei), Data: data,
+	})
+	require.NoError(c.t, err)
+	require.NoError(c.t, c.SendTransaction(ctx, tx))
+	return tx
+}
+
+// ping mines a block in which the contract logs value.
+func (c *chain) ping(value int64) {
+	c.send(&c.contract, common.LeftPadBytes(big.NewInt(value).Bytes(), 32))
+	c.Commit()
+}
+
+func (c *chain) head() uint64 {
+	return c.Blockchain().CurrentBlock().NumberU64()
+}
+
+// fork replaces the blocks after number with a longer branch pinging
+// values, one per block.
+func (c *chain) fork(number uint64, values ...int64) {
+	c.t.Helper()
+	old := c.head()
+	require.NoError(c.t, c.Fork(context.Background(), c.Blockchain().GetHeaderByNumber(number).Hash()))
+	for _, v := range values {
+		c.ping(v)
+	}
+	for c.head() <= old {
+		c.Commit()
+	}
+}
+
+func openStore(t *testing.T, path string) *Store {
+	s, err := OpenStore(path)
+	require.NoError(t, err)
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+// values returns the pinged values the store holds, in chain order.
+func values(t *testing.T, s *Store) []string {
+	logs, err := s.Logs(context.Background(), 0, 1<<32)
+	require.NoError(t, err)
+	var out []string
+	for _, l := range logs {
+		require.Len(t, l.Args, 2)
+		out = append(out, l.Args[1].Value.(string))
+	}
+	return out
+}
+
+func newIndexer(t *testing.T, b Backend, s *Store, cfg Config) (*Indexer, *[]Progress) {
+	parsed, err := abi.JSON(strings.NewReader(pingABI))
+	require.NoError(t, err)
+	cfg.ABI = &parsed
+	var mu sync.Mutex
+	var progress []Progress
+	cfg.Report = func(p Progress) {
+		mu.Lock()
+		defer mu.Unlock()
+		progress = append(progress, p)
+	}
+	ix, err := New(context.Background(), b, s, cfg)
+	require.NoError(t, err)
+	return ix, &progress
+}
+
+func TestSync(t *testing.T) {
+	ctx := context.Background()
+	c := newChain(t)
+	for v := int64(1); v <= 5; v++ {
+		c.ping(v)
+		c.Commit()
+	}
+	s := openStore(t, filepath.Join(t.TempDir(), "index.db"))
+	ix, progress := newIndexer(t, c, s, Config{Addresses: []common.Address{c.contract}, Chunk: 4})
+	require.NoError(t, ix.Sync(ctx))
+
+	assert.Equal(t, []string{"1", "2", "3", "4", "5"}, values(t, s))
+	assert.Equal(t, []Progress{
+		{Kind: KindLogs, From: 0, To: 3, Logs: 1, Head: 11},
+		{Kind: KindLogs, From: 4, To: 7, Logs: 2, Head: 11},
+		{Kind: KindLogs, From: 8, To: 11, Logs: 2, Head: 11},
+	}, *progress)
+
+	logs, err := s.Logs(ctx, 2, 2)
+	require.NoError(t, err)
+	require.Len(t, logs, 1)
+	l := logs[0]
+	assert.Equal(t, "Ping(address,uint256)", l.Event)
+	assert.Equal(t, c.contract, l.Address)
+	assert.Equal(t, []common.Hash{pingTopic, crypto.PubkeyToAddress(c.key.PublicKey).Hash()}, l.Topics)
+	assert.Equal(t, c.Blockchain().GetHeaderByNumber(2).Hash(), l.BlockHash)
+	assert.Equal(t, crypto.PubkeyToAddress(c.key.PublicKey).Hex(), l.Args[0].Value)
+
+	cp, ok, err := s.Checkpoint(ctx)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, Block{Number: 11, Hash: c.Blockchain().CurrentHeader().Hash()}, cp)
+}
+
+func TestResume(t *testing.T) {
+	ctx := context.Background()
+	c := newChain(t)
+	c.ping(1)
+	path := filepath.Join(t.TempDir(), "index.db")
+	cfg := Config{Addresses: []common.Address{c.contract}, Topics: [][]common.Hash{{pingTopic}}, From: 1, Confirmations: 1}
+
+	s, err := OpenStore(path)
+	require.NoError(t, err)
+	ix, _ := newIndexer(t, c, s, cfg)
+	require.NoError(t, ix.Sync(ctx))
+	assert.Empty(t, values(t, s), "block 2 lacks a confirmation")
+	c.Commit()
+	require.NoError(t, ix.Sync(ctx))
+	assert.Equal(t, []string{"1"}, values(t, s))
+	require.NoError(t, s.Close())
+
+	c.ping(2)
+	c.ping(3)
+	c.Commit()
+	s = openStore(t, path)
+	ix, progress := newIndexer(t, c, s, cfg)
+	require.NoError(t, ix.Sync(ctx))
+	assert.Equal(t, []string{"1", "2", "3"}, values(t, s))
+	assert.Equal(t, []Progress{{Kind: KindLogs, From: 3, To: 5, Logs: 2, Head: 6}}, *progress)
+
+	cfg.From = 0
+	_, err = New(ctx, c, s, cfg)
+	assert.ErrorIs(t, err, ErrFilterChanged)
+}
+
+func TestReorg(t *testing.T) {
+	ctx := context.Background()
+	c := newChain(t)
+	for v := int64(1); v <= 6; v++ {
+		c.ping(v)
+	}
+	s := openStore(t, filepath.Join(t.TempDir(), "index.db"))
+	ix, progress := newIndexer(t, c, s, Config{Addresses: []common.Address{c.contract}, Chunk: 3})
+	require.NoError(t, ix.Sync(ctx))
+	require.Equal(t, []string{"1", "2", "3", "4", "5", "6"}, values(t, s))
+
+	// Blocks 5-7 pinged 4, 5 and 6; the new branch pings 40 and 50
+	// and is one block longer.
+	c.fork(4, 40, 50)
+	*progress = nil
+	require.NoError(t, ix.Sync(ctx))
+	assert.Equal(t, []string{"1", "2", "3", "40", "50"}, values(t, s))
+	require.NotEmpty(t, *progress)
+	assert.Equal(t, Progress{Kind: KindReorg, From: 5, To: 7, Head: 8}, (*progress)[0])
+
+	// A reorg deeper than every stored block starts over. Block 1
+	// deployed the contract and, holding no logs, was never stored.
+	c.fork(1, 7)
+	*progress = nil
+	require.NoError(t, ix.Sync(ctx))
+	assert.Equal(t, []string{"7"}, values(t, s))
+	assert.Equal(t, KindReorg, (*progress)[0].Kind)
+	assert.Equal(t, uint64(0), (*progress)[0].From)
+}
+
+// flaky refuses log queries over more than max blocks, like providers
+// that cap eth_getLogs ranges.
+type flaky struct {
+	*chain
+	max uint64
+}
+
+func (f flaky) FilterLogs(ctx context.Context, q ethereum.FilterQuery) ([]types.Log, error) {
+	if q.ToBlock.Uint64()-q.FromBlock.Uint64()+1 > f.max {
+		return nil, errors.New("block range too large")
+	}
+	return f.chain.FilterLogs(ctx, q)
+}
+
+func (flaky) SubscribeNewHead(ctx context.Context, ch chan<- *types.Header) (ethereum.Subscription, error) {
+	return nil, errors.New("notifications not supported")
+}
+
+func TestAdaptiveChunk(t *testing.T) {
+	ctx := context.Background()
+	c := newChain(t)
+	for v := int64(1); v <= 10; v++ {
+		c.ping(v)
+	}
+	s := openStore(t, filepath.Join(t.TempDir(), "index.db"))
+	ix, progress := newIndexer(t, flaky{c, 3}, s, Config{Addresses: []common.Address{c.contract}, Chunk: 8})
+	require.NoError(t, ix.Sync(ctx))
+	assert.Len(t, values(t, s), 10)
+	for _, p := range *progress {
+		assert.LessOrEqual(t, p.To-p.From+1, uint64(3), "%+v", p)
+	}
+
+	c.ping(11)
+	ix, _ = newIndexer(t, flaky{c, 0}, s, Config{Addresses: []common.Address{c.contract}, Chunk: 8})
+	assert.ErrorContains(t, ix.Sync(ctx), "logs of blocks 12-12: block range too large")
+}
+
+func TestRun(t *testing.T) {
+	for name, b := range map[string]func(c *chain) Backend{
+		"subscription": func(c *chain) Backend { return c },
+		"polling":      func(c *chain) Backend { return flaky{c, 100} },
+	} {
+		b := b
+		t.Run(name, func(t *testing.T) {
+			c := newChain(t)
+			s := openStore(t, filepath.Join(t.TempDir(), "index.db"))
+			ix, progress := newIndexer(t, b(c), s, Config{Addresses: []common.Address{c.contract}, PollInterval: 5 * time.Millisecond})
+			ctx, cancel := context.WithCancel(context.Background())
+			done := make(chan error)
+			go func() { done <- ix.Run(ctx) }()
+
+			c.ping(1)
+			c.ping(2)
+			require.Eventually(t, func() bool {
+				logs, err := s.Logs(context.Background(), 0, 100)
+				return err == nil && len(logs) == 2
+			}, 5*time.Second, 5*time.Millisecond)
+			cancel()
+			require.NoError(t, <-done)
+			if name == "polling" {
+				assert.Equal(t, KindPoll, (*progress)[0].Kind)
+			}
+		})
+	}
+}
@@ -0,0 +1,31 @@
+CREATE TABLE IF NOT EXISTS meta (
+	key   TEXT PRIMARY KEY,
+	value TEXT NOT NULL
+);
+
+-- blocks holds the hashes the indexer saw: every block with a stored
+-- log and the end of every indexed range. Reorgs are found by comparing
+-- them with the chain.
+CREATE TABLE IF NOT EXISTS blocks (
+	number INTEGER PRIMARY KEY,
+	hash   TEXT NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS logs (
+	block_number INTEGER NOT NULL,
+	log_index    INTEGER NOT NULL,
+	block_hash   TEXT NOT NULL,
+	tx_hash      TEXT NOT NULL,
+	tx_index     INTEGER NOT NULL,
+	address      TEXT NOT NULL,
+	topic0       TEXT,
+	topic1       TEXT,
+	topic2       TEXT,
+	topic3       TEXT,
+	data         TEXT NOT NULL,
+	event        TEXT,
+	args         TEXT,
+	PRIMARY KEY (block_number, log_index)
+);
+
+CREATE INDEX IF NOT EXISTS logs_address_topic0 ON logs (address, topic0);
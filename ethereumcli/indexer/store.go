@@ -0,0 +1,238 @@
+package indexer
+
+import (
+	"context"
+	"database/sql"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"golang/ethereumcli/codec"
+	"strconv"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	_ "modernc.org/sqlite"
+)
+
+// ErrFilterChanged is returned when a database is reopened with other
+// addresses, topics or start block than it was indexed with.
+var ErrFilterChanged = errors.New("the database was indexed with a different filter; use a new database")
+
+//go:embed schema.sql
+var schema string
+
+// Block identifies an indexed block.
+type Block struct {
+	Number uint64      `json:"number"`
+	Hash   common.Hash `json:"hash"`
+}
+
+// Log is a stored log. Event and Args are set if the ABI decoded it.
+type Log struct {
+	BlockNumber uint64         `json:"blockNumber"`
+	BlockHash   common.Hash    `json:"blockHash"`
+	TxHash      common.Hash    `json:"transactionHash"`
+	TxIndex     uint           `json:"transactionIndex"`
+	Index       uint           `json:"logIndex"`
+	Address     common.Address `json:"address"`
+	Topics      []common.Hash  `json:"topics"`
+	Data        hexutil.Bytes  `json:"data"`
+	Event       string         `json:"event,omitempty"`
+	Args        []codec.Field  `json:"args,omitempty"`
+}
+
+// Store keeps indexed logs, the hashes of their blocks and the
+// checkpoint in SQLite.
+type Store struct {
+	db *sql.DB
+}
+
+// OpenStore opens (or creates) the database at path.
+func OpenStore(path string) (*Store, error) {
+	db, err := sql.Open("sqlite", fmt.Sprintf("file:%s?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)", path))
+	if err != nil {
+		return nil, err
+	}
+	if _, err := db.Exec(schema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("open %s: %w", path, err)
+	}
+	return &Store{db: db}, nil
+}
+
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// CheckFilter records filter, a description of what is indexed, in a new
+// database and fails with ErrFilterChanged if an existing one differs.
+func (s *Store) CheckFilter(ctx context.Context, filter string) error {
+	var stored string
+	err := s.db.QueryRowContext(ctx, `SELECT value FROM meta WHERE key = 'filter'`).Scan(&stored)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		_, err = s.db.ExecContext(ctx, `INSERT INTO meta (key, value) VALUES ('filter', ?)`, filter)
+		return err
+	case err != nil:
+		return err
+	case stored != filter:
+		return ErrFilterChanged
+	}
+	return nil
+}
+
+// Checkpoint returns the last indexed block; ok is false if nothing has
+// been indexed yet.
+func (s *Store) Checkpoint(ctx context.Context) (b Block, ok bool, err error) {
+	var number uint64
+	var hash string
+	err = s.db.QueryRowContext(ctx, `SELECT b.number, b.hash FROM meta m JOIN blocks b ON b.number = CAST(m.value AS INTEGER) WHERE m.key = 'checkpoint'`).Scan(&number, &hash)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Block{}, false, nil
+	}
+	if err != nil {
+		return Block{}, false, err
+	}
+	return Block{Number: number, Hash: common.HexToHash(hash)}, true, nil
+}
+
+// Save stores the logs of a range and moves the checkpoint to its last
+// block, atomically.
+func (s *Store) Save(ctx context.Context, logs []Log, checkpoint Block) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	blocks := []Block{checkpoint}
+	for _, l := range logs {
+		blocks = append(blocks, Block{Number: l.BlockNumber, Hash: l.BlockHash})
+		var args []byte
+		if l.Args != nil {
+			if args, err = json.Marshal(l.Args); err != nil {
+				return err
+			}
+		}
+		topics := make([]interface{}, 4)
+		for i, t := range l.Topics {
+			topics[i] = t.Hex()
+		}
+		if _, err := tx.ExecContext(ctx, `INSERT OR REPLACE INTO logs
+			(block_number, log_index, block_hash, tx_hash, tx_index, address, topic0, topic1, topic2, topic3, data, event, args)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			l.BlockNumber, l.Index, l.BlockHash.Hex(), l.TxHash.Hex(), l.TxIndex, l.Address.Hex(),
+			topics[0], topics[1], topics[2], topics[3], l.Data.String(), nullString(l.Event), nullString(string(args))); err != nil {
+			return err
+		}
+	}
+	for _, b := range blocks {
+		if _, err := tx.ExecContext(ctx, `INSERT OR REPLACE INTO blocks (number, hash) VALUES (?, ?)`, b.Number, b.Hash.Hex()); err != nil {
+			return err
+		}
+	}
+	if err := setCheckpoint(ctx, tx, &checkpoint); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Blocks returns up to limit stored blocks numbered at most below,
+// newest first.
+func (s *Store) Blocks(ctx context.Context, below uint64, limit int) ([]Block, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT number, hash FROM blocks WHERE number <= ? ORDER BY number DESC LIMIT ?`, below, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var blocks []Block
+	for rows.Next() {
+		var b Block
+		var hash string
+		if err := rows.Scan(&b.Number, &hash); err != nil {
+			return nil, err
+		}
+		b.Hash = common.HexToHash(hash)
+		blocks = append(blocks, b)
+	}
+	return blocks, rows.Err()
+}
+
+// Rewind drops everything after block to, which becomes the checkpoint.
+// A nil to empties the database but for its filter.
+func (s *Store) Rewind(ctx context.Context, to *Block) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	after := int64(-1)
+	if to != nil {
+		after = int64(to.Number)
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM logs WHERE block_number > ?`, after); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM blocks WHERE number > ?`, after); err != nil {
+		return err
+	}
+	if err := setCheckpoint(ctx, tx, to); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func setCheckpoint(ctx context.Context, tx *sql.Tx, b *Block) error {
+	if b == nil {
+		_, err := tx.ExecContext(ctx, `DELETE FROM meta WHERE key = 'checkpoint'`)
+		return err
+	}
+	_, err := tx.ExecContext(ctx, `INSERT OR REPLACE INTO meta (key, value) VALUES ('checkpoint', ?)`, strconv.FormatUint(b.Number, 10))
+	return err
+}
+
+// Logs returns the stored logs of blocks from through to, in chain order.
+func (s *Store) Logs(ctx context.Context, from, to uint64) ([]Log, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT block_number, log_index, block_hash, tx_hash, tx_index, address,
+		topic0, topic1, topic2, topic3, data, event, args
+		FROM logs WHERE block_number BETWEEN ? AND ? ORDER BY block_number, log_index`, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var logs []Log
+	for rows.Next() {
+		var (
+			l                                Log
+			blockHash, txHash, address, data string
+			topics                           [4]sql.NullString
+			event, args                      sql.NullString
+		)
+		if err := rows.Scan(&l.BlockNumber, &l.Index, &blockHash, &txHash, &l.TxIndex, &address,
+			&topics[0], &topics[1], &topics[2], &topics[3], &data, &event, &args); err != nil {
+			return nil, err
+		}
+		l.BlockHash, l.TxHash, l.Address = common.HexToHash(blockHash), common.HexToHash(txHash), common.HexToAddress(address)
+		for _, t := range topics {
+			if t.Valid {
+				l.Topics = append(l.Topics, common.HexToHash(t.String))
+			}
+		}
+		if l.Data, err = hexutil.Decode(data); err != nil {
+			return nil, fmt.Errorf("log %d/%d: %w", l.BlockNumber, l.Index, err)
+		}
+		l.Event = event.String
+		if args.Valid {
+			if err := json.Unmarshal([]byte(args.String), &l.Args); err != nil {
+				return nil, fmt.Errorf("log %d/%d: %w", l.BlockNumber, l.Index, err)
+			}
+		}
+		logs = append(logs, l)
+	}
+	return logs, rows.Err()
+}
+
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
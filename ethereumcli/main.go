@@ -2,22 +2,23 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"log"
-	"time"
-
-	"github.com/ethereum/go-ethereum/ethclient"
+	"golang/ethereumcli/cli"
+	"os"
+	"os/signal"
 )
 
 func main() {
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
 
-	client, err := ethclient.DialContext(ctx, "https://cloudflare-eth.com")
+	err := cli.Run(ctx, os.Args[1:], os.Stdin, os.Stdout, os.Stderr)
+	if errors.Is(err, cli.ErrUsage) {
+		os.Exit(2)
+	}
 	if err != nil {
-		log.Fatal("连接失败:", err)
+		fmt.Fprintln(os.Stderr, "ethereumcli:", err)
+		os.Exit(1)
 	}
-	defer client.Close()
-
-	fmt.Println("成功连接到以太坊网络")
 }
@@ -0,0 +1,153 @@
+package rpcpool
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// blockID is the part of eth_getBlockByNumber a check needs.
+type blockID struct {
+	Number hexutil.Uint64 `json:"number"`
+	Hash   common.Hash    `json:"hash"`
+}
+
+// Check asks every endpoint for its head, marks those that fail as down
+// and those more than MaxLag blocks behind the highest head as lagging,
+// then compares the hashes the others report for the lowest of their
+// heads: endpoints outside the largest group of equal hashes have
+// diverged. It returns ErrNoHealthy if no endpoint is left healthy.
+func (p *Pool) Check(ctx context.Context) error {
+	defer func() {
+		p.mu.Lock()
+		p.checking, p.lastCheck = false, time.Now()
+		p.mu.Unlock()
+	}()
+
+	heads := make([]*blockID, len(p.endpoints))
+	latencies := make([]time.Duration, len(p.endpoints))
+	errs := make([]error, len(p.endpoints))
+	p.each(func(i int, ep *endpoint) {
+		start := time.Now()
+		heads[i], errs[i] = p.block(ctx, ep, "latest")
+		latencies[i] = time.Since(start)
+	})
+
+	var top uint64
+	for i := range p.endpoints {
+		if errs[i] == nil && uint64(heads[i].Number) > top {
+			top = uint64(heads[i].Number)
+		}
+	}
+	states := make([]string, len(p.endpoints))
+	height := top
+	for i := range p.endpoints {
+		switch {
+		case errs[i] != nil:
+			states[i] = Down
+		case uint64(heads[i].Number)+p.cfg.MaxLag < top:
+			states[i] = Lagging
+		default:
+			states[i] = Healthy
+			if n := uint64(heads[i].Number); n < height {
+				height = n
+			}
+		}
+	}
+
+	// Compare the block every healthy endpoint has.
+	hashes := make([]common.Hash, len(p.endpoints))
+	p.each(func(i int, ep *endpoint) {
+		if states[i] != Healthy {
+			return
+		}
+		if uint64(heads[i].Number) == height {
+			hashes[i] = heads[i].Hash
+			return
+		}
+		b, err := p.block(ctx, ep, hexutil.EncodeUint64(height))
+		if err != nil {
+			errs[i] = err
+			return
+		}
+		hashes[i] = b.Hash
+	})
+	votes := map[common.Hash]int{}
+	for i := range p.endpoints {
+		if states[i] == Healthy && errs[i] == nil {
+			votes[hashes[i]]++
+		}
+	}
+	var majority common.Hash
+	for i := range p.endpoints {
+		// Ties go to the hash of the endpoint listed first.
+		if h := hashes[i]; states[i] == Healthy && errs[i] == nil && votes[h] > votes[majority] {
+			majority = h
+		}
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	healthy := 0
+	var down []string
+	for i, ep := range p.endpoints {
+		s := &ep.status
+		s.Error = ""
+		switch {
+		case errs[i] != nil:
+			s.State, s.Error = Down, errs[i].Error()
+			s.Failures++
+			down = append(down, s.URL+": "+s.Error)
+			continue
+		case states[i] == Healthy && hashes[i] != majority:
+			s.State = Diverged
+			s.Error = fmt.Sprintf("block %d is %s, the majority has %s", height, hashes[i].Hex(), majority.Hex())
+		default:
+			s.State = states[i]
+		}
+		if s.State == Healthy {
+			healthy++
+		}
+		s.Head, s.Hash = uint64(heads[i].Number), heads[i].Hash.Hex()
+		s.Latency, s.Failures = latencies[i], 0
+	}
+	if healthy == 0 {
+		if len(down) > 0 {
+			return fmt.Errorf("%w (%s)", ErrNoHealthy, strings.Join(down, "; "))
+		}
+		return ErrNoHealthy
+	}
+	return nil
+}
+
+// each runs f for every endpoint concurrently.
+func (p *Pool) each(f func(i int, ep *endpoint)) {
+	var wg sync.WaitGroup
+	for i, ep := range p.endpoints {
+		i, ep := i, ep
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			f(i, ep)
+		}()
+	}
+	wg.Wait()
+}
+
+func (p *Pool) block(ctx context.Context, ep *endpoint, number string) (*blockID, error) {
+	ctx, cancel := context.WithTimeout(ctx, p.cfg.Timeout)
+	defer cancel()
+	var b *blockID
+	if err := ep.client.CallContext(ctx, &b, "eth_getBlockByNumber", number, false); err != nil {
+		return nil, err
+	}
+	if b == nil {
+		return nil, fmt.Errorf("no block %s", number)
+	}
+	return b, nil
+}
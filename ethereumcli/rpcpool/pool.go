@@ -0,0 +1,309 @@
+// Package rpcpool spreads JSON-RPC calls over several HTTP endpoints. It
+// checks their heads, sends each call to the healthiest one and retries
+// on the others when it fails. Endpoints that fall behind or report
+// other block hashes than the majority are only used as a last resort.
+//
+// A Pool is an http.RoundTripper, so any JSON-RPC client that takes an
+// http.Client can use it; Client returns a go-ethereum one. Start keeps
+// the endpoints' states fresh in the background, and Close releases the
+// pool.
+package rpcpool
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// ErrNoHealthy is returned by Check when no endpoint is healthy.
+var ErrNoHealthy = errors.New("no healthy RPC endpoint")
+
+// ErrClosed is returned for calls through a closed pool.
+var ErrClosed = errors.New("rpcpool: pool is closed")
+
+// Defaults for Config.
+const (
+	DefaultCheckInterval = 30 * time.Second
+	DefaultMaxLag        = 3
+	DefaultTimeout       = 10 * time.Second
+)
+
+// Config tunes a Pool. The zero value uses the defaults.
+type Config struct {
+	// CheckInterval is how often Start checks the endpoints, and how old
+	// the last check may get before a call starts a new one.
+	CheckInterval time.Duration
+	// MaxLag is how many blocks an endpoint may trail the highest head
+	// before it counts as lagging.
+	MaxLag uint64
+	// Timeout bounds each attempt of a call and each health check.
+	Timeout time.Duration
+	// Retries is how many other endpoints a failed call is tried on;
+	// zero means all of them.
+	Retries int
+	// Transport sends the requests; nil means http.DefaultTransport.
+	Transport http.RoundTripper
+}
+
+// Endpoint states.
+const (
+	Unknown  = "unknown"
+	Healthy  = "healthy"
+	Lagging  = "lagging"
+	Diverged = "diverged"
+	Down     = "down"
+)
+
+// Status describes an endpoint as of the last health check and the
+// calls since.
+type Status struct {
+	URL     string        `json:"url"`
+	State   string        `json:"state"`
+	Head    uint64        `json:"head"`
+	Hash    string        `json:"hash,omitempty"`
+	Latency time.Duration `json:"latency"`
+	// Failures counts failed calls and checks in a row.
+	Failures int    `json:"failures"`
+	Error    string `json:"error,omitempty"`
+}
+
+type endpoint struct {
+	url    *url.URL
+	client *rpc.Client
+	status Status
+}
+
+// Pool is a set of endpoints serving the same chain.
+type Pool struct {
+	cfg       Config
+	endpoints []*endpoint
+
+	mu        sync.Mutex
+	lastCheck time.Time
+	checking  bool
+	closed    bool
+
+	done      chan struct{}
+	closeOnce sync.Once
+	wg        sync.WaitGroup
+}
+
+// New returns a pool of the given http(s) endpoints. It does not contact
+// them; call Check to know their state before the first call.
+func New(urls []string, cfg Config) (*Pool, error) {
+	if len(urls) == 0 {
+		return nil, errors.New("rpcpool: no endpoints")
+	}
+	if cfg.CheckInterval == 0 {
+		cfg.CheckInterval = DefaultCheckInterval
+	}
+	if cfg.MaxLag == 0 {
+		cfg.MaxLag = DefaultMaxLag
+	}
+	if cfg.Timeout == 0 {
+		cfg.Timeout = DefaultTimeout
+	}
+	if cfg.Retries <= 0 || cfg.Retries >= len(urls) {
+		cfg.Retries = len(urls) - 1
+	}
+	if cfg.Transport == nil {
+		cfg.Transport = http.DefaultTransport
+	}
+	p := &Pool{cfg: cfg, done: make(chan struct{})}
+	for _, raw := range urls {
+		u, err := url.Parse(raw)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return nil, fmt.Errorf("rpcpool: %q is not an http(s) URL", raw)
+		}
+		c, err := rpc.DialHTTPWithClient(raw, &http.Client{Transport: cfg.Transport})
+		if err != nil {
+			p.Close()
+			return nil, err
+		}
+		p.endpoints = append(p.endpoints, &endpoint{url: u, client: c, status: Status{URL: raw, State: Unknown}})
+	}
+	return p, nil
+}
+
+// Client returns a JSON-RPC client whose calls go through the pool.
+// Subscriptions need WebSocket or IPC and are not supported. Closing the
+// client leaves the pool open.
+func (p *Pool) Client() (*rpc.Client, error) {
+	return rpc.DialHTTPWithClient("http://rpcpool.invalid", &http.Client{Transport: p})
+}
+
+// Start checks the endpoints every CheckInterval until ctx is done or
+// the pool is closed. Call Check first to know their states before the
+// first call.
+func (p *Pool) Start(ctx context.Context) {
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+		t := time.NewTicker(p.cfg.CheckInterval)
+		defer t.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-p.done:
+				return
+			case <-t.C:
+			}
+			p.mu.Lock()
+			busy := p.checking
+			p.checking = true
+			p.mu.Unlock()
+			if !busy {
+				p.checkWithTimeout(ctx)
+			}
+		}
+	}()
+}
+
+// Close stops the background checks and closes the endpoints' clients.
+// Calls through the pool fail afterwards.
+func (p *Pool) Close() {
+	p.closeOnce.Do(func() {
+		p.mu.Lock()
+		p.closed = true
+		p.mu.Unlock()
+		close(p.done)
+		p.wg.Wait()
+		for _, ep := range p.endpoints {
+			ep.client.Close()
+		}
+	})
+}
+
+// Status returns the endpoints' states in the order they were given.
+func (p *Pool) Status() []Status {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	out := make([]Status, len(p.endpoints))
+	for i, ep := range p.endpoints {
+		out[i] = ep.status
+	}
+	return out
+}
+
+// RoundTrip sends a JSON-RPC request to the healthiest endpoint and, if
+// the endpoint cannot be reached or answers with HTTP 429 or 5xx, to the
+// next ones. JSON-RPC errors are answers and are not retried.
+func (p *Pool) RoundTrip(req *http.Request) (*http.Response, error) {
+	body, err := io.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	p.mu.Lock()
+	closed := p.closed
+	p.mu.Unlock()
+	if closed {
+		return nil, ErrClosed
+	}
+	p.maybeCheck()
+
+	var errs []error
+	for _, ep := range p.route() {
+		resp, err := p.forward(req, ep, body)
+		if err == nil {
+			return resp, nil
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", ep.status.URL, err))
+		if req.Context().Err() != nil {
+			break
+		}
+	}
+	return nil, fmt.Errorf("every RPC endpoint failed: %w", errors.Join(errs...))
+}
+
+// forward sends body to ep and reads the whole response, so that the
+// attempt's timeout covers it. A call the caller gave up on says nothing
+// about the endpoint and leaves its status alone.
+func (p *Pool) forward(req *http.Request, ep *endpoint, body []byte) (*http.Response, error) {
+	ctx, cancel := context.WithTimeout(req.Context(), p.cfg.Timeout)
+	defer cancel()
+	out := req.Clone(ctx)
+	out.URL, out.Host = ep.url, ep.url.Host
+	out.Body, out.ContentLength = io.NopCloser(bytes.NewReader(body)), int64(len(body))
+
+	start := time.Now()
+	resp, err := p.cfg.Transport.RoundTrip(out)
+	if err == nil {
+		var data []byte
+		data, err = io.ReadAll(resp.Body)
+		resp.Body.Close()
+		resp.Body = io.NopCloser(bytes.NewReader(data))
+		if err == nil && (resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500) {
+			err = fmt.Errorf("HTTP %s", resp.Status)
+		}
+	}
+	if err != nil && req.Context().Err() != nil {
+		return nil, err
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err != nil {
+		ep.status.Failures++
+		ep.status.State, ep.status.Error = Down, err.Error()
+		return nil, err
+	}
+	ep.status.Failures, ep.status.Latency = 0, time.Since(start)
+	if ep.status.State == Down {
+		// It answers again; the next check says how well.
+		ep.status.State, ep.status.Error = Unknown, ""
+	}
+	return resp, nil
+}
+
+// stateRank orders endpoints for routing: the best state first.
+var stateRank = map[string]int{Healthy: 0, Unknown: 1, Lagging: 2, Diverged: 3, Down: 4}
+
+// route returns the endpoints to try a call on: healthy ones with the
+// highest head and lowest latency first, then the rest.
+func (p *Pool) route() []*endpoint {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	eps := append([]*endpoint(nil), p.endpoints...)
+	sort.SliceStable(eps, func(i, j int) bool {
+		a, b := eps[i].status, eps[j].status
+		if stateRank[a.State] != stateRank[b.State] {
+			return stateRank[a.State] < stateRank[b.State]
+		}
+		if a.Head != b.Head {
+			return a.Head > b.Head
+		}
+		return a.Latency < b.Latency
+	})
+	return eps[:p.cfg.Retries+1]
+}
+
+// maybeCheck starts a background check when the last one is too old.
+func (p *Pool) maybeCheck() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed || p.checking || p.lastCheck.IsZero() || time.Since(p.lastCheck) < p.cfg.CheckInterval {
+		return
+	}
+	p.checking = true
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+		p.checkWithTimeout(context.Background())
+	}()
+}
+
+func (p *Pool) checkWithTimeout(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, p.cfg.Timeout)
+	defer cancel()
+	p.Check(ctx)
+}
@@ -0,0 +1,302 @@
+package rpcpool
+
+import (
+	"context"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeNode serves a chain of empty headers up to head. Blocks above
+// forkAt are tagged with fork, which changes their hashes.
+type fakeNode struct {
+	mu     sync.Mutex
+	head   uint64
+	forkAt uint64
+	fork   string
+	down   bool
+	calls  int
+}
+
+func (n *fakeNode) header(number uint64) *types.Header {
+	h := &types.Header{Number: new(big.Int).SetUint64(number), Difficulty: big.NewInt(0)}
+	if number > n.forkAt && n.fork != "" {
+		h.Extra = []byte(n.fork)
+	}
+	return h
+}
+
+type ethAPI struct{ n *fakeNode }
+
+func (a ethAPI) BlockNumber() hexutil.Uint64 {
+	a.n.mu.Lock()
+	defer a.n.mu.Unlock()
+	return hexutil.Uint64(a.n.head)
+}
+
+func (a ethAPI) GetBlockByNumber(number rpc.BlockNumber, full bool) *types.Header {
+	a.n.mu.Lock()
+	defer a.n.mu.Unlock()
+	if number < 0 {
+		return a.n.header(a.n.head)
+	}
+	if uint64(number) > a.n.head {
+		return nil
+	}
+	return a.n.header(uint64(number))
+}
+
+func (n *fakeNode) setDown(down bool) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.down = down
+}
+
+func (n *fakeNode) served() int {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.calls
+}
+
+// serve starts an HTTP endpoint for n. A down node answers 503.
+func serve(t *testing.T, n *fakeNode) string {
+	t.Helper()
+	srv := rpc.NewServer()
+	require.NoError(t, srv.RegisterName("eth", ethAPI{n}))
+	t.Cleanup(srv.Stop)
+	hs := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n.mu.Lock()
+		down := n.down
+		n.calls++
+		n.mu.Unlock()
+		if down {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		srv.ServeHTTP(w, r)
+	}))
+	t.Cleanup(hs.Close)
+	return hs.URL
+}
+
+func newPool(t *testing.T, nodes ...*fakeNode) *Pool {
+	t.Helper()
+	var urls []string
+	for _, n := range nodes {
+		urls = append(urls, serve(t, n))
+	}
+	p, err := New(urls, Config{MaxLag: 2})
+	require.NoError(t, err)
+	t.Cleanup(p.Close)
+	return p
+}
+
+func dial(t *testing.T, p *Pool) *rpc.Client {
+	t.Helper()
+	c, err := p.Client()
+	require.NoError(t, err)
+	t.Cleanup(c.Close)
+	return c
+}
+
+func states(p *Pool) []string {
+	var out []string
+	for _, s := range p.Status() {
+		out = append(out, s.State)
+	}
+	return out
+}
+
+func TestCheck(t *testing.T) {
+	ctx := context.Background()
+	a := &fakeNode{head: 100}
+	b := &fakeNode{head: 99}
+	lagging := &fakeNode{head: 90}
+	forked := &fakeNode{head: 100, forkAt: 95, fork: "other"}
+	down := &fakeNode{head: 100, down: true}
+	p := newPool(t, a, b, lagging, forked, down)
+
+	assert.Equal(t, []string{Unknown, Unknown, Unknown, Unknown, Unknown}, states(p))
+	require.NoError(t, p.Check(ctx))
+	assert.Equal(t, []string{Healthy, Healthy, Lagging, Diverged, Down}, states(p))
+
+	s := p.Status()
+	assert.Equal(t, uint64(100), s[0].Head)
+	assert.Equal(t, a.header(100).Hash().Hex(), s[0].Hash)
+	assert.Contains(t, s[3].Error, "block 99 is "+forked.header(99).Hash().Hex())
+	assert.Contains(t, s[4].Error, "503")
+	assert.Equal(t, 1, s[4].Failures)
+
+	// Blocks below the fork agree, so a forked node that falls back
+	// behind it is only lagging.
+	forked.mu.Lock()
+	forked.head = 95
+	forked.mu.Unlock()
+	down.setDown(false)
+	require.NoError(t, p.Check(ctx))
+	assert.Equal(t, []string{Healthy, Healthy, Lagging, Lagging, Healthy}, states(p))
+	assert.Equal(t, 0, p.Status()[4].Failures)
+
+	// Lag is measured against the endpoints that answer.
+	a.setDown(true)
+	b.setDown(true)
+	down.setDown(true)
+	require.NoError(t, p.Check(ctx))
+	assert.Equal(t, []string{Down, Down, Lagging, Healthy, Down}, states(p))
+
+	forked.setDown(true)
+	lagging.setDown(true)
+	assert.ErrorIs(t, p.Check(ctx), ErrNoHealthy)
+}
+
+func TestRouting(t *testing.T) {
+	ctx := context.Background()
+	behind := &fakeNode{head: 98}
+	best := &fakeNode{head: 100}
+	lagging := &fakeNode{head: 10}
+	p := newPool(t, behind, best, lagging)
+	require.NoError(t, p.Check(ctx))
+	client := ethclient.NewClient(dial(t, p))
+
+	// Calls go to the healthy node with the highest head.
+	before := behind.served()
+	head, err := client.BlockNumber(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(100), head)
+	assert.Equal(t, before, behind.served())
+
+	// When it fails the call is retried on the next one.
+	best.setDown(true)
+	head, err = client.BlockNumber(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(98), head)
+	assert.Equal(t, []string{Healthy, Down, Lagging}, states(p))
+	assert.Equal(t, 1, p.Status()[1].Failures)
+
+	// A lagging node beats no answer at all.
+	behind.setDown(true)
+	head, err = client.BlockNumber(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(10), head)
+
+	lagging.setDown(true)
+	_, err = client.BlockNumber(ctx)
+	assert.ErrorContains(t, err, "every RPC endpoint failed")
+	assert.Equal(t, []string{Down, Down, Down}, states(p))
+
+	// A node that answers again is used, and JSON-RPC errors are not
+	// retried.
+	best.setDown(false)
+	_, err = client.BlockNumber(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, Unknown, p.Status()[1].State)
+	before = best.served()
+	err = dial(t, p).CallContext(ctx, nil, "eth_nonexistent")
+	assert.ErrorContains(t, err, "does not exist")
+	assert.Equal(t, before+1, best.served())
+}
+
+func TestRecheck(t *testing.T) {
+	ctx := context.Background()
+	n := &fakeNode{head: 5}
+	p, err := New([]string{serve(t, n)}, Config{CheckInterval: time.Millisecond})
+	require.NoError(t, err)
+	t.Cleanup(p.Close)
+	require.NoError(t, p.Check(ctx))
+
+	// Calls start a new check once the last one is too old.
+	n.mu.Lock()
+	n.head = 6
+	n.mu.Unlock()
+	client := ethclient.NewClient(dial(t, p))
+	assert.Eventually(t, func() bool {
+		_, err := client.BlockNumber(ctx)
+		return err == nil && p.Status()[0].Head == 6
+	}, time.Second, 5*time.Millisecond)
+}
+
+func TestStart(t *testing.T) {
+	n := &fakeNode{head: 5}
+	p, err := New([]string{serve(t, n)}, Config{CheckInterval: time.Millisecond})
+	require.NoError(t, err)
+	t.Cleanup(p.Close)
+
+	// Heights are checked without any calls going through the pool.
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	p.Start(ctx)
+	assert.Eventually(t, func() bool { return p.Status()[0].Head == 5 }, time.Second, time.Millisecond)
+	n.mu.Lock()
+	n.head = 6
+	n.mu.Unlock()
+	assert.Eventually(t, func() bool { return p.Status()[0].Head == 6 }, time.Second, time.Millisecond)
+
+	p.Close()
+	n.mu.Lock()
+	n.head = 7
+	n.mu.Unlock()
+	time.Sleep(20 * time.Millisecond)
+	assert.Equal(t, uint64(6), p.Status()[0].Head, "a closed pool stops checking")
+	c, err := p.Client()
+	require.NoError(t, err)
+	defer c.Close()
+	_, err = ethclient.NewClient(c).BlockNumber(context.Background())
+	assert.ErrorIs(t, err, ErrClosed)
+}
+
+func TestCancelledCall(t *testing.T) {
+	n := &fakeNode{head: 5}
+	release := make(chan struct{})
+	srv := rpc.NewServer()
+	require.NoError(t, srv.RegisterName("eth", ethAPI{n}))
+	t.Cleanup(srv.Stop)
+	hs := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		srv.ServeHTTP(w, r)
+	}))
+	t.Cleanup(hs.Close)
+	t.Cleanup(func() { close(release) })
+	p, err := New([]string{hs.URL}, Config{})
+	require.NoError(t, err)
+	t.Cleanup(p.Close)
+
+	// The caller giving up is not the endpoint's fault.
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err = ethclient.NewClient(dial(t, p)).BlockNumber(ctx)
+	assert.Error(t, err)
+	assert.Equal(t, Unknown, p.Status()[0].State)
+	assert.Equal(t, 0, p.Status()[0].Failures)
+}
+
+func TestRetries(t *testing.T) {
+	ctx := context.Background()
+	nodes := []*fakeNode{{head: 3, down: true}, {head: 2, down: true}, {head: 1}}
+	var urls []string
+	for _, n := range nodes {
+		urls = append(urls, serve(t, n))
+	}
+	p, err := New(urls, Config{Retries: 1})
+	require.NoError(t, err)
+	t.Cleanup(p.Close)
+	_, err = ethclient.NewClient(dial(t, p)).BlockNumber(ctx)
+	assert.ErrorContains(t, err, "every RPC endpoint failed")
+	assert.Equal(t, 0, nodes[2].served())
+}
+
+func TestNew(t *testing.T) {
+	_, err := New(nil, Config{})
+	assert.ErrorContains(t, err, "no endpoints")
+	_, err = New([]string{"http://localhost:8545", "ws://localhost:8546"}, Config{})
+	assert.ErrorContains(t, err, `"ws://localhost:8546" is not an http(s) URL`)
+}
@@ -0,0 +1,82 @@
+package sender
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"sort"
+
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// Speed selects how high a priority fee to bid.
+type Speed string
+
+// The fee presets. Each bids a percentile of the priority fees paid in
+// recent blocks.
+const (
+	Slow   Speed = "slow"
+	Normal Speed = "normal"
+	Fast   Speed = "fast"
+)
+
+var percentiles = map[Speed]float64{Slow: 10, Normal: 50, Fast: 90}
+
+// ParseSpeed parses a preset name.
+func ParseSpeed(s string) (Speed, error) {
+	if _, ok := percentiles[Speed(s)]; !ok {
+		return "", fmt.Errorf("unknown speed %q: want slow, normal or fast", s)
+	}
+	return Speed(s), nil
+}
+
+// HistoryBlocks is how many recent blocks fees are estimated from.
+const HistoryBlocks = 20
+
+// DefaultTip is bid when no recent block paid a priority fee.
+var DefaultTip = big.NewInt(params.GWei)
+
+// Fees are the fee caps of an EIP-1559 transaction.
+type Fees struct {
+	// BaseFee is the base fee of the next block.
+	BaseFee *big.Int
+	MaxFee  *big.Int
+	Tip     *big.Int
+}
+
+// EstimateFees reads eth_feeHistory for the last HistoryBlocks blocks and
+// bids the median, over blocks with transactions, of the speed's reward
+// percentile. The max fee leaves room for the base fee to double, which
+// takes six full blocks.
+func EstimateFees(ctx context.Context, b Backend, speed Speed) (Fees, error) {
+	pct, ok := percentiles[speed]
+	if !ok {
+		return Fees{}, fmt.Errorf("unknown speed %q", speed)
+	}
+	h, err := b.FeeHistory(ctx, HistoryBlocks, nil, []float64{pct})
+	if err != nil {
+		return Fees{}, fmt.Errorf("fee history: %w", err)
+	}
+	if len(h.BaseFee) == 0 {
+		return Fees{}, fmt.Errorf("fee history: no blocks")
+	}
+	// BaseFee has one more entry than there are blocks: the next block's.
+	baseFee := h.BaseFee[len(h.BaseFee)-1]
+	if baseFee == nil {
+		return Fees{}, fmt.Errorf("the chain has no base fee; EIP-1559 is not active")
+	}
+
+	var tips []*big.Int
+	for i, rewards := range h.Reward {
+		if i < len(h.GasUsedRatio) && h.GasUsedRatio[i] > 0 && len(rewards) > 0 {
+			tips = append(tips, rewards[0])
+		}
+	}
+	tip := new(big.Int).Set(DefaultTip)
+	if len(tips) > 0 {
+		sort.Slice(tips, func(i, j int) bool { return tips[i].Cmp(tips[j]) < 0 })
+		tip.Set(tips[len(tips)/2])
+	}
+	maxFee := new(big.Int).Mul(baseFee, big.NewInt(2))
+	return Fees{BaseFee: baseFee, MaxFee: maxFee.Add(maxFee, tip), Tip: tip}, nil
+}
@@ -0,0 +1,137 @@
+// Package sender builds EIP-1559 transactions, estimating their gas and
+// fees from the chain, and waits for them to be confirmed.
+package sender
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// Backend is the part of ethclient.Client a sender needs.
+type Backend interface {
+	ChainID(ctx context.Context) (*big.Int, error)
+	BlockNumber(ctx context.Context) (uint64, error)
+	FeeHistory(ctx context.Context, blockCount uint64, lastBlock *big.Int, rewardPercentiles []float64) (*ethereum.FeeHistory, error)
+	PendingNonceAt(ctx context.Context, account common.Address) (uint64, error)
+	EstimateGas(ctx context.Context, call ethereum.CallMsg) (uint64, error)
+	SendTransaction(ctx context.Context, tx *types.Transaction) error
+	TransactionReceipt(ctx context.Context, txHash common.Hash) (*types.Receipt, error)
+}
+
+// Request describes a transaction. Zero values are filled in by Build.
+type Request struct {
+	From common.Address
+	// To is nil for contract creation.
+	To    *common.Address
+	Value *big.Int
+	Data  []byte
+	// Gas is estimated when zero.
+	Gas uint64
+	// Nonce is the account's pending nonce when nil.
+	Nonce *uint64
+	// MaxFee and Tip are estimated for Speed when nil.
+	MaxFee *big.Int
+	Tip    *big.Int
+	Speed  Speed
+}
+
+// Build turns req into an unsigned EIP-1559 transaction for the
+// backend's chain.
+func Build(ctx context.Context, b Backend, req Request) (*types.DynamicFeeTx, error) {
+	chainID, err := b.ChainID(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("chain ID: %w", err)
+	}
+	value := req.Value
+	if value == nil {
+		value = new(big.Int)
+	}
+	tx := &types.DynamicFeeTx{ChainID: chainID, To: req.To, Value: value, Data: req.Data, Gas: req.Gas}
+
+	if req.Nonce != nil {
+		tx.Nonce = *req.Nonce
+	} else if tx.Nonce, err = b.PendingNonceAt(ctx, req.From); err != nil {
+		return nil, fmt.Errorf("nonce: %w", err)
+	}
+
+	tx.GasFeeCap, tx.GasTipCap = req.MaxFee, req.Tip
+	if req.MaxFee == nil || req.Tip == nil {
+		speed := req.Speed
+		if speed == "" {
+			speed = Normal
+		}
+		fees, err := EstimateFees(ctx, b, speed)
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case req.MaxFee == nil && req.Tip == nil:
+			tx.GasFeeCap, tx.GasTipCap = fees.MaxFee, fees.Tip
+		case req.MaxFee == nil:
+			tx.GasFeeCap = new(big.Int).Sub(fees.MaxFee, fees.Tip)
+			tx.GasFeeCap.Add(tx.GasFeeCap, req.Tip)
+		default:
+			// The tip can't exceed the max fee.
+			tx.GasTipCap = fees.Tip
+			if tx.GasTipCap.Cmp(req.MaxFee) > 0 {
+				tx.GasTipCap = req.MaxFee
+			}
+		}
+	}
+	if tx.GasTipCap.Cmp(tx.GasFeeCap) > 0 {
+		return nil, fmt.Errorf("priority fee %s exceeds max fee %s", tx.GasTipCap, tx.GasFeeCap)
+	}
+
+	if tx.Gas == 0 {
+		tx.Gas, err = b.EstimateGas(ctx, ethereum.CallMsg{
+			From: req.From, To: req.To, Value: value, Data: req.Data,
+			GasFeeCap: tx.GasFeeCap, GasTipCap: tx.GasTipCap,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("estimate gas: %w", err)
+		}
+	}
+	return tx, nil
+}
+
+// MaxCost is the most tx can cost: its value plus gas at the max fee.
+func MaxCost(tx *types.DynamicFeeTx) *big.Int {
+	cost := new(big.Int).Mul(tx.GasFeeCap, new(big.Int).SetUint64(tx.Gas))
+	return cost.Add(cost, tx.Value)
+}
+
+// Wait polls every interval until hash is mined and has the given number
+// of confirmations; the block that includes it is the first. If ctx ends
+// first, the receipt is returned too when the transaction was mined.
+func Wait(ctx context.Context, b Backend, hash common.Hash, confirmations uint64, interval time.Duration) (*types.Receipt, error) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		receipt, err := b.TransactionReceipt(ctx, hash)
+		if err != nil && !errors.Is(err, ethereum.NotFound) {
+			return nil, err
+		}
+		if receipt != nil {
+			head, err := b.BlockNumber(ctx)
+			if err != nil {
+				return nil, err
+			}
+			// A reorg can briefly put the receipt ahead of the head.
+			if n := receipt.BlockNumber.Uint64(); head >= n && head-n+1 >= confirmations {
+				return receipt, nil
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return receipt, fmt.Errorf("waiting for %s: %w", hash.Hex(), ctx.Err())
+		case <-ticker.C:
+		}
+	}
+}
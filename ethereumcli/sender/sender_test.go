@@ -0,0 +1,230 @@
+package sender
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"math/big"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind/backends"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus/misc"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// simBackend adds the methods ethclient has and the simulated backend
+// lacks.
+type simBackend struct {
+	*backends.SimulatedBackend
+}
+
+func (b simBackend) ChainID(ctx context.Context) (*big.Int, error) {
+	return params.AllEthashProtocolChanges.ChainID, nil
+}
+
+func (b simBackend) BlockNumber(ctx context.Context) (uint64, error) {
+	return b.Blockchain().CurrentBlock().NumberU64(), nil
+}
+
+// FeeHistory computes eth_feeHistory like geth: rewards are the tips at
+// the given percentiles of each block's gas.
+func (b simBackend) FeeHistory(ctx context.Context, count uint64, last *big.Int, percentiles []float64) (*ethereum.FeeHistory, error) {
+	head := b.Blockchain().CurrentBlock()
+	h := &ethereum.FeeHistory{}
+	first := int64(head.NumberU64()) - int64(count) + 1
+	if first < 0 {
+		first = 0
+	}
+	h.OldestBlock = big.NewInt(first)
+	for n := uint64(first); n <= head.NumberU64(); n++ {
+		block := b.Blockchain().GetBlockByNumber(n)
+		receipts := b.Blockchain().GetReceiptsByHash(block.Hash())
+		h.BaseFee = append(h.BaseFee, block.BaseFee())
+		h.GasUsedRatio = append(h.GasUsedRatio, float64(block.GasUsed())/float64(block.GasLimit()))
+
+		type txTip struct {
+			gas uint64
+			tip *big.Int
+		}
+		var tips []txTip
+		for i, tx := range block.Transactions() {
+			tip, _ := tx.EffectiveGasTip(block.BaseFee())
+			tips = append(tips, txTip{receipts[i].GasUsed, tip})
+		}
+		sort.Slice(tips, func(i, j int) bool { return tips[i].tip.Cmp(tips[j].tip) < 0 })
+		rewards := make([]*big.Int, len(percentiles))
+		for i, p := range percentiles {
+			rewards[i] = new(big.Int)
+			threshold := uint64(float64(block.GasUsed()) * p / 100)
+			var sum uint64
+			for _, t := range tips {
+				sum += t.gas
+				rewards[i] = t.tip
+				if sum >= threshold {
+					break
+				}
+			}
+		}
+		h.Reward = append(h.Reward, rewards)
+	}
+	h.BaseFee = append(h.BaseFee, misc.CalcBaseFee(params.AllEthashProtocolChanges, head.Header()))
+	return h, nil
+}
+
+type chain struct {
+	simBackend
+	t    *testing.T
+	keys []*ecdsa.PrivateKey
+}
+
+func newChain(t *testing.T, accounts int) *chain {
+	c := &chain{t: t}
+	alloc := core.GenesisAlloc{}
+	for i := 0; i < accounts; i++ {
+		key, err := crypto.GenerateKey()
+		require.NoError(t, err)
+		c.keys = append(c.keys, key)
+		alloc[crypto.PubkeyToAddress(key.PublicKey)] = core.GenesisAccount{Balance: new(big.Int).Mul(big.NewInt(100), big.NewInt(params.Ether))}
+	}
+	c.simBackend = simBackend{backends.NewSimulatedBackend(alloc, 30_000_000)}
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+func (c *chain) addr(i int) common.Address {
+	return crypto.PubkeyToAddress(c.keys[i].PublicKey)
+}
+
+// sendTip sends a transfer from account i paying tip.
+func (c *chain) sendTip(i int, tip int64) {
+	c.t.Helper()
+	ctx := context.Background()
+	nonce, err := c.PendingNonceAt(ctx, c.addr(i))
+	require.NoError(c.t, err)
+	to := common.Address{0xaa}
+	tx, err := types.SignNewTx(c.keys[i], types.LatestSignerForChainID(big.NewInt(1337)), &types.DynamicFeeTx{
+		ChainID: big.NewInt(1337), Nonce: nonce, To: &to, Value: big.NewInt(1), Gas: 21000,
+		GasTipCap: big.NewInt(tip), GasFeeCap: big.NewInt(100 * params.GWei),
+	})
+	require.NoError(c.t, err)
+	require.NoError(c.t, c.SendTransaction(ctx, tx))
+}
+
+func TestEstimateFees(t *testing.T) {
+	ctx := context.Background()
+	c := newChain(t, 3)
+
+	// An empty chain has no tips to go by.
+	fees, err := EstimateFees(ctx, c, Fast)
+	require.NoError(t, err)
+	assert.Equal(t, DefaultTip, fees.Tip)
+
+	for block := 0; block < 3; block++ {
+		c.sendTip(0, 1*params.GWei)
+		c.sendTip(1, 2*params.GWei)
+		c.sendTip(2, 3*params.GWei)
+		c.Commit()
+	}
+	// Empty blocks don't drag the estimate down.
+	c.Commit()
+
+	head, err := c.HeaderByNumber(ctx, nil)
+	require.NoError(t, err)
+	next := misc.CalcBaseFee(params.AllEthashProtocolChanges, head)
+	for speed, tip := range map[Speed]int64{Slow: 1 * params.GWei, Normal: 2 * params.GWei, Fast: 3 * params.GWei} {
+		fees, err := EstimateFees(ctx, c, speed)
+		require.NoError(t, err)
+		assert.Equal(t, big.NewInt(tip), fees.Tip, speed)
+		assert.Equal(t, next, fees.BaseFee, speed)
+		want := new(big.Int).Mul(next, big.NewInt(2))
+		assert.Equal(t, want.Add(want, fees.Tip), fees.MaxFee, speed)
+	}
+
+	_, err = ParseSpeed("ludicrous")
+	assert.ErrorContains(t, err, `unknown speed "ludicrous"`)
+}
+
+func TestBuildSendWait(t *testing.T) {
+	ctx := context.Background()
+	c := newChain(t, 2)
+	from, to := c.addr(0), c.addr(1)
+
+	tx, err := Build(ctx, c, Request{From: from, To: &to, Value: big.NewInt(params.Ether), Speed: Fast})
+	require.NoError(t, err)
+	assert.Equal(t, uint64(21000), tx.Gas)
+	assert.Equal(t, uint64(0), tx.Nonce)
+	assert.Equal(t, big.NewInt(1337), tx.ChainID)
+	assert.Equal(t, DefaultTip, tx.GasTipCap)
+	assert.Equal(t, new(big.Int).Add(big.NewInt(params.Ether), new(big.Int).Mul(tx.GasFeeCap, big.NewInt(21000))), MaxCost(tx))
+
+	signed, err := types.SignNewTx(c.keys[0], types.LatestSignerForChainID(tx.ChainID), tx)
+	require.NoError(t, err)
+	require.NoError(t, c.SendTransaction(ctx, signed))
+
+	// Mine a block every few milliseconds until Wait is satisfied.
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		for {
+			select {
+			case <-done:
+				return
+			case <-time.After(5 * time.Millisecond):
+				c.Commit()
+			}
+		}
+	}()
+	receipt, err := Wait(ctx, c, signed.Hash(), 3, time.Millisecond)
+	require.NoError(t, err)
+	assert.Equal(t, types.ReceiptStatusSuccessful, receipt.Status)
+	head, err := c.BlockNumber(ctx)
+	require.NoError(t, err)
+	assert.GreaterOrEqual(t, head, receipt.BlockNumber.Uint64()+2)
+
+	// The next transaction takes the next nonce.
+	tx, err = Build(ctx, c, Request{From: from, To: &to, Value: big.NewInt(1)})
+	require.NoError(t, err)
+	assert.Equal(t, uint64(1), tx.Nonce)
+}
+
+func TestBuildOverrides(t *testing.T) {
+	ctx := context.Background()
+	c := newChain(t, 1)
+	from := c.addr(0)
+	nonce := uint64(9)
+
+	tx, err := Build(ctx, c, Request{From: from, To: &common.Address{1}, Gas: 50000, Nonce: &nonce, Tip: big.NewInt(params.GWei)})
+	require.NoError(t, err)
+	assert.Equal(t, uint64(50000), tx.Gas)
+	assert.Equal(t, uint64(9), tx.Nonce)
+	fees, err := EstimateFees(ctx, c, Normal)
+	require.NoError(t, err)
+	assert.Equal(t, new(big.Int).Add(new(big.Int).Mul(fees.BaseFee, big.NewInt(2)), big.NewInt(params.GWei)), tx.GasFeeCap)
+
+	// The empty chain's base fee is 0.875 gwei and the default tip 1 gwei.
+	tx, err = Build(ctx, c, Request{From: from, To: &common.Address{1}, MaxFee: big.NewInt(900_000_000)})
+	require.NoError(t, err)
+	assert.Equal(t, big.NewInt(900_000_000), tx.GasTipCap, "the estimated tip is capped at the max fee")
+
+	_, err = Build(ctx, c, Request{From: from, To: &common.Address{1}, MaxFee: big.NewInt(10), Tip: big.NewInt(11)})
+	assert.ErrorContains(t, err, "priority fee 11 exceeds max fee 10")
+
+	_, err = Build(ctx, c, Request{From: from, To: &common.Address{1}, Value: new(big.Int).Mul(big.NewInt(1000), big.NewInt(params.Ether))})
+	assert.ErrorContains(t, err, "estimate gas")
+}
+
+func TestWaitTimeout(t *testing.T) {
+	c := newChain(t, 1)
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err := Wait(ctx, c, common.Hash{1}, 1, time.Millisecond)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
@@ -0,0 +1,70 @@
+// Package units converts between wei and the decimal units amounts are
+// shown in.
+package units
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// Decimals of the common ether denominations.
+const (
+	Wei   = 0
+	Gwei  = 9
+	Ether = 18
+)
+
+// Format renders value, an integer amount of the smallest unit, as a
+// decimal number with the given number of decimals. Trailing zeros of
+// the fraction are dropped.
+func Format(value *big.Int, decimals int) string {
+	if value == nil {
+		return "0"
+	}
+	neg := value.Sign() < 0
+	digits := new(big.Int).Abs(value).String()
+	if decimals > 0 {
+		if len(digits) <= decimals {
+			digits = strings.Repeat("0", decimals-len(digits)+1) + digits
+		}
+		whole, frac := digits[:len(digits)-decimals], strings.TrimRight(digits[len(digits)-decimals:], "0")
+		digits = whole
+		if frac != "" {
+			digits += "." + frac
+		}
+	}
+	if neg {
+		return "-" + digits
+	}
+	return digits
+}
+
+// Parse converts a non-negative decimal amount such as "1.5" into an
+// integer amount of the smallest unit. It fails if the amount has more
+// fractional digits than decimals.
+func Parse(s string, decimals int) (*big.Int, error) {
+	whole, frac, _ := strings.Cut(strings.TrimSpace(s), ".")
+	if whole == "" && frac == "" || strings.Trim(whole+frac, "0123456789") != "" {
+		return nil, fmt.Errorf("invalid amount %q", s)
+	}
+	if len(frac) > decimals {
+		return nil, fmt.Errorf("invalid amount %q: more than %d decimals", s, decimals)
+	}
+	v, _ := new(big.Int).SetString(whole+frac+strings.Repeat("0", decimals-len(frac)), 10)
+	return v, nil
+}
+
+// names maps the unit names accepted on the command line to decimals.
+var names = map[string]int{
+	"wei":   Wei,
+	"gwei":  Gwei,
+	"ether": Ether,
+	"eth":   Ether,
+}
+
+// Lookup returns the decimals of a named unit: wei, gwei or ether.
+func Lookup(name string) (decimals int, ok bool) {
+	decimals, ok = names[strings.ToLower(name)]
+	return decimals, ok
+}
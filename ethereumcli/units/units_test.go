@@ -0,0 +1,49 @@
+package units
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFormat(t *testing.T) {
+	for _, tc := range []struct {
+		value    int64
+		decimals int
+		want     string
+	}{
+		{0, Ether, "0"},
+		{1, Wei, "1"},
+		{1, Gwei, "0.000000001"},
+		{1_500_000_000, Gwei, "1.5"},
+		{2_000_000_000, Gwei, "2"},
+		{-25, 1, "-2.5"},
+	} {
+		assert.Equal(t, tc.want, Format(big.NewInt(tc.value), tc.decimals), "%d/%d", tc.value, tc.decimals)
+	}
+	eth, _ := new(big.Int).SetString("123456789000000000000", 10)
+	assert.Equal(t, "123.456789", Format(eth, Ether))
+	assert.Equal(t, "0", Format(nil, Ether))
+}
+
+func TestParse(t *testing.T) {
+	for in, want := range map[string]string{
+		"1.5":         "1500000000000000000",
+		"0.000000001": "1000000000",
+		".5":          "500000000000000000",
+		"2.":          "2000000000000000000",
+		"0":           "0",
+	} {
+		v, err := Parse(in, Ether)
+		if assert.NoError(t, err, in) {
+			assert.Equal(t, want, v.String(), in)
+		}
+	}
+	for _, in := range []string{"", ".", "-1", "1e18", "1.2.3", "0x10"} {
+		_, err := Parse(in, Ether)
+		assert.ErrorContains(t, err, "invalid amount", in)
+	}
+	_, err := Parse("1.5", Wei)
+	assert.ErrorContains(t, err, "more than 0 decimals")
+}
@@ -0,0 +1,97 @@
+package wallet
+
+import (
+	"crypto/ecdsa"
+	"crypto/hmac"
+	"crypto/sha512"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/tyler-smith/go-bip39"
+)
+
+// DefaultPath is the BIP-44 path of the first Ethereum account,
+// m/44'/60'/0'/0/0.
+var DefaultPath = accounts.DefaultBaseDerivationPath
+
+// errInvalidChild is returned in the astronomically unlikely case that a
+// BIP-32 derivation step yields an invalid key.
+var errInvalidChild = errors.New("derived key is invalid; use the next index")
+
+// NewMnemonic returns a random BIP-39 English mnemonic of 12, 15, 18, 21
+// or 24 words.
+func NewMnemonic(words int) (string, error) {
+	if words < 12 || words > 24 || words%3 != 0 {
+		return "", fmt.Errorf("invalid mnemonic length %d: want 12, 15, 18, 21 or 24 words", words)
+	}
+	entropy, err := bip39.NewEntropy(words / 3 * 32)
+	if err != nil {
+		return "", err
+	}
+	return bip39.NewMnemonic(entropy)
+}
+
+// NormalizeMnemonic lower-cases a mnemonic and collapses its whitespace.
+func NormalizeMnemonic(mnemonic string) string {
+	return strings.Join(strings.Fields(strings.ToLower(mnemonic)), " ")
+}
+
+// DeriveKey checks a BIP-39 mnemonic and derives the key at path from its
+// seed. passphrase is the optional BIP-39 passphrase, not the keystore
+// password.
+func DeriveKey(mnemonic, passphrase string, path accounts.DerivationPath) (*ecdsa.PrivateKey, error) {
+	seed, err := bip39.NewSeedWithErrorChecking(NormalizeMnemonic(mnemonic), passphrase)
+	if err != nil {
+		return nil, fmt.Errorf("invalid mnemonic: %w", err)
+	}
+	return deriveFromSeed(seed, path)
+}
+
+// deriveFromSeed walks a BIP-32 path from the master key of seed. Only
+// private derivation is needed, so extended public keys are never built.
+func deriveFromSeed(seed []byte, path accounts.DerivationPath) (*ecdsa.PrivateKey, error) {
+	sum := hmacSHA512([]byte("Bitcoin seed"), seed)
+	key, chain := new(big.Int).SetBytes(sum[:32]), sum[32:]
+	n := crypto.S256().Params().N
+	if key.Sign() == 0 || key.Cmp(n) >= 0 {
+		return nil, errInvalidChild
+	}
+	for _, index := range path {
+		data := make([]byte, 0, 37)
+		if index >= 0x80000000 {
+			data = append(data, 0)
+			data = append(data, crypto.FromECDSA(toECDSA(key))...)
+		} else {
+			data = append(data, crypto.CompressPubkey(&toECDSA(key).PublicKey)...)
+		}
+		data = binary.BigEndian.AppendUint32(data, index)
+		sum := hmacSHA512(chain, data)
+		tweak := new(big.Int).SetBytes(sum[:32])
+		if tweak.Cmp(n) >= 0 {
+			return nil, errInvalidChild
+		}
+		key = tweak.Add(tweak, key).Mod(tweak, n)
+		if key.Sign() == 0 {
+			return nil, errInvalidChild
+		}
+		chain = sum[32:]
+	}
+	return toECDSA(key), nil
+}
+
+func toECDSA(d *big.Int) *ecdsa.PrivateKey {
+	// d is in [1, n), so ToECDSA cannot fail.
+	key, _ := crypto.ToECDSA(d.FillBytes(make([]byte, 32)))
+	return key
+}
+
+func hmacSHA512(key, data []byte) []byte {
+	mac := hmac.New(sha512.New, key)
+	mac.Write(data)
+	return mac.Sum(nil)
+}
@@ -0,0 +1,106 @@
+// Package wallet manages keys in an encrypted go-ethereum keystore
+// directory (scrypt, JSON v3) and derives them from BIP-39 mnemonics.
+package wallet
+
+import (
+	"crypto/ecdsa"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// ErrExists is returned when importing a key the keystore already holds.
+var ErrExists = errors.New("account already exists")
+
+// ErrNoAccount is returned for addresses the keystore doesn't hold.
+var ErrNoAccount = errors.New("no such account in the keystore")
+
+// Wallet is a keystore directory.
+type Wallet struct {
+	dir string
+	ks  *keystore.KeyStore
+}
+
+// Open opens the keystore in dir; it is created with the first key.
+// scryptN and scryptP set the cost of encrypting new keys, normally
+// keystore.StandardScryptN and keystore.StandardScryptP.
+func Open(dir string, scryptN, scryptP int) *Wallet {
+	return &Wallet{dir: dir, ks: keystore.NewKeyStore(dir, scryptN, scryptP)}
+}
+
+// Dir returns the keystore directory.
+func (w *Wallet) Dir() string {
+	return w.dir
+}
+
+// Accounts returns the keystore's accounts, oldest first.
+func (w *Wallet) Accounts() []accounts.Account {
+	return w.ks.Accounts()
+}
+
+// New generates a random key and stores it encrypted with password.
+func (w *Wallet) New(password string) (accounts.Account, error) {
+	return w.ks.NewAccount(password)
+}
+
+// Import stores key encrypted with password.
+func (w *Wallet) Import(key *ecdsa.PrivateKey, password string) (accounts.Account, error) {
+	// The keystore only notices existing files once its cache is loaded.
+	w.ks.Accounts()
+	a, err := w.ks.ImportECDSA(key, password)
+	if errors.Is(err, keystore.ErrAccountAlreadyExists) {
+		return a, fmt.Errorf("%s: %w", a.Address.Hex(), ErrExists)
+	}
+	return a, err
+}
+
+// Find returns the account for addr.
+func (w *Wallet) Find(addr common.Address) (accounts.Account, error) {
+	a, err := w.ks.Find(accounts.Account{Address: addr})
+	if err != nil {
+		return a, fmt.Errorf("%s: %w", addr.Hex(), ErrNoAccount)
+	}
+	return a, nil
+}
+
+// ExportJSON returns addr's key file re-encrypted with the same password,
+// which proves the password is right.
+func (w *Wallet) ExportJSON(addr common.Address, password string) ([]byte, error) {
+	a, err := w.Find(addr)
+	if err != nil {
+		return nil, err
+	}
+	return w.ks.Export(a, password, password)
+}
+
+// PrivateKey decrypts addr's key.
+func (w *Wallet) PrivateKey(addr common.Address, password string) (*ecdsa.PrivateKey, error) {
+	a, err := w.Find(addr)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(a.URL.Path)
+	if err != nil {
+		return nil, err
+	}
+	key, err := keystore.DecryptKey(data, password)
+	if err != nil {
+		return nil, err
+	}
+	return key.PrivateKey, nil
+}
+
+// SignTx signs tx with addr's key for the given chain.
+func (w *Wallet) SignTx(addr common.Address, password string, tx *types.Transaction, chainID *big.Int) (*types.Transaction, error) {
+	a, err := w.Find(addr)
+	if err != nil {
+		return nil, err
+	}
+	return w.ks.SignTxWithPassphrase(a, password, tx, chainID)
+}
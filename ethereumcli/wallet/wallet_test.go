@@ -0,0 +1,86 @@
+package wallet
+
+import (
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testMnemonic is the usual all-"abandon" BIP-39 test phrase.
+const testMnemonic = "abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about"
+
+func TestDeriveKey(t *testing.T) {
+	key, err := DeriveKey(testMnemonic, "", DefaultPath)
+	require.NoError(t, err)
+	assert.Equal(t, "0x9858EfFD232B4033E47d90003D41EC34EcaEda94", crypto.PubkeyToAddress(key.PublicKey).Hex())
+	assert.Equal(t, "1ab42cc412b618bdea3a599e3c9bae199ebf030895b039e9db1e30dafb12b727", hex.EncodeToString(crypto.FromECDSA(key)))
+
+	second, err := accounts.ParseDerivationPath("m/44'/60'/0'/0/1")
+	require.NoError(t, err)
+	key, err = DeriveKey("  ABANDON "+strings.TrimPrefix(testMnemonic, "abandon"), "", second)
+	require.NoError(t, err)
+	assert.Equal(t, "0x6Fac4D18c912343BF86fa7049364Dd4E424Ab9C0", crypto.PubkeyToAddress(key.PublicKey).Hex())
+
+	_, err = DeriveKey(strings.Replace(testMnemonic, "about", "abandon", 1), "", DefaultPath)
+	assert.ErrorContains(t, err, "invalid mnemonic")
+}
+
+// TestDeriveFromSeed checks BIP-32 test vector 1.
+func TestDeriveFromSeed(t *testing.T) {
+	seed, _ := hex.DecodeString("000102030405060708090a0b0c0d0e0f")
+	path, err := accounts.ParseDerivationPath("m/0'/1/2'/2/1000000000")
+	require.NoError(t, err)
+	key, err := deriveFromSeed(seed, path)
+	require.NoError(t, err)
+	assert.Equal(t, "471b76e389e528d6de6d816857e012c5455051cad6660850e58372a6c3e6e7c8", hex.EncodeToString(crypto.FromECDSA(key)))
+}
+
+func TestNewMnemonic(t *testing.T) {
+	for _, words := range []int{12, 24} {
+		m, err := NewMnemonic(words)
+		require.NoError(t, err)
+		assert.Len(t, strings.Fields(m), words)
+		_, err = DeriveKey(m, "", DefaultPath)
+		assert.NoError(t, err)
+	}
+	_, err := NewMnemonic(13)
+	assert.Error(t, err)
+}
+
+func TestWallet(t *testing.T) {
+	w := Open(t.TempDir(), keystore.LightScryptN, keystore.LightScryptP)
+	key, err := DeriveKey(testMnemonic, "", DefaultPath)
+	require.NoError(t, err)
+	a, err := w.Import(key, "secret")
+	require.NoError(t, err)
+	b, err := w.New("other")
+	require.NoError(t, err)
+
+	// A second handle on the directory sees both keys and the duplicate.
+	w = Open(w.Dir(), keystore.LightScryptN, keystore.LightScryptP)
+	assert.Len(t, w.Accounts(), 2)
+	_, err = w.Import(key, "secret")
+	assert.ErrorIs(t, err, ErrExists)
+
+	got, err := w.PrivateKey(a.Address, "secret")
+	require.NoError(t, err)
+	assert.Equal(t, crypto.FromECDSA(key), crypto.FromECDSA(got))
+	_, err = w.PrivateKey(b.Address, "secret")
+	assert.ErrorIs(t, err, keystore.ErrDecrypt)
+
+	data, err := w.ExportJSON(b.Address, "other")
+	require.NoError(t, err)
+	exported, err := keystore.DecryptKey(data, "other")
+	require.NoError(t, err)
+	assert.Equal(t, b.Address, exported.Address)
+
+	_, err = w.Find(common.HexToAddress("0x01"))
+	assert.ErrorIs(t, err, ErrNoAccount)
+}
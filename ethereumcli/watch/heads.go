@@ -0,0 +1,110 @@
+package watch
+
+import (
+	"context"
+	"errors"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// Heads calls emit with the current head and every new one until ctx is
+// done or emit fails. Heads missed while disconnected or between polls
+// are fetched, up to the last 128; after a reorg the new branch's head
+// may repeat a number.
+func Heads(ctx context.Context, cfg Config, emit func(Head) error) error {
+	w := newWatcher(cfg)
+	return w.run(ctx, func(ctx context.Context, c *rpc.Client) error {
+		return w.heads(ctx, ethclient.NewClient(c), emit)
+	})
+}
+
+func (w *watcher) heads(ctx context.Context, ec *ethclient.Client, emit func(Head) error) error {
+	ch := make(chan *types.Header, 64)
+	sub, err := ec.SubscribeNewHead(ctx, ch)
+	if errors.Is(err, rpc.ErrNotificationsUnsupported) {
+		return w.pollHeads(ctx, ec, emit)
+	}
+	if err != nil {
+		return err
+	}
+	defer sub.Unsubscribe()
+	w.connected(Subscribed)
+
+	head, err := w.header(ctx, ec, nil)
+	if err != nil {
+		return err
+	}
+	if !w.seen || head.Number.Uint64() > w.last {
+		if err := w.head(ctx, ec, head, emit); err != nil {
+			return err
+		}
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case err := <-sub.Err():
+			if err == nil {
+				err = errSubscriptionClosed
+			}
+			return err
+		case h := <-ch:
+			if err := w.head(ctx, ec, h, emit); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (w *watcher) pollHeads(ctx context.Context, ec *ethclient.Client, emit func(Head) error) error {
+	w.connected(Polling)
+	for {
+		head, err := w.header(ctx, ec, nil)
+		if err != nil {
+			return err
+		}
+		if !w.seen || head.Number.Uint64() > w.last {
+			if err := w.head(ctx, ec, head, emit); err != nil {
+				return err
+			}
+		}
+		if !w.wait(ctx) {
+			return nil
+		}
+	}
+}
+
+// head emits h, first fetching the heads between the last one and h.
+func (w *watcher) head(ctx context.Context, ec *ethclient.Client, h *types.Header, emit func(Head) error) error {
+	if h.Hash() == w.hash {
+		return nil
+	}
+	n := h.Number.Uint64()
+	if w.seen && n > w.last+1 {
+		from := w.last + 1
+		if n-from > maxBackfill {
+			from = n - maxBackfill
+		}
+		for i := from; i < n; i++ {
+			missed, err := w.header(ctx, ec, new(big.Int).SetUint64(i))
+			if err != nil {
+				return err
+			}
+			if err := w.emitHead(missed, emit); err != nil {
+				return err
+			}
+		}
+	}
+	return w.emitHead(h, emit)
+}
+
+func (w *watcher) emitHead(h *types.Header, emit func(Head) error) error {
+	if err := emit(newHead(h)); err != nil {
+		return emitError{err}
+	}
+	w.seen, w.last, w.hash = true, h.Number.Uint64(), h.Hash()
+	return nil
+}
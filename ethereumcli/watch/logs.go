@@ -0,0 +1,157 @@
+package watch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// Logs calls emit with every new log matching q's addresses and topics
+// until ctx is done or emit fails. If q.FromBlock is set the logs since
+// that block come first. Logs of blocks mined while disconnected are
+// fetched with eth_getLogs after redialing, including the rest of a block
+// whose logs were cut off. Over a subscription, logs of blocks dropped by
+// a reorg come again with Removed set; polling cannot tell, so it never
+// reports them.
+func Logs(ctx context.Context, cfg Config, q ethereum.FilterQuery, emit func(Log) error) error {
+	w := newWatcher(cfg)
+	if q.FromBlock != nil {
+		// The genesis block holds no logs, so starting after it when
+		// asked for block 0 loses nothing.
+		w.seen = true
+		if from := q.FromBlock.Uint64(); from > 0 {
+			w.last = from - 1
+		}
+	}
+	q.FromBlock, q.ToBlock, q.BlockHash = nil, nil, nil
+	return w.run(ctx, func(ctx context.Context, c *rpc.Client) error {
+		return w.logs(ctx, ethclient.NewClient(c), q, emit)
+	})
+}
+
+func (w *watcher) logs(ctx context.Context, ec *ethclient.Client, q ethereum.FilterQuery, emit func(Log) error) error {
+	ch := make(chan types.Log, 256)
+	sub, err := ec.SubscribeFilterLogs(ctx, q, ch)
+	if errors.Is(err, rpc.ErrNotificationsUnsupported) {
+		return w.pollLogs(ctx, ec, q, emit)
+	}
+	if err != nil {
+		return err
+	}
+	defer sub.Unsubscribe()
+	w.connected(Subscribed)
+
+	// The subscription may deliver logs catchUp fetched again.
+	caughtUp, err := w.catchUp(ctx, ec, q, emit)
+	if err != nil {
+		return err
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case err := <-sub.Err():
+			if err == nil {
+				err = errSubscriptionClosed
+			}
+			return err
+		case l := <-ch:
+			key := logKey{l.BlockHash, l.Index}
+			if !l.Removed && (l.BlockNumber <= caughtUp || w.sent[key]) {
+				continue
+			}
+			if err := emit(newLog(l)); err != nil {
+				return emitError{err}
+			}
+			if l.Removed {
+				continue
+			}
+			// Logs come in block order, so the blocks before this one
+			// are complete; this one may not be yet.
+			if l.BlockNumber > w.last+1 {
+				w.last, w.sent = l.BlockNumber-1, nil
+			}
+			if w.sent == nil {
+				w.sent = map[logKey]bool{}
+			}
+			w.sent[key] = true
+		}
+	}
+}
+
+func (w *watcher) pollLogs(ctx context.Context, ec *ethclient.Client, q ethereum.FilterQuery, emit func(Log) error) error {
+	w.connected(Polling)
+	for {
+		if _, err := w.catchUp(ctx, ec, q, emit); err != nil {
+			return err
+		}
+		if !w.wait(ctx) {
+			return nil
+		}
+	}
+}
+
+// catchUp emits the logs of the blocks after the last complete one up to
+// the head, skipping those already sent, and returns the last block it
+// fetched, or 0 if none. The first time, with no start block, it only
+// notes the head. Blocks are fetched at most Chunk at a time.
+func (w *watcher) catchUp(ctx context.Context, ec *ethclient.Client, q ethereum.FilterQuery, emit func(Log) error) (uint64, error) {
+	head, err := w.header(ctx, ec, nil)
+	if err != nil {
+		return 0, err
+	}
+	n := head.Number.Uint64()
+	if !w.seen {
+		w.seen, w.last = true, n
+		return 0, nil
+	}
+	if n <= w.last {
+		return 0, nil
+	}
+	for w.last < n {
+		from := w.last + 1
+		end := from + w.chunk - 1
+		if end > n || end < from {
+			end = n
+		}
+		q.FromBlock, q.ToBlock = new(big.Int).SetUint64(from), new(big.Int).SetUint64(end)
+		logs, err := w.filterLogs(ctx, ec, q)
+		if err != nil {
+			if ctx.Err() != nil || w.chunk == 1 {
+				return 0, fmt.Errorf("logs of blocks %d-%d: %w", from, end, err)
+			}
+			w.chunk /= 2
+			w.streak = 0
+			continue
+		}
+		for _, l := range logs {
+			if w.sent[logKey{l.BlockHash, l.Index}] {
+				continue
+			}
+			if err := emit(newLog(l)); err != nil {
+				return 0, emitError{err}
+			}
+		}
+		w.last, w.sent = end, nil
+		if w.streak++; w.streak >= growAfter && w.chunk < w.cfg.Chunk {
+			w.chunk *= 2
+			if w.chunk > w.cfg.Chunk {
+				w.chunk = w.cfg.Chunk
+			}
+			w.streak = 0
+		}
+	}
+	return n, nil
+}
+
+func (w *watcher) filterLogs(ctx context.Context, ec *ethclient.Client, q ethereum.FilterQuery) ([]types.Log, error) {
+	ctx, cancel := w.rpcContext(ctx)
+	defer cancel()
+	return ec.FilterLogs(ctx, q)
+}
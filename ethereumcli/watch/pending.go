@@ -0,0 +1,78 @@
+package watch
+
+import (
+	"context"
+	"errors"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// PendingTxs calls emit with the hash of every transaction entering the
+// node's pool until ctx is done or emit fails. Over HTTP it polls a
+// pending-transaction filter, which not every provider offers.
+func PendingTxs(ctx context.Context, cfg Config, emit func(Pending) error) error {
+	w := newWatcher(cfg)
+	return w.run(ctx, func(ctx context.Context, c *rpc.Client) error {
+		return w.pending(ctx, c, emit)
+	})
+}
+
+func (w *watcher) pending(ctx context.Context, c *rpc.Client, emit func(Pending) error) error {
+	ch := make(chan common.Hash, 1024)
+	subCtx, cancel := w.rpcContext(ctx)
+	sub, err := c.EthSubscribe(subCtx, ch, "newPendingTransactions")
+	cancel()
+	if errors.Is(err, rpc.ErrNotificationsUnsupported) {
+		return w.pollPending(ctx, c, emit)
+	}
+	if err != nil {
+		return err
+	}
+	defer sub.Unsubscribe()
+	w.connected(Subscribed)
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case err := <-sub.Err():
+			if err == nil {
+				err = errSubscriptionClosed
+			}
+			return err
+		case h := <-ch:
+			if err := emit(Pending{Hash: h.Hex()}); err != nil {
+				return emitError{err}
+			}
+		}
+	}
+}
+
+func (w *watcher) pollPending(ctx context.Context, c *rpc.Client, emit func(Pending) error) error {
+	var id string
+	if err := w.call(ctx, c, &id, "eth_newPendingTransactionFilter"); err != nil {
+		return err
+	}
+	defer w.call(context.Background(), c, nil, "eth_uninstallFilter", id)
+	w.connected(Polling)
+	for {
+		if !w.wait(ctx) {
+			return nil
+		}
+		var hashes []common.Hash
+		if err := w.call(ctx, c, &hashes, "eth_getFilterChanges", id); err != nil {
+			return err
+		}
+		for _, h := range hashes {
+			if err := emit(Pending{Hash: h.Hex()}); err != nil {
+				return emitError{err}
+			}
+		}
+	}
+}
+
+func (w *watcher) call(ctx context.Context, c *rpc.Client, result interface{}, method string, args ...interface{}) error {
+	ctx, cancel := w.rpcContext(ctx)
+	defer cancel()
+	return c.CallContext(ctx, result, method, args...)
+}
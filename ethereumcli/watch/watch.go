@@ -0,0 +1,248 @@
+// Package watch follows new heads, logs and pending transactions. It
+// subscribes over WebSocket and IPC, polls over HTTP, and redials with
+// exponential backoff when the connection drops.
+package watch
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// Defaults for Config.
+const (
+	DefaultPollInterval = 4 * time.Second
+	DefaultMinBackoff   = time.Second
+	DefaultMaxBackoff   = time.Minute
+	DefaultChunk        = 2000
+)
+
+// growAfter is how many ranges in a row must succeed before a chunk that
+// was halved is doubled again.
+const growAfter = 4
+
+// maxBackfill caps how many missed heads are fetched after a gap.
+const maxBackfill = 128
+
+// Config says how to reach the node.
+type Config struct {
+	// Dial connects to the node; it is called again after every failure.
+	Dial func(ctx context.Context) (*rpc.Client, error)
+	// PollInterval is how often to poll when the node cannot push.
+	PollInterval time.Duration
+	// MinBackoff and MaxBackoff bound the wait before redialing; it
+	// doubles after every failed attempt.
+	MinBackoff, MaxBackoff time.Duration
+	// Timeout bounds dialing and each RPC call; zero means no limit.
+	Timeout time.Duration
+	// Chunk is the largest block range Logs asks for at once when it
+	// catches up; ranges are halved while the node refuses them.
+	Chunk uint64
+	// Status, if set, is told when the watcher subscribes, starts
+	// polling or loses the connection.
+	Status func(Status)
+}
+
+// Connection states.
+const (
+	Subscribed   = "subscribed"
+	Polling      = "polling"
+	Disconnected = "disconnected"
+)
+
+// Status is a change of connection state. Err and Retry are set when
+// Disconnected.
+type Status struct {
+	State string
+	Err   error
+	Retry time.Duration
+}
+
+// Head is a new block header.
+type Head struct {
+	Number     uint64    `json:"number"`
+	Hash       string    `json:"hash"`
+	ParentHash string    `json:"parent_hash"`
+	Time       time.Time `json:"time"`
+	Miner      string    `json:"miner"`
+	GasUsed    uint64    `json:"gas_used"`
+	GasLimit   uint64    `json:"gas_limit"`
+	BaseFee    string    `json:"base_fee,omitempty"`
+}
+
+func newHead(h *types.Header) Head {
+	head := Head{
+		Number:     h.Number.Uint64(),
+		Hash:       h.Hash().Hex(),
+		ParentHash: h.ParentHash.Hex(),
+		Time:       time.Unix(int64(h.Time), 0).UTC(),
+		Miner:      h.Coinbase.Hex(),
+		GasUsed:    h.GasUsed,
+		GasLimit:   h.GasLimit,
+	}
+	if h.BaseFee != nil {
+		head.BaseFee = h.BaseFee.String()
+	}
+	return head
+}
+
+// Log is a log matching the filter. Removed logs belonged to blocks a
+// reorg took off the chain.
+type Log struct {
+	BlockNumber uint64   `json:"block_number"`
+	BlockHash   string   `json:"block_hash"`
+	TxHash      string   `json:"tx_hash"`
+	Index       uint     `json:"index"`
+	Address     string   `json:"address"`
+	Topics      []string `json:"topics"`
+	Data        string   `json:"data"`
+	Removed     bool     `json:"removed,omitempty"`
+}
+
+func newLog(l types.Log) Log {
+	topics := make([]string, len(l.Topics))
+	for i, t := range l.Topics {
+		topics[i] = t.Hex()
+	}
+	return Log{
+		BlockNumber: l.BlockNumber,
+		BlockHash:   l.BlockHash.Hex(),
+		TxHash:      l.TxHash.Hex(),
+		Index:       l.Index,
+		Address:     l.Address.Hex(),
+		Topics:      topics,
+		Data:        "0x" + common.Bytes2Hex(l.Data),
+		Removed:     l.Removed,
+	}
+}
+
+// Pending is the hash of a transaction that entered the node's pool.
+type Pending struct {
+	Hash string `json:"hash"`
+}
+
+// emitError carries an error of the caller's emit function, which ends
+// the watch instead of causing a redial.
+type emitError struct{ err error }
+
+func (e emitError) Error() string { return e.err.Error() }
+
+// watcher holds what survives a redial: the last block seen and the
+// block range the node accepts.
+type watcher struct {
+	cfg  Config
+	up   bool
+	seen bool
+	last uint64
+	hash common.Hash
+	// sent holds the logs emitted of block last+1, which a subscription
+	// may have delivered only in part before the connection dropped.
+	sent map[logKey]bool
+	// chunk and streak size eth_getLogs ranges like the indexer does.
+	chunk  uint64
+	streak int
+}
+
+type logKey struct {
+	block common.Hash
+	index uint
+}
+
+func newWatcher(cfg Config) *watcher {
+	if cfg.PollInterval == 0 {
+		cfg.PollInterval = DefaultPollInterval
+	}
+	if cfg.MinBackoff == 0 {
+		cfg.MinBackoff = DefaultMinBackoff
+	}
+	if cfg.Chunk == 0 {
+		cfg.Chunk = DefaultChunk
+	}
+	if cfg.MaxBackoff < cfg.MinBackoff {
+		cfg.MaxBackoff = DefaultMaxBackoff
+		if cfg.MaxBackoff < cfg.MinBackoff {
+			cfg.MaxBackoff = cfg.MinBackoff
+		}
+	}
+	return &watcher{cfg: cfg, chunk: cfg.Chunk}
+}
+
+// run dials and follows until ctx is done or emit fails, redialing with
+// backoff whenever the connection or a call fails. The backoff starts
+// over once a connection has been following.
+func (w *watcher) run(ctx context.Context, follow func(ctx context.Context, c *rpc.Client) error) error {
+	backoff := w.cfg.MinBackoff
+	for {
+		w.up = false
+		dialCtx, cancel := w.rpcContext(ctx)
+		c, err := w.cfg.Dial(dialCtx)
+		cancel()
+		if err == nil {
+			err = follow(ctx, c)
+			c.Close()
+		}
+		var stop emitError
+		if errors.As(err, &stop) {
+			return stop.err
+		}
+		if ctx.Err() != nil {
+			return nil
+		}
+		if w.up {
+			backoff = w.cfg.MinBackoff
+		}
+		w.status(Status{State: Disconnected, Err: err, Retry: backoff})
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(backoff):
+		}
+		if backoff *= 2; backoff > w.cfg.MaxBackoff {
+			backoff = w.cfg.MaxBackoff
+		}
+	}
+}
+
+func (w *watcher) connected(state string) {
+	w.up = true
+	w.status(Status{State: state})
+}
+
+func (w *watcher) status(s Status) {
+	if w.cfg.Status != nil {
+		w.cfg.Status(s)
+	}
+}
+
+func (w *watcher) rpcContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if w.cfg.Timeout == 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, w.cfg.Timeout)
+}
+
+func (w *watcher) header(ctx context.Context, ec *ethclient.Client, number *big.Int) (*types.Header, error) {
+	ctx, cancel := w.rpcContext(ctx)
+	defer cancel()
+	return ec.HeaderByNumber(ctx, number)
+}
+
+// wait sleeps for the poll interval; it reports false if ctx is done.
+func (w *watcher) wait(ctx context.Context) bool {
+	select {
+	case <-ctx.Done():
+		return false
+	case <-time.After(w.cfg.PollInterval):
+		return true
+	}
+}
+
+// errSubscriptionClosed is returned if the node ends a subscription
+// without an error.
+var errSubscriptionClosed = errors.New("subscription closed by the node")
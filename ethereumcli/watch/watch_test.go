@@ -0,0 +1,491 @@
+package watch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/event"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	contract = common.HexToAddress("0x00000000000000000000000000000000000C0DE5")
+	other    = common.HexToAddress("0x0000000000000000000000000000000000000123")
+)
+
+// fakeChain is a node whose blocks the test mines. It pushes heads, logs
+// and pending transactions to subscribers and serves them to pollers.
+type fakeChain struct {
+	mu      sync.Mutex
+	headers []*types.Header
+	logs    []types.Log
+	pending []common.Hash
+	// filters maps a pending-transaction filter to its next index in
+	// pending.
+	filters map[string]int
+	// maxRange, if set, is the largest block range eth_getLogs serves.
+	maxRange uint64
+
+	headFeed, logFeed, txFeed event.Feed
+}
+
+func newFakeChain() *fakeChain {
+	return &fakeChain{
+		headers: []*types.Header{{Number: big.NewInt(0), Difficulty: big.NewInt(0)}},
+		filters: map[string]int{},
+	}
+}
+
+// mine adds a block with a log from each of addrs.
+func (c *fakeChain) mine(addrs ...common.Address) {
+	c.minePushing(len(addrs), addrs...)
+}
+
+// minePushing is mine, but only the first push logs reach subscribers,
+// as when a connection drops in the middle of a block.
+func (c *fakeChain) minePushing(push int, addrs ...common.Address) {
+	c.mu.Lock()
+	parent := c.headers[len(c.headers)-1]
+	h := &types.Header{
+		ParentHash: parent.Hash(),
+		Number:     big.NewInt(int64(len(c.headers))),
+		Time:       1_700_000_000 + 12*uint64(len(c.headers)),
+		Difficulty: big.NewInt(0),
+		GasLimit:   30_000_000,
+		BaseFee:    big.NewInt(1_000_000_000),
+	}
+	c.headers = append(c.headers, h)
+	var logs []types.Log
+	for i, addr := range addrs {
+		logs = append(logs, types.Log{
+			Address: addr, Topics: []common.Hash{{0x01}}, Data: []byte{byte(i)},
+			BlockNumber: h.Number.Uint64(), BlockHash: h.Hash(), TxHash: common.Hash{byte(h.Number.Uint64())}, Index: uint(i),
+		})
+	}
+	c.logs = append(c.logs, logs...)
+	c.mu.Unlock()
+
+	c.headFeed.Send(h)
+	for _, l := range logs[:push] {
+		c.logFeed.Send(l)
+	}
+}
+
+func (c *fakeChain) addPending(h common.Hash) {
+	c.mu.Lock()
+	c.pending = append(c.pending, h)
+	c.mu.Unlock()
+	c.txFeed.Send(h)
+}
+
+type filterCrit struct {
+	FromBlock rpc.BlockNumber  `json:"fromBlock"`
+	ToBlock   rpc.BlockNumber  `json:"toBlock"`
+	Addresses []common.Address `json:"address"`
+}
+
+func (f filterCrit) match(l types.Log) bool {
+	for _, a := range f.Addresses {
+		if a == l.Address {
+			return true
+		}
+	}
+	return len(f.Addresses) == 0
+}
+
+type ethAPI struct{ c *fakeChain }
+
+func (a ethAPI) GetBlockByNumber(number rpc.BlockNumber, full bool) *types.Header {
+	a.c.mu.Lock()
+	defer a.c.mu.Unlock()
+	if number < 0 {
+		return a.c.headers[len(a.c.headers)-1]
+	}
+	if int(number) >= len(a.c.headers) {
+		return nil
+	}
+	return a.c.headers[number]
+}
+
+func (a ethAPI) GetLogs(crit filterCrit) ([]types.Log, error) {
+	a.c.mu.Lock()
+	defer a.c.mu.Unlock()
+	if a.c.maxRange > 0 && crit.ToBlock >= 0 && uint64(crit.ToBlock-crit.FromBlock) >= a.c.maxRange {
+		return nil, fmt.Errorf("block range exceeds %d", a.c.maxRange)
+	}
+	logs := []types.Log{}
+	for _, l := range a.c.logs {
+		if crit.match(l) && l.BlockNumber >= uint64(crit.FromBlock) && (crit.ToBlock < 0 || l.BlockNumber <= uint64(crit.ToBlock)) {
+			logs = append(logs, l)
+		}
+	}
+	return logs, nil
+}
+
+func (a ethAPI) NewHeads(ctx context.Context) (*rpc.Subscription, error) {
+	return notify(ctx, &a.c.headFeed, make(chan *types.Header), func(*types.Header) bool { return true })
+}
+
+func (a ethAPI) Logs(ctx context.Context, crit filterCrit) (*rpc.Subscription, error) {
+	return notify(ctx, &a.c.logFeed, make(chan types.Log), crit.match)
+}
+
+func (a ethAPI) NewPendingTransactions(ctx context.Context) (*rpc.Subscription, error) {
+	return notify(ctx, &a.c.txFeed, make(chan common.Hash), func(common.Hash) bool { return true })
+}
+
+// notify forwards the values of feed that keep accepts to a new
+// subscription.
+func notify[T any](ctx context.Context, feed *event.Feed, ch chan T, keep func(T) bool) (*rpc.Subscription, error) {
+	notifier, ok := rpc.NotifierFromContext(ctx)
+	if !ok {
+		return nil, rpc.ErrNotificationsUnsupported
+	}
+	sub := notifier.CreateSubscription()
+	feedSub := feed.Subscribe(ch)
+	go func() {
+		defer feedSub.Unsubscribe()
+		for {
+			select {
+			case v := <-ch:
+				if keep(v) {
+					notifier.Notify(sub.ID, v)
+				}
+			case <-sub.Err():
+				return
+			}
+		}
+	}()
+	return sub, nil
+}
+
+func (a ethAPI) NewPendingTransactionFilter() string {
+	a.c.mu.Lock()
+	defer a.c.mu.Unlock()
+	id := fmt.Sprintf("0x%x", len(a.c.filters)+1)
+	a.c.filters[id] = len(a.c.pending)
+	return id
+}
+
+func (a ethAPI) GetFilterChanges(id string) ([]common.Hash, error) {
+	a.c.mu.Lock()
+	defer a.c.mu.Unlock()
+	next, ok := a.c.filters[id]
+	if !ok {
+		return nil, errors.New("filter not found")
+	}
+	a.c.filters[id] = len(a.c.pending)
+	return append([]common.Hash{}, a.c.pending[next:]...), nil
+}
+
+func (a ethAPI) UninstallFilter(id string) bool {
+	a.c.mu.Lock()
+	defer a.c.mu.Unlock()
+	_, ok := a.c.filters[id]
+	delete(a.c.filters, id)
+	return ok
+}
+
+// node serves a fakeChain in-process, which supports subscriptions, or
+// over HTTP, which does not. While down it refuses connections and
+// calls.
+type node struct {
+	chain *fakeChain
+	srv   *rpc.Server
+	url   string
+	down  atomic.Bool
+
+	mu      sync.Mutex
+	clients []*rpc.Client
+}
+
+func newNode(t *testing.T, transport string) *node {
+	n := &node{chain: newFakeChain(), srv: rpc.NewServer()}
+	require.NoError(t, n.srv.RegisterName("eth", ethAPI{n.chain}))
+	t.Cleanup(n.srv.Stop)
+	if transport == "http" {
+		hs := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if n.down.Load() {
+				http.Error(w, "node down", http.StatusServiceUnavailable)
+				return
+			}
+			n.srv.ServeHTTP(w, r)
+		}))
+		t.Cleanup(hs.Close)
+		n.url = hs.URL
+	}
+	return n
+}
+
+func (n *node) dial(ctx context.Context) (*rpc.Client, error) {
+	if n.down.Load() {
+		return nil, errors.New("connection refused")
+	}
+	if n.url != "" {
+		return rpc.DialHTTP(n.url)
+	}
+	c := rpc.DialInProc(n.srv)
+	n.mu.Lock()
+	n.clients = append(n.clients, c)
+	n.mu.Unlock()
+	return c, nil
+}
+
+// setDown takes the node down, dropping in-process connections, or
+// brings it back.
+func (n *node) setDown(down bool) {
+	n.down.Store(down)
+	if !down {
+		return
+	}
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	for _, c := range n.clients {
+		c.Close()
+	}
+	n.clients = nil
+}
+
+// watch runs start in the background and returns a channel of what it
+// emits and one of its states. The watch stops with the test.
+func watch[T any](t *testing.T, n *node, start func(ctx context.Context, cfg Config, emit func(T) error) error) (<-chan T, <-chan string) {
+	ctx, cancel := context.WithCancel(context.Background())
+	values, states := make(chan T, 100), make(chan string, 100)
+	cfg := Config{
+		Dial:         n.dial,
+		PollInterval: 5 * time.Millisecond,
+		MinBackoff:   time.Millisecond,
+		MaxBackoff:   5 * time.Millisecond,
+		Status:       func(s Status) { states <- s.State },
+	}
+	done := make(chan error)
+	go func() {
+		done <- start(ctx, cfg, func(v T) error {
+			values <- v
+			return nil
+		})
+	}()
+	t.Cleanup(func() {
+		cancel()
+		assert.NoError(t, <-done)
+	})
+	return values, states
+}
+
+func next[T any](t *testing.T, ch <-chan T) T {
+	t.Helper()
+	select {
+	case v := <-ch:
+		return v
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out")
+	}
+	panic("unreachable")
+}
+
+// await reads states until want.
+func await(t *testing.T, states <-chan string, want string) {
+	t.Helper()
+	for next(t, states) != want {
+	}
+}
+
+var modes = map[string]string{"inproc": Subscribed, "http": Polling}
+
+func TestHeads(t *testing.T) {
+	for transport, mode := range modes {
+		transport, mode := transport, mode
+		t.Run(transport, func(t *testing.T) {
+			n := newNode(t, transport)
+			n.chain.mine()
+			n.chain.mine()
+			heads, states := watch(t, n, Heads)
+			await(t, states, mode)
+			first := next(t, heads)
+			assert.Equal(t, uint64(2), first.Number)
+			assert.Equal(t, "1000000000", first.BaseFee)
+
+			n.chain.mine()
+			n.chain.mine()
+			assert.Equal(t, uint64(3), next(t, heads).Number)
+			assert.Equal(t, uint64(4), next(t, heads).Number)
+
+			// Blocks mined while the node is down arrive after redialing.
+			n.setDown(true)
+			await(t, states, Disconnected)
+			n.chain.mine()
+			n.chain.mine()
+			n.setDown(false)
+			await(t, states, mode)
+			n.chain.mine()
+			for want := uint64(5); want <= 7; want++ {
+				h := next(t, heads)
+				require.Equal(t, want, h.Number)
+				assert.Equal(t, n.chain.headers[want].Hash().Hex(), h.Hash)
+				assert.Equal(t, n.chain.headers[want-1].Hash().Hex(), h.ParentHash)
+			}
+		})
+	}
+}
+
+func TestLogs(t *testing.T) {
+	for transport, mode := range modes {
+		transport, mode := transport, mode
+		t.Run(transport, func(t *testing.T) {
+			n := newNode(t, transport)
+			n.chain.mine(contract)
+			n.chain.mine(other)
+			n.chain.mine(contract, other, contract)
+			logs, states := watch(t, n, func(ctx context.Context, cfg Config, emit func(Log) error) error {
+				return Logs(ctx, cfg, ethereum.FilterQuery{FromBlock: big.NewInt(2), Addresses: []common.Address{contract}}, emit)
+			})
+			await(t, states, mode)
+
+			n.chain.mine(other, contract)
+			n.setDown(true)
+			await(t, states, Disconnected)
+			n.chain.mine(contract)
+			n.setDown(false)
+			await(t, states, mode)
+			n.chain.mine(contract)
+
+			type pos struct {
+				block uint64
+				index uint
+			}
+			var got []pos
+			for i := 0; i < 5; i++ {
+				l := next(t, logs)
+				assert.Equal(t, contract.Hex(), l.Address)
+				got = append(got, pos{l.BlockNumber, l.Index})
+			}
+			assert.Equal(t, []pos{{3, 0}, {3, 2}, {4, 1}, {5, 0}, {6, 0}}, got)
+			select {
+			case l := <-logs:
+				t.Errorf("unexpected log %+v", l)
+			case <-time.After(50 * time.Millisecond):
+			}
+		})
+	}
+}
+
+func TestLogsCutOffBlock(t *testing.T) {
+	n := newNode(t, "inproc")
+	logs, states := watch(t, n, func(ctx context.Context, cfg Config, emit func(Log) error) error {
+		return Logs(ctx, cfg, ethereum.FilterQuery{FromBlock: big.NewInt(1), Addresses: []common.Address{contract}}, emit)
+	})
+	await(t, states, Subscribed)
+
+	// Only the first log of block 1 arrives before the connection drops;
+	// the rest of the block is fetched after redialing, once.
+	n.chain.minePushing(1, contract, contract, contract)
+	l := next(t, logs)
+	assert.Equal(t, uint(0), l.Index)
+	n.setDown(true)
+	await(t, states, Disconnected)
+	n.setDown(false)
+	await(t, states, Subscribed)
+	n.chain.mine(contract)
+
+	type pos struct {
+		block uint64
+		index uint
+	}
+	var got []pos
+	for i := 0; i < 3; i++ {
+		l := next(t, logs)
+		got = append(got, pos{l.BlockNumber, l.Index})
+	}
+	assert.Equal(t, []pos{{1, 1}, {1, 2}, {2, 0}}, got)
+	select {
+	case l := <-logs:
+		t.Errorf("unexpected log %+v", l)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestLogsChunks(t *testing.T) {
+	n := newNode(t, "http")
+	n.chain.maxRange = 3
+	for i := 0; i < 10; i++ {
+		n.chain.mine(contract)
+	}
+	logs, states := watch(t, n, func(ctx context.Context, cfg Config, emit func(Log) error) error {
+		cfg.Chunk = 8
+		return Logs(ctx, cfg, ethereum.FilterQuery{FromBlock: big.NewInt(1), Addresses: []common.Address{contract}}, emit)
+	})
+	await(t, states, Polling)
+
+	// Ranges the node refuses are halved instead of redialing forever.
+	for want := uint64(1); want <= 10; want++ {
+		assert.Equal(t, want, next(t, logs).BlockNumber)
+	}
+	select {
+	case s := <-states:
+		t.Errorf("unexpected state %s", s)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestPendingTxs(t *testing.T) {
+	for transport, mode := range modes {
+		transport, mode := transport, mode
+		t.Run(transport, func(t *testing.T) {
+			n := newNode(t, transport)
+			n.chain.addPending(common.Hash{0x01})
+			txs, states := watch(t, n, PendingTxs)
+			await(t, states, mode)
+			n.chain.addPending(common.Hash{0x02})
+			n.chain.addPending(common.Hash{0x03})
+			assert.Equal(t, common.Hash{0x02}.Hex(), next(t, txs).Hash)
+			assert.Equal(t, common.Hash{0x03}.Hex(), next(t, txs).Hash)
+		})
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	var retries []time.Duration
+	ctx, cancel := context.WithCancel(context.Background())
+	cfg := Config{
+		Dial:       func(context.Context) (*rpc.Client, error) { return nil, errors.New("connection refused") },
+		MinBackoff: time.Millisecond,
+		MaxBackoff: 4 * time.Millisecond,
+		Status: func(s Status) {
+			assert.Equal(t, Disconnected, s.State)
+			assert.EqualError(t, s.Err, "connection refused")
+			if retries = append(retries, s.Retry); len(retries) == 5 {
+				cancel()
+			}
+		},
+	}
+	require.NoError(t, Heads(ctx, cfg, func(Head) error { return nil }))
+	assert.Equal(t, []time.Duration{1, 2, 4, 4, 4}, scale(retries, time.Millisecond))
+}
+
+func scale(ds []time.Duration, unit time.Duration) []time.Duration {
+	out := make([]time.Duration, len(ds))
+	for i, d := range ds {
+		out[i] = d / unit
+	}
+	return out
+}
+
+func TestEmitError(t *testing.T) {
+	n := newNode(t, "inproc")
+	broken := errors.New("broken pipe")
+	err := Heads(context.Background(), Config{Dial: n.dial}, func(Head) error { return broken })
+	assert.ErrorIs(t, err, broken)
+}
@@ -0,0 +1,56 @@
+package respondjson
+
+import (
+	"bytes"
+	"encoding/json"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// Media types Respond can produce.
+const (
+	MediaJSON    = "application/json"
+	MediaMsgPack = "application/msgpack"
+	MediaCBOR    = "application/cbor"
+	MediaNDJSON  = "application/x-ndjson"
+)
+
+type marshalFunc func(v interface{}) ([]byte, error)
+
+// codecs maps every media type we answer to its encoder. The legacy
+// MessagePack names are answered under the name the client used.
+var codecs = map[string]marshalFunc{
+	MediaJSON:                 json.Marshal,
+	MediaMsgPack:              marshalMsgPack,
+	"application/x-msgpack":   marshalMsgPack,
+	"application/vnd.msgpack": marshalMsgPack,
+	MediaCBOR:                 marshalCBOR,
+}
+
+// offers lists the codecs in order of preference; JSON comes first so it
+// wins for */* and when Accept is missing.
+var offers = []string{MediaJSON, MediaMsgPack, MediaCBOR, "application/x-msgpack", "application/vnd.msgpack"}
+
+// listOffers adds NDJSON, which only makes sense for lists and is only
+// chosen when asked for by name.
+var listOffers = append(append([]string{}, offers...), MediaNDJSON)
+
+func marshalMsgPack(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := msgpack.NewEncoder(&buf)
+	// Reuse the json tags so every format has the same field names.
+	enc.SetCustomStructTag("json")
+	enc.UseCompactInts(true)
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// cborMode encodes times as RFC 3339 strings, matching the JSON output.
+var cborMode, _ = cbor.EncOptions{Time: cbor.TimeRFC3339Nano}.EncMode()
+
+func marshalCBOR(v interface{}) ([]byte, error) {
+	return cborMode.Marshal(v)
+}
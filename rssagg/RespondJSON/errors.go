@@ -0,0 +1,135 @@
+package respondjson
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// FieldError describes one invalid input field.
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// ValidationError is returned when the request itself is malformed or
+// fails validation. It maps to 400 and lists the offending fields.
+type ValidationError struct {
+	Detail string
+	Fields []FieldError
+}
+
+func (e *ValidationError) Error() string {
+	if len(e.Fields) == 0 {
+		return e.Detail
+	}
+	return fmt.Sprintf("%s: %s: %s", e.Detail, e.Fields[0].Field, e.Fields[0].Message)
+}
+
+func (e *ValidationError) Problem() Problem {
+	return Problem{
+		Type:   problemType("validation"),
+		Title:  "Your request is not valid",
+		Status: http.StatusBadRequest,
+		Detail: e.Detail,
+		Errors: e.Fields,
+	}
+}
+
+// Validation builds a ValidationError. detail may be followed by
+// field/message pairs.
+func Validation(detail string, fieldsAndMessages ...string) error {
+	e := &ValidationError{Detail: detail}
+	for i := 0; i+1 < len(fieldsAndMessages); i += 2 {
+		e.Fields = append(e.Fields, FieldError{Field: fieldsAndMessages[i], Message: fieldsAndMessages[i+1]})
+	}
+	return e
+}
+
+// NotFoundError maps to 404.
+type NotFoundError struct {
+	Resource string
+}
+
+func (e *NotFoundError) Error() string {
+	return e.Resource + " not found"
+}
+
+func (e *NotFoundError) Problem() Problem {
+	return Problem{
+		Type:   problemType("not-found"),
+		Title:  "Resource not found",
+		Status: http.StatusNotFound,
+		Detail: e.Error(),
+	}
+}
+
+func NotFound(resource string) error {
+	return &NotFoundError{Resource: resource}
+}
+
+// ConflictError maps to 409.
+type ConflictError struct {
+	Detail string
+}
+
+func (e *ConflictError) Error() string {
+	return e.Detail
+}
+
+func (e *ConflictError) Problem() Problem {
+	return Problem{
+		Type:   problemType("conflict"),
+		Title:  "Resource already exists",
+		Status: http.StatusConflict,
+		Detail: e.Detail,
+	}
+}
+
+func Conflict(detail string) error {
+	return &ConflictError{Detail: detail}
+}
+
+// UnauthorizedError maps to 401.
+type UnauthorizedError struct {
+	Detail string
+}
+
+func (e *UnauthorizedError) Error() string {
+	return e.Detail
+}
+
+func (e *UnauthorizedError) Problem() Problem {
+	return Problem{
+		Type:   problemType("unauthorized"),
+		Title:  "Authentication required",
+		Status: http.StatusUnauthorized,
+		Detail: e.Detail,
+	}
+}
+
+func Unauthorized(detail string) error {
+	return &UnauthorizedError{Detail: detail}
+}
+
+// StatusError carries any other client-facing status, e.g. 410 or 413.
+type StatusError struct {
+	Status int
+	Detail string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("%d %s: %s", e.Status, http.StatusText(e.Status), e.Detail)
+}
+
+func (e *StatusError) Problem() Problem {
+	return Problem{
+		Type:   "about:blank",
+		Title:  http.StatusText(e.Status),
+		Status: e.Status,
+		Detail: e.Detail,
+	}
+}
+
+func Status(status int, detail string) error {
+	return &StatusError{Status: status, Detail: detail}
+}
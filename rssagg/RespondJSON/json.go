@@ -6,14 +6,18 @@ import (
 	"net/http"
 )
 
+// RespondWithJSON writes payload as JSON regardless of what the client
+// accepts. Handlers with a request at hand should use Respond.
 func RespondWithJSON(w http.ResponseWriter, code int, payload interface{}) {
 	dat, err := json.Marshal(payload)
 	if err != nil {
-		log.Printf("Failed to marshal JSON response: %v", payload)
-		w.WriteHeader(500)
-		return
+		log.Printf("Failed to marshal JSON response: %v", err)
+		dat, _ = json.Marshal(internalProblem())
+		code = 500
+		w.Header().Set("Content-Type", "application/problem+json")
+	} else {
+		w.Header().Set("Content-Type", "application/json")
 	}
-	w.Header().Add("Content-Type", "application/json")
 	w.WriteHeader(code)
 	w.Write(dat)
 }
@@ -0,0 +1,97 @@
+package respondjson
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type qValue struct {
+	value string
+	q     float64
+}
+
+// parseQList parses a header such as Accept or Accept-Encoding into its
+// values and their quality factors. Parameters other than q are dropped.
+func parseQList(header string) []qValue {
+	var out []qValue
+	for _, part := range strings.Split(header, ",") {
+		fields := strings.Split(part, ";")
+		v := strings.ToLower(strings.TrimSpace(fields[0]))
+		if v == "" {
+			continue
+		}
+		q := 1.0
+		for _, param := range fields[1:] {
+			name, val, ok := strings.Cut(strings.TrimSpace(param), "=")
+			if !ok || strings.TrimSpace(name) != "q" {
+				continue
+			}
+			if f, err := strconv.ParseFloat(strings.TrimSpace(val), 64); err == nil && f >= 0 && f <= 1 {
+				q = f
+			}
+		}
+		out = append(out, qValue{value: v, q: q})
+	}
+	return out
+}
+
+// negotiateMedia returns the offer the Accept header prefers, or "" when
+// none is acceptable. Ties go to the earlier offer, so the first one is
+// also the default when the header is missing.
+func negotiateMedia(accept string, offers []string) string {
+	if strings.TrimSpace(accept) == "" {
+		return offers[0]
+	}
+	ranges := parseQList(accept)
+	best, bestQ := "", 0.0
+	for _, offer := range offers {
+		q, specificity := 0.0, -1
+		typ, _, _ := strings.Cut(offer, "/")
+		for _, rng := range ranges {
+			s := -1
+			switch {
+			case rng.value == offer:
+				s = 2
+			case rng.value == typ+"/*":
+				s = 1
+			case rng.value == "*/*" || rng.value == "*":
+				s = 0
+			}
+			if s > specificity {
+				q, specificity = rng.q, s
+			}
+		}
+		if q > bestQ {
+			best, bestQ = offer, q
+		}
+	}
+	return best
+}
+
+// negotiateEncoding picks a content coding from Accept-Encoding, preferring
+// br over gzip when the client rates them equally. "" means identity.
+func negotiateEncoding(header string) string {
+	values := parseQList(header)
+	quality := func(coding string) float64 {
+		q, wildcard := 0.0, -1.0
+		for _, v := range values {
+			switch v.value {
+			case coding:
+				return v.q
+			case "*":
+				wildcard = v.q
+			}
+		}
+		if wildcard >= 0 {
+			q = wildcard
+		}
+		return q
+	}
+	offers := []qValue{{"br", quality("br")}, {"gzip", quality("gzip")}}
+	sort.SliceStable(offers, func(i, j int) bool { return offers[i].q > offers[j].q })
+	if offers[0].q > 0 {
+		return offers[0].value
+	}
+	return ""
+}
@@ -0,0 +1,101 @@
+package respondjson
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"runtime/debug"
+)
+
+// Problem is an RFC 7807 problem details object.
+type Problem struct {
+	Type     string       `json:"type"`
+	Title    string       `json:"title"`
+	Status   int          `json:"status"`
+	Detail   string       `json:"detail,omitempty"`
+	Instance string       `json:"instance,omitempty"`
+	Errors   []FieldError `json:"errors,omitempty"`
+}
+
+// problemer is implemented by errors that know how to describe
+// themselves to clients.
+type problemer interface {
+	Problem() Problem
+}
+
+func problemType(kind string) string {
+	return "urn:rssagg:problem:" + kind
+}
+
+// RespondWithProblem writes p as application/problem+json.
+func RespondWithProblem(w http.ResponseWriter, r *http.Request, p Problem) {
+	if p.Instance == "" {
+		p.Instance = r.URL.Path
+	}
+	dat, err := json.Marshal(p)
+	if err != nil {
+		log.Printf("Failed to marshal problem: %v", err)
+		w.WriteHeader(500)
+		return
+	}
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.WriteHeader(p.Status)
+	w.Write(dat)
+}
+
+// RespondWithErr maps err to a problem response. Errors without a
+// Problem method become a 500 whose details are only logged.
+func RespondWithErr(w http.ResponseWriter, r *http.Request, err error) {
+	var pe problemer
+	if errors.As(err, &pe) {
+		p := pe.Problem()
+		if p.Status > 499 {
+			log.Printf("Server error on %s %s: %v", r.Method, r.URL.Path, err)
+		}
+		RespondWithProblem(w, r, p)
+		return
+	}
+	log.Printf("Server error on %s %s: %v", r.Method, r.URL.Path, err)
+	RespondWithProblem(w, r, internalProblem())
+}
+
+func internalProblem() Problem {
+	return Problem{
+		Type:   "about:blank",
+		Title:  http.StatusText(http.StatusInternalServerError),
+		Status: http.StatusInternalServerError,
+	}
+}
+
+// APIFunc is a handler that reports failures by returning an error.
+type APIFunc func(w http.ResponseWriter, r *http.Request) error
+
+// MakeHTTPHandler adapts f to net/http, turning a returned error into a
+// problem response.
+func MakeHTTPHandler(f APIFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := f(w, r); err != nil {
+			RespondWithErr(w, r, err)
+		}
+	}
+}
+
+// Recoverer turns a panic in next into a logged stack trace and a bare
+// 500 problem.
+func Recoverer(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			p := recover()
+			if p == nil {
+				return
+			}
+			if p == http.ErrAbortHandler {
+				panic(p)
+			}
+			log.Printf("Panic on %s %s: %v\n%s", r.Method, r.URL.Path, p, debug.Stack())
+			RespondWithProblem(w, r, internalProblem())
+		}()
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,95 @@
+package respondjson
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func serve(h http.Handler, path string) (*httptest.ResponseRecorder, Problem) {
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", path, nil))
+	var p Problem
+	json.Unmarshal(rec.Body.Bytes(), &p)
+	return rec, p
+}
+
+func TestMakeHTTPHandlerMapsTypedErrors(t *testing.T) {
+	cases := []struct {
+		err    error
+		status int
+		typ    string
+	}{
+		{NotFound("Feed"), 404, "urn:rssagg:problem:not-found"},
+		{Conflict("Feed already exists"), 409, "urn:rssagg:problem:conflict"},
+		{Unauthorized("Invalid API key"), 401, "urn:rssagg:problem:unauthorized"},
+		{Status(410, "Unknown subscription"), 410, "about:blank"},
+		// Wrapping keeps the mapping.
+		{fmt.Errorf("lookup: %w", NotFound("User")), 404, "urn:rssagg:problem:not-found"},
+	}
+	for _, c := range cases {
+		h := MakeHTTPHandler(func(w http.ResponseWriter, r *http.Request) error { return c.err })
+		rec, p := serve(h, "/v1/feeds/1")
+		assert.Equal(t, c.status, rec.Code, c.err.Error())
+		assert.Equal(t, "application/problem+json", rec.Header().Get("Content-Type"))
+		assert.Equal(t, c.status, p.Status)
+		assert.Equal(t, c.typ, p.Type)
+		assert.NotEmpty(t, p.Title)
+		assert.Equal(t, "/v1/feeds/1", p.Instance)
+	}
+}
+
+func TestValidationFields(t *testing.T) {
+	h := MakeHTTPHandler(func(w http.ResponseWriter, r *http.Request) error {
+		return Validation("Invalid search", "q", "is required", "limit", "must be between 1 and 100")
+	})
+	rec, p := serve(h, "/v1/search")
+	assert.Equal(t, 400, rec.Code)
+	assert.Equal(t, "Invalid search", p.Detail)
+	assert.Equal(t, []FieldError{
+		{Field: "q", Message: "is required"},
+		{Field: "limit", Message: "must be between 1 and 100"},
+	}, p.Errors)
+}
+
+func TestUnknownErrorsAreNotLeaked(t *testing.T) {
+	h := MakeHTTPHandler(func(w http.ResponseWriter, r *http.Request) error {
+		return errors.New("couldn't get feeds: database is locked")
+	})
+	rec, p := serve(h, "/v1/feeds")
+	assert.Equal(t, 500, rec.Code)
+	assert.Empty(t, p.Detail)
+	assert.NotContains(t, rec.Body.String(), "database")
+}
+
+func TestNilErrorLeavesResponseAlone(t *testing.T) {
+	h := MakeHTTPHandler(func(w http.ResponseWriter, r *http.Request) error {
+		RespondWithJSON(w, 201, struct{}{})
+		return nil
+	})
+	rec, _ := serve(h, "/")
+	assert.Equal(t, 201, rec.Code)
+	assert.Equal(t, "{}", rec.Body.String())
+}
+
+func TestRecoverer(t *testing.T) {
+	h := Recoverer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("secret connection string")
+	}))
+	rec, p := serve(h, "/v1/feeds")
+	assert.Equal(t, 500, rec.Code)
+	assert.Equal(t, 500, p.Status)
+	assert.False(t, strings.Contains(rec.Body.String(), "secret"))
+
+	abort := Recoverer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic(http.ErrAbortHandler)
+	}))
+	require.PanicsWithValue(t, http.ErrAbortHandler, func() { serve(abort, "/") })
+}
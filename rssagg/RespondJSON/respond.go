@@ -0,0 +1,204 @@
+package respondjson
+
+import (
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+)
+
+// Bodies smaller than this are sent uncompressed; the framing would eat
+// most of the gain.
+const minCompressSize = 1024
+
+// Streams flush after this many items so clients see progress.
+const streamFlushEvery = 64
+
+// Respond writes payload in the format negotiated from the Accept header.
+// The body is encoded in full before anything is written, so encoding
+// failures still produce a well-formed 500.
+func Respond(w http.ResponseWriter, r *http.Request, code int, payload interface{}) {
+	w.Header().Add("Vary", "Accept")
+	mediaType := negotiateMedia(r.Header.Get("Accept"), offers)
+	if mediaType == "" {
+		RespondWithProblem(w, r, notAcceptable())
+		return
+	}
+	dat, err := codecs[mediaType](payload)
+	if err != nil {
+		log.Printf("Failed to encode %s response for %s %s: %v", mediaType, r.Method, r.URL.Path, err)
+		RespondWithProblem(w, r, internalProblem())
+		return
+	}
+	RespondWithBytes(w, r, code, mediaType, dat)
+}
+
+// RespondWithList is Respond for lists. Clients asking for NDJSON get one
+// JSON document per line, written as it is encoded.
+func RespondWithList[T any](w http.ResponseWriter, r *http.Request, code int, items []T) {
+	if negotiateMedia(r.Header.Get("Accept"), listOffers) != MediaNDJSON {
+		if items == nil {
+			items = []T{}
+		}
+		Respond(w, r, code, items)
+		return
+	}
+	w.Header().Add("Vary", "Accept")
+	s := NewStream(w, r, code)
+	for _, item := range items {
+		if err := s.Send(item); err != nil {
+			log.Printf("Failed to stream %s %s: %v", r.Method, r.URL.Path, err)
+			break
+		}
+	}
+	s.Close()
+}
+
+// RespondWithBytes writes an already encoded body. Successful GETs carry
+// an ETag and are answered with 304 when the client already has them;
+// larger bodies are compressed when the client accepts it.
+func RespondWithBytes(w http.ResponseWriter, r *http.Request, code int, contentType string, body []byte) {
+	h := w.Header()
+	h.Add("Vary", "Accept-Encoding")
+	if code == 200 && (r.Method == http.MethodGet || r.Method == http.MethodHead) {
+		etag := computeETag(contentType, body)
+		h.Set("ETag", etag)
+		if etagMatches(r.Header.Get("If-None-Match"), etag) {
+			w.WriteHeader(304)
+			return
+		}
+	}
+	h.Set("Content-Type", contentType)
+	if len(body) >= minCompressSize {
+		if cw := newCompressor(w, r); cw != nil {
+			w.WriteHeader(code)
+			cw.Write(body)
+			cw.Close()
+			return
+		}
+	}
+	h.Set("Content-Length", strconv.Itoa(len(body)))
+	w.WriteHeader(code)
+	w.Write(body)
+}
+
+// computeETag returns a weak validator, since the same tag is used for
+// every content coding of the representation.
+func computeETag(contentType string, body []byte) string {
+	sum := sha256.New()
+	io.WriteString(sum, contentType)
+	sum.Write([]byte{0})
+	sum.Write(body)
+	return `W/"` + base64.RawURLEncoding.EncodeToString(sum.Sum(nil)[:16]) + `"`
+}
+
+// etagMatches applies the weak comparison If-None-Match calls for.
+func etagMatches(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
+}
+
+// compressor is a content-coding writer that can push buffered output
+// through to the client.
+type compressor interface {
+	io.WriteCloser
+	Flush() error
+}
+
+// newCompressor sets Content-Encoding and returns a writer wrapping w, or
+// nil when the client only takes identity.
+func newCompressor(w http.ResponseWriter, r *http.Request) compressor {
+	var cw compressor
+	enc := negotiateEncoding(r.Header.Get("Accept-Encoding"))
+	switch enc {
+	case "br":
+		cw = brotli.NewWriterLevel(w, brotli.DefaultCompression)
+	case "gzip":
+		cw = gzip.NewWriter(w)
+	default:
+		return nil
+	}
+	w.Header().Set("Content-Encoding", enc)
+	w.Header().Del("Content-Length")
+	return cw
+}
+
+// Stream writes NDJSON: one JSON document per line, flushed in batches.
+// Once a stream has started its status can no longer change, so errors
+// can only cut it short.
+type Stream struct {
+	w       http.ResponseWriter
+	cw      compressor
+	enc     *json.Encoder
+	pending int
+}
+
+// NewStream sends the headers for an NDJSON response.
+func NewStream(w http.ResponseWriter, r *http.Request, code int) *Stream {
+	w.Header().Set("Content-Type", MediaNDJSON)
+	w.Header().Add("Vary", "Accept-Encoding")
+	s := &Stream{w: w}
+	var out io.Writer = w
+	if s.cw = newCompressor(w, r); s.cw != nil {
+		out = s.cw
+	}
+	s.enc = json.NewEncoder(out)
+	w.WriteHeader(code)
+	return s
+}
+
+// Send writes v as the next line.
+func (s *Stream) Send(v interface{}) error {
+	if err := s.enc.Encode(v); err != nil {
+		return fmt.Errorf("encode stream item: %w", err)
+	}
+	s.pending++
+	if s.pending >= streamFlushEvery {
+		return s.Flush()
+	}
+	return nil
+}
+
+// Flush pushes everything sent so far to the client.
+func (s *Stream) Flush() error {
+	s.pending = 0
+	if s.cw != nil {
+		if err := s.cw.Flush(); err != nil {
+			return err
+		}
+	}
+	if f, ok := s.w.(http.Flusher); ok {
+		f.Flush()
+	}
+	return nil
+}
+
+// Close finishes the stream.
+func (s *Stream) Close() error {
+	if s.cw != nil {
+		return s.cw.Close()
+	}
+	return nil
+}
+
+func notAcceptable() Problem {
+	return Problem{
+		Type:   "about:blank",
+		Title:  http.StatusText(http.StatusNotAcceptable),
+		Status: http.StatusNotAcceptable,
+		Detail: "Supported media types: " + strings.Join(offers[:3], ", "),
+	}
+}
@@ -0,0 +1,204 @@
+package respondjson
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/andybalholm/brotli"
+	"github.com/fxamacker/cbor/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+type item struct {
+	ID        string     `json:"id"`
+	CreatedAt time.Time  `json:"created_at"`
+	Hub       *string    `json:"hub,omitempty"`
+	FetchedAt *time.Time `json:"fetched_at"`
+}
+
+func request(headers ...string) *http.Request {
+	r := httptest.NewRequest("GET", "/v1/feeds", nil)
+	for i := 0; i+1 < len(headers); i += 2 {
+		r.Header.Set(headers[i], headers[i+1])
+	}
+	return r
+}
+
+func TestNegotiateMedia(t *testing.T) {
+	cases := map[string]string{
+		"":                                      MediaJSON,
+		"*/*":                                   MediaJSON,
+		"application/*":                         MediaJSON,
+		"application/cbor":                      MediaCBOR,
+		"application/msgpack;q=0.9, */*;q=0.1":  MediaMsgPack,
+		"application/json;q=0, application/*":   MediaMsgPack,
+		"application/x-msgpack":                 "application/x-msgpack",
+		"text/html":                             "",
+		"text/html, application/cbor;q=0.5":     MediaCBOR,
+		"application/json; charset=utf-8; q=.5": MediaJSON,
+	}
+	for accept, want := range cases {
+		assert.Equal(t, want, negotiateMedia(accept, offers), accept)
+	}
+	assert.Equal(t, MediaJSON, negotiateMedia("*/*", listOffers))
+	assert.Equal(t, MediaNDJSON, negotiateMedia("application/x-ndjson", listOffers))
+}
+
+func TestNegotiateEncoding(t *testing.T) {
+	cases := map[string]string{
+		"":                     "",
+		"gzip":                 "gzip",
+		"gzip, deflate, br":    "br",
+		"br;q=0.5, gzip":       "gzip",
+		"*":                    "br",
+		"*, br;q=0":            "gzip",
+		"identity":             "",
+		"gzip;q=0, br;q=0, *;": "",
+	}
+	for header, want := range cases {
+		assert.Equal(t, want, negotiateEncoding(header), header)
+	}
+}
+
+func TestRespondFormats(t *testing.T) {
+	hub := "https://hub.example"
+	payload := item{ID: "1", CreatedAt: time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC), Hub: &hub}
+
+	rec := httptest.NewRecorder()
+	Respond(rec, request(), 200, payload)
+	assert.Equal(t, MediaJSON, rec.Header().Get("Content-Type"))
+	assert.JSONEq(t, `{"id":"1","created_at":"2024-05-01T12:00:00Z","hub":"https://hub.example","fetched_at":null}`, rec.Body.String())
+
+	rec = httptest.NewRecorder()
+	Respond(rec, request("Accept", MediaMsgPack), 200, payload)
+	assert.Equal(t, MediaMsgPack, rec.Header().Get("Content-Type"))
+	var m map[string]interface{}
+	require.NoError(t, msgpack.Unmarshal(rec.Body.Bytes(), &m))
+	assert.Equal(t, "1", m["id"])
+	assert.Equal(t, "https://hub.example", m["hub"])
+	assert.Contains(t, m, "created_at")
+
+	rec = httptest.NewRecorder()
+	Respond(rec, request("Accept", MediaCBOR), 200, payload)
+	assert.Equal(t, MediaCBOR, rec.Header().Get("Content-Type"))
+	var c map[string]interface{}
+	require.NoError(t, cbor.Unmarshal(rec.Body.Bytes(), &c))
+	assert.Equal(t, "1", c["id"])
+	assert.Equal(t, "2024-05-01T12:00:00Z", c["created_at"])
+
+	rec = httptest.NewRecorder()
+	Respond(rec, request("Accept", "text/html"), 200, payload)
+	assert.Equal(t, 406, rec.Code)
+	assert.Equal(t, "application/problem+json", rec.Header().Get("Content-Type"))
+}
+
+func TestRespondEncodingFailure(t *testing.T) {
+	rec := httptest.NewRecorder()
+	Respond(rec, request(), 200, map[string]interface{}{"bad": func() {}})
+	assert.Equal(t, 500, rec.Code)
+	assert.Equal(t, "application/problem+json", rec.Header().Get("Content-Type"))
+	assert.Contains(t, rec.Body.String(), `"status":500`)
+
+	rec = httptest.NewRecorder()
+	RespondWithJSON(rec, 200, func() {})
+	assert.Equal(t, 500, rec.Code)
+	assert.Equal(t, "application/problem+json", rec.Header().Get("Content-Type"))
+	assert.NotEmpty(t, rec.Body.String())
+}
+
+func TestETag(t *testing.T) {
+	payload := []item{{ID: "1"}, {ID: "2"}}
+	rec := httptest.NewRecorder()
+	Respond(rec, request(), 200, payload)
+	etag := rec.Header().Get("ETag")
+	require.NotEmpty(t, etag)
+
+	rec = httptest.NewRecorder()
+	Respond(rec, request("If-None-Match", `"other", `+etag), 200, payload)
+	assert.Equal(t, 304, rec.Code)
+	assert.Empty(t, rec.Body.String())
+
+	// A different representation has a different tag.
+	rec = httptest.NewRecorder()
+	Respond(rec, request("Accept", MediaCBOR, "If-None-Match", etag), 200, payload)
+	assert.Equal(t, 200, rec.Code)
+	assert.NotEqual(t, etag, rec.Header().Get("ETag"))
+
+	// Only successful reads are validated.
+	rec = httptest.NewRecorder()
+	Respond(rec, request("If-None-Match", "*"), 201, payload)
+	assert.Equal(t, 201, rec.Code)
+	assert.Empty(t, rec.Header().Get("ETag"))
+}
+
+func TestCompression(t *testing.T) {
+	payload := make([]item, 100)
+	for i := range payload {
+		payload[i].ID = strings.Repeat("x", 20)
+	}
+	want, _ := json.Marshal(payload)
+
+	rec := httptest.NewRecorder()
+	Respond(rec, request("Accept-Encoding", "gzip"), 200, payload)
+	assert.Equal(t, "gzip", rec.Header().Get("Content-Encoding"))
+	assert.Less(t, rec.Body.Len(), len(want))
+	zr, err := gzip.NewReader(rec.Body)
+	require.NoError(t, err)
+	got, err := io.ReadAll(zr)
+	require.NoError(t, err)
+	assert.Equal(t, want, got)
+
+	rec = httptest.NewRecorder()
+	Respond(rec, request("Accept-Encoding", "gzip, br"), 200, payload)
+	assert.Equal(t, "br", rec.Header().Get("Content-Encoding"))
+	got, err = io.ReadAll(brotli.NewReader(rec.Body))
+	require.NoError(t, err)
+	assert.Equal(t, want, got)
+
+	// Small bodies are not worth compressing.
+	rec = httptest.NewRecorder()
+	Respond(rec, request("Accept-Encoding", "gzip"), 200, item{ID: "1"})
+	assert.Empty(t, rec.Header().Get("Content-Encoding"))
+	assert.NotEmpty(t, rec.Header().Get("Content-Length"))
+}
+
+func TestRespondWithListNDJSON(t *testing.T) {
+	items := make([]item, streamFlushEvery+3)
+	for i := range items {
+		items[i].ID = string(rune('a' + i%26))
+	}
+
+	rec := httptest.NewRecorder()
+	RespondWithList(rec, request("Accept", MediaNDJSON, "Accept-Encoding", "gzip"), 200, items)
+	assert.Equal(t, MediaNDJSON, rec.Header().Get("Content-Type"))
+	assert.Equal(t, "gzip", rec.Header().Get("Content-Encoding"))
+	assert.True(t, rec.Flushed)
+
+	zr, err := gzip.NewReader(rec.Body)
+	require.NoError(t, err)
+	sc := bufio.NewScanner(zr)
+	n := 0
+	for sc.Scan() {
+		var got item
+		require.NoError(t, json.Unmarshal(sc.Bytes(), &got))
+		assert.Equal(t, items[n].ID, got.ID)
+		n++
+	}
+	assert.Equal(t, len(items), n)
+
+	// Without asking for NDJSON the list is a single document, and an
+	// empty list is [] rather than null.
+	rec = httptest.NewRecorder()
+	RespondWithList[item](rec, request(), 200, nil)
+	assert.Equal(t, MediaJSON, rec.Header().Get("Content-Type"))
+	assert.Equal(t, "[]", rec.Body.String())
+}
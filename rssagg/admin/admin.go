@@ -0,0 +1,132 @@
+// Package admin implements the `rssagg admin` operator commands.
+package admin
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"golang/rssagg/database"
+	"io"
+	"strings"
+)
+
+// ErrUsage is returned for unknown commands and bad flags; the usage has
+// already been printed.
+var ErrUsage = errors.New("usage error")
+
+type command struct {
+	name    string
+	args    string
+	summary string
+	// raw commands open the database themselves.
+	raw bool
+	run func(ctx context.Context, e *env, fs *flag.FlagSet, args []string) error
+}
+
+var commands = []command{
+	{name: "migrate", args: "[-status]", summary: "apply pending database migrations", run: runMigrate},
+	{name: "feeds list", args: "[-failing] [-disabled]", summary: "list feeds and their fetch state", run: runFeedsList},
+	{name: "feeds refresh", args: "[-unconditional] [-allow-private] <feed-id|url>...", summary: "fetch feeds now, ignoring their schedule", run: runFeedsRefresh},
+	{name: "feeds disable", args: "<feed-id|url>...", summary: "stop fetching feeds", run: runFeedsDisable},
+	{name: "feeds enable", args: "<feed-id|url>...", summary: "resume fetching feeds and clear their errors", run: runFeedsEnable},
+	{name: "reindex", args: "[-pid <server-pid>]", summary: "rebuild the search index", run: runReindex},
+	{name: "export", args: "<file>", summary: "write a consistent copy of the database to file", run: runExport},
+	{name: "import", args: "-force <file>", summary: "replace the database with file; stop the server first", raw: true, run: runImport},
+	{name: "stats", args: "", summary: "print scraper statistics", run: runStats},
+}
+
+type env struct {
+	dbPath string
+	db     *database.DB
+	out    io.Writer
+}
+
+// Run executes one admin command against the database at dbPath. Results
+// go to out, usage and flag errors to errOut.
+func Run(ctx context.Context, dbPath string, args []string, out, errOut io.Writer) error {
+	cmd, rest := lookup(args)
+	if cmd == nil {
+		usage(errOut)
+		return ErrUsage
+	}
+	fs := flag.NewFlagSet("rssagg admin "+cmd.name, flag.ContinueOnError)
+	fs.SetOutput(errOut)
+	fs.Usage = func() {
+		fmt.Fprintf(errOut, "usage: rssagg admin %s %s\n", cmd.name, cmd.args)
+		fs.PrintDefaults()
+	}
+
+	e := &env{dbPath: dbPath, out: out}
+	if !cmd.raw {
+		db, err := database.Open(dbPath)
+		if err != nil {
+			return fmt.Errorf("open database: %w", err)
+		}
+		defer db.Close()
+		e.db = db
+		if cmd.name != "migrate" {
+			if err := requireMigrated(ctx, db); err != nil {
+				return err
+			}
+		}
+	}
+	err := cmd.run(ctx, e, fs, rest)
+	if errors.Is(err, flag.ErrHelp) {
+		return nil
+	}
+	return err
+}
+
+// lookup matches the longest command name at the start of args.
+func lookup(args []string) (*command, []string) {
+	var best *command
+	for i := range commands {
+		words := strings.Fields(commands[i].name)
+		if len(args) >= len(words) && strings.Join(args[:len(words)], " ") == commands[i].name {
+			if best == nil || len(words) > len(strings.Fields(best.name)) {
+				best = &commands[i]
+			}
+		}
+	}
+	if best == nil {
+		return nil, nil
+	}
+	return best, args[len(strings.Fields(best.name)):]
+}
+
+func usage(w io.Writer) {
+	fmt.Fprintln(w, "usage: rssagg admin <command> [flags]")
+	fmt.Fprintln(w, "\nCommands:")
+	for _, c := range commands {
+		fmt.Fprintf(w, "  %-14s %s\n", c.name, c.summary)
+	}
+	fmt.Fprintln(w, "\nThe database is DB_PATH, read from the environment or .env like the server.")
+}
+
+func requireMigrated(ctx context.Context, db *database.DB) error {
+	pending, err := db.PendingMigrations(ctx)
+	if err != nil {
+		return err
+	}
+	if len(pending) > 0 {
+		return fmt.Errorf("database has %d pending migrations; run `rssagg admin migrate` first", len(pending))
+	}
+	return nil
+}
+
+// parseFlags parses args and checks the number of positional arguments;
+// max < 0 means no upper bound.
+func parseFlags(fs *flag.FlagSet, args []string, min, max int) error {
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return err
+		}
+		return ErrUsage
+	}
+	if n := fs.NArg(); n < min || (max >= 0 && n > max) {
+		fs.Usage()
+		return ErrUsage
+	}
+	return nil
+}
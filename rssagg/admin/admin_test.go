@@ -0,0 +1,116 @@
+package admin
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"golang/rssagg/database"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func run(t *testing.T, dbPath string, args ...string) (string, error) {
+	t.Helper()
+	var out, errOut bytes.Buffer
+	err := Run(context.Background(), dbPath, args, &out, &errOut)
+	return out.String() + errOut.String(), err
+}
+
+func TestCommands(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	dbPath := filepath.Join(dir, "rssagg.db")
+
+	_, err := run(t, dbPath, "stats")
+	assert.ErrorContains(t, err, "pending migrations")
+	out, err := run(t, dbPath, "migrate")
+	require.NoError(t, err)
+	assert.Contains(t, out, "applied  001_")
+	out, err = run(t, dbPath, "migrate", "-status")
+	require.NoError(t, err)
+	assert.Equal(t, "Database is up to date\n", out)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/feed" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, `<rss version="2.0"><channel><title>T</title><item><guid>1</guid><title>Hello</title></item></channel></rss>`)
+	}))
+	defer srv.Close()
+
+	db, err := database.Open(dbPath)
+	require.NoError(t, err)
+	_, err = db.CreateFeed(ctx, database.CreateFeedParams{ID: "good", Name: "Good", URL: srv.URL + "/feed"})
+	require.NoError(t, err)
+	_, err = db.CreateFeed(ctx, database.CreateFeedParams{ID: "bad", Name: "Bad", URL: srv.URL + "/gone"})
+	require.NoError(t, err)
+	require.NoError(t, db.Close())
+
+	out, err = run(t, dbPath, "feeds", "refresh", "-allow-private", "good", srv.URL+"/gone")
+	assert.ErrorContains(t, err, "1 of 2 feeds failed")
+	assert.Contains(t, out, "ok    "+srv.URL+"/feed")
+	assert.Contains(t, out, "FAIL  "+srv.URL+"/gone")
+
+	out, err = run(t, dbPath, "feeds", "list", "-failing")
+	require.NoError(t, err)
+	assert.Contains(t, out, "bad")
+	assert.NotContains(t, out, "good")
+
+	_, err = run(t, dbPath, "feeds", "disable", "bad")
+	require.NoError(t, err)
+	out, err = run(t, dbPath, "stats")
+	require.NoError(t, err)
+	assert.Regexp(t, `Feeds\s+2`, out)
+	assert.Regexp(t, `disabled\s+1`, out)
+	assert.Regexp(t, `Posts\s+1`, out)
+	assert.NotContains(t, out, "Last fetch            never")
+	assert.Contains(t, out, "Most failing feeds:")
+
+	_, err = run(t, dbPath, "feeds", "enable", "nope")
+	assert.ErrorContains(t, err, `no feed with ID or URL "nope"`)
+	_, err = run(t, dbPath, "feeds", "enable", "bad")
+	require.NoError(t, err)
+	out, err = run(t, dbPath, "feeds", "list", "-disabled")
+	require.NoError(t, err)
+	assert.Equal(t, 1, strings.Count(out, "\n"), "only the header is left")
+
+	out, err = run(t, dbPath, "reindex")
+	require.NoError(t, err)
+	assert.Contains(t, out, "Indexed 1 posts")
+
+	// Round trip through export and import.
+	backup := filepath.Join(dir, "backup.db")
+	_, err = run(t, dbPath, "export", backup)
+	require.NoError(t, err)
+	_, err = run(t, dbPath, "export", backup)
+	assert.ErrorContains(t, err, "already exists")
+
+	restored := filepath.Join(dir, "restored.db")
+	_, err = run(t, restored, "import", backup)
+	require.NoError(t, err)
+	_, err = run(t, restored, "import", backup)
+	assert.ErrorContains(t, err, "pass -force")
+	_, err = run(t, restored, "import", "-force", backup)
+	require.NoError(t, err)
+	out, err = run(t, restored, "feeds", "list")
+	require.NoError(t, err)
+	assert.Contains(t, out, "good")
+	assert.Contains(t, out, "bad")
+}
+
+func TestUsage(t *testing.T) {
+	out, err := run(t, filepath.Join(t.TempDir(), "x.db"), "feeds", "frobnicate")
+	assert.ErrorIs(t, err, ErrUsage)
+	assert.Contains(t, out, "feeds refresh")
+
+	out, err = run(t, filepath.Join(t.TempDir(), "x.db"), "migrate", "extra")
+	assert.ErrorIs(t, err, ErrUsage)
+	assert.Contains(t, out, "usage: rssagg admin migrate [-status]")
+}
@@ -0,0 +1,321 @@
+package admin
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"golang/rssagg/database"
+	"golang/rssagg/ingest"
+	"golang/rssagg/scraper"
+	"golang/rssagg/search"
+	"os"
+	"path/filepath"
+	"sort"
+	"syscall"
+	"text/tabwriter"
+	"time"
+)
+
+func runMigrate(ctx context.Context, e *env, fs *flag.FlagSet, args []string) error {
+	status := fs.Bool("status", false, "list pending migrations without applying them")
+	if err := parseFlags(fs, args, 0, 0); err != nil {
+		return err
+	}
+	if *status {
+		pending, err := e.db.PendingMigrations(ctx)
+		if err != nil {
+			return err
+		}
+		if len(pending) == 0 {
+			fmt.Fprintln(e.out, "Database is up to date")
+		}
+		for _, m := range pending {
+			fmt.Fprintf(e.out, "pending  %s\n", m.Name)
+		}
+		return nil
+	}
+	ran, err := e.db.Migrate(ctx)
+	for _, m := range ran {
+		fmt.Fprintf(e.out, "applied  %s\n", m.Name)
+	}
+	if err != nil {
+		return err
+	}
+	if len(ran) == 0 {
+		fmt.Fprintln(e.out, "Database is up to date")
+	}
+	return nil
+}
+
+func runFeedsList(ctx context.Context, e *env, fs *flag.FlagSet, args []string) error {
+	failing := fs.Bool("failing", false, "only feeds whose last fetch failed")
+	disabled := fs.Bool("disabled", false, "only disabled feeds")
+	if err := parseFlags(fs, args, 0, 0); err != nil {
+		return err
+	}
+	feeds, err := e.db.GetFeeds(ctx)
+	if err != nil {
+		return err
+	}
+	tw := tabwriter.NewWriter(e.out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tNAME\tURL\tLAST FETCH\tNEXT FETCH\tERRORS\tSTATE")
+	for _, f := range feeds {
+		if (*failing && f.ErrorCount == 0) || (*disabled && !f.Disabled) {
+			continue
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%d\t%s\n",
+			f.ID, truncate(f.Name, 30), f.URL, formatTime(f.LastFetchedAt), formatTime(f.NextFetchAt), f.ErrorCount, feedState(f))
+	}
+	return tw.Flush()
+}
+
+func runFeedsRefresh(ctx context.Context, e *env, fs *flag.FlagSet, args []string) error {
+	unconditional := fs.Bool("unconditional", false, "ignore ETag and Last-Modified and download the full feed")
+	allowPrivate := fs.Bool("allow-private", false, "allow feeds on loopback and private addresses")
+	if err := parseFlags(fs, args, 1, -1); err != nil {
+		return err
+	}
+	feeds, err := resolveFeeds(ctx, e.db, fs.Args())
+	if err != nil {
+		return err
+	}
+	// The running server keeps its own in-memory index; it picks the new
+	// posts up on restart or SIGHUP.
+	in := ingest.New(e.db, search.NewIndex(), nil)
+	cfg := scraper.DefaultConfig()
+	cfg.AllowPrivate = *allowPrivate
+	scr := scraper.New(e.db, cfg, in.HandleFeed)
+	failed := 0
+	for _, f := range feeds {
+		if *unconditional {
+			f.ETag, f.LastModified = "", ""
+		}
+		if err := scr.ScrapeFeed(ctx, f); err != nil {
+			failed++
+			fmt.Fprintf(e.out, "FAIL  %s  %v\n", f.URL, err)
+			continue
+		}
+		fmt.Fprintf(e.out, "ok    %s\n", f.URL)
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d feeds failed", failed, len(feeds))
+	}
+	return nil
+}
+
+func runFeedsDisable(ctx context.Context, e *env, fs *flag.FlagSet, args []string) error {
+	return setDisabled(ctx, e, fs, args, true)
+}
+
+func runFeedsEnable(ctx context.Context, e *env, fs *flag.FlagSet, args []string) error {
+	return setDisabled(ctx, e, fs, args, false)
+}
+
+func setDisabled(ctx context.Context, e *env, fs *flag.FlagSet, args []string, disabled bool) error {
+	if err := parseFlags(fs, args, 1, -1); err != nil {
+		return err
+	}
+	feeds, err := resolveFeeds(ctx, e.db, fs.Args())
+	if err != nil {
+		return err
+	}
+	verb := "enabled"
+	if disabled {
+		verb = "disabled"
+	}
+	for _, f := range feeds {
+		if err := e.db.SetFeedDisabled(ctx, f.ID, disabled); err != nil {
+			return err
+		}
+		fmt.Fprintf(e.out, "%s  %s\n", verb, f.URL)
+	}
+	return nil
+}
+
+func runReindex(ctx context.Context, e *env, fs *flag.FlagSet, args []string) error {
+	pid := fs.Int("pid", 0, "also signal the server with this pid to rebuild its index")
+	if err := parseFlags(fs, args, 0, 0); err != nil {
+		return err
+	}
+	start := time.Now()
+	n, err := ingest.RebuildIndex(ctx, e.db, search.NewIndex())
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(e.out, "Indexed %d posts in %s\n", n, time.Since(start).Round(time.Millisecond))
+	if *pid == 0 {
+		fmt.Fprintln(e.out, "The server keeps its index in memory; pass -pid or send it SIGHUP to rebuild it there.")
+		return nil
+	}
+	proc, err := os.FindProcess(*pid)
+	if err != nil {
+		return err
+	}
+	if err := proc.Signal(syscall.SIGHUP); err != nil {
+		return fmt.Errorf("signal server: %w", err)
+	}
+	fmt.Fprintf(e.out, "Asked server %d to rebuild its index\n", *pid)
+	return nil
+}
+
+func runExport(ctx context.Context, e *env, fs *flag.FlagSet, args []string) error {
+	if err := parseFlags(fs, args, 1, 1); err != nil {
+		return err
+	}
+	path := fs.Arg(0)
+	if _, err := os.Stat(path); err == nil {
+		return fmt.Errorf("%s already exists", path)
+	}
+	if err := e.db.Backup(ctx, path); err != nil {
+		return fmt.Errorf("export: %w", err)
+	}
+	fmt.Fprintf(e.out, "Exported database to %s\n", path)
+	return nil
+}
+
+// runImport copies file over the database. The copy is written next to
+// the database and renamed into place so a failed import leaves the old
+// database untouched.
+func runImport(ctx context.Context, e *env, fs *flag.FlagSet, args []string) error {
+	force := fs.Bool("force", false, "replace the existing database")
+	if err := parseFlags(fs, args, 1, 1); err != nil {
+		return err
+	}
+	src := fs.Arg(0)
+	if _, err := os.Stat(src); err != nil {
+		return err
+	}
+	if _, err := os.Stat(e.dbPath); err == nil && !*force {
+		return fmt.Errorf("%s exists; pass -force to replace it", e.dbPath)
+	}
+
+	db, err := database.Open(src)
+	if err != nil {
+		return fmt.Errorf("open %s: %w", src, err)
+	}
+	defer db.Close()
+	version, err := db.SchemaVersion(ctx)
+	if err != nil || version == 0 {
+		return fmt.Errorf("%s is not an rssagg database", src)
+	}
+	all, err := database.Migrations()
+	if err != nil {
+		return err
+	}
+	if latest := all[len(all)-1].Version; version > latest {
+		return fmt.Errorf("%s has schema version %d, newer than this binary's %d", src, version, latest)
+	}
+	tmp := filepath.Join(filepath.Dir(e.dbPath), "."+filepath.Base(e.dbPath)+".import")
+	os.Remove(tmp)
+	if err := db.Backup(ctx, tmp); err != nil {
+		return fmt.Errorf("copy %s: %w", src, err)
+	}
+	if err := os.Rename(tmp, e.dbPath); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	// The old database's WAL must not be replayed into the new file.
+	os.Remove(e.dbPath + "-wal")
+	os.Remove(e.dbPath + "-shm")
+	fmt.Fprintf(e.out, "Imported %s into %s\n", src, e.dbPath)
+	if latest := all[len(all)-1].Version; version < latest {
+		fmt.Fprintln(e.out, "The imported database is older than this binary; run `rssagg admin migrate`.")
+	}
+	return nil
+}
+
+func runStats(ctx context.Context, e *env, fs *flag.FlagSet, args []string) error {
+	if err := parseFlags(fs, args, 0, 0); err != nil {
+		return err
+	}
+	st, err := e.db.GetScraperStats(ctx, time.Now())
+	if err != nil {
+		return err
+	}
+	tw := tabwriter.NewWriter(e.out, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "Feeds\t%d\n", st.Feeds)
+	fmt.Fprintf(tw, "  due now\t%d\n", st.Due)
+	fmt.Fprintf(tw, "  failing\t%d\n", st.Failing)
+	fmt.Fprintf(tw, "  disabled\t%d\n", st.Disabled)
+	fmt.Fprintf(tw, "  never fetched\t%d\n", st.NeverFetched)
+	fmt.Fprintf(tw, "  pushed via WebSub\t%d\n", st.PushActive)
+	fmt.Fprintf(tw, "Last fetch\t%s\n", formatTime(st.LastFetchedAt))
+	fmt.Fprintf(tw, "Posts\t%d\n", st.Posts)
+	fmt.Fprintf(tw, "  last 24h\t%d\n", st.PostsLastDay)
+	fmt.Fprintf(tw, "  awaiting full text\t%d\n", st.PendingContent)
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	feeds, err := e.db.GetFeeds(ctx)
+	if err != nil {
+		return err
+	}
+	var failing []database.Feed
+	for _, f := range feeds {
+		if f.ErrorCount > 0 {
+			failing = append(failing, f)
+		}
+	}
+	if len(failing) == 0 {
+		return nil
+	}
+	sort.SliceStable(failing, func(i, j int) bool { return failing[i].ErrorCount > failing[j].ErrorCount })
+	if len(failing) > 10 {
+		failing = failing[:10]
+	}
+	fmt.Fprintln(e.out, "\nMost failing feeds:")
+	tw = tabwriter.NewWriter(e.out, 0, 0, 2, ' ', 0)
+	for _, f := range failing {
+		fmt.Fprintf(tw, "  %d\t%s\t%s\n", f.ErrorCount, f.URL, truncate(f.LastError, 60))
+	}
+	return tw.Flush()
+}
+
+// resolveFeeds looks up each argument as a feed ID, then as a feed URL.
+func resolveFeeds(ctx context.Context, db *database.DB, refs []string) ([]database.Feed, error) {
+	var feeds []database.Feed
+	for _, ref := range refs {
+		f, err := db.GetFeed(ctx, ref)
+		if errors.Is(err, database.ErrNotFound) {
+			f, err = db.GetFeedByURL(ctx, ref)
+		}
+		if errors.Is(err, database.ErrNotFound) {
+			return nil, fmt.Errorf("no feed with ID or URL %q", ref)
+		}
+		if err != nil {
+			return nil, err
+		}
+		feeds = append(feeds, f)
+	}
+	return feeds, nil
+}
+
+func feedState(f database.Feed) string {
+	switch {
+	case f.Disabled:
+		return "disabled"
+	case f.ErrorCount > 0:
+		return "failing"
+	case f.PushExpiresAt.After(time.Now()):
+		return "push"
+	default:
+		return "ok"
+	}
+}
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return "never"
+	}
+	return t.Local().Format("2006-01-02 15:04")
+}
+
+func truncate(s string, n int) string {
+	r := []rune(s)
+	if len(r) <= n {
+		return s
+	}
+	return string(r[:n-1]) + "…"
+}
@@ -0,0 +1,130 @@
+// Package api mounts versioned route tables, marks deprecated routes with
+// Deprecation and Sunset headers, and documents the tables.
+package api
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi"
+)
+
+// Version is one API version, served under "/" + Name.
+type Version struct {
+	Name   string
+	Routes []Route
+}
+
+// Route is one endpoint of a Version.
+type Route struct {
+	Method  string
+	Pattern string
+	Summary string
+	// Auth marks routes that need an API key; it is only documentation.
+	Auth    bool
+	Handler http.Handler
+	// Deprecation is nil for current routes.
+	Deprecation *Deprecation
+}
+
+// Deprecation describes the retirement of a route.
+type Deprecation struct {
+	// Since is when the route was, or will be, deprecated.
+	Since time.Time
+	// Sunset, when set, is when the route stops working.
+	Sunset time.Time
+	// Successor is the path of the replacement, such as "/v2/follows".
+	Successor string
+}
+
+// Headers returns the response headers of a deprecated route: Deprecation
+// (RFC 9745), Sunset (RFC 8594) and a successor-version Link.
+func (d Deprecation) Headers() http.Header {
+	h := http.Header{}
+	h.Set("Deprecation", fmt.Sprintf("@%d", d.Since.Unix()))
+	if !d.Sunset.IsZero() {
+		h.Set("Sunset", d.Sunset.UTC().Format(http.TimeFormat))
+	}
+	if d.Successor != "" {
+		h.Add("Link", fmt.Sprintf("<%s>; rel=\"successor-version\"", d.Successor))
+	}
+	return h
+}
+
+// Deprecate returns middleware that adds d's headers to every response.
+func Deprecate(d Deprecation) func(http.Handler) http.Handler {
+	headers := d.Headers()
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			for k, vs := range headers {
+				for _, v := range vs {
+					w.Header().Add(k, v)
+				}
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// Mount serves each version's routes on r under "/" + its name.
+func Mount(r chi.Router, versions ...Version) {
+	for _, v := range versions {
+		sub := chi.NewRouter()
+		for _, rt := range v.Routes {
+			h := rt.Handler
+			if rt.Deprecation != nil {
+				h = Deprecate(*rt.Deprecation)(h)
+			}
+			sub.Method(rt.Method, rt.Pattern, h)
+		}
+		r.Mount("/"+v.Name, sub)
+	}
+}
+
+// WriteTable writes the routes of versions as Markdown tables, one per
+// version, sorted by path and method.
+func WriteTable(w io.Writer, versions ...Version) error {
+	var b strings.Builder
+	b.WriteString("# rssagg API routes\n\n")
+	b.WriteString("<!-- Generated from the route tables in handler/routes.go; run `go test ./handler -update` after changing them. -->\n")
+	for _, v := range versions {
+		routes := append([]Route(nil), v.Routes...)
+		sort.SliceStable(routes, func(i, j int) bool {
+			if routes[i].Pattern != routes[j].Pattern {
+				return routes[i].Pattern < routes[j].Pattern
+			}
+			return routes[i].Method < routes[j].Method
+		})
+		fmt.Fprintf(&b, "\n## /%s\n\n", v.Name)
+		b.WriteString("| Method | Path | Auth | Description | Status |\n")
+		b.WriteString("|--------|------|------|-------------|--------|\n")
+		for _, rt := range routes {
+			auth := ""
+			if rt.Auth {
+				auth = "API key"
+			}
+			fmt.Fprintf(&b, "| %s | `/%s%s` | %s | %s | %s |\n",
+				rt.Method, v.Name, rt.Pattern, auth, strings.ReplaceAll(rt.Summary, "|", `\|`), status(rt.Deprecation))
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func status(d *Deprecation) string {
+	if d == nil {
+		return "current"
+	}
+	s := "deprecated " + d.Since.UTC().Format("2006-01-02")
+	if !d.Sunset.IsZero() {
+		s += ", removed " + d.Sunset.UTC().Format("2006-01-02")
+	}
+	if d.Successor != "" {
+		s += "; use `" + d.Successor + "`"
+	}
+	return s
+}
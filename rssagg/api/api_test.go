@@ -0,0 +1,72 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func ok(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusNoContent) }
+
+var testVersions = []Version{
+	{Name: "v1", Routes: []Route{
+		{Method: "GET", Pattern: "/things", Summary: "List things", Handler: http.HandlerFunc(ok), Deprecation: &Deprecation{
+			Since:     time.Date(2026, 1, 2, 0, 0, 0, 0, time.UTC),
+			Sunset:    time.Date(2026, 7, 1, 0, 0, 0, 0, time.UTC),
+			Successor: "/v2/items",
+		}},
+		{Method: "GET", Pattern: "/health", Summary: "Health | status", Handler: http.HandlerFunc(ok)},
+	}},
+	{Name: "v2", Routes: []Route{
+		{Method: "GET", Pattern: "/items", Summary: "List items", Auth: true, Handler: http.HandlerFunc(ok)},
+		{Method: "GET", Pattern: "/health", Summary: "Health | status", Handler: http.HandlerFunc(ok)},
+	}},
+}
+
+func TestMount(t *testing.T) {
+	r := chi.NewRouter()
+	Mount(r, testVersions...)
+	serve := func(path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest("GET", path, nil))
+		return rec
+	}
+
+	rec := serve("/v1/things")
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+	assert.Equal(t, "@1767312000", rec.Header().Get("Deprecation"))
+	assert.Equal(t, "Wed, 01 Jul 2026 00:00:00 GMT", rec.Header().Get("Sunset"))
+	assert.Equal(t, `</v2/items>; rel="successor-version"`, rec.Header().Get("Link"))
+
+	for _, path := range []string{"/v1/health", "/v2/items", "/v2/health"} {
+		rec := serve(path)
+		assert.Equal(t, http.StatusNoContent, rec.Code, path)
+		assert.Empty(t, rec.Header().Get("Deprecation"), path)
+	}
+	assert.Equal(t, http.StatusNotFound, serve("/v2/things").Code)
+	assert.Equal(t, http.StatusNotFound, serve("/things").Code)
+}
+
+func TestDeprecationWithoutSunset(t *testing.T) {
+	h := Deprecation{Since: time.Unix(100, 0)}.Headers()
+	assert.Equal(t, "@100", h.Get("Deprecation"))
+	assert.NotContains(t, h, "Sunset")
+	assert.NotContains(t, h, "Link")
+}
+
+func TestWriteTable(t *testing.T) {
+	var b strings.Builder
+	require.NoError(t, WriteTable(&b, testVersions...))
+	out := b.String()
+	assert.Contains(t, out, "## /v1\n")
+	assert.Contains(t, out, "| GET | `/v1/things` |  | List things | deprecated 2026-01-02, removed 2026-07-01; use `/v2/items` |\n")
+	assert.Contains(t, out, "| GET | `/v2/items` | API key | List items | current |\n")
+	assert.Contains(t, out, `Health \| status`)
+	assert.Less(t, strings.Index(out, "`/v1/health`"), strings.Index(out, "`/v1/things`"), "sorted by path")
+}
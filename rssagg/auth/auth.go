@@ -0,0 +1,23 @@
+package auth
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+)
+
+var ErrNoAuthHeaderIncluded = errors.New("no authorization header included")
+
+// GetAPIKey extracts the API key from a header of the form
+// "Authorization: ApiKey <key>".
+func GetAPIKey(headers http.Header) (string, error) {
+	val := headers.Get("Authorization")
+	if val == "" {
+		return "", ErrNoAuthHeaderIncluded
+	}
+	vals := strings.Fields(val)
+	if len(vals) != 2 || vals[0] != "ApiKey" {
+		return "", errors.New("malformed authorization header")
+	}
+	return vals[1], nil
+}
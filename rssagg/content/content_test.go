@@ -0,0 +1,131 @@
+package content
+
+import (
+	"context"
+	"golang/rssagg/scraper"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSanitize(t *testing.T) {
+	base, _ := url.Parse("https://blog.example/posts/1")
+	cases := map[string]string{
+		`<p onclick="x()">Hi <b>there</b></p>`:                            `<p>Hi <b>there</b></p>`,
+		`<script>alert(1)</script><p>ok</p>`:                              `<p>ok</p>`,
+		`<a href="/about" target="_blank">About</a>`:                      `<a href="https://blog.example/about" rel="nofollow noopener noreferrer">About</a>`,
+		`<a href="javascript:alert(1)">x</a>`:                             `<a>x</a>`,
+		`<a href="mailto:me@blog.example">mail</a>`:                       `<a href="mailto:me@blog.example" rel="nofollow noopener noreferrer">mail</a>`,
+		`<img src="data:image/png;base64,AAAA"><img src="a.png" alt="A">`: `<img src="https://blog.example/posts/a.png" alt="A">`,
+		`<font color="red">red</font> <center>c</center>`:                 `red c`,
+		`<iframe src="https://evil.example"></iframe>text`:                `text`,
+		`<p>unclosed <em>tags`:                                            `<p>unclosed <em>tags</em></p>`,
+		`<!-- comment --><style>p{}</style>&lt;b&gt; &amp;`:               `&lt;b&gt; &amp;`,
+		`<table><tr><td colspan="2" style="x">c</td></tr></table>`:        `<table><tbody><tr><td colspan="2">c</td></tr></tbody></table>`,
+		`<img src="x.png" alt="&quot;><script>">`:                         `<img src="https://blog.example/posts/x.png" alt="&#34;&gt;&lt;script&gt;">`,
+	}
+	for in, want := range cases {
+		assert.Equal(t, want, Sanitize(in, base), in)
+	}
+	assert.Equal(t, `<a>x</a>`, Sanitize(`<a href="/x">x</a>`, nil), "relative links need a base")
+}
+
+func TestPlainText(t *testing.T) {
+	assert.Equal(t, "Some bold text", PlainText("<p>Some <b>bold</b> text</p><style>p{}</style>"))
+	assert.Equal(t, "Title\nFirst\nSecond\nwo\nrd", PlainText("<h1>Title</h1><ul><li>First</li><li>Second</li></ul><p>wo<br>rd</p>"))
+	assert.Equal(t, "unbroken", PlainText("un<b>bro</b>ken"))
+	assert.Equal(t, "Tom & Jerry", PlainText("Tom &amp; Jerry"))
+}
+
+func TestReadingMinutes(t *testing.T) {
+	assert.Equal(t, 0, ReadingMinutes(""))
+	assert.Equal(t, 1, ReadingMinutes("Just a few words."))
+	assert.Equal(t, 2, ReadingMinutes(strings.Repeat("word ", 231)))
+	assert.Equal(t, 1, ReadingMinutes(strings.Repeat("字", 500)))
+	assert.Equal(t, 2, ReadingMinutes(strings.Repeat("字", 501)))
+	assert.Equal(t, 5, WordCount("don't stop, it's 中文"))
+}
+
+// serveTestdata serves the fixture pages the way a site would. No
+// charset is declared, so the pages' own meta tags apply.
+func serveTestdata(t *testing.T) *httptest.Server {
+	files := http.FileServer(http.Dir("testdata"))
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		files.ServeHTTP(w, r)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestExtractArticle(t *testing.T) {
+	srv := serveTestdata(t)
+	e := NewExtractor(Config{MinWords: 150, AllowPrivate: true})
+	art, err := e.Fetch(context.Background(), srv.URL+"/article.html")
+	require.NoError(t, err)
+
+	assert.Equal(t, "Understanding Go Channels", art.Title)
+	assert.Contains(t, art.Text, "Channels are the pipes that connect concurrent goroutines.")
+	assert.Contains(t, art.Text, "Closing a channel indicates")
+	assert.Contains(t, art.Text, "fmt.Println(v)")
+	for _, noise := range []string{"Popular posts", "Archive", "42 comments", "Great post", "Copyright", "Buy now", "analytics", "showAd"} {
+		assert.NotContains(t, art.Text, noise)
+	}
+
+	assert.Contains(t, art.HTML, `<img src="`+srv.URL+`/images/pipes.png" alt="Two goroutines connected by a channel">`)
+	assert.Contains(t, art.HTML, `<a href="`+srv.URL+`/posts/buffered-channels" rel="nofollow noopener noreferrer">follow-up on buffering</a>`)
+	assert.Contains(t, art.HTML, `<a>official tour</a>`)
+	assert.NotContains(t, art.HTML, "onclick")
+	assert.NotContains(t, art.HTML, "onerror")
+	assert.NotContains(t, art.HTML, "<script")
+	assert.Equal(t, 1, ReadingMinutes(art.Text))
+}
+
+func TestExtractDivParagraphs(t *testing.T) {
+	srv := serveTestdata(t)
+	art, err := NewExtractor(Config{AllowPrivate: true}).Fetch(context.Background(), srv.URL+"/news.html")
+	require.NoError(t, err)
+
+	assert.Equal(t, "City council approves new bike lanes - Daily Example", art.Title)
+	assert.Contains(t, art.Text, "twelve kilometres of protected bike lanes")
+	assert.Contains(t, art.Text, "café district", "the page's charset is honoured")
+	assert.NotContains(t, art.Text, "Tram extension")
+	assert.NotContains(t, art.Text, "Weather")
+	// Links resolve against <base>.
+	assert.Contains(t, art.HTML, `href="https://news.example/2024/05/bike-lanes-map.html"`)
+}
+
+func TestFetchHeaders(t *testing.T) {
+	var accept string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		accept = r.Header.Get("Accept")
+		// No <meta charset>: only the header says this is Latin-1.
+		w.Header().Set("Content-Type", "text/html; charset=iso-8859-1")
+		w.Write([]byte("<html><body><article><p>Le caf\xe9 du coin ouvre ses portes ce matin.</p></article></body></html>"))
+	}))
+	t.Cleanup(srv.Close)
+
+	art, err := NewExtractor(Config{AllowPrivate: true}).Fetch(context.Background(), srv.URL)
+	require.NoError(t, err)
+	assert.Contains(t, art.Text, "Le café du coin")
+	assert.True(t, strings.HasPrefix(accept, "text/html"), accept)
+
+	_, err = NewExtractor(Config{}).Fetch(context.Background(), srv.URL)
+	assert.ErrorIs(t, err, scraper.ErrPrivateAddress)
+}
+
+func TestExtractNoContent(t *testing.T) {
+	_, err := Extract(strings.NewReader(`<html><body><nav><a href="/">Home</a></nav></body></html>`), nil)
+	assert.ErrorIs(t, err, ErrNoContent)
+}
+
+func TestTruncated(t *testing.T) {
+	e := NewExtractor(Config{MinWords: 5})
+	assert.True(t, e.Truncated("Read more…"))
+	assert.False(t, e.Truncated("This item has quite enough words in it."))
+}
@@ -0,0 +1,289 @@
+package content
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"math"
+	"net/url"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// ErrNoContent is returned when a page has nothing that looks like an
+// article.
+var ErrNoContent = errors.New("content: no readable content found")
+
+// The class and id patterns follow Mozilla's Readability.
+var (
+	unlikelyNames = regexp.MustCompile(`(?i)-ad-|ai2html|banner|breadcrumbs|combx|comment|community|cover-wrap|disqus|extra|footer|gdpr|header|legends|menu|related|remark|replies|rss|shoutbox|sidebar|skyscraper|social|sponsor|supplemental|ad-break|agegate|pagination|pager|popup|yom-remote|share|nav`)
+	maybeNames    = regexp.MustCompile(`(?i)and|article|body|column|content|main|shadow`)
+	positiveNames = regexp.MustCompile(`(?i)article|body|content|entry|hentry|h-entry|main|page|post|text|blog|story`)
+	negativeNames = regexp.MustCompile(`(?i)-ad-|hidden|\bhid\b|banner|combx|comment|com-|contact|foot|footer|footnote|gdpr|masthead|media|meta|outbrain|promo|related|scroll|share|shoutbox|sidebar|skyscraper|sponsor|shopping|tags|tool|widget`)
+)
+
+// minParagraphLength is the shortest text that counts towards a
+// candidate's score.
+const minParagraphLength = 25
+
+// Article is the main content of a page.
+type Article struct {
+	Title string
+	// HTML is sanitized and has its links resolved.
+	HTML string
+	Text string
+}
+
+// Extract finds the main content of the HTML page read from r. pageURL is
+// used to resolve relative links and may be nil.
+func Extract(r io.Reader, pageURL *url.URL) (*Article, error) {
+	doc, err := html.Parse(r)
+	if err != nil {
+		return nil, err
+	}
+	if base := findFirst(doc, atom.Base); base != nil && pageURL != nil {
+		if href, err := url.Parse(attr(base, "href")); err == nil {
+			pageURL = pageURL.ResolveReference(href)
+		}
+	}
+	body := findFirst(doc, atom.Body)
+	if body == nil {
+		return nil, ErrNoContent
+	}
+
+	art := &Article{Title: documentTitle(doc)}
+	prune(body)
+	var buf bytes.Buffer
+	for _, n := range articleNodes(body) {
+		if err := html.Render(&buf, n); err != nil {
+			return nil, err
+		}
+	}
+	art.HTML = Sanitize(buf.String(), pageURL)
+	art.Text = PlainText(art.HTML)
+	if WordCount(art.Text) == 0 {
+		return nil, ErrNoContent
+	}
+	return art, nil
+}
+
+// prune removes elements that are never part of the article.
+func prune(n *html.Node) {
+	for c := n.FirstChild; c != nil; {
+		next := c.NextSibling
+		if c.Type == html.CommentNode || (c.Type == html.ElementNode && unlikely(c)) {
+			n.RemoveChild(c)
+		} else {
+			prune(c)
+		}
+		c = next
+	}
+}
+
+func unlikely(n *html.Node) bool {
+	if droppedElements[n.DataAtom] {
+		return true
+	}
+	switch n.DataAtom {
+	case atom.Nav, atom.Aside, atom.Footer:
+		return true
+	case atom.Body, atom.Html, atom.Article, atom.Main, atom.A:
+		return false
+	}
+	names := attr(n, "class") + " " + attr(n, "id")
+	return unlikelyNames.MatchString(names) && !maybeNames.MatchString(names)
+}
+
+// articleNodes scores the containers of every paragraph and returns the
+// best one together with those of its siblings that look related.
+func articleNodes(body *html.Node) []*html.Node {
+	scores := map[*html.Node]float64{}
+	var candidates []*html.Node
+	walk(body, func(n *html.Node) {
+		if !isParagraph(n) {
+			return
+		}
+		text := innerText(n)
+		length := utf8.RuneCountInString(text)
+		if length < minParagraphLength {
+			return
+		}
+		score := 1 + float64(strings.Count(text, ",")+strings.Count(text, "，")) + math.Min(float64(length)/100, 3)
+		// Parents get the full score, grandparents half and the level
+		// above a sixth.
+		for level, anc := 0, n.Parent; level < 3 && anc != nil && anc.Type == html.ElementNode; level, anc = level+1, anc.Parent {
+			if _, ok := scores[anc]; !ok {
+				scores[anc] = initialScore(anc)
+				candidates = append(candidates, anc)
+			}
+			scores[anc] += score / []float64{1, 2, 6}[level]
+		}
+	})
+
+	var top *html.Node
+	for _, c := range candidates {
+		scores[c] *= 1 - linkDensity(c)
+		if top == nil || scores[c] > scores[top] {
+			top = c
+		}
+	}
+	if top == nil || top.Parent == nil {
+		return []*html.Node{body}
+	}
+
+	threshold := math.Max(10, scores[top]*0.2)
+	var out []*html.Node
+	for s := top.Parent.FirstChild; s != nil; s = s.NextSibling {
+		if s == top {
+			out = append(out, s)
+			continue
+		}
+		if s.Type != html.ElementNode {
+			continue
+		}
+		bonus := 0.0
+		if class := attr(s, "class"); class != "" && class == attr(top, "class") {
+			bonus = scores[top] * 0.2
+		}
+		if score, ok := scores[s]; ok && score+bonus >= threshold {
+			out = append(out, s)
+			continue
+		}
+		if s.DataAtom == atom.P {
+			text := innerText(s)
+			length := utf8.RuneCountInString(text)
+			density := linkDensity(s)
+			if (length > 80 && density < 0.25) || (length > 0 && density == 0 && strings.Contains(text+" ", ". ")) {
+				out = append(out, s)
+			}
+		}
+	}
+	return out
+}
+
+// isParagraph reports whether n holds running text: a p, pre or td, or a
+// div used as a paragraph.
+func isParagraph(n *html.Node) bool {
+	if n.Type != html.ElementNode {
+		return false
+	}
+	switch n.DataAtom {
+	case atom.P, atom.Pre, atom.Td:
+		return true
+	case atom.Div:
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			if c.Type == html.ElementNode && blockElements[c.DataAtom] && c.DataAtom != atom.Br {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+func initialScore(n *html.Node) float64 {
+	var score float64
+	switch n.DataAtom {
+	case atom.Div, atom.Article, atom.Main:
+		score = 5
+	case atom.Pre, atom.Td, atom.Blockquote:
+		score = 3
+	case atom.Address, atom.Ol, atom.Ul, atom.Dl, atom.Dd, atom.Dt, atom.Li, atom.Form:
+		score = -3
+	case atom.H1, atom.H2, atom.H3, atom.H4, atom.H5, atom.H6, atom.Th:
+		score = -5
+	}
+	for _, name := range []string{attr(n, "class"), attr(n, "id")} {
+		if name == "" {
+			continue
+		}
+		if negativeNames.MatchString(name) {
+			score -= 25
+		}
+		if positiveNames.MatchString(name) {
+			score += 25
+		}
+	}
+	return score
+}
+
+// linkDensity is the share of n's text that sits inside links.
+func linkDensity(n *html.Node) float64 {
+	total := utf8.RuneCountInString(innerText(n))
+	if total == 0 {
+		return 0
+	}
+	linked := 0
+	walk(n, func(c *html.Node) {
+		if c.Type == html.ElementNode && c.DataAtom == atom.A {
+			linked += utf8.RuneCountInString(innerText(c))
+		}
+	})
+	return float64(linked) / float64(total)
+}
+
+func documentTitle(doc *html.Node) string {
+	var title string
+	walk(doc, func(n *html.Node) {
+		if n.Type != html.ElementNode || title != "" {
+			return
+		}
+		if n.DataAtom == atom.Meta && (attr(n, "property") == "og:title" || attr(n, "name") == "twitter:title") {
+			title = strings.TrimSpace(attr(n, "content"))
+		}
+	})
+	if title != "" {
+		return title
+	}
+	if n := findFirst(doc, atom.Title); n != nil {
+		return innerText(n)
+	}
+	if n := findFirst(doc, atom.H1); n != nil {
+		return innerText(n)
+	}
+	return ""
+}
+
+func innerText(n *html.Node) string {
+	var b strings.Builder
+	walk(n, func(c *html.Node) {
+		if c.Type == html.TextNode {
+			b.WriteString(c.Data)
+			b.WriteByte(' ')
+		}
+	})
+	return strings.Join(strings.Fields(b.String()), " ")
+}
+
+// walk calls fn for n and its descendants in document order.
+func walk(n *html.Node, fn func(*html.Node)) {
+	fn(n)
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		walk(c, fn)
+	}
+}
+
+func findFirst(n *html.Node, a atom.Atom) *html.Node {
+	if n.Type == html.ElementNode && n.DataAtom == a {
+		return n
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if found := findFirst(c, a); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+func attr(n *html.Node, key string) string {
+	for _, a := range n.Attr {
+		if a.Namespace == "" && a.Key == key {
+			return a.Val
+		}
+	}
+	return ""
+}
@@ -0,0 +1,71 @@
+package content
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"golang/rssagg/scraper"
+	"net/http"
+	"net/url"
+	"time"
+
+	"golang.org/x/net/html/charset"
+)
+
+type Config struct {
+	// MinWords is the length below which feed content is treated as a
+	// teaser and the full article is fetched.
+	MinWords     int
+	HostInterval time.Duration
+	UserAgent    string
+	// AllowPrivate lets the extractor reach loopback and private
+	// addresses. Article URLs come from feed content, so it is off by
+	// default.
+	AllowPrivate bool
+}
+
+func DefaultConfig() Config {
+	return Config{
+		MinWords:     150,
+		HostInterval: 2 * time.Second,
+		UserAgent:    scraper.DefaultConfig().UserAgent,
+	}
+}
+
+// Extractor downloads article pages and extracts their main content.
+type Extractor struct {
+	cfg     Config
+	fetcher *scraper.Fetcher
+}
+
+func NewExtractor(cfg Config) *Extractor {
+	client := scraper.PublicClient(30 * time.Second)
+	if cfg.AllowPrivate {
+		client = &http.Client{Timeout: 30 * time.Second}
+	}
+	return &Extractor{
+		cfg:     cfg,
+		fetcher: scraper.NewFetcher(client, scraper.NewHostLimiter(cfg.HostInterval), cfg.UserAgent).WithAccept(scraper.HTMLAccept),
+	}
+}
+
+// Truncated reports whether text is short enough to be worth replacing
+// with the full article.
+func (e *Extractor) Truncated(text string) bool {
+	return WordCount(text) < e.cfg.MinWords
+}
+
+// Fetch downloads pageURL and extracts its article. The charset declared
+// in Content-Type wins over the page's meta tags and sniffing.
+func (e *Extractor) Fetch(ctx context.Context, pageURL string) (*Article, error) {
+	u, err := url.Parse(pageURL)
+	if err != nil {
+		return nil, err
+	}
+	res, err := e.fetcher.Fetch(ctx, pageURL, "", "")
+	if err != nil {
+		return nil, fmt.Errorf("fetch %s: %w", pageURL, err)
+	}
+	enc, _, _ := charset.DetermineEncoding(res.Body, res.ContentType)
+	return Extract(enc.NewDecoder().Reader(bytes.NewReader(res.Body)), u)
+}
@@ -0,0 +1,107 @@
+package content
+
+import (
+	"math"
+	"strings"
+	"unicode"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// Reading speeds used by ReadingMinutes. Scripts without spaces between
+// words are counted per character.
+const (
+	wordsPerMinute = 230
+	charsPerMinute = 500
+)
+
+// blockElements start a new line in PlainText.
+var blockElements = map[atom.Atom]bool{
+	atom.Address: true, atom.Article: true, atom.Aside: true, atom.Blockquote: true,
+	atom.Br: true, atom.Dd: true, atom.Details: true, atom.Div: true, atom.Dl: true,
+	atom.Dt: true, atom.Figcaption: true, atom.Figure: true, atom.Footer: true,
+	atom.H1: true, atom.H2: true, atom.H3: true, atom.H4: true, atom.H5: true,
+	atom.H6: true, atom.Header: true, atom.Hr: true, atom.Li: true, atom.Main: true,
+	atom.Ol: true, atom.P: true, atom.Pre: true, atom.Section: true, atom.Summary: true,
+	atom.Table: true, atom.Td: true, atom.Th: true, atom.Tr: true, atom.Ul: true,
+}
+
+// PlainText drops the markup from an HTML fragment. Block elements become
+// line breaks and runs of whitespace collapse to one space.
+func PlainText(s string) string {
+	z := html.NewTokenizer(strings.NewReader(s))
+	var b strings.Builder
+	skip := 0
+	for {
+		tt := z.Next()
+		switch tt {
+		case html.ErrorToken:
+			return collapse(b.String())
+		case html.StartTagToken, html.EndTagToken, html.SelfClosingTagToken:
+			name, _ := z.TagName()
+			a := atom.Lookup(name)
+			if a == atom.Script || a == atom.Style {
+				if tt == html.StartTagToken {
+					skip++
+				} else if tt == html.EndTagToken && skip > 0 {
+					skip--
+				}
+			}
+			if blockElements[a] {
+				b.WriteByte('\n')
+			}
+		case html.TextToken:
+			if skip == 0 {
+				b.Write(z.Text())
+			}
+		}
+	}
+}
+
+func collapse(s string) string {
+	var lines []string
+	for _, line := range strings.Split(s, "\n") {
+		if line = strings.Join(strings.Fields(line), " "); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// ReadingMinutes estimates how long text takes to read, rounded up. Any
+// non-empty text takes at least a minute.
+func ReadingMinutes(text string) int {
+	words, chars := countWords(text)
+	if words == 0 && chars == 0 {
+		return 0
+	}
+	minutes := float64(words)/wordsPerMinute + float64(chars)/charsPerMinute
+	return int(math.Max(1, math.Ceil(minutes)))
+}
+
+// WordCount returns the number of words in text, counting each character
+// of scripts written without spaces as a word.
+func WordCount(text string) int {
+	words, chars := countWords(text)
+	return words + chars
+}
+
+func countWords(text string) (words, chars int) {
+	inWord := false
+	for _, r := range text {
+		switch {
+		case unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul, unicode.Thai):
+			chars++
+			inWord = false
+		case unicode.IsSpace(r) || (unicode.IsPunct(r) && r != '\'' && r != '’'):
+			inWord = false
+		default:
+			if !inWord {
+				words++
+			}
+			inWord = true
+		}
+	}
+	return words, chars
+}
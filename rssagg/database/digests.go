@@ -0,0 +1,208 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+const (
+	DigestDaily  = "daily"
+	DigestWeekly = "weekly"
+
+	DigestEmail   = "email"
+	DigestWebhook = "webhook"
+)
+
+type DigestSubscription struct {
+	ID        string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	UserID    string
+	Frequency string
+	Channel   string
+	Target    string
+	Secret    string
+	Folder    string
+	Hour      int
+	Weekday   int
+	Timezone  string
+	NextRunAt time.Time
+	// LastSentAt is zero until the first digest goes out.
+	LastSentAt time.Time
+	LastError  string
+}
+
+const digestSubscriptionColumns = `id, created_at, updated_at, user_id, frequency, channel, target, secret, folder,
+	hour, weekday, timezone, next_run_at, last_sent_at, last_error`
+
+func scanDigestSubscription(s scanner) (DigestSubscription, error) {
+	var sub DigestSubscription
+	var lastSentAt sql.NullTime
+	err := s.Scan(&sub.ID, &sub.CreatedAt, &sub.UpdatedAt, &sub.UserID, &sub.Frequency, &sub.Channel, &sub.Target,
+		&sub.Secret, &sub.Folder, &sub.Hour, &sub.Weekday, &sub.Timezone, &sub.NextRunAt, &lastSentAt, &sub.LastError)
+	sub.LastSentAt = lastSentAt.Time
+	return sub, notFound(err)
+}
+
+type CreateDigestSubscriptionParams struct {
+	ID        string
+	UserID    string
+	Frequency string
+	Channel   string
+	Target    string
+	Secret    string
+	Folder    string
+	Hour      int
+	Weekday   int
+	Timezone  string
+	NextRunAt time.Time
+}
+
+func (d *DB) CreateDigestSubscription(ctx context.Context, arg CreateDigestSubscriptionParams) (DigestSubscription, error) {
+	now := time.Now().UTC()
+	row := d.db.QueryRowContext(ctx, `INSERT INTO digest_subscriptions (`+digestSubscriptionColumns+`)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, NULL, '')
+		RETURNING `+digestSubscriptionColumns,
+		arg.ID, now, now, arg.UserID, arg.Frequency, arg.Channel, arg.Target, arg.Secret, arg.Folder,
+		arg.Hour, arg.Weekday, arg.Timezone, arg.NextRunAt.UTC())
+	sub, err := scanDigestSubscription(row)
+	if isUniqueViolation(err) {
+		return sub, ErrConflict
+	}
+	return sub, err
+}
+
+func (d *DB) GetDigestSubscription(ctx context.Context, id, userID string) (DigestSubscription, error) {
+	return scanDigestSubscription(d.db.QueryRowContext(ctx, `SELECT `+digestSubscriptionColumns+`
+		FROM digest_subscriptions WHERE id = ? AND user_id = ?`, id, userID))
+}
+
+func (d *DB) GetDigestSubscriptionsForUser(ctx context.Context, userID string) ([]DigestSubscription, error) {
+	return d.queryDigestSubscriptions(ctx, `SELECT `+digestSubscriptionColumns+` FROM digest_subscriptions
+		WHERE user_id = ? ORDER BY created_at`, userID)
+}
+
+// GetDueDigestSubscriptions returns up to limit subscriptions whose next
+// digest was due at or before now, oldest first.
+func (d *DB) GetDueDigestSubscriptions(ctx context.Context, now time.Time, limit int) ([]DigestSubscription, error) {
+	return d.queryDigestSubscriptions(ctx, `SELECT `+digestSubscriptionColumns+` FROM digest_subscriptions
+		WHERE next_run_at <= ? ORDER BY next_run_at LIMIT ?`, now.UTC(), limit)
+}
+
+func (d *DB) queryDigestSubscriptions(ctx context.Context, query string, args ...any) ([]DigestSubscription, error) {
+	rows, err := d.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var subs []DigestSubscription
+	for rows.Next() {
+		sub, err := scanDigestSubscription(rows)
+		if err != nil {
+			return nil, err
+		}
+		subs = append(subs, sub)
+	}
+	return subs, rows.Err()
+}
+
+func (d *DB) DeleteDigestSubscription(ctx context.Context, id, userID string) error {
+	res, err := d.db.ExecContext(ctx, `DELETE FROM digest_subscriptions WHERE id = ? AND user_id = ?`, id, userID)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// RescheduleDigestSubscription moves the next run of a subscription that
+// had nothing to send.
+func (d *DB) RescheduleDigestSubscription(ctx context.Context, id string, nextRunAt time.Time) error {
+	_, err := d.db.ExecContext(ctx, `UPDATE digest_subscriptions SET updated_at = ?, next_run_at = ? WHERE id = ?`,
+		time.Now().UTC(), nextRunAt.UTC(), id)
+	return err
+}
+
+// DigestDelivery is one attempt to deliver a digest. Error is empty when
+// it went out.
+type DigestDelivery struct {
+	ID             string
+	CreatedAt      time.Time
+	SubscriptionID string
+	PostCount      int
+	Error          string
+}
+
+const digestDeliveryColumns = `id, created_at, subscription_id, post_count, error`
+
+type RecordDigestDeliveryParams struct {
+	ID             string
+	SubscriptionID string
+	// PostIDs are every post the digest carried, including the other
+	// copies of clustered stories. They are only recorded when Error is
+	// empty, so a failed digest is retried in full.
+	PostIDs   []string
+	Error     string
+	NextRunAt time.Time
+}
+
+// RecordDigestDelivery logs a delivery attempt, marks its posts as sent
+// and schedules the subscription's next run.
+func (d *DB) RecordDigestDelivery(ctx context.Context, arg RecordDigestDeliveryParams) (DigestDelivery, error) {
+	now := time.Now().UTC()
+	var delivery DigestDelivery
+	err := d.withTx(ctx, func(tx *sql.Tx) error {
+		count := len(arg.PostIDs)
+		if arg.Error != "" {
+			count = 0
+		}
+		err := tx.QueryRowContext(ctx, `INSERT INTO digest_deliveries (`+digestDeliveryColumns+`)
+			VALUES (?, ?, ?, ?, ?)
+			RETURNING `+digestDeliveryColumns,
+			arg.ID, now, arg.SubscriptionID, count, arg.Error).
+			Scan(&delivery.ID, &delivery.CreatedAt, &delivery.SubscriptionID, &delivery.PostCount, &delivery.Error)
+		if err != nil {
+			return err
+		}
+		if arg.Error != "" {
+			_, err = tx.ExecContext(ctx, `UPDATE digest_subscriptions
+				SET updated_at = ?, next_run_at = ?, last_error = ? WHERE id = ?`,
+				now, arg.NextRunAt.UTC(), arg.Error, arg.SubscriptionID)
+			return err
+		}
+		for _, postID := range arg.PostIDs {
+			if _, err := tx.ExecContext(ctx, `INSERT INTO digest_items (subscription_id, post_id, delivery_id)
+				VALUES (?, ?, ?) ON CONFLICT DO NOTHING`, arg.SubscriptionID, postID, arg.ID); err != nil {
+				return err
+			}
+		}
+		_, err = tx.ExecContext(ctx, `UPDATE digest_subscriptions
+			SET updated_at = ?, next_run_at = ?, last_sent_at = ?, last_error = '' WHERE id = ?`,
+			now, arg.NextRunAt.UTC(), now, arg.SubscriptionID)
+		return err
+	})
+	return delivery, err
+}
+
+// GetDigestDeliveries returns the latest delivery attempts of a
+// subscription, newest first.
+func (d *DB) GetDigestDeliveries(ctx context.Context, subscriptionID string, limit int) ([]DigestDelivery, error) {
+	rows, err := d.db.QueryContext(ctx, `SELECT `+digestDeliveryColumns+` FROM digest_deliveries
+		WHERE subscription_id = ? ORDER BY created_at DESC, id LIMIT ?`, subscriptionID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []DigestDelivery
+	for rows.Next() {
+		var dd DigestDelivery
+		if err := rows.Scan(&dd.ID, &dd.CreatedAt, &dd.SubscriptionID, &dd.PostCount, &dd.Error); err != nil {
+			return nil, err
+		}
+		out = append(out, dd)
+	}
+	return out, rows.Err()
+}
@@ -0,0 +1,186 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+type Feed struct {
+	ID            string
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+	Name          string
+	URL           string
+	ETag          string
+	LastModified  string
+	LastFetchedAt time.Time
+	NextFetchAt   time.Time
+	ErrorCount    int
+	LastError     string
+	LastErrorAt   time.Time
+	Disabled      bool
+	// PushExpiresAt is when the feed's WebSub lease ends, zero without one.
+	PushExpiresAt time.Time
+}
+
+type CreateFeedParams struct {
+	ID   string
+	Name string
+	URL  string
+}
+
+const feedColumns = `id, created_at, updated_at, name, url, etag, last_modified, last_fetched_at,
+	next_fetch_at, error_count, last_error, last_error_at, disabled, push_expires_at`
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func scanFeed(s scanner) (Feed, error) {
+	var f Feed
+	var nt feedNullTimes
+	err := s.Scan(feedDests(&f, &nt)...)
+	nt.apply(&f)
+	return f, err
+}
+
+type feedNullTimes struct {
+	lastFetchedAt sql.NullTime
+	lastErrorAt   sql.NullTime
+	pushExpiresAt sql.NullTime
+}
+
+func (nt *feedNullTimes) apply(f *Feed) {
+	f.LastFetchedAt = nt.lastFetchedAt.Time
+	f.LastErrorAt = nt.lastErrorAt.Time
+	f.PushExpiresAt = nt.pushExpiresAt.Time
+}
+
+// feedDests returns the scan destinations matching feedColumns.
+func feedDests(f *Feed, nt *feedNullTimes) []any {
+	return []any{&f.ID, &f.CreatedAt, &f.UpdatedAt, &f.Name, &f.URL, &f.ETag, &f.LastModified,
+		&nt.lastFetchedAt, &f.NextFetchAt, &f.ErrorCount, &f.LastError, &nt.lastErrorAt, &f.Disabled, &nt.pushExpiresAt}
+}
+
+func (d *DB) CreateFeed(ctx context.Context, arg CreateFeedParams) (Feed, error) {
+	now := time.Now().UTC()
+	row := d.db.QueryRowContext(ctx, `INSERT INTO feeds (id, created_at, updated_at, name, url, next_fetch_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+		RETURNING `+feedColumns,
+		arg.ID, now, now, arg.Name, arg.URL, now)
+	f, err := scanFeed(row)
+	if isUniqueViolation(err) {
+		return f, ErrConflict
+	}
+	return f, err
+}
+
+func (d *DB) GetFeed(ctx context.Context, id string) (Feed, error) {
+	row := d.db.QueryRowContext(ctx, `SELECT `+feedColumns+` FROM feeds WHERE id = ?`, id)
+	f, err := scanFeed(row)
+	return f, notFound(err)
+}
+
+func (d *DB) GetFeedByURL(ctx context.Context, url string) (Feed, error) {
+	row := d.db.QueryRowContext(ctx, `SELECT `+feedColumns+` FROM feeds WHERE url = ?`, url)
+	f, err := scanFeed(row)
+	return f, notFound(err)
+}
+
+func (d *DB) GetFeeds(ctx context.Context) ([]Feed, error) {
+	return d.queryFeeds(ctx, `SELECT `+feedColumns+` FROM feeds ORDER BY created_at`)
+}
+
+// GetFeedsDue returns enabled feeds whose next fetch time has passed,
+// oldest first.
+func (d *DB) GetFeedsDue(ctx context.Context, now time.Time, limit int) ([]Feed, error) {
+	return d.queryFeeds(ctx, `SELECT `+feedColumns+` FROM feeds
+		WHERE disabled = 0 AND next_fetch_at <= ?
+		ORDER BY next_fetch_at
+		LIMIT ?`, now.UTC(), limit)
+}
+
+func (d *DB) queryFeeds(ctx context.Context, query string, args ...any) ([]Feed, error) {
+	rows, err := d.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var feeds []Feed
+	for rows.Next() {
+		f, err := scanFeed(rows)
+		if err != nil {
+			return nil, err
+		}
+		feeds = append(feeds, f)
+	}
+	return feeds, rows.Err()
+}
+
+type MarkFeedFetchedParams struct {
+	ID           string
+	FetchedAt    time.Time
+	NextFetchAt  time.Time
+	ETag         string
+	LastModified string
+}
+
+// MarkFeedFetched records a successful fetch (including a 304) and clears
+// the feed's error state.
+func (d *DB) MarkFeedFetched(ctx context.Context, arg MarkFeedFetchedParams) error {
+	_, err := d.db.ExecContext(ctx, `UPDATE feeds SET
+		updated_at = ?, last_fetched_at = ?, next_fetch_at = ?,
+		etag = ?, last_modified = ?,
+		error_count = 0, last_error = '', last_error_at = NULL
+		WHERE id = ?`,
+		time.Now().UTC(), arg.FetchedAt.UTC(), arg.NextFetchAt.UTC(), arg.ETag, arg.LastModified, arg.ID)
+	return err
+}
+
+type MarkFeedFailedParams struct {
+	ID          string
+	FailedAt    time.Time
+	NextFetchAt time.Time
+	ErrorCount  int
+	Error       string
+	Disable     bool
+}
+
+func (d *DB) MarkFeedFailed(ctx context.Context, arg MarkFeedFailedParams) error {
+	_, err := d.db.ExecContext(ctx, `UPDATE feeds SET
+		updated_at = ?, next_fetch_at = ?,
+		error_count = ?, last_error = ?, last_error_at = ?,
+		disabled = (disabled OR ?)
+		WHERE id = ?`,
+		time.Now().UTC(), arg.NextFetchAt.UTC(), arg.ErrorCount, arg.Error, arg.FailedAt.UTC(), arg.Disable, arg.ID)
+	return err
+}
+
+// DeferFeed postpones the next fetch without touching the error state.
+func (d *DB) DeferFeed(ctx context.Context, id string, until time.Time) error {
+	_, err := d.db.ExecContext(ctx, `UPDATE feeds SET updated_at = ?, next_fetch_at = ? WHERE id = ?`,
+		time.Now().UTC(), until.UTC(), id)
+	return err
+}
+
+// SetFeedDisabled stops or resumes fetching a feed. Enabling clears the
+// error state and makes the feed due at once.
+func (d *DB) SetFeedDisabled(ctx context.Context, id string, disabled bool) error {
+	now := time.Now().UTC()
+	var res sql.Result
+	var err error
+	if disabled {
+		res, err = d.db.ExecContext(ctx, `UPDATE feeds SET updated_at = ?, disabled = 1 WHERE id = ?`, now, id)
+	} else {
+		res, err = d.db.ExecContext(ctx, `UPDATE feeds SET updated_at = ?, disabled = 0, next_fetch_at = ?,
+			error_count = 0, last_error = '', last_error_at = NULL WHERE id = ?`, now, now, id)
+	}
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
@@ -0,0 +1,102 @@
+package database
+
+import (
+	"context"
+	"time"
+)
+
+type FeedFollow struct {
+	ID        string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	UserID    string
+	FeedID    string
+	Folder    string
+}
+
+type CreateFeedFollowParams struct {
+	ID     string
+	UserID string
+	FeedID string
+	Folder string
+}
+
+const feedFollowColumns = `id, created_at, updated_at, user_id, feed_id, folder`
+
+func scanFeedFollow(s scanner) (FeedFollow, error) {
+	var ff FeedFollow
+	err := s.Scan(&ff.ID, &ff.CreatedAt, &ff.UpdatedAt, &ff.UserID, &ff.FeedID, &ff.Folder)
+	return ff, notFound(err)
+}
+
+func (d *DB) CreateFeedFollow(ctx context.Context, arg CreateFeedFollowParams) (FeedFollow, error) {
+	now := time.Now().UTC()
+	row := d.db.QueryRowContext(ctx, `INSERT INTO feed_follows (`+feedFollowColumns+`)
+		VALUES (?, ?, ?, ?, ?, ?)
+		RETURNING `+feedFollowColumns,
+		arg.ID, now, now, arg.UserID, arg.FeedID, arg.Folder)
+	ff, err := scanFeedFollow(row)
+	if isUniqueViolation(err) {
+		return ff, ErrConflict
+	}
+	return ff, err
+}
+
+func (d *DB) GetFeedFollowsForUser(ctx context.Context, userID string) ([]FeedFollow, error) {
+	rows, err := d.db.QueryContext(ctx, `SELECT `+feedFollowColumns+` FROM feed_follows
+		WHERE user_id = ? ORDER BY folder, created_at`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var follows []FeedFollow
+	for rows.Next() {
+		ff, err := scanFeedFollow(rows)
+		if err != nil {
+			return nil, err
+		}
+		follows = append(follows, ff)
+	}
+	return follows, rows.Err()
+}
+
+func (d *DB) DeleteFeedFollow(ctx context.Context, id, userID string) error {
+	res, err := d.db.ExecContext(ctx, `DELETE FROM feed_follows WHERE id = ? AND user_id = ?`, id, userID)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// Subscription is a follow together with the followed feed.
+type Subscription struct {
+	Follow FeedFollow
+	Feed   Feed
+}
+
+func (d *DB) GetSubscriptionsForUser(ctx context.Context, userID string) ([]Subscription, error) {
+	rows, err := d.db.QueryContext(ctx, `SELECT `+prefixColumns("ff", feedFollowColumns)+`, `+prefixColumns("f", feedColumns)+`
+		FROM feed_follows ff JOIN feeds f ON f.id = ff.feed_id
+		WHERE ff.user_id = ?
+		ORDER BY ff.folder, f.name`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var subs []Subscription
+	for rows.Next() {
+		var s Subscription
+		var nt feedNullTimes
+		ff := &s.Follow
+		dests := append([]any{&ff.ID, &ff.CreatedAt, &ff.UpdatedAt, &ff.UserID, &ff.FeedID, &ff.Folder}, feedDests(&s.Feed, &nt)...)
+		if err := rows.Scan(dests...); err != nil {
+			return nil, err
+		}
+		nt.apply(&s.Feed)
+		subs = append(subs, s)
+	}
+	return subs, rows.Err()
+}
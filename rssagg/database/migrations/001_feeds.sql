@@ -0,0 +1,17 @@
+CREATE TABLE feeds (
+    id TEXT PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    name TEXT NOT NULL,
+    url TEXT NOT NULL UNIQUE,
+    etag TEXT NOT NULL DEFAULT '',
+    last_modified TEXT NOT NULL DEFAULT '',
+    last_fetched_at TIMESTAMP,
+    next_fetch_at TIMESTAMP NOT NULL,
+    error_count INTEGER NOT NULL DEFAULT 0,
+    last_error TEXT NOT NULL DEFAULT '',
+    last_error_at TIMESTAMP,
+    disabled INTEGER NOT NULL DEFAULT 0
+);
+
+CREATE INDEX feeds_next_fetch_at_idx ON feeds (next_fetch_at);
@@ -0,0 +1,15 @@
+CREATE TABLE posts (
+    id TEXT PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    feed_id TEXT NOT NULL REFERENCES feeds (id) ON DELETE CASCADE,
+    guid TEXT NOT NULL,
+    title TEXT NOT NULL,
+    url TEXT NOT NULL,
+    description TEXT NOT NULL,
+    published_at TIMESTAMP NOT NULL,
+    UNIQUE (feed_id, guid)
+);
+
+CREATE INDEX posts_feed_id_published_at_idx ON posts (feed_id, published_at);
+CREATE INDEX posts_published_at_idx ON posts (published_at);
@@ -0,0 +1,12 @@
+CREATE TABLE feed_follows (
+    id TEXT PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    user_id TEXT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    feed_id TEXT NOT NULL REFERENCES feeds (id) ON DELETE CASCADE,
+    -- Slash separated folder path, e.g. "Tech/Go". Empty means top level.
+    folder TEXT NOT NULL DEFAULT '',
+    UNIQUE (user_id, feed_id)
+);
+
+CREATE INDEX feed_follows_feed_id_idx ON feed_follows (feed_id);
@@ -0,0 +1,15 @@
+CREATE TABLE websub_subscriptions (
+    feed_id TEXT PRIMARY KEY REFERENCES feeds (id) ON DELETE CASCADE,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    hub_url TEXT NOT NULL,
+    topic_url TEXT NOT NULL,
+    secret TEXT NOT NULL,
+    -- pending, active or denied
+    state TEXT NOT NULL,
+    lease_expires_at TIMESTAMP,
+    last_error TEXT NOT NULL DEFAULT ''
+);
+
+-- Set while a WebSub lease is active so the scraper can poll less often.
+ALTER TABLE feeds ADD COLUMN push_expires_at TIMESTAMP;
@@ -0,0 +1,9 @@
+-- content_html and content_text hold the sanitized body of the post, taken
+-- from the feed or, once content_status is 'extracted', from the article
+-- page itself.
+ALTER TABLE posts ADD COLUMN content_html TEXT NOT NULL DEFAULT '';
+ALTER TABLE posts ADD COLUMN content_text TEXT NOT NULL DEFAULT '';
+ALTER TABLE posts ADD COLUMN reading_minutes INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE posts ADD COLUMN content_status TEXT NOT NULL DEFAULT 'feed';
+
+CREATE INDEX posts_content_status_idx ON posts (content_status) WHERE content_status = 'pending';
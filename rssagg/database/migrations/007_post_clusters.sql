@@ -0,0 +1,21 @@
+-- canonical_url and minhash fingerprint a post for duplicate detection.
+-- Posts with the same cluster_id are the same story; a cluster is named
+-- after its first post. An empty cluster_id marks posts stored before
+-- fingerprinting.
+ALTER TABLE posts ADD COLUMN canonical_url TEXT NOT NULL DEFAULT '';
+ALTER TABLE posts ADD COLUMN minhash BLOB;
+ALTER TABLE posts ADD COLUMN cluster_id TEXT NOT NULL DEFAULT '';
+
+CREATE INDEX posts_canonical_url_idx ON posts (canonical_url) WHERE canonical_url != '';
+CREATE INDEX posts_cluster_id_idx ON posts (cluster_id);
+
+-- The locality sensitive hashing bands of each minhash, for finding
+-- near-duplicate candidates.
+CREATE TABLE post_minhash_bands (
+    band_key INTEGER NOT NULL,
+    post_id TEXT NOT NULL REFERENCES posts (id) ON DELETE CASCADE,
+    created_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (band_key, post_id)
+);
+
+CREATE INDEX post_minhash_bands_post_id_idx ON post_minhash_bands (post_id);
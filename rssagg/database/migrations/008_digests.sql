@@ -0,0 +1,44 @@
+CREATE TABLE digest_subscriptions (
+    id TEXT PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    user_id TEXT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    -- daily or weekly
+    frequency TEXT NOT NULL,
+    -- email or webhook; target is the address or URL
+    channel TEXT NOT NULL,
+    target TEXT NOT NULL,
+    -- signs webhook deliveries
+    secret TEXT NOT NULL DEFAULT '',
+    folder TEXT NOT NULL DEFAULT '',
+    -- delivery time in the user's time zone; weekday is 0 for Sunday
+    hour INTEGER NOT NULL,
+    weekday INTEGER NOT NULL,
+    timezone TEXT NOT NULL,
+    next_run_at TIMESTAMP NOT NULL,
+    last_sent_at TIMESTAMP,
+    last_error TEXT NOT NULL DEFAULT '',
+    UNIQUE (user_id, channel, target, folder)
+);
+
+CREATE INDEX digest_subscriptions_next_run_at_idx ON digest_subscriptions (next_run_at);
+
+CREATE TABLE digest_deliveries (
+    id TEXT PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    subscription_id TEXT NOT NULL REFERENCES digest_subscriptions (id) ON DELETE CASCADE,
+    post_count INTEGER NOT NULL,
+    error TEXT NOT NULL DEFAULT ''
+);
+
+CREATE INDEX digest_deliveries_subscription_id_idx ON digest_deliveries (subscription_id, created_at);
+
+-- Every post a subscription has delivered, so no story is sent twice.
+CREATE TABLE digest_items (
+    subscription_id TEXT NOT NULL REFERENCES digest_subscriptions (id) ON DELETE CASCADE,
+    post_id TEXT NOT NULL REFERENCES posts (id) ON DELETE CASCADE,
+    delivery_id TEXT NOT NULL REFERENCES digest_deliveries (id) ON DELETE CASCADE,
+    PRIMARY KEY (subscription_id, post_id)
+);
+
+CREATE INDEX digest_items_post_id_idx ON digest_items (post_id);
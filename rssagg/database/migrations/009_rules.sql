@@ -0,0 +1,48 @@
+-- A rule matches a user's incoming posts and acts on them. Every condition
+-- that is set must hold: feed_id limits it to one feed, field/operator/
+-- pattern test the post's text.
+CREATE TABLE rules (
+    id TEXT PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    user_id TEXT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    name TEXT NOT NULL DEFAULT '',
+    feed_id TEXT REFERENCES feeds (id) ON DELETE CASCADE,
+    -- title, body, url or '' for no text condition
+    field TEXT NOT NULL DEFAULT '',
+    -- contains or regex
+    operator TEXT NOT NULL DEFAULT '',
+    pattern TEXT NOT NULL DEFAULT '',
+    -- tag, mark_read or star; tag names the tag for the first
+    action TEXT NOT NULL,
+    tag TEXT NOT NULL DEFAULT '',
+    enabled INTEGER NOT NULL DEFAULT 1
+);
+
+CREATE INDEX rules_user_id_idx ON rules (user_id);
+
+-- What a user has done with a post, by hand or through rules. Posts
+-- without a row are unread and unstarred.
+CREATE TABLE post_states (
+    user_id TEXT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    post_id TEXT NOT NULL REFERENCES posts (id) ON DELETE CASCADE,
+    updated_at TIMESTAMP NOT NULL,
+    read INTEGER NOT NULL DEFAULT 0,
+    starred INTEGER NOT NULL DEFAULT 0,
+    PRIMARY KEY (user_id, post_id)
+);
+
+CREATE INDEX post_states_post_id_idx ON post_states (post_id);
+
+CREATE TABLE post_tags (
+    user_id TEXT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    post_id TEXT NOT NULL REFERENCES posts (id) ON DELETE CASCADE,
+    tag TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    -- the rule that added the tag, if any
+    rule_id TEXT REFERENCES rules (id) ON DELETE SET NULL,
+    PRIMARY KEY (user_id, post_id, tag)
+);
+
+CREATE INDEX post_tags_user_tag_idx ON post_tags (user_id, tag);
+CREATE INDEX post_tags_post_id_idx ON post_tags (post_id);
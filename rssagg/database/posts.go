@@ -0,0 +1,460 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"time"
+)
+
+// Where the content of a post comes from.
+const (
+	ContentFromFeed  = "feed"
+	ContentPending   = "pending"
+	ContentExtracted = "extracted"
+	ContentFailed    = "failed"
+)
+
+type Post struct {
+	ID             string
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+	FeedID         string
+	GUID           string
+	Title          string
+	URL            string
+	Description    string
+	PublishedAt    time.Time
+	ContentHTML    string
+	ContentText    string
+	ReadingMinutes int
+	ContentStatus  string
+	CanonicalURL   string
+	MinHash        []byte
+	ClusterID      string
+}
+
+type CreatePostParams struct {
+	ID             string
+	FeedID         string
+	GUID           string
+	Title          string
+	URL            string
+	Description    string
+	PublishedAt    time.Time
+	ContentHTML    string
+	ContentText    string
+	ReadingMinutes int
+	// ContentStatus defaults to ContentFromFeed.
+	ContentStatus string
+	CanonicalURL  string
+	MinHash       []byte
+	// BandKeys index MinHash for near-duplicate lookups.
+	BandKeys []int64
+	// ClusterID defaults to ID, starting a cluster of its own.
+	ClusterID string
+}
+
+const postColumns = `id, created_at, updated_at, feed_id, guid, title, url, description, published_at, content_html, content_text, reading_minutes, content_status, canonical_url, minhash, cluster_id`
+
+func scanPost(s scanner) (Post, error) {
+	var p Post
+	err := s.Scan(postDests(&p)...)
+	return p, err
+}
+
+// postDests returns the scan destinations matching postColumns.
+func postDests(p *Post) []any {
+	return []any{&p.ID, &p.CreatedAt, &p.UpdatedAt, &p.FeedID, &p.GUID, &p.Title, &p.URL, &p.Description, &p.PublishedAt,
+		&p.ContentHTML, &p.ContentText, &p.ReadingMinutes, &p.ContentStatus, &p.CanonicalURL, &p.MinHash, &p.ClusterID}
+}
+
+// CreatePost inserts a post unless the feed already has one with the same
+// GUID. The returned bool reports whether a row was inserted.
+func (d *DB) CreatePost(ctx context.Context, arg CreatePostParams) (Post, bool, error) {
+	now := time.Now().UTC()
+	publishedAt := arg.PublishedAt
+	if publishedAt.IsZero() {
+		publishedAt = now
+	}
+	status := arg.ContentStatus
+	if status == "" {
+		status = ContentFromFeed
+	}
+	clusterID := arg.ClusterID
+	if clusterID == "" {
+		clusterID = arg.ID
+	}
+	var p Post
+	created := false
+	err := d.withTx(ctx, func(tx *sql.Tx) error {
+		rows, err := tx.QueryContext(ctx, `INSERT INTO posts (`+postColumns+`)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+			ON CONFLICT (feed_id, guid) DO NOTHING
+			RETURNING `+postColumns,
+			arg.ID, now, now, arg.FeedID, arg.GUID, arg.Title, arg.URL, arg.Description, publishedAt.UTC(),
+			arg.ContentHTML, arg.ContentText, arg.ReadingMinutes, status, arg.CanonicalURL, arg.MinHash, clusterID)
+		if err != nil {
+			return err
+		}
+		if rows.Next() {
+			p, err = scanPost(rows)
+			created = err == nil
+		} else {
+			err = rows.Err()
+		}
+		rows.Close()
+		if err != nil || !created {
+			return err
+		}
+		return insertBandKeys(ctx, tx, p.ID, now, arg.BandKeys)
+	})
+	if err != nil {
+		return Post{}, false, err
+	}
+	return p, created, nil
+}
+
+func insertBandKeys(ctx context.Context, tx *sql.Tx, postID string, createdAt time.Time, keys []int64) error {
+	for _, key := range keys {
+		if _, err := tx.ExecContext(ctx, `INSERT OR IGNORE INTO post_minhash_bands (band_key, post_id, created_at) VALUES (?, ?, ?)`,
+			key, postID, createdAt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (d *DB) GetPost(ctx context.Context, id string) (Post, error) {
+	p, err := scanPost(d.db.QueryRowContext(ctx, `SELECT `+postColumns+` FROM posts WHERE id = ?`, id))
+	return p, notFound(err)
+}
+
+// GetPostsByIDs returns the requested posts keyed by ID. Missing IDs are
+// left out.
+func (d *DB) GetPostsByIDs(ctx context.Context, ids []string) (map[string]Post, error) {
+	out := make(map[string]Post, len(ids))
+	if len(ids) == 0 {
+		return out, nil
+	}
+	args := make([]any, len(ids))
+	for i, id := range ids {
+		args[i] = id
+	}
+	posts, err := d.queryPosts(ctx, `SELECT `+postColumns+` FROM posts WHERE id IN (`+placeholders(len(ids))+`)`, args...)
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range posts {
+		out[p.ID] = p
+	}
+	return out, nil
+}
+
+// EachPost calls fn for every stored post, in insertion order.
+func (d *DB) EachPost(ctx context.Context, fn func(Post) error) error {
+	rows, err := d.db.QueryContext(ctx, `SELECT `+postColumns+` FROM posts ORDER BY created_at`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		p, err := scanPost(rows)
+		if err != nil {
+			return err
+		}
+		if err := fn(p); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+type UpdatePostContentParams struct {
+	ID             string
+	ContentHTML    string
+	ContentText    string
+	ReadingMinutes int
+	ContentStatus  string
+}
+
+// UpdatePostContent replaces the stored content of a post.
+func (d *DB) UpdatePostContent(ctx context.Context, arg UpdatePostContentParams) (Post, error) {
+	p, err := scanPost(d.db.QueryRowContext(ctx, `UPDATE posts
+		SET updated_at = ?, content_html = ?, content_text = ?, reading_minutes = ?, content_status = ?
+		WHERE id = ?
+		RETURNING `+postColumns,
+		time.Now().UTC(), arg.ContentHTML, arg.ContentText, arg.ReadingMinutes, arg.ContentStatus, arg.ID))
+	return p, notFound(err)
+}
+
+// SetPostContentStatus changes only the content status of a post.
+func (d *DB) SetPostContentStatus(ctx context.Context, id, status string) error {
+	res, err := d.db.ExecContext(ctx, `UPDATE posts SET updated_at = ?, content_status = ? WHERE id = ?`,
+		time.Now().UTC(), status, id)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// GetPostsPendingContent returns up to limit posts waiting for their
+// article to be extracted, oldest first.
+func (d *DB) GetPostsPendingContent(ctx context.Context, limit int) ([]Post, error) {
+	return d.queryPosts(ctx, `SELECT `+postColumns+` FROM posts
+		WHERE content_status = ?
+		ORDER BY created_at
+		LIMIT ?`, ContentPending, limit)
+}
+
+// GetPostsWithoutContent pages through posts stored before content was
+// kept, in ID order starting after afterID.
+func (d *DB) GetPostsWithoutContent(ctx context.Context, afterID string, limit int) ([]Post, error) {
+	return d.queryPosts(ctx, `SELECT `+postColumns+` FROM posts
+		WHERE content_html = '' AND description != '' AND id > ?
+		ORDER BY id
+		LIMIT ?`, afterID, limit)
+}
+
+type FindDuplicatesParams struct {
+	CanonicalURL string
+	BandKeys     []int64
+	// BandsSince limits near-duplicate candidates to posts stored after
+	// it; the same link matches at any age.
+	BandsSince time.Time
+	Limit      int
+}
+
+// FindDuplicates returns stored posts that share the canonical URL or a
+// MinHash band key, oldest first. Band matches are only candidates: the
+// caller still has to compare the signatures.
+func (d *DB) FindDuplicates(ctx context.Context, arg FindDuplicatesParams) ([]Post, error) {
+	if arg.CanonicalURL == "" && len(arg.BandKeys) == 0 {
+		return nil, nil
+	}
+	query := `SELECT ` + postColumns + ` FROM posts WHERE (? != '' AND canonical_url = ?)`
+	args := []any{arg.CanonicalURL, arg.CanonicalURL}
+	if len(arg.BandKeys) > 0 {
+		query += ` OR id IN (SELECT post_id FROM post_minhash_bands
+			WHERE band_key IN (` + placeholders(len(arg.BandKeys)) + `) AND created_at >= ?)`
+		for _, key := range arg.BandKeys {
+			args = append(args, key)
+		}
+		args = append(args, arg.BandsSince.UTC())
+	}
+	return d.queryPosts(ctx, query+` ORDER BY created_at, id LIMIT ?`, append(args, arg.Limit)...)
+}
+
+type SetPostFingerprintParams struct {
+	ID           string
+	CanonicalURL string
+	MinHash      []byte
+	BandKeys     []int64
+	ClusterID    string
+}
+
+// SetPostFingerprint stores the duplicate-detection fingerprint of a post
+// and the cluster it belongs to.
+func (d *DB) SetPostFingerprint(ctx context.Context, arg SetPostFingerprintParams) error {
+	return d.withTx(ctx, func(tx *sql.Tx) error {
+		var createdAt time.Time
+		err := tx.QueryRowContext(ctx, `UPDATE posts SET canonical_url = ?, minhash = ?, cluster_id = ?
+			WHERE id = ?
+			RETURNING created_at`,
+			arg.CanonicalURL, arg.MinHash, arg.ClusterID, arg.ID).Scan(&createdAt)
+		if err != nil {
+			return notFound(err)
+		}
+		if _, err := tx.ExecContext(ctx, `DELETE FROM post_minhash_bands WHERE post_id = ?`, arg.ID); err != nil {
+			return err
+		}
+		return insertBandKeys(ctx, tx, arg.ID, createdAt, arg.BandKeys)
+	})
+}
+
+// GetPostsWithoutFingerprint pages through posts stored before duplicate
+// detection, in ID order starting after afterID.
+func (d *DB) GetPostsWithoutFingerprint(ctx context.Context, afterID string, limit int) ([]Post, error) {
+	return d.queryPosts(ctx, `SELECT `+postColumns+` FROM posts
+		WHERE cluster_id = '' AND id > ?
+		ORDER BY id
+		LIMIT ?`, afterID, limit)
+}
+
+func (d *DB) queryPosts(ctx context.Context, query string, args ...any) ([]Post, error) {
+	rows, err := d.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var posts []Post
+	for rows.Next() {
+		p, err := scanPost(rows)
+		if err != nil {
+			return nil, err
+		}
+		posts = append(posts, p)
+	}
+	return posts, rows.Err()
+}
+
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?, ", n), ", ")
+}
+
+// TimelinePost is a post together with the name and URL of its feed.
+type TimelinePost struct {
+	Post     Post
+	FeedName string
+	FeedURL  string
+	// State is the user's read, starred and tag state of Post.
+	State PostState
+	// AlsoIn lists the same story as published by the user's other feeds.
+	AlsoIn []TimelineSource
+}
+
+// TimelineSource is one more place a clustered story was published.
+type TimelineSource struct {
+	PostID   string
+	URL      string
+	FeedID   string
+	FeedName string
+}
+
+type GetTimelineParams struct {
+	UserID string
+	// Folder restricts the timeline to feeds filed in this folder or any
+	// of its subfolders. Empty means all follows.
+	Folder string
+	// CreatedAfter, when set, leaves out posts stored before it.
+	CreatedAfter time.Time
+	// UnsentBy, when set, leaves out every story that digest subscription
+	// has already delivered, including later copies from other feeds.
+	UnsentBy string
+	// Unread, Starred and Tag keep only posts the user has not read, has
+	// starred or has tagged with Tag.
+	Unread  bool
+	Starred bool
+	Tag     string
+	Limit   int
+	Offset  int
+}
+
+// timelinePosts selects the posts of the feeds a user follows, filtered by
+// folder, age, digest and the user's state; see timelineArgs.
+const timelinePosts = `posts p
+		JOIN feeds f ON f.id = p.feed_id
+		JOIN feed_follows ff ON ff.feed_id = p.feed_id AND ff.user_id = ?
+		LEFT JOIN post_states ps ON ps.user_id = ff.user_id AND ps.post_id = p.id
+		WHERE (? = '' OR ff.folder = ? OR substr(ff.folder, 1, length(?) + 1) = ? || '/')
+			AND p.created_at > ?
+			AND (? = '' OR NOT EXISTS (
+				SELECT 1 FROM digest_items di JOIN posts sp ON sp.id = di.post_id
+				WHERE di.subscription_id = ? AND COALESCE(NULLIF(sp.cluster_id, ''), sp.id) = ` + postCluster + `))
+			AND (? = 0 OR NOT COALESCE(ps.read, 0))
+			AND (? = 0 OR COALESCE(ps.starred, 0))
+			AND (? = '' OR EXISTS (
+				SELECT 1 FROM post_tags pt WHERE pt.user_id = ff.user_id AND pt.post_id = p.id AND pt.tag = ?))`
+
+// postCluster names the cluster of p, falling back to the post itself for
+// posts that were never fingerprinted.
+const postCluster = `COALESCE(NULLIF(p.cluster_id, ''), p.id)`
+
+func timelineArgs(arg GetTimelineParams) []any {
+	return []any{arg.UserID, arg.Folder, arg.Folder, arg.Folder, arg.Folder,
+		arg.CreatedAfter.UTC(), arg.UnsentBy, arg.UnsentBy, arg.Unread, arg.Starred, arg.Tag, arg.Tag}
+}
+
+// GetTimeline returns the merged posts of every feed the user follows,
+// newest first. A story published by several of those feeds appears once,
+// as its earliest post, with the others in AlsoIn.
+func (d *DB) GetTimeline(ctx context.Context, arg GetTimelineParams) ([]TimelinePost, error) {
+	rows, err := d.db.QueryContext(ctx, `WITH visible AS (
+			SELECT `+prefixColumns("p", postColumns)+`, f.name AS feed_name, f.url AS feed_url,
+				COALESCE(ps.read, 0) AS read, COALESCE(ps.starred, 0) AS starred,
+				ROW_NUMBER() OVER (PARTITION BY `+postCluster+` ORDER BY p.published_at, p.id) AS cluster_rank
+			FROM `+timelinePosts+`
+		)
+		SELECT `+postColumns+`, feed_name, feed_url, read, starred
+		FROM visible
+		WHERE cluster_rank = 1
+		ORDER BY published_at DESC, id
+		LIMIT ? OFFSET ?`,
+		append(timelineArgs(arg), arg.Limit, arg.Offset)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []TimelinePost
+	for rows.Next() {
+		var tp TimelinePost
+		if err := rows.Scan(append(postDests(&tp.Post), &tp.FeedName, &tp.FeedURL, &tp.State.Read, &tp.State.Starred)...); err != nil {
+			return nil, err
+		}
+		out = append(out, tp)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if err := d.fillTags(ctx, arg.UserID, out); err != nil {
+		return nil, err
+	}
+	return out, d.fillAlsoIn(ctx, arg, out)
+}
+
+func (d *DB) fillTags(ctx context.Context, userID string, posts []TimelinePost) error {
+	if len(posts) == 0 {
+		return nil
+	}
+	ids := make([]string, len(posts))
+	for i, tp := range posts {
+		ids[i] = tp.Post.ID
+	}
+	tags, err := d.getPostTags(ctx, userID, ids)
+	if err != nil {
+		return err
+	}
+	for i := range posts {
+		posts[i].State.Tags = tags[posts[i].Post.ID]
+	}
+	return nil
+}
+
+// fillAlsoIn looks up the other visible posts of each cluster on the page.
+func (d *DB) fillAlsoIn(ctx context.Context, arg GetTimelineParams, posts []TimelinePost) error {
+	if len(posts) == 0 {
+		return nil
+	}
+	byCluster := make(map[string]*TimelinePost, len(posts))
+	args := timelineArgs(arg)
+	for i := range posts {
+		cluster := posts[i].Post.ClusterID
+		if cluster == "" {
+			cluster = posts[i].Post.ID
+		}
+		byCluster[cluster] = &posts[i]
+		args = append(args, cluster)
+	}
+	rows, err := d.db.QueryContext(ctx, `SELECT `+postCluster+`, p.id, p.url, f.id, f.name
+		FROM `+timelinePosts+`
+			AND `+postCluster+` IN (`+placeholders(len(posts))+`)
+		ORDER BY p.published_at, p.id`, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var cluster string
+		var src TimelineSource
+		if err := rows.Scan(&cluster, &src.PostID, &src.URL, &src.FeedID, &src.FeedName); err != nil {
+			return err
+		}
+		if tp := byCluster[cluster]; tp != nil && tp.Post.ID != src.PostID {
+			tp.AlsoIn = append(tp.AlsoIn, src)
+		}
+	}
+	return rows.Err()
+}
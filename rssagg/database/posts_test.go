@@ -0,0 +1,114 @@
+package database
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestDB(t *testing.T) *DB {
+	t.Helper()
+	db, err := Open(filepath.Join(t.TempDir(), "test.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+	_, err = db.Migrate(context.Background())
+	require.NoError(t, err)
+	return db
+}
+
+func TestGetTimelineFolders(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+	user, err := db.CreateUser(ctx, CreateUserParams{ID: "u1", Name: "alice"})
+	require.NoError(t, err)
+
+	folders := map[string]string{"go": "Tech/Go", "tech": "Tech", "techno": "Technology", "top": ""}
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	i := 0
+	for id, folder := range folders {
+		_, err := db.CreateFeed(ctx, CreateFeedParams{ID: id, Name: id, URL: "https://example.com/" + id})
+		require.NoError(t, err)
+		_, err = db.CreateFeedFollow(ctx, CreateFeedFollowParams{ID: id, UserID: user.ID, FeedID: id, Folder: folder})
+		require.NoError(t, err)
+		i++
+		_, created, err := db.CreatePost(ctx, CreatePostParams{ID: "p-" + id, FeedID: id, GUID: id, Title: id, PublishedAt: base.Add(time.Duration(i) * time.Hour)})
+		require.NoError(t, err)
+		require.True(t, created)
+	}
+
+	feedIDs := func(folder string) []string {
+		posts, err := db.GetTimeline(ctx, GetTimelineParams{UserID: user.ID, Folder: folder, Limit: 10})
+		require.NoError(t, err)
+		var ids []string
+		for _, p := range posts {
+			ids = append(ids, p.Post.FeedID)
+		}
+		return ids
+	}
+	assert.Len(t, feedIDs(""), 4)
+	assert.ElementsMatch(t, []string{"go", "tech"}, feedIDs("Tech"))
+	assert.Equal(t, []string{"go"}, feedIDs("Tech/Go"))
+	assert.Empty(t, feedIDs("Tec"))
+
+	posts, err := db.GetTimeline(ctx, GetTimelineParams{UserID: user.ID, Limit: 10})
+	require.NoError(t, err)
+	for j := 1; j < len(posts); j++ {
+		assert.True(t, posts[j-1].Post.PublishedAt.After(posts[j].Post.PublishedAt), "newest first")
+	}
+}
+
+func TestGetTimelineCollapsesClusters(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+	user, err := db.CreateUser(ctx, CreateUserParams{ID: "u1", Name: "alice"})
+	require.NoError(t, err)
+	for id, folder := range map[string]string{"a": "News", "b": "News", "c": "Tech", "unfollowed": ""} {
+		_, err := db.CreateFeed(ctx, CreateFeedParams{ID: id, Name: "Feed " + id, URL: "https://example.com/" + id})
+		require.NoError(t, err)
+		if id != "unfollowed" {
+			_, err = db.CreateFeedFollow(ctx, CreateFeedFollowParams{ID: id, UserID: user.ID, FeedID: id, Folder: folder})
+			require.NoError(t, err)
+		}
+	}
+
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	posts := []CreatePostParams{
+		{ID: "story-a", FeedID: "a", GUID: "1", URL: "https://a.example/story"},
+		{ID: "story-b", FeedID: "b", GUID: "1", URL: "https://b.example/story", ClusterID: "story-a"},
+		{ID: "story-c", FeedID: "c", GUID: "1", URL: "https://c.example/story", ClusterID: "story-a"},
+		{ID: "story-x", FeedID: "unfollowed", GUID: "1", URL: "https://x.example/story", ClusterID: "story-a"},
+		{ID: "other", FeedID: "b", GUID: "2", URL: "https://b.example/other"},
+	}
+	for i, p := range posts {
+		p.PublishedAt = base.Add(time.Duration(i) * time.Hour)
+		_, _, err := db.CreatePost(ctx, p)
+		require.NoError(t, err)
+	}
+
+	timeline, err := db.GetTimeline(ctx, GetTimelineParams{UserID: user.ID, Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, timeline, 2)
+	assert.Equal(t, "other", timeline[0].Post.ID)
+	assert.Empty(t, timeline[0].AlsoIn)
+	assert.Equal(t, "story-a", timeline[1].Post.ID, "the earliest post represents the cluster")
+	require.Len(t, timeline[1].AlsoIn, 2, "feeds the user does not follow are left out")
+	assert.Equal(t, TimelineSource{PostID: "story-b", URL: "https://b.example/story", FeedID: "b", FeedName: "Feed b"}, timeline[1].AlsoIn[0])
+	assert.Equal(t, "story-c", timeline[1].AlsoIn[1].PostID)
+
+	// Within a folder the cluster is represented by what the folder holds.
+	timeline, err = db.GetTimeline(ctx, GetTimelineParams{UserID: user.ID, Folder: "Tech", Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, timeline, 1)
+	assert.Equal(t, "story-c", timeline[0].Post.ID)
+	assert.Empty(t, timeline[0].AlsoIn)
+
+	// Paging counts clusters, not posts.
+	timeline, err = db.GetTimeline(ctx, GetTimelineParams{UserID: user.ID, Limit: 1, Offset: 1})
+	require.NoError(t, err)
+	require.Len(t, timeline, 1)
+	assert.Equal(t, "story-a", timeline[0].Post.ID)
+}
@@ -0,0 +1,264 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// Rule fields, operators and actions.
+const (
+	RuleFieldTitle = "title"
+	RuleFieldBody  = "body"
+	RuleFieldURL   = "url"
+
+	RuleContains = "contains"
+	RuleRegex    = "regex"
+
+	RuleTag      = "tag"
+	RuleMarkRead = "mark_read"
+	RuleStar     = "star"
+)
+
+type Rule struct {
+	ID        string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	UserID    string
+	Name      string
+	// FeedID limits the rule to one feed; empty matches every feed.
+	FeedID string
+	// Field is empty when the rule has no text condition.
+	Field    string
+	Operator string
+	Pattern  string
+	Action   string
+	// Tag is the tag added by RuleTag rules.
+	Tag     string
+	Enabled bool
+}
+
+const ruleColumns = `id, created_at, updated_at, user_id, name, feed_id, field, operator, pattern, action, tag, enabled`
+
+func scanRule(s scanner) (Rule, error) {
+	var r Rule
+	var feedID sql.NullString
+	err := s.Scan(&r.ID, &r.CreatedAt, &r.UpdatedAt, &r.UserID, &r.Name, &feedID, &r.Field, &r.Operator, &r.Pattern,
+		&r.Action, &r.Tag, &r.Enabled)
+	r.FeedID = feedID.String
+	return r, notFound(err)
+}
+
+type RuleParams struct {
+	Name     string
+	FeedID   string
+	Field    string
+	Operator string
+	Pattern  string
+	Action   string
+	Tag      string
+	Enabled  bool
+}
+
+func (d *DB) CreateRule(ctx context.Context, id, userID string, arg RuleParams) (Rule, error) {
+	now := time.Now().UTC()
+	return scanRule(d.db.QueryRowContext(ctx, `INSERT INTO rules (`+ruleColumns+`)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		RETURNING `+ruleColumns,
+		id, now, now, userID, arg.Name, nullString(arg.FeedID), arg.Field, arg.Operator, arg.Pattern,
+		arg.Action, arg.Tag, arg.Enabled))
+}
+
+// UpdateRule replaces every setting of a rule. Posts it already acted on
+// keep their tags and state.
+func (d *DB) UpdateRule(ctx context.Context, id, userID string, arg RuleParams) (Rule, error) {
+	return scanRule(d.db.QueryRowContext(ctx, `UPDATE rules
+		SET updated_at = ?, name = ?, feed_id = ?, field = ?, operator = ?, pattern = ?, action = ?, tag = ?, enabled = ?
+		WHERE id = ? AND user_id = ?
+		RETURNING `+ruleColumns,
+		time.Now().UTC(), arg.Name, nullString(arg.FeedID), arg.Field, arg.Operator, arg.Pattern, arg.Action, arg.Tag,
+		arg.Enabled, id, userID))
+}
+
+func (d *DB) GetRule(ctx context.Context, id, userID string) (Rule, error) {
+	return scanRule(d.db.QueryRowContext(ctx, `SELECT `+ruleColumns+` FROM rules
+		WHERE id = ? AND user_id = ?`, id, userID))
+}
+
+func (d *DB) GetRulesForUser(ctx context.Context, userID string) ([]Rule, error) {
+	return d.queryRules(ctx, `SELECT `+ruleColumns+` FROM rules
+		WHERE user_id = ? ORDER BY created_at, id`, userID)
+}
+
+// GetRulesForFeed returns the enabled rules that apply to new posts of a
+// feed: those of its followers that are not limited to another feed.
+func (d *DB) GetRulesForFeed(ctx context.Context, feedID string) ([]Rule, error) {
+	return d.queryRules(ctx, `SELECT `+prefixColumns("r", ruleColumns)+` FROM rules r
+		JOIN feed_follows ff ON ff.user_id = r.user_id AND ff.feed_id = ?
+		WHERE r.enabled AND (r.feed_id IS NULL OR r.feed_id = ?)
+		ORDER BY r.user_id, r.created_at, r.id`, feedID, feedID)
+}
+
+func (d *DB) queryRules(ctx context.Context, query string, args ...any) ([]Rule, error) {
+	rows, err := d.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var rules []Rule
+	for rows.Next() {
+		r, err := scanRule(rows)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, r)
+	}
+	return rules, rows.Err()
+}
+
+func (d *DB) DeleteRule(ctx context.Context, id, userID string) error {
+	res, err := d.db.ExecContext(ctx, `DELETE FROM rules WHERE id = ? AND user_id = ?`, id, userID)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// PostState is what a user has done with a post.
+type PostState struct {
+	Read    bool
+	Starred bool
+	Tags    []string
+}
+
+// HasTag reports whether the post carries tag.
+func (s PostState) HasTag(tag string) bool {
+	for _, t := range s.Tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// GetPostStates returns the user's state of each of postIDs that has one.
+func (d *DB) GetPostStates(ctx context.Context, userID string, postIDs []string) (map[string]PostState, error) {
+	states := make(map[string]PostState, len(postIDs))
+	if len(postIDs) == 0 {
+		return states, nil
+	}
+	args := []any{userID}
+	for _, id := range postIDs {
+		args = append(args, id)
+	}
+	rows, err := d.db.QueryContext(ctx, `SELECT post_id, read, starred FROM post_states
+		WHERE user_id = ? AND post_id IN (`+placeholders(len(postIDs))+`)`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id string
+		var s PostState
+		if err := rows.Scan(&id, &s.Read, &s.Starred); err != nil {
+			return nil, err
+		}
+		states[id] = s
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	tags, err := d.getPostTags(ctx, userID, postIDs)
+	if err != nil {
+		return nil, err
+	}
+	for id, t := range tags {
+		s := states[id]
+		s.Tags = t
+		states[id] = s
+	}
+	return states, nil
+}
+
+func (d *DB) getPostTags(ctx context.Context, userID string, postIDs []string) (map[string][]string, error) {
+	args := []any{userID}
+	for _, id := range postIDs {
+		args = append(args, id)
+	}
+	rows, err := d.db.QueryContext(ctx, `SELECT post_id, tag FROM post_tags
+		WHERE user_id = ? AND post_id IN (`+placeholders(len(postIDs))+`)
+		ORDER BY post_id, tag`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	tags := make(map[string][]string)
+	for rows.Next() {
+		var id, tag string
+		if err := rows.Scan(&id, &tag); err != nil {
+			return nil, err
+		}
+		tags[id] = append(tags[id], tag)
+	}
+	return tags, rows.Err()
+}
+
+// PostAction is one rule action to carry out on a user's post.
+type PostAction struct {
+	UserID string
+	PostID string
+	RuleID string
+	Action string
+	Tag    string
+}
+
+// ApplyPostActions carries out actions in one transaction. Actions only
+// ever add: a post is marked read or starred, or gains a tag, and applying
+// an action twice changes nothing.
+func (d *DB) ApplyPostActions(ctx context.Context, actions []PostAction) error {
+	if len(actions) == 0 {
+		return nil
+	}
+	now := time.Now().UTC()
+	return d.withTx(ctx, func(tx *sql.Tx) error {
+		for _, a := range actions {
+			var err error
+			switch a.Action {
+			case RuleTag:
+				_, err = tx.ExecContext(ctx, `INSERT INTO post_tags (user_id, post_id, tag, created_at, rule_id)
+					VALUES (?, ?, ?, ?, ?)
+					ON CONFLICT DO NOTHING`, a.UserID, a.PostID, a.Tag, now, nullString(a.RuleID))
+			case RuleMarkRead:
+				_, err = tx.ExecContext(ctx, `INSERT INTO post_states (user_id, post_id, updated_at, read)
+					VALUES (?, ?, ?, 1)
+					ON CONFLICT DO UPDATE SET read = 1, updated_at = excluded.updated_at`, a.UserID, a.PostID, now)
+			case RuleStar:
+				_, err = tx.ExecContext(ctx, `INSERT INTO post_states (user_id, post_id, updated_at, starred)
+					VALUES (?, ?, ?, 1)
+					ON CONFLICT DO UPDATE SET starred = 1, updated_at = excluded.updated_at`, a.UserID, a.PostID, now)
+			}
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// GetFollowedPosts returns up to limit posts of the feeds the user follows
+// with IDs after afterID, in ID order, for walking all of them in pages.
+// feedID, when set, restricts the walk to one feed.
+func (d *DB) GetFollowedPosts(ctx context.Context, userID, feedID, afterID string, limit int) ([]Post, error) {
+	return d.queryPosts(ctx, `SELECT `+prefixColumns("p", postColumns)+` FROM posts p
+		JOIN feed_follows ff ON ff.feed_id = p.feed_id AND ff.user_id = ?
+		WHERE (? = '' OR p.feed_id = ?) AND p.id > ?
+		ORDER BY p.id
+		LIMIT ?`, userID, feedID, feedID, afterID, limit)
+}
+
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
@@ -0,0 +1,52 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// ScraperStats summarizes the fetch state of all feeds and the posts they
+// produced.
+type ScraperStats struct {
+	Feeds        int
+	Disabled     int
+	Failing      int
+	Due          int
+	NeverFetched int
+	PushActive   int
+	// LastFetchedAt is the most recent successful fetch of any feed.
+	LastFetchedAt  time.Time
+	Posts          int
+	PostsLastDay   int
+	PendingContent int
+}
+
+func (d *DB) GetScraperStats(ctx context.Context, now time.Time) (ScraperStats, error) {
+	var st ScraperStats
+	var lastFetchedAt sql.NullString
+	err := d.db.QueryRowContext(ctx, `SELECT
+			COUNT(*),
+			COALESCE(SUM(disabled), 0),
+			COALESCE(SUM(error_count > 0 AND disabled = 0), 0),
+			COALESCE(SUM(disabled = 0 AND next_fetch_at <= ?), 0),
+			COALESCE(SUM(last_fetched_at IS NULL), 0),
+			COALESCE(SUM(push_expires_at > ?), 0),
+			MAX(last_fetched_at)
+		FROM feeds`, now.UTC(), now.UTC()).
+		Scan(&st.Feeds, &st.Disabled, &st.Failing, &st.Due, &st.NeverFetched, &st.PushActive, &lastFetchedAt)
+	if err != nil {
+		return st, err
+	}
+	if lastFetchedAt.Valid {
+		// MAX() loses the column type, so the value comes back as text.
+		st.LastFetchedAt, _ = time.Parse("2006-01-02 15:04:05.999999999-07:00", lastFetchedAt.String)
+	}
+	err = d.db.QueryRowContext(ctx, `SELECT
+			COUNT(*),
+			COALESCE(SUM(created_at > ?), 0),
+			COALESCE(SUM(content_status = ?), 0)
+		FROM posts`, now.Add(-24*time.Hour).UTC(), ContentPending).
+		Scan(&st.Posts, &st.PostsLastDay, &st.PendingContent)
+	return st, err
+}
@@ -0,0 +1,51 @@
+package database
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"time"
+)
+
+type User struct {
+	ID        string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	Name      string
+	APIKey    string
+}
+
+type CreateUserParams struct {
+	ID   string
+	Name string
+}
+
+const userColumns = `id, created_at, updated_at, name, api_key`
+
+func scanUser(s scanner) (User, error) {
+	var u User
+	err := s.Scan(&u.ID, &u.CreatedAt, &u.UpdatedAt, &u.Name, &u.APIKey)
+	return u, notFound(err)
+}
+
+// CreateUser stores a new user with a freshly generated API key.
+func (d *DB) CreateUser(ctx context.Context, arg CreateUserParams) (User, error) {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return User{}, err
+	}
+	now := time.Now().UTC()
+	row := d.db.QueryRowContext(ctx, `INSERT INTO users (`+userColumns+`)
+		VALUES (?, ?, ?, ?, ?)
+		RETURNING `+userColumns,
+		arg.ID, now, now, arg.Name, hex.EncodeToString(key))
+	return scanUser(row)
+}
+
+func (d *DB) GetUser(ctx context.Context, id string) (User, error) {
+	return scanUser(d.db.QueryRowContext(ctx, `SELECT `+userColumns+` FROM users WHERE id = ?`, id))
+}
+
+func (d *DB) GetUserByAPIKey(ctx context.Context, apiKey string) (User, error) {
+	return scanUser(d.db.QueryRowContext(ctx, `SELECT `+userColumns+` FROM users WHERE api_key = ?`, apiKey))
+}
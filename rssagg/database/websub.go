@@ -0,0 +1,121 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+const (
+	WebSubPending = "pending"
+	WebSubActive  = "active"
+	WebSubDenied  = "denied"
+)
+
+type WebSubSubscription struct {
+	FeedID         string
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+	HubURL         string
+	TopicURL       string
+	Secret         string
+	State          string
+	LeaseExpiresAt time.Time
+	LastError      string
+}
+
+const webSubColumns = `feed_id, created_at, updated_at, hub_url, topic_url, secret, state, lease_expires_at, last_error`
+
+func scanWebSubSubscription(s scanner) (WebSubSubscription, error) {
+	var sub WebSubSubscription
+	var leaseExpiresAt sql.NullTime
+	err := s.Scan(&sub.FeedID, &sub.CreatedAt, &sub.UpdatedAt, &sub.HubURL, &sub.TopicURL, &sub.Secret, &sub.State,
+		&leaseExpiresAt, &sub.LastError)
+	sub.LeaseExpiresAt = leaseExpiresAt.Time
+	return sub, notFound(err)
+}
+
+type RequestWebSubSubscriptionParams struct {
+	FeedID    string
+	HubURL    string
+	TopicURL  string
+	Secret    string
+	LastError string
+}
+
+// RequestWebSubSubscription records that a subscription request was sent
+// to a hub. An active subscription to the same hub and topic stays active
+// while its renewal is pending.
+func (d *DB) RequestWebSubSubscription(ctx context.Context, arg RequestWebSubSubscriptionParams) error {
+	now := time.Now().UTC()
+	_, err := d.db.ExecContext(ctx, `INSERT INTO websub_subscriptions (`+webSubColumns+`)
+		VALUES (?, ?, ?, ?, ?, ?, ?, NULL, ?)
+		ON CONFLICT (feed_id) DO UPDATE SET
+			updated_at = excluded.updated_at,
+			state = CASE
+				WHEN state = 'active' AND hub_url = excluded.hub_url AND topic_url = excluded.topic_url THEN state
+				ELSE excluded.state END,
+			hub_url = excluded.hub_url,
+			topic_url = excluded.topic_url,
+			secret = excluded.secret,
+			last_error = excluded.last_error`,
+		arg.FeedID, now, now, arg.HubURL, arg.TopicURL, arg.Secret, WebSubPending, arg.LastError)
+	return err
+}
+
+func (d *DB) GetWebSubSubscription(ctx context.Context, feedID string) (WebSubSubscription, error) {
+	return scanWebSubSubscription(d.db.QueryRowContext(ctx, `SELECT `+webSubColumns+` FROM websub_subscriptions WHERE feed_id = ?`, feedID))
+}
+
+// GetWebSubSubscriptionsToRenew returns active subscriptions whose lease
+// ends before before, and pending ones last touched before retryBefore.
+func (d *DB) GetWebSubSubscriptionsToRenew(ctx context.Context, before, retryBefore time.Time) ([]WebSubSubscription, error) {
+	rows, err := d.db.QueryContext(ctx, `SELECT `+webSubColumns+` FROM websub_subscriptions
+		WHERE (state = 'active' AND lease_expires_at < ?) OR (state = 'pending' AND updated_at < ?)
+		ORDER BY updated_at`, before.UTC(), retryBefore.UTC())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var subs []WebSubSubscription
+	for rows.Next() {
+		sub, err := scanWebSubSubscription(rows)
+		if err != nil {
+			return nil, err
+		}
+		subs = append(subs, sub)
+	}
+	return subs, rows.Err()
+}
+
+// ActivateWebSubSubscription marks a subscription as verified by the hub
+// and tells the scraper that the feed is pushed until leaseExpiresAt.
+func (d *DB) ActivateWebSubSubscription(ctx context.Context, feedID string, leaseExpiresAt time.Time) error {
+	return d.withTx(ctx, func(tx *sql.Tx) error {
+		now := time.Now().UTC()
+		if _, err := tx.ExecContext(ctx, `UPDATE websub_subscriptions SET
+			updated_at = ?, state = 'active', lease_expires_at = ?, last_error = ''
+			WHERE feed_id = ?`, now, leaseExpiresAt.UTC(), feedID); err != nil {
+			return err
+		}
+		_, err := tx.ExecContext(ctx, `UPDATE feeds SET updated_at = ?, push_expires_at = ? WHERE id = ?`,
+			now, leaseExpiresAt.UTC(), feedID)
+		return err
+	})
+}
+
+// DenyWebSubSubscription records a hub's refusal and returns the feed to
+// regular polling.
+func (d *DB) DenyWebSubSubscription(ctx context.Context, feedID, reason string) error {
+	return d.withTx(ctx, func(tx *sql.Tx) error {
+		now := time.Now().UTC()
+		if _, err := tx.ExecContext(ctx, `UPDATE websub_subscriptions SET
+			updated_at = ?, state = 'denied', lease_expires_at = NULL, last_error = ?
+			WHERE feed_id = ?`, now, reason, feedID); err != nil {
+			return err
+		}
+		_, err := tx.ExecContext(ctx, `UPDATE feeds SET updated_at = ?, push_expires_at = NULL, next_fetch_at = ? WHERE id = ?`,
+			now, now, feedID)
+		return err
+	})
+}
@@ -0,0 +1,188 @@
+// Package dedup recognises the same story published by several feeds,
+// either under the same link or with nearly identical text.
+package dedup
+
+import (
+	"encoding/binary"
+	"golang/rssagg/search"
+	"hash/fnv"
+	"net"
+	"net/url"
+	"path"
+	"strings"
+)
+
+const (
+	// signatureSize is the number of MinHash values kept per text.
+	signatureSize = 32
+	// Signatures are split into bands of bandRows values for locality
+	// sensitive hashing: texts that agree on every value of any one band
+	// are candidates. With 8 bands of 4, texts with a Jaccard similarity
+	// of 0.7 become candidates 89% of the time, and of 0.3 only 6%.
+	bandRows = 4
+	// MinSimilarity is the estimated Jaccard similarity of shingles above
+	// which two texts count as the same story.
+	MinSimilarity = 0.7
+)
+
+// Texts with fewer shingles than this get no signature; a few words are
+// too easily shared by unrelated posts.
+const minShingles = 8
+
+const shingleSize = 3
+
+// trackingParams are query parameters that identify a campaign rather
+// than a page.
+var trackingParams = map[string]bool{
+	"fbclid": true, "gclid": true, "dclid": true, "msclkid": true, "yclid": true,
+	"mc_cid": true, "mc_eid": true, "_hsenc": true, "_hsmi": true, "igshid": true,
+	"ref": true, "ref_src": true,
+}
+
+// CanonicalURL normalizes a post link so that the variants feeds use for
+// the same page compare equal: the scheme, a leading "www.", default
+// ports, fragments, trailing slashes, tracking parameters and the order
+// of the remaining parameters are ignored. It returns "" for anything
+// that is not an http(s) URL or that points at a site's front page,
+// which many feeds use as the link of every item.
+func CanonicalURL(raw string) string {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil || u.Host == "" {
+		return ""
+	}
+	scheme := strings.ToLower(u.Scheme)
+	if scheme != "http" && scheme != "https" {
+		return ""
+	}
+
+	host := strings.TrimSuffix(strings.ToLower(u.Hostname()), ".")
+	host = strings.TrimPrefix(host, "www.")
+	if port := u.Port(); port != "" && port != "80" && port != "443" {
+		host = net.JoinHostPort(host, port)
+	}
+
+	p := u.EscapedPath()
+	if p != "" {
+		p = path.Clean(p)
+	}
+	p = strings.TrimSuffix(p, "/")
+
+	q := u.Query()
+	for key := range q {
+		if lower := strings.ToLower(key); strings.HasPrefix(lower, "utm_") || trackingParams[lower] {
+			q.Del(key)
+		}
+	}
+	if p == "" && len(q) == 0 {
+		return ""
+	}
+	out := host + p
+	if len(q) > 0 {
+		// Encode sorts by key.
+		out += "?" + q.Encode()
+	}
+	return out
+}
+
+// Signature is the MinHash of a text's three-word shingles. A nil
+// Signature stands for a text too short to compare.
+type Signature []uint32
+
+// MinHash computes the signature of text. Terms are normalized the way
+// the search index does it, so case, punctuation and inflection do not
+// matter.
+func MinHash(text string) Signature {
+	tokens := search.Tokenize(text)
+	if len(tokens)-shingleSize+1 < minShingles {
+		return nil
+	}
+	sig := make(Signature, signatureSize)
+	for i := range sig {
+		sig[i] = ^uint32(0)
+	}
+	for i := 0; i+shingleSize <= len(tokens); i++ {
+		h := fnv.New64a()
+		for _, t := range tokens[i : i+shingleSize] {
+			h.Write([]byte(t.Term))
+			h.Write([]byte{0})
+		}
+		shingle := h.Sum64()
+		for j := range sig {
+			// Each position uses its own permutation of the shingle hash.
+			if v := uint32(mix(shingle ^ uint64(j+1)*0x9e3779b97f4a7c15)); v < sig[j] {
+				sig[j] = v
+			}
+		}
+	}
+	return sig
+}
+
+// Similarity estimates the Jaccard similarity of the texts behind s and o.
+func (s Signature) Similarity(o Signature) float64 {
+	if len(s) != signatureSize || len(o) != signatureSize {
+		return 0
+	}
+	same := 0
+	for i := range s {
+		if s[i] == o[i] {
+			same++
+		}
+	}
+	return float64(same) / signatureSize
+}
+
+// NearDuplicate reports whether s and o belong to the same story.
+func (s Signature) NearDuplicate(o Signature) bool {
+	return s.Similarity(o) >= MinSimilarity
+}
+
+// BandKeys returns one key per band. Signatures sharing any key are
+// near-duplicate candidates.
+func (s Signature) BandKeys() []int64 {
+	if len(s) != signatureSize {
+		return nil
+	}
+	keys := make([]int64, 0, signatureSize/bandRows)
+	var buf [4]byte
+	for band := 0; band < signatureSize/bandRows; band++ {
+		h := fnv.New64a()
+		h.Write([]byte{byte(band)})
+		for _, v := range s[band*bandRows : (band+1)*bandRows] {
+			binary.LittleEndian.PutUint32(buf[:], v)
+			h.Write(buf[:])
+		}
+		keys = append(keys, int64(h.Sum64()))
+	}
+	return keys
+}
+
+// Bytes encodes s for storage.
+func (s Signature) Bytes() []byte {
+	if s == nil {
+		return nil
+	}
+	b := make([]byte, 4*len(s))
+	for i, v := range s {
+		binary.LittleEndian.PutUint32(b[4*i:], v)
+	}
+	return b
+}
+
+// ParseSignature decodes what Bytes returned.
+func ParseSignature(b []byte) Signature {
+	if len(b) != 4*signatureSize {
+		return nil
+	}
+	s := make(Signature, signatureSize)
+	for i := range s {
+		s[i] = binary.LittleEndian.Uint32(b[4*i:])
+	}
+	return s
+}
+
+// mix is the SplitMix64 finalizer.
+func mix(x uint64) uint64 {
+	x = (x ^ (x >> 30)) * 0xbf58476d1ce4e5b9
+	x = (x ^ (x >> 27)) * 0x94d049bb133111eb
+	return x ^ (x >> 31)
+}
@@ -0,0 +1,79 @@
+package dedup
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCanonicalURL(t *testing.T) {
+	same := []string{
+		"https://www.example.com/2024/05/story/",
+		"http://example.com/2024/05/story",
+		"https://EXAMPLE.com:443/2024/05//story#comments",
+		"https://example.com/2024/05/story?utm_source=rss&utm_medium=feed",
+		"https://example.com/2024/05/./story?fbclid=abc",
+	}
+	for _, raw := range same {
+		assert.Equal(t, "example.com/2024/05/story", CanonicalURL(raw), raw)
+	}
+
+	assert.Equal(t, "example.com/read?a=1&id=7", CanonicalURL("https://example.com/read?id=7&a=1&utm_campaign=x"))
+	assert.Equal(t, "example.com:8080/post", CanonicalURL("http://example.com:8080/post"))
+	assert.NotEqual(t, CanonicalURL("https://example.com/Post"), CanonicalURL("https://example.com/post"), "paths are case sensitive")
+
+	for _, raw := range []string{"", "not a url", "urn:uuid:1234", "ftp://example.com/file", "https://example.com/", "https://example.com?utm_source=x"} {
+		assert.Empty(t, CanonicalURL(raw), raw)
+	}
+}
+
+const story = `The city council on Tuesday approved a plan to add twelve kilometres of
+protected bike lanes, after a debate that lasted almost four hours and drew a record
+number of public comments. Supporters said the lanes would make the cafe district
+safer, while shop owners worried about losing parking spaces and passing trade.
+Construction is expected to start in the autumn and finish within two years.`
+
+func TestMinHash(t *testing.T) {
+	sig := MinHash(story)
+	require.Len(t, sig, signatureSize)
+	assert.Equal(t, sig, MinHash(story))
+	assert.Equal(t, 1.0, sig.Similarity(MinHash(story)))
+
+	// Syndicated copies differ in punctuation, case and a few words.
+	edited := strings.Replace(story, "Tuesday", "tuesday", 1)
+	edited = strings.Replace(edited, "two years", "2 years", 1) + " (Reuters)"
+	assert.True(t, sig.NearDuplicate(MinHash(edited)), "similarity %.2f", sig.Similarity(MinHash(edited)))
+	assert.NotEmpty(t, sharedKeys(sig, MinHash(edited)), "near-duplicates share a band")
+
+	other := `The school board voted on Wednesday to extend the academic year by a week,
+citing lost classroom time during the winter storms. Teachers' unions said they had not
+been consulted and would review the decision with their members before the summer.`
+	assert.False(t, sig.NearDuplicate(MinHash(other)), "similarity %.2f", sig.Similarity(MinHash(other)))
+	assert.Empty(t, sharedKeys(sig, MinHash(other)))
+
+	assert.Nil(t, MinHash("Too short to compare"))
+	assert.False(t, Signature(nil).NearDuplicate(nil))
+	assert.Nil(t, Signature(nil).BandKeys())
+}
+
+func TestSignatureBytes(t *testing.T) {
+	sig := MinHash(story)
+	assert.Equal(t, sig, ParseSignature(sig.Bytes()))
+	assert.Nil(t, Signature(nil).Bytes())
+	assert.Nil(t, ParseSignature(nil))
+	assert.Nil(t, ParseSignature([]byte{1, 2, 3}))
+}
+
+func sharedKeys(a, b Signature) []int64 {
+	var shared []int64
+	for _, ka := range a.BandKeys() {
+		for _, kb := range b.BandKeys() {
+			if ka == kb {
+				shared = append(shared, ka)
+			}
+		}
+	}
+	return shared
+}
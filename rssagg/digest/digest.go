@@ -0,0 +1,240 @@
+// Package digest builds daily or weekly summaries of the posts a user has
+// not been sent yet and delivers them by email or webhook.
+package digest
+
+import (
+	"context"
+	"fmt"
+	"golang/rssagg/database"
+	"golang/rssagg/scraper"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type Config struct {
+	SMTP SMTPConfig
+	// MaxPosts caps the stories in one digest; the rest wait for the
+	// next one.
+	MaxPosts      int
+	CheckInterval time.Duration
+	// RetryInterval is how soon a failed delivery is tried again.
+	RetryInterval time.Duration
+	// BatchSize is how many due subscriptions one check handles.
+	BatchSize int
+	// AllowPrivate lets webhooks target loopback and private addresses.
+	// Webhook URLs are chosen by users, so it is off by default.
+	AllowPrivate bool
+}
+
+func DefaultConfig(smtp SMTPConfig) Config {
+	return Config{
+		SMTP:          smtp,
+		MaxPosts:      50,
+		CheckInterval: time.Minute,
+		RetryInterval: 15 * time.Minute,
+		BatchSize:     100,
+	}
+}
+
+type Scheduler struct {
+	db     *database.DB
+	cfg    Config
+	client *http.Client
+}
+
+func NewScheduler(db *database.DB, cfg Config) *Scheduler {
+	client := scraper.PublicClient(30 * time.Second)
+	if cfg.AllowPrivate {
+		client = &http.Client{Timeout: 30 * time.Second}
+	}
+	return &Scheduler{
+		db:     db,
+		cfg:    cfg,
+		client: client,
+	}
+}
+
+// EmailEnabled reports whether an SMTP server is configured.
+func (s *Scheduler) EmailEnabled() bool {
+	return s.cfg.SMTP.enabled()
+}
+
+// CheckWebhook fails with scraper.ErrPrivateAddress when target's host
+// resolves to an address the scheduler would refuse to post to.
+func (s *Scheduler) CheckWebhook(ctx context.Context, target string) error {
+	if s.cfg.AllowPrivate {
+		return nil
+	}
+	u, err := url.Parse(target)
+	if err != nil {
+		return err
+	}
+	return scraper.CheckPublic(ctx, u.Hostname())
+}
+
+// Start delivers due digests until ctx is cancelled.
+func (s *Scheduler) Start(ctx context.Context) {
+	ticker := time.NewTicker(s.cfg.CheckInterval)
+	defer ticker.Stop()
+	for {
+		if _, err := s.RunDue(ctx, time.Now()); err != nil && ctx.Err() == nil {
+			log.Printf("Digests: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunDue delivers every digest due at now and returns how many went out.
+// Failed deliveries are logged and retried after RetryInterval.
+func (s *Scheduler) RunDue(ctx context.Context, now time.Time) (int, error) {
+	subs, err := s.db.GetDueDigestSubscriptions(ctx, now, s.cfg.BatchSize)
+	if err != nil {
+		return 0, err
+	}
+	sent := 0
+	for _, sub := range subs {
+		if ctx.Err() != nil {
+			return sent, ctx.Err()
+		}
+		ok, err := s.run(ctx, sub, now)
+		if err != nil {
+			return sent, fmt.Errorf("digest %s: %w", sub.ID, err)
+		}
+		if ok {
+			sent++
+		}
+	}
+	return sent, nil
+}
+
+// run builds and sends one digest and schedules the next. It reports
+// whether a digest went out; delivery errors are recorded, not returned.
+func (s *Scheduler) run(ctx context.Context, sub database.DigestSubscription, now time.Time) (bool, error) {
+	next := NextRun(sub.Frequency, sub.Hour, sub.Weekday, location(sub.Timezone), now)
+	d, err := s.Build(ctx, sub, now)
+	if err != nil {
+		return false, err
+	}
+	if len(d.Posts) == 0 {
+		return false, s.db.RescheduleDigestSubscription(ctx, sub.ID, next)
+	}
+
+	arg := database.RecordDigestDeliveryParams{
+		ID:             uuid.New().String(),
+		SubscriptionID: sub.ID,
+		PostIDs:        d.postIDs(),
+		NextRunAt:      next,
+	}
+	if err := s.send(ctx, sub, d); err != nil {
+		log.Printf("Digests: delivering %s to %s: %v", sub.ID, sub.Target, err)
+		arg.Error = err.Error()
+		if retry := now.Add(s.cfg.RetryInterval); retry.Before(next) {
+			arg.NextRunAt = retry
+		}
+	}
+	if _, err := s.db.RecordDigestDelivery(ctx, arg); err != nil {
+		return false, err
+	}
+	return arg.Error == "", nil
+}
+
+// Build collects the stories sub has not delivered yet, collapsing
+// clusters the same way the timeline does. Only posts stored after the
+// subscription was created are considered, so the first digest is not the
+// whole archive. Posts the user has read, by hand or through a rule, are
+// left out.
+func (s *Scheduler) Build(ctx context.Context, sub database.DigestSubscription, now time.Time) (*Digest, error) {
+	user, err := s.db.GetUser(ctx, sub.UserID)
+	if err != nil {
+		return nil, err
+	}
+	posts, err := s.db.GetTimeline(ctx, database.GetTimelineParams{
+		UserID:       sub.UserID,
+		Folder:       sub.Folder,
+		CreatedAfter: sub.CreatedAt,
+		UnsentBy:     sub.ID,
+		Unread:       true,
+		Limit:        s.cfg.MaxPosts + 1,
+	})
+	if err != nil {
+		return nil, err
+	}
+	d := &Digest{
+		SubscriptionID: sub.ID,
+		UserID:         user.ID,
+		UserName:       user.Name,
+		Frequency:      sub.Frequency,
+		Folder:         sub.Folder,
+		Since:          sub.CreatedAt,
+		GeneratedAt:    now.UTC(),
+	}
+	if !sub.LastSentAt.IsZero() {
+		d.Since = sub.LastSentAt
+	}
+	if len(posts) > s.cfg.MaxPosts {
+		posts = posts[:s.cfg.MaxPosts]
+		d.More = true
+	}
+	d.Posts = digestPosts(posts)
+	return d, nil
+}
+
+func (s *Scheduler) send(ctx context.Context, sub database.DigestSubscription, d *Digest) error {
+	text, html, err := d.Render()
+	if err != nil {
+		return err
+	}
+	switch sub.Channel {
+	case database.DigestEmail:
+		if !s.EmailEnabled() {
+			return fmt.Errorf("email digests are not configured")
+		}
+		return sendMail(ctx, s.cfg.SMTP, sub.Target, d.Subject(), text, html)
+	case database.DigestWebhook:
+		return s.postWebhook(ctx, sub.Target, sub.Secret, webhookPayload{
+			Digest:  d,
+			Subject: d.Subject(),
+			Text:    text,
+			HTML:    html,
+		})
+	default:
+		return fmt.Errorf("unknown channel %q", sub.Channel)
+	}
+}
+
+// NextRun returns the first delivery time after after: hour o'clock in loc,
+// every day or, for weekly digests, on weekday.
+func NextRun(frequency string, hour, weekday int, loc *time.Location, after time.Time) time.Time {
+	local := after.In(loc)
+	days := 0
+	if frequency == database.DigestWeekly {
+		days = (weekday - int(local.Weekday()) + 7) % 7
+	}
+	next := time.Date(local.Year(), local.Month(), local.Day()+days, hour, 0, 0, 0, loc)
+	if !next.After(after) {
+		if frequency == database.DigestWeekly {
+			next = time.Date(local.Year(), local.Month(), local.Day()+days+7, hour, 0, 0, 0, loc)
+		} else {
+			next = time.Date(local.Year(), local.Month(), local.Day()+1, hour, 0, 0, 0, loc)
+		}
+	}
+	return next.UTC()
+}
+
+// location loads a subscription's time zone. Zones are validated when a
+// subscription is created, so a failure here falls back to UTC.
+func location(name string) *time.Location {
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
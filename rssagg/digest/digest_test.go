@@ -0,0 +1,403 @@
+package digest
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"golang/rssagg/database"
+	"golang/rssagg/scraper"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeSMTP speaks just enough SMTP for net/smtp.SendMail and keeps every
+// message it accepts.
+type fakeSMTP struct {
+	ln       net.Listener
+	mu       sync.Mutex
+	messages []smtpMessage
+}
+
+type smtpMessage struct {
+	From string
+	To   []string
+	Data string
+}
+
+func newFakeSMTP(t *testing.T) *fakeSMTP {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	s := &fakeSMTP{ln: ln}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	return s
+}
+
+func (s *fakeSMTP) Addr() string { return s.ln.Addr().String() }
+
+func (s *fakeSMTP) Messages() []smtpMessage {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]smtpMessage(nil), s.messages...)
+}
+
+func (s *fakeSMTP) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	reply := func(line string) { io.WriteString(conn, line+"\r\n") }
+	reply("220 fake ESMTP")
+	var msg smtpMessage
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		cmd := strings.ToUpper(strings.TrimSpace(line))
+		switch {
+		case strings.HasPrefix(cmd, "EHLO"), strings.HasPrefix(cmd, "HELO"):
+			reply("250-fake")
+			reply("250 8BITMIME")
+		case strings.HasPrefix(cmd, "MAIL FROM:"):
+			msg = smtpMessage{From: smtpPath(line)}
+			reply("250 OK")
+		case strings.HasPrefix(cmd, "RCPT TO:"):
+			msg.To = append(msg.To, smtpPath(line))
+			reply("250 OK")
+		case cmd == "DATA":
+			reply("354 go ahead")
+			var data strings.Builder
+			for {
+				l, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if l == ".\r\n" {
+					break
+				}
+				data.WriteString(strings.TrimPrefix(l, "."))
+			}
+			msg.Data = data.String()
+			s.mu.Lock()
+			s.messages = append(s.messages, msg)
+			s.mu.Unlock()
+			reply("250 queued")
+		case cmd == "QUIT":
+			reply("221 bye")
+			return
+		default:
+			reply("250 OK")
+		}
+	}
+}
+
+// smtpPath returns the address between angle brackets in a MAIL or RCPT
+// command.
+func smtpPath(line string) string {
+	start, end := strings.IndexByte(line, '<'), strings.IndexByte(line, '>')
+	if start < 0 || end < start {
+		return ""
+	}
+	return line[start+1 : end]
+}
+
+type fixture struct {
+	db    *database.DB
+	user  database.User
+	feeds []database.Feed
+}
+
+func newFixture(t *testing.T) *fixture {
+	t.Helper()
+	ctx := context.Background()
+	db, err := database.Open(filepath.Join(t.TempDir(), "test.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+	_, err = db.Migrate(ctx)
+	require.NoError(t, err)
+
+	f := &fixture{db: db}
+	f.user, err = db.CreateUser(ctx, database.CreateUserParams{ID: "u1", Name: "Ada"})
+	require.NoError(t, err)
+	for i, name := range []string{"One", "Two"} {
+		feed, err := db.CreateFeed(ctx, database.CreateFeedParams{
+			ID: "f" + name, Name: name, URL: "https://example.com/" + name,
+		})
+		require.NoError(t, err)
+		_, err = db.CreateFeedFollow(ctx, database.CreateFeedFollowParams{
+			ID: "ff" + name, UserID: f.user.ID, FeedID: feed.ID, Folder: []string{"news", "tech"}[i],
+		})
+		require.NoError(t, err)
+		f.feeds = append(f.feeds, feed)
+	}
+	return f
+}
+
+func (f *fixture) subscribe(t *testing.T, channel, target, secret string) database.DigestSubscription {
+	t.Helper()
+	sub, err := f.db.CreateDigestSubscription(context.Background(), database.CreateDigestSubscriptionParams{
+		ID: "d-" + channel, UserID: f.user.ID, Frequency: database.DigestDaily, Channel: channel, Target: target,
+		Secret: secret, Hour: 7, Weekday: 1, Timezone: "UTC", NextRunAt: time.Now(),
+	})
+	require.NoError(t, err)
+	return sub
+}
+
+func (f *fixture) post(t *testing.T, id string, feed int, cluster, title string) {
+	t.Helper()
+	_, _, err := f.db.CreatePost(context.Background(), database.CreatePostParams{
+		ID: id, FeedID: f.feeds[feed].ID, GUID: id, Title: title, URL: "https://example.com/" + id,
+		Description: "<p>About " + title + "</p>", ClusterID: cluster,
+	})
+	require.NoError(t, err)
+}
+
+func TestRunDueSendsEmailOnce(t *testing.T) {
+	ctx := context.Background()
+	f := newFixture(t)
+	smtpServer := newFakeSMTP(t)
+	s := NewScheduler(f.db, DefaultConfig(SMTPConfig{Addr: smtpServer.Addr(), From: "rssagg <digest@example.com>"}))
+	sub := f.subscribe(t, database.DigestEmail, "ada@example.com", "")
+
+	f.post(t, "p1", 0, "story", "Go 1.30 released")
+	f.post(t, "p2", 1, "story", "Go 1.30 is out")
+	f.post(t, "p3", 1, "", "Tabs <versus> spaces")
+
+	now := time.Now().Add(time.Minute)
+	sent, err := s.RunDue(ctx, now)
+	require.NoError(t, err)
+	assert.Equal(t, 1, sent)
+
+	msgs := smtpServer.Messages()
+	require.Len(t, msgs, 1)
+	assert.Equal(t, "digest@example.com", msgs[0].From)
+	assert.Equal(t, []string{"ada@example.com"}, msgs[0].To)
+	data := msgs[0].Data
+	assert.Contains(t, data, "Subject: Your daily rssagg digest: 2 new posts")
+	assert.Contains(t, data, "multipart/alternative")
+	assert.Contains(t, data, "Content-Type: text/plain; charset=utf-8")
+	assert.Contains(t, data, "Tabs &lt;versus&gt; spaces", "HTML part is escaped")
+	assert.Contains(t, data, "Also in Two: https://example.com/p2")
+	assert.NotContains(t, data, "* Go 1.30 is out", "clustered copies are not listed twice")
+
+	sub, err = f.db.GetDigestSubscription(ctx, sub.ID, f.user.ID)
+	require.NoError(t, err)
+	assert.False(t, sub.LastSentAt.IsZero())
+	assert.True(t, sub.NextRunAt.After(now))
+
+	// A late copy of a story that was already sent stays out; new stories
+	// go into the next digest.
+	f.post(t, "p4", 0, "p3", "Tabs versus spaces, again")
+	f.post(t, "p5", 0, "", "Generics in practice")
+	sent, err = s.RunDue(ctx, sub.NextRunAt)
+	require.NoError(t, err)
+	assert.Equal(t, 1, sent)
+	msgs = smtpServer.Messages()
+	require.Len(t, msgs, 2)
+	assert.Contains(t, msgs[1].Data, "Generics in practice")
+	assert.NotContains(t, msgs[1].Data, "again")
+	assert.Contains(t, msgs[1].Data, "1 new post")
+
+	// Nothing new: nothing is sent but the next run moves on.
+	sub, err = f.db.GetDigestSubscription(ctx, sub.ID, f.user.ID)
+	require.NoError(t, err)
+	sent, err = s.RunDue(ctx, sub.NextRunAt)
+	require.NoError(t, err)
+	assert.Zero(t, sent)
+	assert.Len(t, smtpServer.Messages(), 2)
+	later, err := f.db.GetDigestSubscription(ctx, sub.ID, f.user.ID)
+	require.NoError(t, err)
+	assert.True(t, later.NextRunAt.After(sub.NextRunAt))
+
+	deliveries, err := f.db.GetDigestDeliveries(ctx, sub.ID, 10)
+	require.NoError(t, err)
+	require.Len(t, deliveries, 2)
+	assert.Equal(t, 1, deliveries[0].PostCount)
+	assert.Equal(t, 3, deliveries[1].PostCount, "both copies of the clustered story are recorded")
+}
+
+func TestRunDueRetriesFailedWebhook(t *testing.T) {
+	ctx := context.Background()
+	f := newFixture(t)
+
+	var mu sync.Mutex
+	fail := true
+	var payloads []map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if r.Header.Get(SignatureHeader) != Sign("s3cret", body) {
+			http.Error(w, "bad signature", http.StatusForbidden)
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		if fail {
+			http.Error(w, "try later", http.StatusServiceUnavailable)
+			return
+		}
+		var p map[string]any
+		json.Unmarshal(body, &p)
+		payloads = append(payloads, p)
+	}))
+	defer srv.Close()
+
+	cfg := DefaultConfig(SMTPConfig{})
+	cfg.AllowPrivate = true
+	s := NewScheduler(f.db, cfg)
+	sub := f.subscribe(t, database.DigestWebhook, srv.URL, "s3cret")
+	f.post(t, "p1", 0, "", "Hello")
+
+	now := time.Now().Add(time.Minute)
+	sent, err := s.RunDue(ctx, now)
+	require.NoError(t, err)
+	assert.Zero(t, sent)
+	sub, err = f.db.GetDigestSubscription(ctx, sub.ID, f.user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "webhook returned 503", sub.LastError)
+	assert.WithinDuration(t, now.Add(15*time.Minute), sub.NextRunAt, time.Second)
+
+	mu.Lock()
+	fail = false
+	mu.Unlock()
+	sent, err = s.RunDue(ctx, sub.NextRunAt)
+	require.NoError(t, err)
+	assert.Equal(t, 1, sent)
+	require.Len(t, payloads, 1)
+	assert.Equal(t, "Ada", payloads[0]["user_name"])
+	assert.Contains(t, payloads[0]["text"], "Hello")
+	posts := payloads[0]["posts"].([]any)
+	require.Len(t, posts, 1)
+	assert.Equal(t, "p1", posts[0].(map[string]any)["id"])
+
+	sub, err = f.db.GetDigestSubscription(ctx, sub.ID, f.user.ID)
+	require.NoError(t, err)
+	assert.Empty(t, sub.LastError)
+}
+
+func TestWebhookPrivateAddress(t *testing.T) {
+	ctx := context.Background()
+	f := newFixture(t)
+	var hits int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+	}))
+	defer srv.Close()
+
+	s := NewScheduler(f.db, DefaultConfig(SMTPConfig{}))
+	assert.ErrorIs(t, s.CheckWebhook(ctx, srv.URL), scraper.ErrPrivateAddress)
+	assert.ErrorIs(t, s.CheckWebhook(ctx, "http://localhost/hook"), scraper.ErrPrivateAddress)
+
+	// Subscriptions created before the check are refused at delivery.
+	sub := f.subscribe(t, database.DigestWebhook, srv.URL, "s3cret")
+	f.post(t, "p1", 0, "", "Hello")
+	sent, err := s.RunDue(ctx, time.Now().Add(time.Minute))
+	require.NoError(t, err)
+	assert.Zero(t, sent)
+	assert.Zero(t, atomic.LoadInt32(&hits))
+	sub, err = f.db.GetDigestSubscription(ctx, sub.ID, f.user.ID)
+	require.NoError(t, err)
+	assert.Contains(t, sub.LastError, "not public")
+}
+
+func TestSendMailTimeout(t *testing.T) {
+	// A server that accepts connections but never greets.
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { ln.Close() })
+	accepted := make(chan net.Conn, 1)
+	go func() {
+		if conn, err := ln.Accept(); err == nil {
+			accepted <- conn
+		}
+	}()
+
+	cfg := SMTPConfig{Addr: ln.Addr().String(), From: "digest@agg.example", Timeout: 50 * time.Millisecond}
+	start := time.Now()
+	err = sendMail(context.Background(), cfg, "ada@example.com", "Digest", "text", "<p>html</p>")
+	assert.ErrorIs(t, err, os.ErrDeadlineExceeded)
+	assert.Less(t, time.Since(start), time.Second)
+	(<-accepted).Close()
+}
+
+func TestBuildFiltersFolderAndCapsPosts(t *testing.T) {
+	ctx := context.Background()
+	f := newFixture(t)
+	cfg := DefaultConfig(SMTPConfig{})
+	cfg.MaxPosts = 2
+	s := NewScheduler(f.db, cfg)
+	sub, err := f.db.CreateDigestSubscription(ctx, database.CreateDigestSubscriptionParams{
+		ID: "d1", UserID: f.user.ID, Frequency: database.DigestWeekly, Channel: database.DigestWebhook,
+		Target: "https://example.com/hook", Folder: "tech", Timezone: "UTC", NextRunAt: time.Now(),
+	})
+	require.NoError(t, err)
+	f.post(t, "p1", 0, "", "In news")
+	for _, id := range []string{"p2", "p3", "p4"} {
+		f.post(t, id, 1, "", "In tech "+id)
+	}
+
+	d, err := s.Build(ctx, sub, time.Now())
+	require.NoError(t, err)
+	assert.Len(t, d.Posts, 2)
+	assert.True(t, d.More)
+	for _, p := range d.Posts {
+		assert.Equal(t, "Two", p.FeedName)
+		assert.Contains(t, p.Summary, "About In tech")
+	}
+	text, html, err := d.Render()
+	require.NoError(t, err)
+	assert.Contains(t, text, "weekly rssagg digest for tech")
+	assert.Contains(t, html, "they will follow in the next digest")
+}
+
+func TestNextRun(t *testing.T) {
+	berlin := time.FixedZone("CET", 3600)
+	monday := time.Date(2024, 3, 4, 6, 30, 0, 0, time.UTC) // a Monday
+
+	tests := []struct {
+		name      string
+		frequency string
+		hour      int
+		weekday   int
+		loc       *time.Location
+		after     time.Time
+		want      time.Time
+	}{
+		{"daily later today", database.DigestDaily, 7, 0, time.UTC, monday, time.Date(2024, 3, 4, 7, 0, 0, 0, time.UTC)},
+		{"daily tomorrow", database.DigestDaily, 6, 0, time.UTC, monday, time.Date(2024, 3, 5, 6, 0, 0, 0, time.UTC)},
+		{"daily exactly now", database.DigestDaily, 6, 0, time.UTC, monday.Add(-30 * time.Minute), time.Date(2024, 3, 5, 6, 0, 0, 0, time.UTC)},
+		{"daily in zone", database.DigestDaily, 7, 0, berlin, monday, time.Date(2024, 3, 5, 6, 0, 0, 0, time.UTC)},
+		{"weekly this week", database.DigestWeekly, 7, int(time.Friday), time.UTC, monday, time.Date(2024, 3, 8, 7, 0, 0, 0, time.UTC)},
+		{"weekly today", database.DigestWeekly, 7, int(time.Monday), time.UTC, monday, time.Date(2024, 3, 4, 7, 0, 0, 0, time.UTC)},
+		{"weekly next week", database.DigestWeekly, 6, int(time.Monday), time.UTC, monday, time.Date(2024, 3, 11, 6, 0, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, NextRun(tt.frequency, tt.hour, tt.weekday, tt.loc, tt.after))
+		})
+	}
+}
+
+func TestExcerpt(t *testing.T) {
+	assert.Equal(t, "short text", excerpt("  short\n text ", 20))
+	assert.Equal(t, "the quick brown…", excerpt("the quick brown fox jumps", 17))
+}
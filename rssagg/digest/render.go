@@ -0,0 +1,136 @@
+package digest
+
+import (
+	"bytes"
+	"embed"
+	"golang/rssagg/content"
+	"golang/rssagg/database"
+	htmltemplate "html/template"
+	"strconv"
+	"strings"
+	texttemplate "text/template"
+	"time"
+	"unicode/utf8"
+)
+
+//go:embed templates/*.tmpl
+var templateFS embed.FS
+
+var (
+	htmlTemplates = htmltemplate.Must(htmltemplate.ParseFS(templateFS, "templates/*.html.tmpl"))
+	textTemplates = texttemplate.Must(texttemplate.ParseFS(templateFS, "templates/*.txt.tmpl"))
+)
+
+// summaryLength caps the excerpt shown under each post, in runes.
+const summaryLength = 280
+
+// Digest is what the templates and the webhook payload are built from.
+type Digest struct {
+	SubscriptionID string    `json:"subscription_id"`
+	UserID         string    `json:"user_id"`
+	UserName       string    `json:"user_name"`
+	Frequency      string    `json:"frequency"`
+	Folder         string    `json:"folder,omitempty"`
+	Since          time.Time `json:"since"`
+	GeneratedAt    time.Time `json:"generated_at"`
+	Posts          []Post    `json:"posts"`
+	// More is set when the timeline had more new posts than fit; they
+	// are left for the next digest.
+	More bool `json:"more"`
+}
+
+type Post struct {
+	ID             string    `json:"id"`
+	Title          string    `json:"title"`
+	URL            string    `json:"url"`
+	FeedName       string    `json:"feed_name"`
+	PublishedAt    time.Time `json:"published_at"`
+	Summary        string    `json:"summary,omitempty"`
+	ReadingMinutes int       `json:"reading_minutes,omitempty"`
+	AlsoIn         []Source  `json:"also_in,omitempty"`
+}
+
+type Source struct {
+	PostID   string `json:"post_id"`
+	URL      string `json:"url"`
+	FeedName string `json:"feed_name"`
+}
+
+func digestPosts(posts []database.TimelinePost) []Post {
+	out := make([]Post, 0, len(posts))
+	for _, tp := range posts {
+		text := tp.Post.ContentText
+		if text == "" {
+			text = content.PlainText(tp.Post.Description)
+		}
+		p := Post{
+			ID:             tp.Post.ID,
+			Title:          tp.Post.Title,
+			URL:            tp.Post.URL,
+			FeedName:       tp.FeedName,
+			PublishedAt:    tp.Post.PublishedAt,
+			Summary:        excerpt(text, summaryLength),
+			ReadingMinutes: tp.Post.ReadingMinutes,
+		}
+		if p.Title == "" {
+			p.Title = p.URL
+		}
+		for _, src := range tp.AlsoIn {
+			p.AlsoIn = append(p.AlsoIn, Source{PostID: src.PostID, URL: src.URL, FeedName: src.FeedName})
+		}
+		out = append(out, p)
+	}
+	return out
+}
+
+// postIDs lists every post d carries, including the other copies of
+// clustered stories.
+func (d *Digest) postIDs() []string {
+	var ids []string
+	for _, p := range d.Posts {
+		ids = append(ids, p.ID)
+		for _, src := range p.AlsoIn {
+			ids = append(ids, src.PostID)
+		}
+	}
+	return ids
+}
+
+// Subject is the email subject line.
+func (d *Digest) Subject() string {
+	noun := "posts"
+	if len(d.Posts) == 1 {
+		noun = "post"
+	}
+	subject := "Your " + d.Frequency + " rssagg digest"
+	if d.Folder != "" {
+		subject += " for " + d.Folder
+	}
+	return subject + ": " + strconv.Itoa(len(d.Posts)) + " new " + noun
+}
+
+// Render returns the plain text and HTML bodies of d.
+func (d *Digest) Render() (text, html string, err error) {
+	var tb, hb bytes.Buffer
+	if err := textTemplates.ExecuteTemplate(&tb, "digest.txt", d); err != nil {
+		return "", "", err
+	}
+	if err := htmlTemplates.ExecuteTemplate(&hb, "digest.html", d); err != nil {
+		return "", "", err
+	}
+	return tb.String(), hb.String(), nil
+}
+
+// excerpt shortens s to at most n runes, cutting at a word boundary.
+func excerpt(s string, n int) string {
+	s = strings.Join(strings.Fields(s), " ")
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	runes := []rune(s)[:n]
+	cut := string(runes)
+	if i := strings.LastIndexByte(cut, ' '); i > len(cut)/2 {
+		cut = cut[:i]
+	}
+	return strings.TrimRight(cut, " ,.;:") + "…"
+}
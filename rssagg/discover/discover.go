@@ -0,0 +1,295 @@
+// Package discover finds the feeds a website publishes, starting from any
+// page of it.
+package discover
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"golang/rssagg/scraper"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Where a candidate was found, strongest hint first.
+const (
+	// SourceDirect means the URL given was a feed already.
+	SourceDirect = "direct"
+	// SourceLink is a <link rel="alternate"> in the page head.
+	SourceLink = "link"
+	// SourceAnchor is an <a> in the page whose target looks like a feed.
+	SourceAnchor = "anchor"
+	// SourceSitemap is a feed-like URL listed in a sitemap.
+	SourceSitemap = "sitemap"
+	// SourceCommon is a well-known feed path such as /feed.
+	SourceCommon = "common"
+)
+
+var sourceScore = map[string]float64{
+	SourceDirect:  100,
+	SourceLink:    60,
+	SourceAnchor:  35,
+	SourceSitemap: 25,
+	SourceCommon:  20,
+}
+
+// commonPaths are tried relative to the site root and to the page's
+// directory.
+var commonPaths = []string{"feed", "rss", "rss.xml", "atom.xml", "feed.xml", "index.xml", "feed/atom"}
+
+var ErrNoFeeds = errors.New("no feeds found")
+
+// Candidate is a feed found for a site. Every candidate has been fetched
+// and parsed.
+type Candidate struct {
+	URL        string
+	Title      string
+	Format     string
+	Source     string
+	Items      int
+	LastItemAt time.Time
+	Score      float64
+}
+
+type Config struct {
+	UserAgent string
+	// HostInterval spaces out probes to one host. Discovery runs while a
+	// client waits, so it is shorter than the scraper's.
+	HostInterval time.Duration
+	// MaxProbes caps how many candidate URLs are fetched.
+	MaxProbes   int
+	Concurrency int
+	// AllowPrivate lets discovery reach loopback and private addresses.
+	// Discovery fetches URLs chosen by users, so it is off by default.
+	AllowPrivate bool
+}
+
+func DefaultConfig() Config {
+	return Config{
+		UserAgent:    scraper.DefaultConfig().UserAgent,
+		HostInterval: 100 * time.Millisecond,
+		MaxProbes:    24,
+		Concurrency:  4,
+	}
+}
+
+type Discoverer struct {
+	cfg     Config
+	fetcher *scraper.Fetcher
+}
+
+func New(cfg Config) *Discoverer {
+	client := scraper.PublicClient(15 * time.Second)
+	if cfg.AllowPrivate {
+		client = &http.Client{Timeout: 15 * time.Second}
+	}
+	return &Discoverer{
+		cfg:     cfg,
+		fetcher: scraper.NewFetcher(client, scraper.NewHostLimiter(cfg.HostInterval), cfg.UserAgent),
+	}
+}
+
+// hint is a URL that may be a feed.
+type hint struct {
+	url    string
+	title  string
+	source string
+}
+
+// Discover returns the feeds found for pageURL, best first. A pageURL that
+// is a feed itself comes back as the only candidate. ErrNoFeeds means the
+// page was fetched but nothing feed-like turned up.
+func (d *Discoverer) Discover(ctx context.Context, pageURL string) ([]Candidate, error) {
+	res, err := d.fetcher.Fetch(ctx, pageURL, "", "")
+	if err != nil {
+		return nil, fmt.Errorf("fetch %s: %w", pageURL, err)
+	}
+	if parsed, err := scraper.ParseFeed(res.Body); err == nil {
+		c := newCandidate(hint{url: res.URL, source: SourceDirect}, parsed)
+		c.Score = score(c, nil)
+		return []Candidate{c}, nil
+	}
+	base, err := url.Parse(res.URL)
+	if err != nil {
+		return nil, err
+	}
+
+	hints := pageHints(res.Body, res.ContentType, base)
+	hints = append(hints, d.sitemapHints(ctx, base)...)
+	hints = append(hints, commonHints(base)...)
+	candidates := d.probe(ctx, dedupHints(hints, d.cfg.MaxProbes))
+	if len(candidates) == 0 {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		return nil, ErrNoFeeds
+	}
+	for i := range candidates {
+		candidates[i].Score = score(candidates[i], base)
+	}
+	candidates = dedupCandidates(candidates)
+	sort.SliceStable(candidates, func(i, j int) bool {
+		if candidates[i].Score != candidates[j].Score {
+			return candidates[i].Score > candidates[j].Score
+		}
+		return candidates[i].URL < candidates[j].URL
+	})
+	return candidates, nil
+}
+
+// probe fetches every hint and keeps the ones that parse as feeds.
+func (d *Discoverer) probe(ctx context.Context, hints []hint) []Candidate {
+	var (
+		mu  sync.Mutex
+		out []Candidate
+		wg  sync.WaitGroup
+	)
+	sem := make(chan struct{}, d.cfg.Concurrency)
+	for _, h := range hints {
+		h := h
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			res, err := d.fetcher.Fetch(ctx, h.url, "", "")
+			if err != nil {
+				return
+			}
+			parsed, err := scraper.ParseFeed(res.Body)
+			if err != nil {
+				return
+			}
+			h.url = res.URL
+			mu.Lock()
+			out = append(out, newCandidate(h, parsed))
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+	return out
+}
+
+func newCandidate(h hint, parsed *scraper.ParsedFeed) Candidate {
+	c := Candidate{
+		URL:    h.url,
+		Title:  parsed.Title,
+		Format: parsed.Format,
+		Source: h.source,
+		Items:  len(parsed.Items),
+	}
+	if c.Title == "" {
+		c.Title = h.title
+	}
+	for _, it := range parsed.Items {
+		if it.PublishedAt.After(c.LastItemAt) {
+			c.LastItemAt = it.PublishedAt
+		}
+	}
+	return c
+}
+
+// score ranks a candidate by where it was found, how alive it looks and
+// whether it is a site's main feed rather than a comments feed.
+func score(c Candidate, page *url.URL) float64 {
+	s := sourceScore[c.Source]
+	items := c.Items
+	if items > 20 {
+		items = 20
+	}
+	s += float64(items) / 2
+	if age := time.Since(c.LastItemAt); !c.LastItemAt.IsZero() {
+		switch {
+		case age < 30*24*time.Hour:
+			s += 10
+		case age < 365*24*time.Hour:
+			s += 5
+		}
+	}
+	lower := strings.ToLower(c.URL + " " + c.Title)
+	if strings.Contains(lower, "comment") {
+		s -= 30
+	}
+	if page != nil && c.Source != SourceLink {
+		if u, err := url.Parse(c.URL); err == nil && siteHost(u.Host) != siteHost(page.Host) {
+			s -= 10
+		}
+	}
+	return s
+}
+
+func siteHost(host string) string {
+	return strings.TrimPrefix(strings.ToLower(host), "www.")
+}
+
+// dedupHints drops repeated URLs, keeping the strongest source, and caps
+// the list at max.
+func dedupHints(hints []hint, max int) []hint {
+	seen := map[string]bool{}
+	var out []hint
+	for _, h := range hints {
+		u, err := url.Parse(h.url)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			continue
+		}
+		u.Fragment = ""
+		h.url = u.String()
+		if seen[h.url] {
+			continue
+		}
+		seen[h.url] = true
+		out = append(out, h)
+		if len(out) == max {
+			break
+		}
+	}
+	return out
+}
+
+// dedupCandidates keeps the best scored of candidates that redirected to
+// the same URL or serve the same document under several paths.
+func dedupCandidates(candidates []Candidate) []Candidate {
+	sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].Score > candidates[j].Score })
+	seen := map[string]bool{}
+	var out []Candidate
+	for _, c := range candidates {
+		key := fmt.Sprintf("%s|%s|%d|%d", c.Format, c.Title, c.Items, c.LastItemAt.Unix())
+		if seen[c.URL] || seen[key] {
+			continue
+		}
+		seen[c.URL], seen[key] = true, true
+		out = append(out, c)
+	}
+	return out
+}
+
+func commonHints(base *url.URL) []hint {
+	dirs := []string{"/"}
+	if dir := base.Path[:strings.LastIndex(base.Path, "/")+1]; dir != "/" && dir != "" {
+		dirs = append([]string{dir}, dirs...)
+	}
+	var out []hint
+	for _, dir := range dirs {
+		for _, p := range commonPaths {
+			u := url.URL{Scheme: base.Scheme, Host: base.Host, Path: dir + p}
+			out = append(out, hint{url: u.String(), source: SourceCommon})
+		}
+	}
+	return out
+}
+
+// looksLikeFeed reports whether a URL path suggests a feed.
+func looksLikeFeed(u *url.URL) bool {
+	p := strings.ToLower(strings.TrimSuffix(u.Path, "/"))
+	for _, suffix := range []string{"/feed", "/rss", "/atom", ".rss", ".atom", "rss.xml", "atom.xml", "feed.xml", "index.xml"} {
+		if strings.HasSuffix(p, suffix) {
+			return true
+		}
+	}
+	q := strings.ToLower(u.RawQuery)
+	return strings.Contains(q, "feed=rss") || strings.Contains(q, "feed=atom") || strings.Contains(q, "format=rss")
+}
@@ -0,0 +1,154 @@
+package discover
+
+import (
+	"context"
+	"fmt"
+	"golang/rssagg/scraper"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func rssFeed(title string, items int) string {
+	body := `<?xml version="1.0"?><rss version="2.0"><channel><title>` + title + `</title>`
+	for i := 0; i < items; i++ {
+		body += fmt.Sprintf(`<item><guid>%s-%d</guid><title>Post %d</title><pubDate>%s</pubDate></item>`,
+			title, i, i, time.Now().Add(-time.Duration(i)*time.Hour).Format(time.RFC1123Z))
+	}
+	return body + `</channel></rss>`
+}
+
+const atomFeed = `<?xml version="1.0"?><feed xmlns="http://www.w3.org/2005/Atom"><title>Blog (Atom)</title>
+<entry><id>a1</id><title>Post</title><updated>2020-01-02T00:00:00Z</updated></entry></feed>`
+
+func testSite(t *testing.T, routes map[string]string) *httptest.Server {
+	t.Helper()
+	mux := http.NewServeMux()
+	for path, body := range routes {
+		path, body := path, body
+		mux.HandleFunc(path, func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path != path {
+				http.NotFound(w, r)
+				return
+			}
+			fmt.Fprint(w, body)
+		})
+	}
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func testDiscoverer() *Discoverer {
+	cfg := DefaultConfig()
+	cfg.HostInterval = time.Millisecond
+	cfg.AllowPrivate = true
+	return New(cfg)
+}
+
+func TestDiscoverRanksCandidates(t *testing.T) {
+	srv := testSite(t, map[string]string{
+		"/blog/": `<html><head>
+			<link rel="alternate" type="application/rss+xml" title="Comments" href="/comments/feed">
+			<link rel="alternate" type="application/atom+xml" href="atom.xml">
+			<link rel="stylesheet" href="/style.css">
+			</head><body><a href="/posts.rss">RSS</a><a href="/about">About</a></body></html>`,
+		"/comments/feed": rssFeed("Comments on Blog", 10),
+		"/blog/atom.xml": atomFeed,
+		"/posts.rss":     rssFeed("Blog", 15),
+		"/feed":          rssFeed("Blog", 15),
+	})
+	cands, err := testDiscoverer().Discover(context.Background(), srv.URL+"/blog/")
+	require.NoError(t, err)
+
+	var urls []string
+	for _, c := range cands {
+		urls = append(urls, c.URL)
+	}
+	assert.Equal(t, []string{
+		srv.URL + "/blog/atom.xml",
+		srv.URL + "/posts.rss",
+		srv.URL + "/comments/feed",
+	}, urls, "/feed duplicates /posts.rss and is dropped")
+	assert.Equal(t, SourceLink, cands[0].Source)
+	assert.Equal(t, "atom", cands[0].Format)
+	assert.Equal(t, "Blog (Atom)", cands[0].Title)
+	assert.Equal(t, SourceAnchor, cands[1].Source)
+	assert.Equal(t, 15, cands[1].Items)
+	assert.False(t, cands[1].LastItemAt.IsZero())
+	assert.Less(t, cands[2].Score, cands[1].Score, "comment feeds rank last")
+}
+
+func TestDiscoverSitemapAndCommonPaths(t *testing.T) {
+	var srv *httptest.Server
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/":
+			fmt.Fprint(w, `<html><body>No links here</body></html>`)
+		case "/robots.txt":
+			fmt.Fprintf(w, "Sitemap: %s/sitemap-index.xml\n", srv.URL)
+		case "/sitemap-index.xml":
+			fmt.Fprintf(w, `<sitemapindex><sitemap><loc>%s/sitemap-1.xml</loc></sitemap></sitemapindex>`, srv.URL)
+		case "/sitemap-1.xml":
+			fmt.Fprintf(w, `<urlset><url><loc>%[1]s/news/</loc></url><url><loc>%[1]s/news/index.xml</loc></url></urlset>`, srv.URL)
+		case "/news/index.xml":
+			fmt.Fprint(w, rssFeed("News", 3))
+		case "/rss.xml":
+			fmt.Fprint(w, rssFeed("Main", 3))
+		default:
+			http.NotFound(w, r)
+		}
+	})
+	srv = httptest.NewServer(mux)
+	defer srv.Close()
+
+	cands, err := testDiscoverer().Discover(context.Background(), srv.URL)
+	require.NoError(t, err)
+	require.Len(t, cands, 2)
+	assert.Equal(t, srv.URL+"/news/index.xml", cands[0].URL)
+	assert.Equal(t, SourceSitemap, cands[0].Source)
+	assert.Equal(t, srv.URL+"/rss.xml", cands[1].URL)
+	assert.Equal(t, SourceCommon, cands[1].Source)
+}
+
+func TestDiscoverDirectFeed(t *testing.T) {
+	srv := testSite(t, map[string]string{"/feed.xml": rssFeed("Direct", 2)})
+	cands, err := testDiscoverer().Discover(context.Background(), srv.URL+"/feed.xml")
+	require.NoError(t, err)
+	require.Len(t, cands, 1)
+	assert.Equal(t, SourceDirect, cands[0].Source)
+	assert.Equal(t, "rss", cands[0].Format)
+}
+
+func TestDiscoverNoFeeds(t *testing.T) {
+	srv := testSite(t, map[string]string{"/": `<html><body>nothing</body></html>`})
+	_, err := testDiscoverer().Discover(context.Background(), srv.URL)
+	assert.ErrorIs(t, err, ErrNoFeeds)
+}
+
+func TestDiscoverPrivateAddress(t *testing.T) {
+	srv := testSite(t, map[string]string{"/feed.xml": rssFeed("Internal", 2)})
+	cfg := DefaultConfig()
+	_, err := New(cfg).Discover(context.Background(), srv.URL+"/feed.xml")
+	assert.ErrorIs(t, err, scraper.ErrPrivateAddress)
+}
+
+func TestLooksLikeFeed(t *testing.T) {
+	for raw, want := range map[string]bool{
+		"https://example.com/feed/":         true,
+		"https://example.com/blog/rss.xml":  true,
+		"https://example.com/?feed=rss2":    true,
+		"https://example.com/feedback":      false,
+		"https://example.com/posts/sitemap": false,
+	} {
+		u, err := url.Parse(raw)
+		require.NoError(t, err)
+		assert.Equal(t, want, looksLikeFeed(u), raw)
+	}
+}
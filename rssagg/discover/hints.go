@@ -0,0 +1,189 @@
+package discover
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/xml"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+	"golang.org/x/net/html/charset"
+)
+
+// feedTypes are the <link type> values of feeds the scraper can read.
+var feedTypes = map[string]bool{
+	"application/rss+xml":  true,
+	"application/atom+xml": true,
+	"application/rdf+xml":  true,
+	"application/xml":      true,
+	"text/xml":             true,
+}
+
+// Limits on how much of a page and its sitemaps is turned into hints.
+const (
+	maxAnchorHints  = 8
+	maxSitemaps     = 3
+	maxSitemapHints = 8
+)
+
+// pageHints collects <link rel="alternate"> feeds and feed-looking links
+// from an HTML page. A <base href> overrides base.
+func pageHints(body []byte, contentType string, base *url.URL) []hint {
+	r, err := charset.NewReader(bytes.NewReader(body), contentType)
+	if err != nil {
+		return nil
+	}
+	doc, err := html.Parse(r)
+	if err != nil {
+		return nil
+	}
+	walk(doc, func(n *html.Node) bool {
+		if n.DataAtom == atom.Base {
+			if u, err := base.Parse(attr(n, "href")); err == nil && attr(n, "href") != "" {
+				base = u
+			}
+			return false
+		}
+		return true
+	})
+
+	var links, anchors []hint
+	walk(doc, func(n *html.Node) bool {
+		switch n.DataAtom {
+		case atom.Link:
+			if !hasToken(attr(n, "rel"), "alternate") || !feedTypes[strings.ToLower(strings.TrimSpace(attr(n, "type")))] {
+				return true
+			}
+			if u, err := base.Parse(strings.TrimSpace(attr(n, "href"))); err == nil && attr(n, "href") != "" {
+				links = append(links, hint{url: u.String(), title: strings.TrimSpace(attr(n, "title")), source: SourceLink})
+			}
+		case atom.A:
+			if len(anchors) == maxAnchorHints {
+				return true
+			}
+			if u, err := base.Parse(strings.TrimSpace(attr(n, "href"))); err == nil && looksLikeFeed(u) {
+				anchors = append(anchors, hint{url: u.String(), source: SourceAnchor})
+			}
+		}
+		return true
+	})
+	return append(links, anchors...)
+}
+
+// sitemapHints reads the sitemaps named in robots.txt, or /sitemap.xml,
+// and returns the feed-looking URLs they list. Sitemap indexes are
+// followed one level deep.
+func (d *Discoverer) sitemapHints(ctx context.Context, base *url.URL) []hint {
+	root := url.URL{Scheme: base.Scheme, Host: base.Host}
+	var sitemaps []string
+	if res, err := d.fetcher.Fetch(ctx, root.String()+"/robots.txt", "", ""); err == nil {
+		for _, sm := range robotsSitemaps(res.Body) {
+			if u, err := root.Parse(sm); err == nil {
+				sitemaps = append(sitemaps, u.String())
+			}
+		}
+	}
+	if len(sitemaps) == 0 {
+		sitemaps = []string{root.String() + "/sitemap.xml"}
+	}
+
+	var out []hint
+	for depth := 0; depth < 2 && len(sitemaps) > 0; depth++ {
+		if len(sitemaps) > maxSitemaps {
+			sitemaps = sitemaps[:maxSitemaps]
+		}
+		var nested []string
+		for _, sm := range sitemaps {
+			res, err := d.fetcher.Fetch(ctx, sm, "", "")
+			if err != nil {
+				continue
+			}
+			pages, children := parseSitemap(res.Body)
+			nested = append(nested, children...)
+			for _, loc := range pages {
+				if len(out) == maxSitemapHints {
+					return out
+				}
+				if u, err := url.Parse(loc); err == nil && looksLikeFeed(u) {
+					out = append(out, hint{url: u.String(), source: SourceSitemap})
+				}
+			}
+		}
+		sitemaps = nested
+	}
+	return out
+}
+
+// robotsSitemaps returns the Sitemap: lines of a robots.txt.
+func robotsSitemaps(body []byte) []string {
+	var out []string
+	sc := bufio.NewScanner(bytes.NewReader(body))
+	for sc.Scan() {
+		key, val, ok := strings.Cut(sc.Text(), ":")
+		if ok && strings.EqualFold(strings.TrimSpace(key), "sitemap") {
+			if v := strings.TrimSpace(val); v != "" {
+				out = append(out, v)
+			}
+		}
+	}
+	return out
+}
+
+// parseSitemap returns the page URLs of a <urlset> and the child sitemaps
+// of a <sitemapindex>.
+func parseSitemap(body []byte) (pages, sitemaps []string) {
+	var doc struct {
+		XMLName xml.Name
+		URLs    []struct {
+			Loc string `xml:"loc"`
+		} `xml:"url"`
+		Sitemaps []struct {
+			Loc string `xml:"loc"`
+		} `xml:"sitemap"`
+	}
+	if err := xml.Unmarshal(body, &doc); err != nil {
+		return nil, nil
+	}
+	for _, u := range doc.URLs {
+		pages = append(pages, strings.TrimSpace(u.Loc))
+	}
+	for _, s := range doc.Sitemaps {
+		sitemaps = append(sitemaps, strings.TrimSpace(s.Loc))
+	}
+	return pages, sitemaps
+}
+
+// walk visits n and its descendants in document order; fn returns false
+// to stop the walk.
+func walk(n *html.Node, fn func(*html.Node) bool) bool {
+	if n.Type == html.ElementNode && !fn(n) {
+		return false
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if !walk(c, fn) {
+			return false
+		}
+	}
+	return true
+}
+
+func attr(n *html.Node, key string) string {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+func hasToken(list, token string) bool {
+	for _, t := range strings.Fields(list) {
+		if strings.EqualFold(t, token) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,217 @@
+// Package feedwriter renders timelines as Atom 1.0 or RSS 2.0 documents.
+package feedwriter
+
+import (
+	"encoding/xml"
+	"io"
+	"time"
+)
+
+type Feed struct {
+	// ID is a permanent, universally unique IRI such as urn:uuid:...
+	ID      string
+	Title   string
+	Author  string
+	Updated time.Time
+	// Links are emitted as atom:link elements; rel="self" is required for
+	// a valid feed, rel="first"/"next"/"prev" describe paging (RFC 5005).
+	Links   []Link
+	Entries []Entry
+}
+
+type Link struct {
+	Rel  string
+	Href string
+	Type string
+}
+
+type Entry struct {
+	ID        string
+	Title     string
+	Link      string
+	Content   string // HTML
+	Published time.Time
+	Updated   time.Time
+	// Source names the feed the entry was aggregated from.
+	SourceTitle string
+	SourceURL   string
+}
+
+const atomNS = "http://www.w3.org/2005/Atom"
+
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	ID      string      `xml:"id"`
+	Title   string      `xml:"title"`
+	Updated string      `xml:"updated"`
+	Author  *atomPerson `xml:"author,omitempty"`
+	Links   []atomLink  `xml:"link"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomPerson struct {
+	Name string `xml:"name"`
+}
+
+type atomLink struct {
+	Rel  string `xml:"rel,attr,omitempty"`
+	Href string `xml:"href,attr"`
+	Type string `xml:"type,attr,omitempty"`
+}
+
+type atomText struct {
+	Type string `xml:"type,attr,omitempty"`
+	Body string `xml:",chardata"`
+}
+
+type atomEntry struct {
+	ID        string      `xml:"id"`
+	Title     string      `xml:"title"`
+	Updated   string      `xml:"updated"`
+	Published string      `xml:"published,omitempty"`
+	Links     []atomLink  `xml:"link"`
+	Content   *atomText   `xml:"content,omitempty"`
+	Source    *atomSource `xml:"source,omitempty"`
+}
+
+type atomSource struct {
+	ID    string     `xml:"id,omitempty"`
+	Title string     `xml:"title"`
+	Links []atomLink `xml:"link"`
+}
+
+func WriteAtom(w io.Writer, f *Feed) error {
+	doc := atomFeed{
+		ID:      f.ID,
+		Title:   f.Title,
+		Updated: atomTime(f.Updated),
+	}
+	if f.Author != "" {
+		doc.Author = &atomPerson{Name: f.Author}
+	}
+	for _, l := range f.Links {
+		doc.Links = append(doc.Links, atomLink{Rel: l.Rel, Href: l.Href, Type: l.Type})
+	}
+	for _, e := range f.Entries {
+		entry := atomEntry{
+			ID:      e.ID,
+			Title:   e.Title,
+			Updated: atomTime(e.Updated),
+		}
+		if !e.Published.IsZero() {
+			entry.Published = atomTime(e.Published)
+		}
+		if e.Link != "" {
+			entry.Links = []atomLink{{Rel: "alternate", Href: e.Link}}
+		}
+		if e.Content != "" {
+			entry.Content = &atomText{Type: "html", Body: e.Content}
+		}
+		if e.SourceTitle != "" || e.SourceURL != "" {
+			entry.Source = &atomSource{ID: e.SourceURL, Title: e.SourceTitle}
+			if e.SourceURL != "" {
+				entry.Source.Links = []atomLink{{Rel: "self", Href: e.SourceURL}}
+			}
+		}
+		doc.Entries = append(doc.Entries, entry)
+	}
+	return encode(w, doc)
+}
+
+type rssDoc struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	AtomNS  string     `xml:"xmlns:atom,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title         string    `xml:"title"`
+	Link          string    `xml:"link"`
+	Description   string    `xml:"description"`
+	LastBuildDate string    `xml:"lastBuildDate"`
+	AtomLinks     []rssAtom `xml:"atom:link"`
+	Items         []rssItem `xml:"item"`
+}
+
+type rssAtom struct {
+	Rel  string `xml:"rel,attr"`
+	Href string `xml:"href,attr"`
+	Type string `xml:"type,attr,omitempty"`
+}
+
+type rssItem struct {
+	Title       string     `xml:"title"`
+	Link        string     `xml:"link,omitempty"`
+	Description string     `xml:"description,omitempty"`
+	GUID        rssGUID    `xml:"guid"`
+	PubDate     string     `xml:"pubDate,omitempty"`
+	Source      *rssSource `xml:"source,omitempty"`
+}
+
+type rssGUID struct {
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+	Value       string `xml:",chardata"`
+}
+
+type rssSource struct {
+	URL   string `xml:"url,attr"`
+	Title string `xml:",chardata"`
+}
+
+// WriteRSS renders f as RSS 2.0. Links other than "alternate" are kept as
+// atom:link elements, which is how RSS feeds advertise self and paging.
+func WriteRSS(w io.Writer, f *Feed) error {
+	doc := rssDoc{
+		Version: "2.0",
+		AtomNS:  atomNS,
+		Channel: rssChannel{
+			Title:         f.Title,
+			Description:   f.Title,
+			LastBuildDate: f.Updated.UTC().Format(time.RFC1123Z),
+		},
+	}
+	for _, l := range f.Links {
+		if l.Rel == "alternate" {
+			doc.Channel.Link = l.Href
+			continue
+		}
+		doc.Channel.AtomLinks = append(doc.Channel.AtomLinks, rssAtom{Rel: l.Rel, Href: l.Href, Type: l.Type})
+		if l.Rel == "self" && doc.Channel.Link == "" {
+			doc.Channel.Link = l.Href
+		}
+	}
+	for _, e := range f.Entries {
+		item := rssItem{
+			Title:       e.Title,
+			Link:        e.Link,
+			Description: e.Content,
+			GUID:        rssGUID{Value: e.ID},
+		}
+		if !e.Published.IsZero() {
+			item.PubDate = e.Published.UTC().Format(time.RFC1123Z)
+		}
+		if e.SourceURL != "" {
+			item.Source = &rssSource{URL: e.SourceURL, Title: e.SourceTitle}
+		}
+		doc.Channel.Items = append(doc.Channel.Items, item)
+	}
+	return encode(w, doc)
+}
+
+func encode(w io.Writer, v any) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+func atomTime(t time.Time) string {
+	return t.UTC().Format(time.RFC3339)
+}
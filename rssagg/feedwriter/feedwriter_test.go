@@ -0,0 +1,83 @@
+package feedwriter
+
+import (
+	"bytes"
+	"encoding/xml"
+	"golang/rssagg/scraper"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testFeed() *Feed {
+	published := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	return &Feed{
+		ID:      "urn:uuid:7d1f0f3e-4b55-4c4e-9c5e-2f8f0f0f0f0f",
+		Title:   "alice's timeline",
+		Author:  "alice",
+		Updated: published.Add(time.Hour),
+		Links: []Link{
+			{Rel: "self", Href: "https://agg.example/v1/users/1/feed.atom?page=2", Type: "application/atom+xml"},
+			{Rel: "next", Href: "https://agg.example/v1/users/1/feed.atom?page=3"},
+		},
+		Entries: []Entry{{
+			ID:          "urn:uuid:0b6f5bd5-0000-4000-8000-000000000001",
+			Title:       "Hello & welcome",
+			Link:        "https://blog.example/hello",
+			Content:     "<p>Hi <b>there</b></p>",
+			Published:   published,
+			Updated:     published.Add(time.Hour),
+			SourceTitle: "Blog",
+			SourceURL:   "https://blog.example/feed",
+		}},
+	}
+}
+
+func TestWriteAtom(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, WriteAtom(&buf, testFeed()))
+	out := buf.String()
+	assert.Contains(t, out, `<feed xmlns="http://www.w3.org/2005/Atom">`)
+	assert.Contains(t, out, `<updated>2024-03-01T13:00:00Z</updated>`)
+	assert.Contains(t, out, `<link rel="next" href="https://agg.example/v1/users/1/feed.atom?page=3"></link>`)
+	assert.Contains(t, out, `<content type="html">&lt;p&gt;Hi &lt;b&gt;there&lt;/b&gt;&lt;/p&gt;</content>`)
+
+	parsed, err := scraper.ParseFeed(buf.Bytes())
+	require.NoError(t, err)
+	require.Len(t, parsed.Items, 1)
+	item := parsed.Items[0]
+	assert.Equal(t, "urn:uuid:0b6f5bd5-0000-4000-8000-000000000001", item.GUID)
+	assert.Equal(t, "Hello & welcome", item.Title)
+	assert.Equal(t, "https://blog.example/hello", item.Link)
+	assert.Equal(t, "<p>Hi <b>there</b></p>", item.Description)
+	assert.Equal(t, time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC), item.PublishedAt)
+}
+
+func TestWriteRSS(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, WriteRSS(&buf, testFeed()))
+	out := buf.String()
+	assert.Contains(t, out, `<rss version="2.0" xmlns:atom="http://www.w3.org/2005/Atom">`)
+	assert.Contains(t, out, `<atom:link rel="self" href="https://agg.example/v1/users/1/feed.atom?page=2" type="application/atom+xml"></atom:link>`)
+	assert.Contains(t, out, `<guid isPermaLink="false">urn:uuid:0b6f5bd5-0000-4000-8000-000000000001</guid>`)
+	assert.Contains(t, out, `<pubDate>Fri, 01 Mar 2024 12:00:00 +0000</pubDate>`)
+	assert.Contains(t, out, `<source url="https://blog.example/feed">Blog</source>`)
+
+	// The document must stay well-formed XML with the atom prefix bound.
+	dec := xml.NewDecoder(&buf)
+	for {
+		_, err := dec.Token()
+		if err != nil {
+			assert.EqualError(t, err, "EOF")
+			break
+		}
+	}
+
+	parsed, err := scraper.ParseFeed([]byte(out))
+	require.NoError(t, err)
+	require.Len(t, parsed.Items, 1)
+	assert.Equal(t, "https://blog.example/hello", parsed.Items[0].Link)
+	assert.Equal(t, time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC), parsed.Items[0].PublishedAt)
+}
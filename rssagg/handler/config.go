@@ -0,0 +1,29 @@
+package handler
+
+import (
+	"golang/rssagg/database"
+	"golang/rssagg/digest"
+	"golang/rssagg/discover"
+	"golang/rssagg/health"
+	"golang/rssagg/search"
+	"golang/rssagg/websub"
+)
+
+// APIConfig carries the dependencies shared by the handlers.
+type APIConfig struct {
+	DB     *database.DB
+	Index  *search.Index
+	Health *health.Registry
+	// WebSub is nil when push subscriptions are disabled.
+	WebSub *websub.Subscriber
+	// Digests builds and delivers digests. Webhook digests need no
+	// settings, so the server always has one; a nil Digests disables the
+	// digest routes.
+	Digests *digest.Scheduler
+	// Discoverer finds the feeds of a website; nil disables discovery.
+	Discoverer *discover.Discoverer
+	// BaseURL is the server's public URL, e.g. https://agg.example. The
+	// absolute links handlers return start with it; when it is empty they
+	// use the request's Host, and forwarded headers are never trusted.
+	BaseURL string
+}
@@ -0,0 +1,91 @@
+package handler
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// The contract tests pin down the behaviour clients of each version rely
+// on. A failing v1 test means an old client broke.
+
+func TestContractV1(t *testing.T) {
+	s := newTestServer(t)
+	key := s.createUser("alice")
+
+	var feed Feed
+	res := s.do("POST", "/v1/feeds", "", `{"name":"Go","url":"https://go.dev/blog/feed.atom"}`, &feed)
+	require.Equal(t, 201, res.StatusCode)
+	assert.Equal(t, "Go", feed.Name)
+	assert.Equal(t, "@1792368000", res.Header.Get("Deprecation"))
+	assert.Equal(t, "Mon, 19 Apr 2027 00:00:00 GMT", res.Header.Get("Sunset"))
+	assert.Equal(t, `</v2/feeds>; rel="successor-version"`, res.Header.Get("Link"))
+
+	res = s.do("POST", "/v1/feeds", "", `{"url":"https://go.dev/blog/feed.atom"}`, nil)
+	assert.Equal(t, http.StatusConflict, res.StatusCode)
+	res = s.do("POST", "/v1/feeds", "", `{"url":"go.dev","discover":true}`, nil)
+	assert.Equal(t, http.StatusBadRequest, res.StatusCode, "anonymous callers can't make the server fetch a site")
+
+	var follow FeedFollow
+	res = s.do("POST", "/v1/feed_follows", key, `{"feed_id":"`+feed.ID+`"}`, &follow)
+	require.Equal(t, 201, res.StatusCode)
+	assert.Equal(t, feed.ID, follow.FeedID)
+	assert.Equal(t, `</v2/follows>; rel="successor-version"`, res.Header.Get("Link"))
+
+	var follows []FeedFollow
+	res = s.do("GET", "/v1/feed_follows", key, "", &follows)
+	require.Equal(t, 200, res.StatusCode)
+	assert.Len(t, follows, 1)
+
+	var timeline []TimelinePost
+	res = s.do("GET", "/v1/timeline", key, "", &timeline)
+	require.Equal(t, 200, res.StatusCode)
+	assert.Empty(t, res.Header.Get("Deprecation"), "unchanged routes are current")
+
+	res = s.do("DELETE", "/v1/feed_follows/"+follow.ID, key, "", nil)
+	assert.Equal(t, 200, res.StatusCode)
+	assert.Equal(t, 500, s.do("GET", "/v1/error", "", "", nil).StatusCode)
+}
+
+func TestContractV2(t *testing.T) {
+	s := newTestServer(t)
+	alice := s.createUser("alice")
+	bob := s.createUser("bob")
+	body := `{"url":"https://go.dev/blog/feed.atom","folder":"Tech/Go"}`
+
+	res := s.do("POST", "/v2/feeds", "", body, nil)
+	assert.Equal(t, http.StatusUnauthorized, res.StatusCode, "v2 feeds are created by a user")
+
+	var created FollowedFeed
+	res = s.do("POST", "/v2/feeds", alice, body, &created)
+	require.Equal(t, 201, res.StatusCode)
+	assert.Empty(t, res.Header.Get("Deprecation"))
+	assert.Equal(t, "https://go.dev/blog/feed.atom", created.Feed.URL)
+	assert.Equal(t, created.Feed.ID, created.FeedFollow.FeedID)
+	assert.Equal(t, "Tech/Go", created.FeedFollow.Folder)
+
+	res = s.do("POST", "/v2/feeds", alice, body, nil)
+	assert.Equal(t, http.StatusConflict, res.StatusCode, "already following")
+
+	var again FollowedFeed
+	res = s.do("POST", "/v2/feeds", bob, body, &again)
+	require.Equal(t, 201, res.StatusCode)
+	assert.Equal(t, created.Feed.ID, again.Feed.ID, "an existing feed is reused")
+
+	var follows []FeedFollow
+	res = s.do("GET", "/v2/follows", bob, "", &follows)
+	require.Equal(t, 200, res.StatusCode)
+	require.Len(t, follows, 1)
+	res = s.do("DELETE", "/v2/follows/"+follows[0].ID, bob, "", nil)
+	assert.Equal(t, 200, res.StatusCode)
+
+	var timeline []TimelinePost
+	res = s.do("GET", "/v2/timeline", alice, "", &timeline)
+	require.Equal(t, 200, res.StatusCode)
+
+	for _, path := range []string{"/v2/feed_follows", "/v2/error"} {
+		assert.Equal(t, http.StatusNotFound, s.do("GET", path, alice, "", nil).StatusCode, path)
+	}
+}
@@ -0,0 +1,209 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	respondjson "golang/rssagg/RespondJSON"
+	"golang/rssagg/database"
+	"golang/rssagg/digest"
+	"golang/rssagg/opml"
+	"golang/rssagg/scraper"
+	"net/http"
+	"net/mail"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/google/uuid"
+)
+
+// Defaults for new digest subscriptions: 7am UTC, Mondays for weekly ones.
+const (
+	defaultDigestHour    = 7
+	defaultDigestWeekday = int(time.Monday)
+	digestDeliveryLimit  = 20
+)
+
+// HandlerCreateDigest serves POST /digests. The response carries the
+// webhook signing secret; it is not shown again.
+func (cfg *APIConfig) HandlerCreateDigest(w http.ResponseWriter, r *http.Request, user database.User) error {
+	type parameters struct {
+		Frequency string `json:"frequency"`
+		Channel   string `json:"channel"`
+		Target    string `json:"target"`
+		Folder    string `json:"folder"`
+		Hour      *int   `json:"hour"`
+		Weekday   *int   `json:"weekday"`
+		Timezone  string `json:"timezone"`
+	}
+	params := parameters{}
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		return respondjson.Validation(fmt.Sprintf("Error parsing JSON: %v", err))
+	}
+	hour, weekday := defaultDigestHour, defaultDigestWeekday
+	if params.Hour != nil {
+		hour = *params.Hour
+	}
+	if params.Weekday != nil {
+		weekday = *params.Weekday
+	}
+	if params.Timezone == "" {
+		params.Timezone = "UTC"
+	}
+
+	var fields []string
+	if params.Frequency != database.DigestDaily && params.Frequency != database.DigestWeekly {
+		fields = append(fields, "frequency", `must be "daily" or "weekly"`)
+	}
+	switch params.Channel {
+	case database.DigestEmail:
+		if cfg.Digests == nil || !cfg.Digests.EmailEnabled() {
+			fields = append(fields, "channel", "email digests are not configured on this server")
+		} else if addr, err := mail.ParseAddress(params.Target); err != nil {
+			fields = append(fields, "target", "must be an email address")
+		} else {
+			params.Target = addr.Address
+		}
+	case database.DigestWebhook:
+		if err := validateFeedURL(params.Target); err != nil {
+			fields = append(fields, "target", err.Error())
+		} else if cfg.Digests != nil {
+			if err := cfg.Digests.CheckWebhook(r.Context(), params.Target); errors.Is(err, scraper.ErrPrivateAddress) {
+				fields = append(fields, "target", "must be on the public internet")
+			} else if err != nil {
+				fields = append(fields, "target", "host couldn't be resolved")
+			}
+		}
+	default:
+		fields = append(fields, "channel", `must be "email" or "webhook"`)
+	}
+	if hour < 0 || hour > 23 {
+		fields = append(fields, "hour", "must be between 0 and 23")
+	}
+	if weekday < 0 || weekday > 6 {
+		fields = append(fields, "weekday", "must be between 0 (Sunday) and 6 (Saturday)")
+	}
+	loc, err := time.LoadLocation(params.Timezone)
+	if err != nil {
+		fields = append(fields, "timezone", "must be an IANA time zone such as Europe/Berlin")
+	}
+	if len(fields) > 0 {
+		return respondjson.Validation("Invalid digest", fields...)
+	}
+	if cfg.Digests == nil {
+		return respondjson.Status(http.StatusServiceUnavailable, "Digests are disabled on this server")
+	}
+
+	secret := ""
+	if params.Channel == database.DigestWebhook {
+		if secret, err = digest.NewSecret(); err != nil {
+			return fmt.Errorf("couldn't generate webhook secret: %w", err)
+		}
+	}
+	sub, err := cfg.DB.CreateDigestSubscription(r.Context(), database.CreateDigestSubscriptionParams{
+		ID:        uuid.NewString(),
+		UserID:    user.ID,
+		Frequency: params.Frequency,
+		Channel:   params.Channel,
+		Target:    params.Target,
+		Secret:    secret,
+		Folder:    opml.JoinFolder(opml.SplitFolder(params.Folder)),
+		Hour:      hour,
+		Weekday:   weekday,
+		Timezone:  loc.String(),
+		NextRunAt: digest.NextRun(params.Frequency, hour, weekday, loc, time.Now()),
+	})
+	if errors.Is(err, database.ErrConflict) {
+		return respondjson.Conflict("A digest for this target and folder already exists")
+	}
+	if err != nil {
+		return fmt.Errorf("couldn't create digest: %w", err)
+	}
+	out := databaseDigestToDigest(sub)
+	out.Secret = sub.Secret
+	respondjson.Respond(w, r, 201, out)
+	return nil
+}
+
+func (cfg *APIConfig) HandlerGetDigests(w http.ResponseWriter, r *http.Request, user database.User) error {
+	subs, err := cfg.DB.GetDigestSubscriptionsForUser(r.Context(), user.ID)
+	if err != nil {
+		return fmt.Errorf("couldn't get digests: %w", err)
+	}
+	out := make([]DigestSubscription, 0, len(subs))
+	for _, sub := range subs {
+		out = append(out, databaseDigestToDigest(sub))
+	}
+	respondjson.RespondWithList(w, r, 200, out)
+	return nil
+}
+
+func (cfg *APIConfig) HandlerDeleteDigest(w http.ResponseWriter, r *http.Request, user database.User) error {
+	err := cfg.DB.DeleteDigestSubscription(r.Context(), chi.URLParam(r, "digestID"), user.ID)
+	if errors.Is(err, database.ErrNotFound) {
+		return respondjson.NotFound("Digest")
+	}
+	if err != nil {
+		return fmt.Errorf("couldn't delete digest: %w", err)
+	}
+	respondjson.Respond(w, r, 200, struct{}{})
+	return nil
+}
+
+// HandlerGetDigestDeliveries serves GET /digests/{digestID}/deliveries: the
+// latest delivery attempts, newest first.
+func (cfg *APIConfig) HandlerGetDigestDeliveries(w http.ResponseWriter, r *http.Request, user database.User) error {
+	sub, err := cfg.getDigest(r, user)
+	if err != nil {
+		return err
+	}
+	deliveries, err := cfg.DB.GetDigestDeliveries(r.Context(), sub.ID, digestDeliveryLimit)
+	if err != nil {
+		return fmt.Errorf("couldn't get digest deliveries: %w", err)
+	}
+	out := make([]DigestDelivery, 0, len(deliveries))
+	for _, d := range deliveries {
+		out = append(out, DigestDelivery{
+			ID:        d.ID,
+			CreatedAt: d.CreatedAt,
+			PostCount: d.PostCount,
+			Error:     d.Error,
+		})
+	}
+	respondjson.RespondWithList(w, r, 200, out)
+	return nil
+}
+
+// HandlerPreviewDigest serves GET /digests/{digestID}/preview: the HTML
+// body the next digest would have if it were sent now. Nothing is marked
+// as sent.
+func (cfg *APIConfig) HandlerPreviewDigest(w http.ResponseWriter, r *http.Request, user database.User) error {
+	if cfg.Digests == nil {
+		return respondjson.Status(http.StatusServiceUnavailable, "Digests are disabled on this server")
+	}
+	sub, err := cfg.getDigest(r, user)
+	if err != nil {
+		return err
+	}
+	d, err := cfg.Digests.Build(r.Context(), sub, time.Now())
+	if err != nil {
+		return fmt.Errorf("couldn't build digest: %w", err)
+	}
+	_, html, err := d.Render()
+	if err != nil {
+		return fmt.Errorf("couldn't render digest: %w", err)
+	}
+	respondjson.RespondWithBytes(w, r, 200, "text/html; charset=utf-8", []byte(html))
+	return nil
+}
+
+func (cfg *APIConfig) getDigest(r *http.Request, user database.User) (database.DigestSubscription, error) {
+	sub, err := cfg.DB.GetDigestSubscription(r.Context(), chi.URLParam(r, "digestID"), user.ID)
+	if errors.Is(err, database.ErrNotFound) {
+		return sub, respondjson.NotFound("Digest")
+	}
+	if err != nil {
+		return sub, fmt.Errorf("couldn't get digest: %w", err)
+	}
+	return sub, nil
+}
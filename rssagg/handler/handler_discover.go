@@ -0,0 +1,58 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	respondjson "golang/rssagg/RespondJSON"
+	"golang/rssagg/database"
+	"golang/rssagg/discover"
+	"log"
+	"net/http"
+	"strings"
+)
+
+// HandlerDiscover serves GET /discover?url=: the feeds published by the
+// site at url, best first. Feeds rssagg already knows carry their feed_id
+// so clients can follow them directly.
+func (cfg *APIConfig) HandlerDiscover(w http.ResponseWriter, r *http.Request, user database.User) error {
+	candidates, err := cfg.discover(r, r.URL.Query().Get("url"))
+	if errors.Is(err, discover.ErrNoFeeds) {
+		candidates = nil
+	} else if err != nil {
+		return err
+	}
+	out := make([]DiscoveredFeed, 0, len(candidates))
+	for _, c := range candidates {
+		df := candidateToDiscoveredFeed(c)
+		if feed, err := cfg.DB.GetFeedByURL(r.Context(), c.URL); err == nil {
+			df.FeedID = feed.ID
+		} else if !errors.Is(err, database.ErrNotFound) {
+			return fmt.Errorf("couldn't look up feed: %w", err)
+		}
+		out = append(out, df)
+	}
+	respondjson.RespondWithList(w, r, 200, out)
+	return nil
+}
+
+// discover validates raw, defaulting to https when no scheme is given,
+// and runs discovery on it. A site that cannot be fetched is a 502 that
+// does not say why, so it cannot be used to probe the network.
+func (cfg *APIConfig) discover(r *http.Request, raw string) ([]discover.Candidate, error) {
+	if cfg.Discoverer == nil {
+		return nil, respondjson.Status(http.StatusServiceUnavailable, "Feed discovery is disabled on this server")
+	}
+	raw = strings.TrimSpace(raw)
+	if raw != "" && !strings.Contains(raw, "://") {
+		raw = "https://" + raw
+	}
+	if err := validateFeedURL(raw); err != nil {
+		return nil, respondjson.Validation("Invalid site", "url", err.Error())
+	}
+	candidates, err := cfg.Discoverer.Discover(r.Context(), raw)
+	if err != nil && !errors.Is(err, discover.ErrNoFeeds) && r.Context().Err() == nil {
+		log.Printf("discover %s: %v", raw, err)
+		return nil, respondjson.Status(http.StatusBadGateway, "Couldn't fetch site")
+	}
+	return candidates, err
+}
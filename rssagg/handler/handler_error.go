@@ -1,10 +1,12 @@
 package handler
 
 import (
-	respondjson "golang/rssagg/RespondJSON"
+	"errors"
 	"net/http"
 )
 
-func HandlerError(w http.ResponseWriter, r *http.Request) {
-	respondjson.RespondWithError(w, 400, "Something went wrong")
+// HandlerError always fails, so clients can see the shape of a server
+// error. The error is only logged; the response is a plain 500 problem.
+func HandlerError(w http.ResponseWriter, r *http.Request) error {
+	return errors.New("test error requested")
 }
@@ -0,0 +1,66 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	respondjson "golang/rssagg/RespondJSON"
+	"golang/rssagg/database"
+	"golang/rssagg/opml"
+	"net/http"
+
+	"github.com/go-chi/chi"
+	"github.com/google/uuid"
+)
+
+func (cfg *APIConfig) HandlerCreateFeedFollow(w http.ResponseWriter, r *http.Request, user database.User) error {
+	type parameters struct {
+		FeedID string `json:"feed_id"`
+		Folder string `json:"folder"`
+	}
+	params := parameters{}
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		return respondjson.Validation(fmt.Sprintf("Error parsing JSON: %v", err))
+	}
+	if _, err := cfg.DB.GetFeed(r.Context(), params.FeedID); errors.Is(err, database.ErrNotFound) {
+		return respondjson.NotFound("Feed")
+	} else if err != nil {
+		return fmt.Errorf("couldn't get feed: %w", err)
+	}
+
+	follow, err := cfg.DB.CreateFeedFollow(r.Context(), database.CreateFeedFollowParams{
+		ID:     uuid.NewString(),
+		UserID: user.ID,
+		FeedID: params.FeedID,
+		Folder: opml.JoinFolder(opml.SplitFolder(params.Folder)),
+	})
+	if errors.Is(err, database.ErrConflict) {
+		return respondjson.Conflict("Already following this feed")
+	}
+	if err != nil {
+		return fmt.Errorf("couldn't create feed follow: %w", err)
+	}
+	respondjson.Respond(w, r, 201, databaseFeedFollowToFeedFollow(follow))
+	return nil
+}
+
+func (cfg *APIConfig) HandlerGetFeedFollows(w http.ResponseWriter, r *http.Request, user database.User) error {
+	follows, err := cfg.DB.GetFeedFollowsForUser(r.Context(), user.ID)
+	if err != nil {
+		return fmt.Errorf("couldn't get feed follows: %w", err)
+	}
+	respondjson.RespondWithList(w, r, 200, databaseFeedFollowsToFeedFollows(follows))
+	return nil
+}
+
+func (cfg *APIConfig) HandlerDeleteFeedFollow(w http.ResponseWriter, r *http.Request, user database.User) error {
+	err := cfg.DB.DeleteFeedFollow(r.Context(), chi.URLParam(r, "feedFollowID"), user.ID)
+	if errors.Is(err, database.ErrNotFound) {
+		return respondjson.NotFound("Feed follow")
+	}
+	if err != nil {
+		return fmt.Errorf("couldn't delete feed follow: %w", err)
+	}
+	respondjson.Respond(w, r, 200, struct{}{})
+	return nil
+}
@@ -0,0 +1,144 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	respondjson "golang/rssagg/RespondJSON"
+	"golang/rssagg/database"
+	"golang/rssagg/discover"
+	"golang/rssagg/opml"
+	"net/http"
+	"net/url"
+
+	"github.com/go-chi/chi"
+	"github.com/google/uuid"
+)
+
+type createFeedParams struct {
+	Name     string `json:"name"`
+	URL      string `json:"url"`
+	Discover bool   `json:"discover"`
+	Folder   string `json:"folder"`
+}
+
+// parseCreateFeed decodes and validates a feed creation request. With
+// "discover": true the URL may be any page of a website and is replaced
+// by the best feed found there; only authenticated routes allow that.
+func (cfg *APIConfig) parseCreateFeed(r *http.Request, authed bool) (createFeedParams, error) {
+	params := createFeedParams{}
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		return params, respondjson.Validation(fmt.Sprintf("Error parsing JSON: %v", err))
+	}
+	if params.Discover && !authed {
+		return params, respondjson.Validation("Discovery needs an API key", "discover", "use POST /v2/feeds")
+	}
+	if params.Discover {
+		candidates, err := cfg.discover(r, params.URL)
+		if errors.Is(err, discover.ErrNoFeeds) {
+			return params, respondjson.Validation("No feed found", "url", "the site does not publish a feed rssagg can read")
+		}
+		if err != nil {
+			return params, err
+		}
+		params.URL = candidates[0].URL
+		if params.Name == "" {
+			params.Name = candidates[0].Title
+		}
+	}
+	if err := validateFeedURL(params.URL); err != nil {
+		return params, respondjson.Validation("Invalid feed", "url", err.Error())
+	}
+	if params.Name == "" {
+		params.Name = params.URL
+	}
+	return params, nil
+}
+
+// HandlerCreateFeed serves POST /v1/feeds. The feed is not followed, and
+// an existing URL is a conflict.
+func (cfg *APIConfig) HandlerCreateFeed(w http.ResponseWriter, r *http.Request) error {
+	params, err := cfg.parseCreateFeed(r, false)
+	if err != nil {
+		return err
+	}
+	feed, err := cfg.DB.CreateFeed(r.Context(), database.CreateFeedParams{
+		ID:   uuid.NewString(),
+		Name: params.Name,
+		URL:  params.URL,
+	})
+	if errors.Is(err, database.ErrConflict) {
+		return respondjson.Conflict("Feed already exists")
+	}
+	if err != nil {
+		return fmt.Errorf("couldn't create feed: %w", err)
+	}
+	respondjson.Respond(w, r, 201, databaseFeedToFeed(feed))
+	return nil
+}
+
+// HandlerCreateFeedV2 serves POST /v2/feeds: the caller follows the feed,
+// filed in "folder", creating it first unless another user already did.
+func (cfg *APIConfig) HandlerCreateFeedV2(w http.ResponseWriter, r *http.Request, user database.User) error {
+	params, err := cfg.parseCreateFeed(r, true)
+	if err != nil {
+		return err
+	}
+	feed, err := cfg.DB.CreateFeed(r.Context(), database.CreateFeedParams{
+		ID:   uuid.NewString(),
+		Name: params.Name,
+		URL:  params.URL,
+	})
+	if errors.Is(err, database.ErrConflict) {
+		feed, err = cfg.DB.GetFeedByURL(r.Context(), params.URL)
+	}
+	if err != nil {
+		return fmt.Errorf("couldn't create feed: %w", err)
+	}
+	follow, err := cfg.DB.CreateFeedFollow(r.Context(), database.CreateFeedFollowParams{
+		ID:     uuid.NewString(),
+		UserID: user.ID,
+		FeedID: feed.ID,
+		Folder: opml.JoinFolder(opml.SplitFolder(params.Folder)),
+	})
+	if errors.Is(err, database.ErrConflict) {
+		return respondjson.Conflict("Already following this feed")
+	}
+	if err != nil {
+		return fmt.Errorf("couldn't create feed follow: %w", err)
+	}
+	respondjson.Respond(w, r, 201, FollowedFeed{
+		Feed:       databaseFeedToFeed(feed),
+		FeedFollow: databaseFeedFollowToFeedFollow(follow),
+	})
+	return nil
+}
+
+func (cfg *APIConfig) HandlerGetFeeds(w http.ResponseWriter, r *http.Request) error {
+	feeds, err := cfg.DB.GetFeeds(r.Context())
+	if err != nil {
+		return fmt.Errorf("couldn't get feeds: %w", err)
+	}
+	respondjson.RespondWithList(w, r, 200, databaseFeedsToFeeds(feeds))
+	return nil
+}
+
+func (cfg *APIConfig) HandlerGetFeed(w http.ResponseWriter, r *http.Request) error {
+	feed, err := cfg.DB.GetFeed(r.Context(), chi.URLParam(r, "feedID"))
+	if errors.Is(err, database.ErrNotFound) {
+		return respondjson.NotFound("Feed")
+	}
+	if err != nil {
+		return fmt.Errorf("couldn't get feed: %w", err)
+	}
+	respondjson.Respond(w, r, 200, databaseFeedToFeed(feed))
+	return nil
+}
+
+func validateFeedURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("must be an absolute http(s) URL")
+	}
+	return nil
+}
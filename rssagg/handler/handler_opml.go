@@ -0,0 +1,175 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	respondjson "golang/rssagg/RespondJSON"
+	"golang/rssagg/database"
+	"golang/rssagg/opml"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const maxOPMLSize = 5 << 20
+
+const (
+	importCreated          = "created"
+	importFollowed         = "followed"
+	importAlreadyFollowing = "already_following"
+	importInvalid          = "invalid"
+	importFailed           = "failed"
+)
+
+type opmlImportResult struct {
+	URL    string `json:"url"`
+	Title  string `json:"title"`
+	Folder string `json:"folder"`
+	Status string `json:"status"`
+	FeedID string `json:"feed_id,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// HandlerImportOPML accepts an OPML document either as the raw request
+// body or as the "file" field of a multipart form, and follows every feed
+// in it. Each outline gets its own result so partial imports are visible.
+func (cfg *APIConfig) HandlerImportOPML(w http.ResponseWriter, r *http.Request, user database.User) error {
+	r.Body = http.MaxBytesReader(w, r.Body, maxOPMLSize)
+	var body io.Reader = r.Body
+	if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType == "multipart/form-data" {
+		file, _, err := r.FormFile("file")
+		if err != nil {
+			return respondjson.Validation("Invalid upload", "file", err.Error())
+		}
+		defer file.Close()
+		body = file
+	}
+
+	doc, err := opml.Parse(body)
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		return respondjson.Status(413, fmt.Sprintf("OPML documents are limited to %d bytes", maxOPMLSize))
+	}
+	if err != nil {
+		return respondjson.Validation(err.Error())
+	}
+
+	results := []opmlImportResult{}
+	summary := map[string]int{}
+	for _, sub := range doc.Subscriptions() {
+		res := cfg.importSubscription(r.Context(), user, sub)
+		summary[res.Status]++
+		results = append(results, res)
+	}
+	respondjson.Respond(w, r, 200, struct {
+		Summary map[string]int     `json:"summary"`
+		Results []opmlImportResult `json:"results"`
+	}{
+		Summary: summary,
+		Results: results,
+	})
+	return nil
+}
+
+func (cfg *APIConfig) importSubscription(ctx context.Context, user database.User, sub opml.Subscription) opmlImportResult {
+	res := opmlImportResult{
+		URL:    sub.XMLURL,
+		Title:  sub.Title,
+		Folder: opml.JoinFolder(sub.Folder),
+	}
+	if err := validateFeedURL(sub.XMLURL); err != nil {
+		res.Status = importInvalid
+		res.Error = err.Error()
+		return res
+	}
+
+	name := sub.Title
+	if name == "" {
+		name = sub.XMLURL
+	}
+	feed, created, err := cfg.getOrCreateFeed(ctx, sub.XMLURL, name)
+	if err != nil {
+		log.Printf("OPML import: %s: %v", sub.XMLURL, err)
+		res.Status = importFailed
+		res.Error = "couldn't create feed"
+		return res
+	}
+	res.FeedID = feed.ID
+
+	_, err = cfg.DB.CreateFeedFollow(ctx, database.CreateFeedFollowParams{
+		ID:     uuid.NewString(),
+		UserID: user.ID,
+		FeedID: feed.ID,
+		Folder: res.Folder,
+	})
+	switch {
+	case errors.Is(err, database.ErrConflict):
+		res.Status = importAlreadyFollowing
+	case err != nil:
+		log.Printf("OPML import: follow %s: %v", sub.XMLURL, err)
+		res.Status = importFailed
+		res.Error = "couldn't follow feed"
+	case created:
+		res.Status = importCreated
+	default:
+		res.Status = importFollowed
+	}
+	return res
+}
+
+// getOrCreateFeed returns the feed stored for url, creating it if needed.
+func (cfg *APIConfig) getOrCreateFeed(ctx context.Context, url, name string) (database.Feed, bool, error) {
+	feed, err := cfg.DB.GetFeedByURL(ctx, url)
+	if err == nil {
+		return feed, false, nil
+	}
+	if !errors.Is(err, database.ErrNotFound) {
+		return feed, false, err
+	}
+	feed, err = cfg.DB.CreateFeed(ctx, database.CreateFeedParams{
+		ID:   uuid.NewString(),
+		Name: name,
+		URL:  url,
+	})
+	if errors.Is(err, database.ErrConflict) {
+		// Created concurrently by someone else.
+		feed, err = cfg.DB.GetFeedByURL(ctx, url)
+		return feed, false, err
+	}
+	return feed, err == nil, err
+}
+
+// HandlerExportOPML renders the user's follows as OPML 2.0, with folders as
+// nested outlines.
+func (cfg *APIConfig) HandlerExportOPML(w http.ResponseWriter, r *http.Request, user database.User) error {
+	subs, err := cfg.DB.GetSubscriptionsForUser(r.Context(), user.ID)
+	if err != nil {
+		return fmt.Errorf("couldn't get feed follows: %w", err)
+	}
+
+	entries := make([]opml.Subscription, 0, len(subs))
+	for _, s := range subs {
+		entries = append(entries, opml.Subscription{
+			Title:  s.Feed.Name,
+			XMLURL: s.Feed.URL,
+			Folder: opml.SplitFolder(s.Follow.Folder),
+		})
+	}
+	doc := opml.New(fmt.Sprintf("%s's subscriptions", user.Name), entries)
+	doc.Head.DateCreated = time.Now().UTC().Format(time.RFC1123Z)
+	doc.Head.OwnerName = user.Name
+
+	var buf bytes.Buffer
+	if err := opml.Write(&buf, doc); err != nil {
+		return fmt.Errorf("couldn't write OPML: %w", err)
+	}
+	w.Header().Set("Content-Disposition", `attachment; filename="subscriptions.opml"`)
+	respondjson.RespondWithBytes(w, r, 200, "text/x-opml; charset=utf-8", buf.Bytes())
+	return nil
+}
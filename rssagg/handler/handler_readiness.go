@@ -2,13 +2,24 @@ package handler
 
 import (
 	respondjson "golang/rssagg/RespondJSON"
+	"log"
 	"net/http"
 )
 
-func HandlerReadiness(w http.ResponseWriter, r *http.Request) {
-	respondjson.RespondWithJSON(w, 200, struct {
-		Info string `json:"info"`
-	}{
-		Info: "everything is ok",
-	})
+// HandlerReadiness runs the registered health checks and answers 503 when
+// the service should not receive traffic. Anyone may ask, so the errors
+// of failing checks go to the log instead of the response.
+func (cfg *APIConfig) HandlerReadiness(w http.ResponseWriter, r *http.Request) error {
+	report := cfg.Health.Run(r.Context())
+	for _, c := range report.Checks {
+		if c.Error != "" {
+			log.Printf("Health check %s: %s", c.Name, c.Error)
+		}
+	}
+	code := 200
+	if !report.Healthy() {
+		code = 503
+	}
+	respondjson.Respond(w, r, code, healthReportToHealth(report))
+	return nil
 }
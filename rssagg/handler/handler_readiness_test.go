@@ -0,0 +1,25 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadinessHidesErrors(t *testing.T) {
+	s := newTestServer(t)
+	s.cfg.Health.Register("database", true, func(ctx context.Context) error {
+		return errors.New("dial tcp 10.0.0.5:5432: connection refused")
+	})
+
+	var report map[string]any
+	res := s.do("GET", "/v1/healthz", "", "", &report)
+	require.Equal(t, 503, res.StatusCode)
+	assert.Equal(t, map[string]any{
+		"status": "unavailable",
+		"checks": []any{map[string]any{"name": "database", "status": "unavailable"}},
+	}, report)
+}
@@ -0,0 +1,208 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	respondjson "golang/rssagg/RespondJSON"
+	"golang/rssagg/database"
+	"golang/rssagg/rules"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi"
+	"github.com/google/uuid"
+)
+
+// maxRuleChanges bounds the changes listed by an apply request; the count
+// covers all of them.
+const maxRuleChanges = 100
+
+// HandlerCreateRule serves POST /rules. New rules act on posts fetched
+// from now on; POST /rules/{ruleID}/apply runs them on older ones.
+func (cfg *APIConfig) HandlerCreateRule(w http.ResponseWriter, r *http.Request, user database.User) error {
+	params, err := cfg.parseRule(r)
+	if err != nil {
+		return err
+	}
+	rule, err := cfg.DB.CreateRule(r.Context(), uuid.NewString(), user.ID, params)
+	if err != nil {
+		return fmt.Errorf("couldn't create rule: %w", err)
+	}
+	respondjson.Respond(w, r, 201, databaseRuleToRule(rule))
+	return nil
+}
+
+func (cfg *APIConfig) HandlerGetRules(w http.ResponseWriter, r *http.Request, user database.User) error {
+	list, err := cfg.DB.GetRulesForUser(r.Context(), user.ID)
+	if err != nil {
+		return fmt.Errorf("couldn't get rules: %w", err)
+	}
+	out := make([]Rule, 0, len(list))
+	for _, rule := range list {
+		out = append(out, databaseRuleToRule(rule))
+	}
+	respondjson.RespondWithList(w, r, 200, out)
+	return nil
+}
+
+// HandlerUpdateRule serves PUT /rules/{ruleID}, replacing the whole rule.
+func (cfg *APIConfig) HandlerUpdateRule(w http.ResponseWriter, r *http.Request, user database.User) error {
+	params, err := cfg.parseRule(r)
+	if err != nil {
+		return err
+	}
+	rule, err := cfg.DB.UpdateRule(r.Context(), chi.URLParam(r, "ruleID"), user.ID, params)
+	if errors.Is(err, database.ErrNotFound) {
+		return respondjson.NotFound("Rule")
+	}
+	if err != nil {
+		return fmt.Errorf("couldn't update rule: %w", err)
+	}
+	respondjson.Respond(w, r, 200, databaseRuleToRule(rule))
+	return nil
+}
+
+func (cfg *APIConfig) HandlerDeleteRule(w http.ResponseWriter, r *http.Request, user database.User) error {
+	err := cfg.DB.DeleteRule(r.Context(), chi.URLParam(r, "ruleID"), user.ID)
+	if errors.Is(err, database.ErrNotFound) {
+		return respondjson.NotFound("Rule")
+	}
+	if err != nil {
+		return fmt.Errorf("couldn't delete rule: %w", err)
+	}
+	respondjson.Respond(w, r, 200, struct{}{})
+	return nil
+}
+
+// HandlerApplyRules serves POST /rules/apply?dry_run=: every enabled rule
+// run against the posts of the feeds the user follows. With dry_run=true
+// the response shows what would change and nothing is written.
+func (cfg *APIConfig) HandlerApplyRules(w http.ResponseWriter, r *http.Request, user database.User) error {
+	list, err := cfg.DB.GetRulesForUser(r.Context(), user.ID)
+	if err != nil {
+		return fmt.Errorf("couldn't get rules: %w", err)
+	}
+	var enabled []database.Rule
+	for _, rule := range list {
+		if rule.Enabled {
+			enabled = append(enabled, rule)
+		}
+	}
+	return cfg.applyRules(w, r, user, rules.CompileAll(enabled), "")
+}
+
+// HandlerApplyRule serves POST /rules/{ruleID}/apply?dry_run=: like
+// HandlerApplyRules for a single rule, which need not be enabled.
+func (cfg *APIConfig) HandlerApplyRule(w http.ResponseWriter, r *http.Request, user database.User) error {
+	rule, err := cfg.DB.GetRule(r.Context(), chi.URLParam(r, "ruleID"), user.ID)
+	if errors.Is(err, database.ErrNotFound) {
+		return respondjson.NotFound("Rule")
+	}
+	if err != nil {
+		return fmt.Errorf("couldn't get rule: %w", err)
+	}
+	compiled, err := rules.Compile(rule)
+	if err != nil {
+		return respondjson.Validation("Invalid rule", "pattern", err.Error())
+	}
+	return cfg.applyRules(w, r, user, []*rules.Rule{compiled}, rule.FeedID)
+}
+
+func (cfg *APIConfig) applyRules(w http.ResponseWriter, r *http.Request, user database.User, list []*rules.Rule, feedID string) error {
+	dryRun, err := parseBoolParam(r, "dry_run")
+	if err != nil {
+		return err
+	}
+	changes, err := rules.Retro(r.Context(), cfg.DB, user.ID, list, feedID, dryRun)
+	if err != nil {
+		return fmt.Errorf("couldn't apply rules: %w", err)
+	}
+	out := RuleApplyResult{DryRun: dryRun, ChangedPosts: len(changes), Changes: []RuleChange{}}
+	for i, c := range changes {
+		if i == maxRuleChanges {
+			break
+		}
+		out.Changes = append(out.Changes, ruleChangeToRuleChange(c))
+	}
+	respondjson.Respond(w, r, 200, out)
+	return nil
+}
+
+// parseRule decodes and validates the body of a create or update request.
+func (cfg *APIConfig) parseRule(r *http.Request) (database.RuleParams, error) {
+	type parameters struct {
+		Name     string `json:"name"`
+		FeedID   string `json:"feed_id"`
+		Field    string `json:"field"`
+		Operator string `json:"operator"`
+		Pattern  string `json:"pattern"`
+		Action   string `json:"action"`
+		Tag      string `json:"tag"`
+		Enabled  *bool  `json:"enabled"`
+	}
+	params := parameters{}
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		return database.RuleParams{}, respondjson.Validation(fmt.Sprintf("Error parsing JSON: %v", err))
+	}
+	out := database.RuleParams{
+		Name:     strings.TrimSpace(params.Name),
+		FeedID:   params.FeedID,
+		Field:    params.Field,
+		Operator: params.Operator,
+		Pattern:  params.Pattern,
+		Action:   params.Action,
+		Tag:      strings.TrimSpace(params.Tag),
+		Enabled:  params.Enabled == nil || *params.Enabled,
+	}
+	if out.Field != "" && out.Operator == "" {
+		out.Operator = database.RuleContains
+	}
+
+	var fields []string
+	switch out.Field {
+	case "", database.RuleFieldTitle, database.RuleFieldBody, database.RuleFieldURL:
+	default:
+		fields = append(fields, "field", `must be "title", "body" or "url"`)
+	}
+	if out.Field != "" {
+		switch out.Operator {
+		case database.RuleContains, database.RuleRegex:
+			if out.Pattern == "" {
+				fields = append(fields, "pattern", "is required")
+			} else if len(out.Pattern) > rules.MaxPatternLength {
+				fields = append(fields, "pattern", fmt.Sprintf("must be at most %d bytes", rules.MaxPatternLength))
+			}
+		default:
+			fields = append(fields, "operator", `must be "contains" or "regex"`)
+		}
+	}
+	switch out.Action {
+	case database.RuleTag:
+		if out.Tag == "" {
+			fields = append(fields, "tag", "is required")
+		}
+	case database.RuleMarkRead, database.RuleStar:
+		out.Tag = ""
+	default:
+		fields = append(fields, "action", `must be "tag", "mark_read" or "star"`)
+	}
+	if out.FeedID == "" && out.Field == "" {
+		fields = append(fields, "field", "is required when the rule is not limited to a feed")
+	}
+	if len(fields) > 0 {
+		return out, respondjson.Validation("Invalid rule", fields...)
+	}
+	if _, err := rules.Compile(database.Rule{Field: out.Field, Operator: out.Operator, Pattern: out.Pattern,
+		Action: out.Action, Tag: out.Tag}); err != nil {
+		return out, respondjson.Validation("Invalid rule", "pattern", err.Error())
+	}
+	if out.FeedID != "" {
+		if _, err := cfg.DB.GetFeed(r.Context(), out.FeedID); errors.Is(err, database.ErrNotFound) {
+			return out, respondjson.Validation("Invalid rule", "feed_id", "no such feed")
+		} else if err != nil {
+			return out, fmt.Errorf("couldn't get feed: %w", err)
+		}
+	}
+	return out, nil
+}
@@ -0,0 +1,116 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	respondjson "golang/rssagg/RespondJSON"
+	"golang/rssagg/search"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+)
+
+type searchResult struct {
+	Score float64 `json:"score"`
+	Post  Post    `json:"post"`
+}
+
+// HandlerSearch serves GET /search?q=&feed_id=&from=&to=&limit=&offset=.
+// from and to accept RFC 3339 timestamps or dates; a date in to includes
+// the whole day.
+func (cfg *APIConfig) HandlerSearch(w http.ResponseWriter, r *http.Request) error {
+	query := r.URL.Query()
+	q := search.Query{Text: query.Get("q")}
+	for _, v := range query["feed_id"] {
+		for _, id := range strings.Split(v, ",") {
+			if id = strings.TrimSpace(id); id != "" {
+				q.FeedIDs = append(q.FeedIDs, id)
+			}
+		}
+	}
+
+	var err error
+	if q.From, err = parseTimeParam(query.Get("from"), false); err != nil {
+		return respondjson.Validation("Invalid search", "from", err.Error())
+	}
+	if q.To, err = parseTimeParam(query.Get("to"), true); err != nil {
+		return respondjson.Validation("Invalid search", "to", err.Error())
+	}
+	if q.Limit, q.Offset, err = parsePage(r); err != nil {
+		return err
+	}
+
+	hits, total, err := cfg.Index.Search(q)
+	if errors.Is(err, search.ErrEmptyQuery) {
+		return respondjson.Validation("Invalid search", "q", "is required")
+	}
+	if err != nil {
+		return fmt.Errorf("search failed: %w", err)
+	}
+
+	ids := make([]string, len(hits))
+	for i, h := range hits {
+		ids[i] = h.ID
+	}
+	posts, err := cfg.DB.GetPostsByIDs(r.Context(), ids)
+	if err != nil {
+		return fmt.Errorf("couldn't get posts: %w", err)
+	}
+
+	results := make([]searchResult, 0, len(hits))
+	for _, h := range hits {
+		p, ok := posts[h.ID]
+		if !ok {
+			continue
+		}
+		results = append(results, searchResult{Score: h.Score, Post: databasePostToPost(p)})
+	}
+	respondjson.Respond(w, r, 200, struct {
+		Total   int            `json:"total"`
+		Results []searchResult `json:"results"`
+	}{
+		Total:   total,
+		Results: results,
+	})
+	return nil
+}
+
+func parseTimeParam(v string, endOfDay bool) (time.Time, error) {
+	if v == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse("2006-01-02", v)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("expected RFC 3339 timestamp or YYYY-MM-DD")
+	}
+	if endOfDay {
+		t = t.AddDate(0, 0, 1)
+	}
+	return t, nil
+}
+
+func parsePage(r *http.Request) (limit, offset int, err error) {
+	limit = defaultPageSize
+	if v := r.URL.Query().Get("limit"); v != "" {
+		limit, err = strconv.Atoi(v)
+		if err != nil || limit < 1 || limit > maxPageSize {
+			return 0, 0, respondjson.Validation("Invalid page", "limit", fmt.Sprintf("must be between 1 and %d", maxPageSize))
+		}
+	}
+	if v := r.URL.Query().Get("offset"); v != "" {
+		offset, err = strconv.Atoi(v)
+		if err != nil || offset < 0 {
+			return 0, 0, respondjson.Validation("Invalid page", "offset", "must be a non-negative integer")
+		}
+	}
+	return limit, offset, nil
+}
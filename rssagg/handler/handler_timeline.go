@@ -0,0 +1,58 @@
+package handler
+
+import (
+	"fmt"
+	respondjson "golang/rssagg/RespondJSON"
+	"golang/rssagg/database"
+	"golang/rssagg/opml"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// HandlerGetTimeline serves
+// GET /timeline?folder=&unread=&starred=&tag=&limit=&offset=: the posts of
+// every feed the user follows, newest first, with stories published by
+// several of them collapsed into one entry.
+func (cfg *APIConfig) HandlerGetTimeline(w http.ResponseWriter, r *http.Request, user database.User) error {
+	limit, offset, err := parsePage(r)
+	if err != nil {
+		return err
+	}
+	query := r.URL.Query()
+	unread, err := parseBoolParam(r, "unread")
+	if err != nil {
+		return err
+	}
+	starred, err := parseBoolParam(r, "starred")
+	if err != nil {
+		return err
+	}
+	posts, err := cfg.DB.GetTimeline(r.Context(), database.GetTimelineParams{
+		UserID:  user.ID,
+		Folder:  opml.JoinFolder(opml.SplitFolder(query.Get("folder"))),
+		Unread:  unread,
+		Starred: starred,
+		Tag:     strings.TrimSpace(query.Get("tag")),
+		Limit:   limit,
+		Offset:  offset,
+	})
+	if err != nil {
+		return fmt.Errorf("couldn't get timeline: %w", err)
+	}
+	respondjson.RespondWithList(w, r, 200, databaseTimelineToTimeline(posts))
+	return nil
+}
+
+// parseBoolParam reads an optional true/false query parameter.
+func parseBoolParam(r *http.Request, name string) (bool, error) {
+	v := r.URL.Query().Get(name)
+	if v == "" {
+		return false, nil
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		return false, respondjson.Validation("Invalid request", name, "must be true or false")
+	}
+	return b, nil
+}
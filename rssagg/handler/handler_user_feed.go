@@ -0,0 +1,186 @@
+package handler
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	respondjson "golang/rssagg/RespondJSON"
+	"golang/rssagg/database"
+	"golang/rssagg/feedwriter"
+	"golang/rssagg/opml"
+	"html"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi"
+	"github.com/google/uuid"
+)
+
+type feedFormat struct {
+	mediaType string
+	write     func(io.Writer, *feedwriter.Feed) error
+}
+
+var (
+	atomFormat = feedFormat{"application/atom+xml", feedwriter.WriteAtom}
+	rssFormat  = feedFormat{"application/rss+xml", feedwriter.WriteRSS}
+)
+
+// HandlerUserAtomFeed serves GET /users/{userID}/feed.atom?folder=&page=&limit=.
+func (cfg *APIConfig) HandlerUserAtomFeed(w http.ResponseWriter, r *http.Request) error {
+	return cfg.handleUserFeed(w, r, atomFormat)
+}
+
+// HandlerUserRSSFeed serves GET /users/{userID}/feed.rss?folder=&page=&limit=.
+func (cfg *APIConfig) HandlerUserRSSFeed(w http.ResponseWriter, r *http.Request) error {
+	return cfg.handleUserFeed(w, r, rssFormat)
+}
+
+// handleUserFeed renders the merged timeline of a user, or of one of their
+// folders, one page at a time. Paging links are sent both in the document
+// and in the Link header.
+func (cfg *APIConfig) handleUserFeed(w http.ResponseWriter, r *http.Request, format feedFormat) error {
+	user, err := cfg.DB.GetUser(r.Context(), chi.URLParam(r, "userID"))
+	if errors.Is(err, database.ErrNotFound) {
+		return respondjson.NotFound("User")
+	}
+	if err != nil {
+		return fmt.Errorf("couldn't get user: %w", err)
+	}
+
+	page, limit, err := parseFeedPage(r)
+	if err != nil {
+		return err
+	}
+	folder := opml.JoinFolder(opml.SplitFolder(r.URL.Query().Get("folder")))
+
+	posts, err := cfg.DB.GetTimeline(r.Context(), database.GetTimelineParams{
+		UserID: user.ID,
+		Folder: folder,
+		Limit:  limit + 1,
+		Offset: (page - 1) * limit,
+	})
+	if err != nil {
+		return fmt.Errorf("couldn't get posts: %w", err)
+	}
+	hasNext := len(posts) > limit
+	if hasNext {
+		posts = posts[:limit]
+	}
+
+	feed := &feedwriter.Feed{
+		ID:      "urn:uuid:" + user.ID,
+		Title:   user.Name + "'s timeline",
+		Author:  user.Name,
+		Updated: user.UpdatedAt,
+	}
+	if folder != "" {
+		// Derived from the user and folder so it is stable across requests.
+		feed.ID = "urn:uuid:" + uuid.NewSHA1(uuid.NameSpaceURL, []byte("rssagg:users/"+user.ID+"/folders/"+folder)).String()
+		feed.Title = fmt.Sprintf("%s's timeline: %s", user.Name, folder)
+	}
+
+	links := []feedwriter.Link{
+		{Rel: "self", Href: cfg.pageURL(r, page), Type: format.mediaType},
+		{Rel: "first", Href: cfg.pageURL(r, 1)},
+	}
+	if page > 1 {
+		links = append(links, feedwriter.Link{Rel: "prev", Href: cfg.pageURL(r, page-1)})
+	}
+	if hasNext {
+		links = append(links, feedwriter.Link{Rel: "next", Href: cfg.pageURL(r, page+1)})
+	}
+	feed.Links = links
+
+	for _, tp := range posts {
+		p := tp.Post
+		if p.UpdatedAt.After(feed.Updated) {
+			feed.Updated = p.UpdatedAt
+		}
+		feed.Entries = append(feed.Entries, feedwriter.Entry{
+			ID:          "urn:uuid:" + p.ID,
+			Title:       p.Title,
+			Link:        p.URL,
+			Content:     p.ContentHTML + alsoInHTML(tp.AlsoIn),
+			Published:   p.PublishedAt,
+			Updated:     p.UpdatedAt,
+			SourceTitle: tp.FeedName,
+			SourceURL:   tp.FeedURL,
+		})
+	}
+
+	var header []string
+	for _, l := range links {
+		if l.Rel != "self" {
+			header = append(header, fmt.Sprintf(`<%s>; rel="%s"`, l.Href, l.Rel))
+		}
+	}
+	var buf bytes.Buffer
+	if err := format.write(&buf, feed); err != nil {
+		return fmt.Errorf("couldn't write feed: %w", err)
+	}
+	w.Header().Set("Link", strings.Join(header, ", "))
+	respondjson.RespondWithBytes(w, r, 200, format.mediaType+"; charset=utf-8", buf.Bytes())
+	return nil
+}
+
+// alsoInHTML lists the other feeds that carried a collapsed story.
+func alsoInHTML(sources []database.TimelineSource) string {
+	if len(sources) == 0 {
+		return ""
+	}
+	links := make([]string, len(sources))
+	for i, s := range sources {
+		links[i] = fmt.Sprintf(`<a href="%s">%s</a>`, html.EscapeString(s.URL), html.EscapeString(s.FeedName))
+	}
+	noun := "feeds"
+	if len(sources) == 1 {
+		noun = "feed"
+	}
+	return fmt.Sprintf("<p>Also in %d %s: %s</p>", len(sources), noun, strings.Join(links, ", "))
+}
+
+func parseFeedPage(r *http.Request) (page, limit int, err error) {
+	page, limit = 1, defaultPageSize
+	if v := r.URL.Query().Get("page"); v != "" {
+		page, err = strconv.Atoi(v)
+		if err != nil || page < 1 {
+			return 0, 0, respondjson.Validation("Invalid page", "page", "must be a positive integer")
+		}
+	}
+	if v := r.URL.Query().Get("limit"); v != "" {
+		limit, err = strconv.Atoi(v)
+		if err != nil || limit < 1 || limit > maxPageSize {
+			return 0, 0, respondjson.Validation("Invalid page", "limit", fmt.Sprintf("must be between 1 and %d", maxPageSize))
+		}
+	}
+	return page, limit, nil
+}
+
+// pageURL returns the absolute URL of the current request with its page
+// parameter replaced. Feed readers and caches keep these links, so they
+// come from BaseURL rather than from headers any client can set.
+func (cfg *APIConfig) pageURL(r *http.Request, page int) string {
+	q := r.URL.Query()
+	if page == 1 {
+		q.Del("page")
+	} else {
+		q.Set("page", strconv.Itoa(page))
+	}
+	u := url.URL{
+		Scheme:   "http",
+		Host:     r.Host,
+		Path:     r.URL.Path,
+		RawQuery: q.Encode(),
+	}
+	if r.TLS != nil {
+		u.Scheme = "https"
+	}
+	if base, err := url.Parse(strings.TrimSuffix(cfg.BaseURL, "/")); err == nil && base.Host != "" {
+		u.Scheme, u.Host, u.Path = base.Scheme, base.Host, base.Path+u.Path
+	}
+	return u.String()
+}
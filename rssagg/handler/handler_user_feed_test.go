@@ -0,0 +1,38 @@
+package handler
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUserFeedLinks(t *testing.T) {
+	s := newTestServer(t)
+	var user User
+	require.Equal(t, 201, s.do("POST", "/v1/users", "", `{"name":"alice"}`, &user).StatusCode)
+	path := "/v1/users/" + user.ID + "/feed.atom?page=2"
+
+	get := func() *http.Response {
+		req, err := http.NewRequest("GET", s.srv.URL+path, nil)
+		require.NoError(t, err)
+		req.Header.Set("X-Forwarded-Proto", "https")
+		req.Header.Set("X-Forwarded-Host", "evil.example")
+		res, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		res.Body.Close()
+		require.Equal(t, 200, res.StatusCode)
+		return res
+	}
+
+	// Forwarded headers can be set by anyone and are ignored.
+	res := get()
+	assert.Contains(t, res.Header.Get("Link"), `<`+s.srv.URL+`/v1/users/`+user.ID+`/feed.atom>; rel="first"`)
+	assert.NotContains(t, res.Header.Get("Link"), "evil.example")
+
+	s.cfg.BaseURL = "https://agg.example/rss/"
+	res = get()
+	assert.Contains(t, res.Header.Get("Link"), `<https://agg.example/rss/v1/users/`+user.ID+`/feed.atom>; rel="first"`)
+	assert.Contains(t, res.Header.Get("Link"), `<https://agg.example/rss/v1/users/`+user.ID+`/feed.atom>; rel="prev"`)
+}
@@ -0,0 +1,39 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	respondjson "golang/rssagg/RespondJSON"
+	"golang/rssagg/database"
+	"net/http"
+
+	"github.com/google/uuid"
+)
+
+func (cfg *APIConfig) HandlerCreateUser(w http.ResponseWriter, r *http.Request) error {
+	type parameters struct {
+		Name string `json:"name"`
+	}
+	params := parameters{}
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		return respondjson.Validation(fmt.Sprintf("Error parsing JSON: %v", err))
+	}
+	if params.Name == "" {
+		return respondjson.Validation("Invalid user", "name", "is required")
+	}
+
+	user, err := cfg.DB.CreateUser(r.Context(), database.CreateUserParams{
+		ID:   uuid.NewString(),
+		Name: params.Name,
+	})
+	if err != nil {
+		return fmt.Errorf("couldn't create user: %w", err)
+	}
+	respondjson.Respond(w, r, 201, databaseUserToUser(user))
+	return nil
+}
+
+func (cfg *APIConfig) HandlerGetUser(w http.ResponseWriter, r *http.Request, user database.User) error {
+	respondjson.Respond(w, r, 200, databaseUserToUser(user))
+	return nil
+}
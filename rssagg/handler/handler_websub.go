@@ -0,0 +1,66 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	respondjson "golang/rssagg/RespondJSON"
+	"golang/rssagg/websub"
+	"io"
+	"log"
+	"net/http"
+
+	"github.com/go-chi/chi"
+)
+
+const maxPushSize = 10 << 20
+
+// HandlerWebSubVerify answers the hub's intent verification (GET on the
+// callback) by echoing hub.challenge.
+func (cfg *APIConfig) HandlerWebSubVerify(w http.ResponseWriter, r *http.Request) error {
+	if cfg.WebSub == nil {
+		return respondjson.NotFound("Subscription")
+	}
+	challenge, err := cfg.WebSub.VerifyIntent(r.Context(), chi.URLParam(r, "feedID"), r.URL.Query())
+	switch {
+	case errors.Is(err, websub.ErrUnknownSubscription):
+		return respondjson.NotFound("Subscription")
+	case errors.Is(err, websub.ErrInvalidRequest):
+		return respondjson.Validation(err.Error())
+	case err != nil:
+		return fmt.Errorf("couldn't verify WebSub subscription: %w", err)
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.WriteHeader(200)
+	w.Write([]byte(challenge))
+	return nil
+}
+
+// HandlerWebSubReceive ingests content distributed by the hub (POST on the
+// callback).
+func (cfg *APIConfig) HandlerWebSubReceive(w http.ResponseWriter, r *http.Request) error {
+	if cfg.WebSub == nil {
+		return respondjson.NotFound("Subscription")
+	}
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxPushSize))
+	if err != nil {
+		return respondjson.Status(413, "Content too large")
+	}
+
+	feedID := chi.URLParam(r, "feedID")
+	err = cfg.WebSub.Receive(r.Context(), feedID, r.Header.Get("X-Hub-Signature"), body)
+	switch {
+	case errors.Is(err, websub.ErrUnknownSubscription):
+		// 410 tells the hub to drop the subscription.
+		return respondjson.Status(410, "Unknown subscription")
+	case errors.Is(err, websub.ErrBadSignature):
+		// The spec asks subscribers to ignore such content but still
+		// acknowledge it, so the sender learns nothing.
+		log.Printf("WebSub: ignoring content for feed %s with a bad signature", feedID)
+	case errors.Is(err, websub.ErrInvalidRequest):
+		return respondjson.Validation(err.Error())
+	case err != nil:
+		return fmt.Errorf("couldn't ingest WebSub content for feed %s: %w", feedID, err)
+	}
+	w.WriteHeader(202)
+	return nil
+}
@@ -0,0 +1,31 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	respondjson "golang/rssagg/RespondJSON"
+	"golang/rssagg/auth"
+	"golang/rssagg/database"
+	"net/http"
+)
+
+type AuthedHandler func(http.ResponseWriter, *http.Request, database.User) error
+
+// MiddlewareAuth resolves the caller from their API key and passes the
+// user on to handler.
+func (cfg *APIConfig) MiddlewareAuth(handler AuthedHandler) respondjson.APIFunc {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		apiKey, err := auth.GetAPIKey(r.Header)
+		if err != nil {
+			return respondjson.Unauthorized(fmt.Sprintf("Auth error: %v", err))
+		}
+		user, err := cfg.DB.GetUserByAPIKey(r.Context(), apiKey)
+		if errors.Is(err, database.ErrNotFound) {
+			return respondjson.Unauthorized("Invalid API key")
+		}
+		if err != nil {
+			return fmt.Errorf("couldn't get user: %w", err)
+		}
+		return handler(w, r, user)
+	}
+}
@@ -0,0 +1,340 @@
+package handler
+
+import (
+	"golang/rssagg/database"
+	"golang/rssagg/discover"
+	"golang/rssagg/health"
+	"golang/rssagg/rules"
+	"time"
+)
+
+type Feed struct {
+	ID            string     `json:"id"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+	Name          string     `json:"name"`
+	URL           string     `json:"url"`
+	LastFetchedAt *time.Time `json:"last_fetched_at"`
+	NextFetchAt   time.Time  `json:"next_fetch_at"`
+	ErrorCount    int        `json:"error_count"`
+	LastError     string     `json:"last_error,omitempty"`
+	LastErrorAt   *time.Time `json:"last_error_at,omitempty"`
+	Disabled      bool       `json:"disabled"`
+}
+
+func databaseFeedToFeed(f database.Feed) Feed {
+	return Feed{
+		ID:            f.ID,
+		CreatedAt:     f.CreatedAt,
+		UpdatedAt:     f.UpdatedAt,
+		Name:          f.Name,
+		URL:           f.URL,
+		LastFetchedAt: timePtr(f.LastFetchedAt),
+		NextFetchAt:   f.NextFetchAt,
+		ErrorCount:    f.ErrorCount,
+		LastError:     f.LastError,
+		LastErrorAt:   timePtr(f.LastErrorAt),
+		Disabled:      f.Disabled,
+	}
+}
+
+func databaseFeedsToFeeds(feeds []database.Feed) []Feed {
+	out := make([]Feed, 0, len(feeds))
+	for _, f := range feeds {
+		out = append(out, databaseFeedToFeed(f))
+	}
+	return out
+}
+
+func timePtr(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
+
+type Post struct {
+	ID          string    `json:"id"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+	FeedID      string    `json:"feed_id"`
+	Title       string    `json:"title"`
+	URL         string    `json:"url"`
+	Description string    `json:"description"`
+	PublishedAt time.Time `json:"published_at"`
+	Content     string    `json:"content"`
+	ContentText string    `json:"content_text"`
+	// FullText is set when Content was extracted from the article page.
+	FullText       bool `json:"full_text"`
+	ReadingMinutes int  `json:"reading_minutes"`
+}
+
+func databasePostToPost(p database.Post) Post {
+	return Post{
+		ID:             p.ID,
+		CreatedAt:      p.CreatedAt,
+		UpdatedAt:      p.UpdatedAt,
+		FeedID:         p.FeedID,
+		Title:          p.Title,
+		URL:            p.URL,
+		Description:    p.Description,
+		PublishedAt:    p.PublishedAt,
+		Content:        p.ContentHTML,
+		ContentText:    p.ContentText,
+		FullText:       p.ContentStatus == database.ContentExtracted,
+		ReadingMinutes: p.ReadingMinutes,
+	}
+}
+
+type User struct {
+	ID        string    `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	Name      string    `json:"name"`
+	APIKey    string    `json:"api_key"`
+}
+
+func databaseUserToUser(u database.User) User {
+	return User{
+		ID:        u.ID,
+		CreatedAt: u.CreatedAt,
+		UpdatedAt: u.UpdatedAt,
+		Name:      u.Name,
+		APIKey:    u.APIKey,
+	}
+}
+
+type FeedFollow struct {
+	ID        string    `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	UserID    string    `json:"user_id"`
+	FeedID    string    `json:"feed_id"`
+	Folder    string    `json:"folder"`
+}
+
+func databaseFeedFollowToFeedFollow(ff database.FeedFollow) FeedFollow {
+	return FeedFollow{
+		ID:        ff.ID,
+		CreatedAt: ff.CreatedAt,
+		UpdatedAt: ff.UpdatedAt,
+		UserID:    ff.UserID,
+		FeedID:    ff.FeedID,
+		Folder:    ff.Folder,
+	}
+}
+
+func databaseFeedFollowsToFeedFollows(follows []database.FeedFollow) []FeedFollow {
+	out := make([]FeedFollow, 0, len(follows))
+	for _, ff := range follows {
+		out = append(out, databaseFeedFollowToFeedFollow(ff))
+	}
+	return out
+}
+
+// FollowedFeed is a feed together with the caller's follow of it.
+type FollowedFeed struct {
+	Feed       Feed       `json:"feed"`
+	FeedFollow FeedFollow `json:"feed_follow"`
+}
+
+type TimelinePost struct {
+	Post
+	FeedName string       `json:"feed_name"`
+	FeedURL  string       `json:"feed_url"`
+	Read     bool         `json:"read"`
+	Starred  bool         `json:"starred"`
+	Tags     []string     `json:"tags"`
+	AlsoIn   []PostSource `json:"also_in"`
+}
+
+// PostSource is another feed that published the same story.
+type PostSource struct {
+	PostID   string `json:"post_id"`
+	URL      string `json:"url"`
+	FeedID   string `json:"feed_id"`
+	FeedName string `json:"feed_name"`
+}
+
+func databaseTimelineToTimeline(posts []database.TimelinePost) []TimelinePost {
+	out := make([]TimelinePost, 0, len(posts))
+	for _, tp := range posts {
+		alsoIn := make([]PostSource, 0, len(tp.AlsoIn))
+		for _, s := range tp.AlsoIn {
+			alsoIn = append(alsoIn, PostSource{
+				PostID:   s.PostID,
+				URL:      s.URL,
+				FeedID:   s.FeedID,
+				FeedName: s.FeedName,
+			})
+		}
+		tags := tp.State.Tags
+		if tags == nil {
+			tags = []string{}
+		}
+		out = append(out, TimelinePost{
+			Post:     databasePostToPost(tp.Post),
+			FeedName: tp.FeedName,
+			FeedURL:  tp.FeedURL,
+			Read:     tp.State.Read,
+			Starred:  tp.State.Starred,
+			Tags:     tags,
+			AlsoIn:   alsoIn,
+		})
+	}
+	return out
+}
+
+type DigestSubscription struct {
+	ID         string     `json:"id"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+	Frequency  string     `json:"frequency"`
+	Channel    string     `json:"channel"`
+	Target     string     `json:"target"`
+	Secret     string     `json:"secret,omitempty"`
+	Folder     string     `json:"folder"`
+	Hour       int        `json:"hour"`
+	Weekday    int        `json:"weekday"`
+	Timezone   string     `json:"timezone"`
+	NextRunAt  time.Time  `json:"next_run_at"`
+	LastSentAt *time.Time `json:"last_sent_at"`
+	LastError  string     `json:"last_error,omitempty"`
+}
+
+// databaseDigestToDigest leaves out the webhook secret, which is only
+// returned when the subscription is created.
+func databaseDigestToDigest(sub database.DigestSubscription) DigestSubscription {
+	return DigestSubscription{
+		ID:         sub.ID,
+		CreatedAt:  sub.CreatedAt,
+		UpdatedAt:  sub.UpdatedAt,
+		Frequency:  sub.Frequency,
+		Channel:    sub.Channel,
+		Target:     sub.Target,
+		Folder:     sub.Folder,
+		Hour:       sub.Hour,
+		Weekday:    sub.Weekday,
+		Timezone:   sub.Timezone,
+		NextRunAt:  sub.NextRunAt,
+		LastSentAt: timePtr(sub.LastSentAt),
+		LastError:  sub.LastError,
+	}
+}
+
+type DigestDelivery struct {
+	ID        string    `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	PostCount int       `json:"post_count"`
+	Error     string    `json:"error,omitempty"`
+}
+
+type DiscoveredFeed struct {
+	URL        string     `json:"url"`
+	Title      string     `json:"title"`
+	Format     string     `json:"format"`
+	Source     string     `json:"source"`
+	Items      int        `json:"items"`
+	LastItemAt *time.Time `json:"last_item_at"`
+	Score      float64    `json:"score"`
+	// FeedID is set when the feed is already known.
+	FeedID string `json:"feed_id,omitempty"`
+}
+
+func candidateToDiscoveredFeed(c discover.Candidate) DiscoveredFeed {
+	return DiscoveredFeed{
+		URL:        c.URL,
+		Title:      c.Title,
+		Format:     c.Format,
+		Source:     c.Source,
+		Items:      c.Items,
+		LastItemAt: timePtr(c.LastItemAt),
+		Score:      c.Score,
+	}
+}
+
+type Rule struct {
+	ID        string    `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	Name      string    `json:"name"`
+	FeedID    string    `json:"feed_id,omitempty"`
+	Field     string    `json:"field,omitempty"`
+	Operator  string    `json:"operator,omitempty"`
+	Pattern   string    `json:"pattern,omitempty"`
+	Action    string    `json:"action"`
+	Tag       string    `json:"tag,omitempty"`
+	Enabled   bool      `json:"enabled"`
+}
+
+func databaseRuleToRule(r database.Rule) Rule {
+	return Rule{
+		ID:        r.ID,
+		CreatedAt: r.CreatedAt,
+		UpdatedAt: r.UpdatedAt,
+		Name:      r.Name,
+		FeedID:    r.FeedID,
+		Field:     r.Field,
+		Operator:  r.Operator,
+		Pattern:   r.Pattern,
+		Action:    r.Action,
+		Tag:       r.Tag,
+		Enabled:   r.Enabled,
+	}
+}
+
+// RuleApplyResult lists the posts rules changed, or would change on a dry
+// run. Changes is capped; ChangedPosts counts all of them.
+type RuleApplyResult struct {
+	DryRun       bool         `json:"dry_run"`
+	ChangedPosts int          `json:"changed_posts"`
+	Changes      []RuleChange `json:"changes"`
+}
+
+type RuleChange struct {
+	PostID  string       `json:"post_id"`
+	FeedID  string       `json:"feed_id"`
+	Title   string       `json:"title"`
+	URL     string       `json:"url"`
+	Actions []RuleAction `json:"actions"`
+}
+
+type RuleAction struct {
+	RuleID string `json:"rule_id"`
+	Action string `json:"action"`
+	Tag    string `json:"tag,omitempty"`
+}
+
+func ruleChangeToRuleChange(c rules.Change) RuleChange {
+	actions := make([]RuleAction, 0, len(c.Actions))
+	for _, a := range c.Actions {
+		actions = append(actions, RuleAction{RuleID: a.RuleID, Action: a.Action, Tag: a.Tag})
+	}
+	return RuleChange{
+		PostID:  c.Post.ID,
+		FeedID:  c.Post.FeedID,
+		Title:   c.Post.Title,
+		URL:     c.Post.URL,
+		Actions: actions,
+	}
+}
+
+// Health is the public readiness report. It names the checks and their
+// status; why a check failed is only logged.
+type Health struct {
+	Status string        `json:"status"`
+	Checks []HealthCheck `json:"checks"`
+}
+
+type HealthCheck struct {
+	Name   string `json:"name"`
+	Status string `json:"status"`
+}
+
+func healthReportToHealth(report health.Report) Health {
+	checks := make([]HealthCheck, len(report.Checks))
+	for i, c := range report.Checks {
+		checks[i] = HealthCheck{Name: c.Name, Status: c.Status}
+	}
+	return Health{Status: report.Status, Checks: checks}
+}
@@ -0,0 +1,103 @@
+package handler
+
+import (
+	respondjson "golang/rssagg/RespondJSON"
+	"golang/rssagg/api"
+	"time"
+)
+
+// v1Deprecated is when the v1 routes replaced in v2 were deprecated, and
+// v1Sunset when they stop working.
+var (
+	v1Deprecated = time.Date(2026, time.October, 19, 0, 0, 0, 0, time.UTC)
+	v1Sunset     = time.Date(2027, time.April, 19, 0, 0, 0, 0, time.UTC)
+)
+
+// Versions returns the route tables of every API version still served.
+func (cfg *APIConfig) Versions() []api.Version {
+	return []api.Version{
+		{Name: "v1", Routes: append(cfg.v1Routes(), cfg.sharedRoutes()...)},
+		{Name: "v2", Routes: append(cfg.v2Routes(), cfg.sharedRoutes()...)},
+	}
+}
+
+// v1Routes are the routes v2 changed or dropped.
+func (cfg *APIConfig) v1Routes() []api.Route {
+	deprecated := func(successor string) *api.Deprecation {
+		return &api.Deprecation{Since: v1Deprecated, Sunset: v1Sunset, Successor: successor}
+	}
+	followsDeprecation := deprecated("/v2/follows")
+	return []api.Route{
+		withDeprecation(public("GET", "/error", "Always answers with a server error problem, for testing clients", HandlerError), deprecated("")),
+		withDeprecation(public("POST", "/feeds", "Create a feed without following it", cfg.HandlerCreateFeed), deprecated("/v2/feeds")),
+		withDeprecation(cfg.authed("POST", "/feed_follows", "Follow a feed", cfg.HandlerCreateFeedFollow), followsDeprecation),
+		withDeprecation(cfg.authed("GET", "/feed_follows", "List the feeds you follow", cfg.HandlerGetFeedFollows), followsDeprecation),
+		withDeprecation(cfg.authed("DELETE", "/feed_follows/{feedFollowID}", "Unfollow a feed", cfg.HandlerDeleteFeedFollow), followsDeprecation),
+	}
+}
+
+// v2Routes are the routes that are new or changed in v2.
+func (cfg *APIConfig) v2Routes() []api.Route {
+	return []api.Route{
+		cfg.authed("POST", "/feeds", "Create a feed, or find the existing one, and follow it", cfg.HandlerCreateFeedV2),
+		cfg.authed("POST", "/follows", "Follow a feed", cfg.HandlerCreateFeedFollow),
+		cfg.authed("GET", "/follows", "List the feeds you follow", cfg.HandlerGetFeedFollows),
+		cfg.authed("DELETE", "/follows/{feedFollowID}", "Unfollow a feed", cfg.HandlerDeleteFeedFollow),
+	}
+}
+
+// sharedRoutes are served unchanged by every version.
+func (cfg *APIConfig) sharedRoutes() []api.Route {
+	return []api.Route{
+		public("GET", "/healthz", "Readiness of the server and its dependencies", cfg.HandlerReadiness),
+
+		public("POST", "/users", "Create a user and its API key", cfg.HandlerCreateUser),
+		cfg.authed("GET", "/users", "The calling user", cfg.HandlerGetUser),
+		public("GET", "/users/{userID}/feed.atom", "A user's timeline as Atom", cfg.HandlerUserAtomFeed),
+		public("GET", "/users/{userID}/feed.rss", "A user's timeline as RSS", cfg.HandlerUserRSSFeed),
+
+		public("GET", "/feeds", "List all feeds", cfg.HandlerGetFeeds),
+		public("GET", "/feeds/{feedID}", "One feed", cfg.HandlerGetFeed),
+		cfg.authed("GET", "/discover", "Find the feeds of a website", cfg.HandlerDiscover),
+
+		cfg.authed("POST", "/opml", "Import follows from OPML", cfg.HandlerImportOPML),
+		cfg.authed("GET", "/opml", "Export follows as OPML", cfg.HandlerExportOPML),
+
+		cfg.authed("GET", "/timeline", "Posts of the feeds you follow, newest first", cfg.HandlerGetTimeline),
+
+		cfg.authed("POST", "/digests", "Subscribe to an email or webhook digest", cfg.HandlerCreateDigest),
+		cfg.authed("GET", "/digests", "List your digest subscriptions", cfg.HandlerGetDigests),
+		cfg.authed("DELETE", "/digests/{digestID}", "Cancel a digest subscription", cfg.HandlerDeleteDigest),
+		cfg.authed("GET", "/digests/{digestID}/deliveries", "Recent deliveries of a digest", cfg.HandlerGetDigestDeliveries),
+		cfg.authed("GET", "/digests/{digestID}/preview", "The next digest, rendered as HTML", cfg.HandlerPreviewDigest),
+
+		cfg.authed("POST", "/rules", "Create a filtering rule", cfg.HandlerCreateRule),
+		cfg.authed("GET", "/rules", "List your filtering rules", cfg.HandlerGetRules),
+		cfg.authed("POST", "/rules/apply", "Run your rules on existing posts; dry_run=true previews", cfg.HandlerApplyRules),
+		cfg.authed("PUT", "/rules/{ruleID}", "Replace a filtering rule", cfg.HandlerUpdateRule),
+		cfg.authed("DELETE", "/rules/{ruleID}", "Delete a filtering rule", cfg.HandlerDeleteRule),
+		cfg.authed("POST", "/rules/{ruleID}/apply", "Run one rule on existing posts; dry_run=true previews", cfg.HandlerApplyRule),
+
+		public("GET", "/search", "Full-text search over all posts", cfg.HandlerSearch),
+
+		public("GET", "/websub/callback/{feedID}", "WebSub subscription verification", cfg.HandlerWebSubVerify),
+		public("POST", "/websub/callback/{feedID}", "WebSub content distribution", cfg.HandlerWebSubReceive),
+	}
+}
+
+// Handlers return errors; MakeHTTPHandler turns them into problem+json
+// responses.
+func public(method, pattern, summary string, h respondjson.APIFunc) api.Route {
+	return api.Route{Method: method, Pattern: pattern, Summary: summary, Handler: respondjson.MakeHTTPHandler(h)}
+}
+
+func (cfg *APIConfig) authed(method, pattern, summary string, h AuthedHandler) api.Route {
+	rt := public(method, pattern, summary, cfg.MiddlewareAuth(h))
+	rt.Auth = true
+	return rt
+}
+
+func withDeprecation(rt api.Route, d *api.Deprecation) api.Route {
+	rt.Deprecation = d
+	return rt
+}
@@ -0,0 +1,114 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"golang/rssagg/api"
+	"golang/rssagg/database"
+	"golang/rssagg/health"
+	"golang/rssagg/search"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var update = flag.Bool("update", false, "rewrite docs/routes.md from the route tables")
+
+const routesDoc = "../docs/routes.md"
+
+// TestRoutesDoc keeps docs/routes.md in sync with the route tables.
+func TestRoutesDoc(t *testing.T) {
+	var b bytes.Buffer
+	require.NoError(t, api.WriteTable(&b, (&APIConfig{}).Versions()...))
+	if *update {
+		require.NoError(t, os.WriteFile(routesDoc, b.Bytes(), 0o644))
+	}
+	want, err := os.ReadFile(routesDoc)
+	require.NoError(t, err)
+	assert.Equal(t, string(want), b.String(), "run `go test ./handler -update` to regenerate %s", routesDoc)
+}
+
+type testServer struct {
+	t   *testing.T
+	srv *httptest.Server
+	cfg *APIConfig
+}
+
+func newTestServer(t *testing.T) *testServer {
+	t.Helper()
+	db, err := database.Open(filepath.Join(t.TempDir(), "test.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+	_, err = db.Migrate(context.Background())
+	require.NoError(t, err)
+
+	cfg := &APIConfig{DB: db, Index: search.NewIndex(), Health: health.NewRegistry(time.Second)}
+	router := chi.NewRouter()
+	api.Mount(router, cfg.Versions()...)
+	srv := httptest.NewServer(router)
+	t.Cleanup(srv.Close)
+	return &testServer{t: t, srv: srv, cfg: cfg}
+}
+
+// do sends a request with an optional API key and JSON body and decodes
+// a JSON response into out when it is not nil.
+func (s *testServer) do(method, path, apiKey, body string, out any) *http.Response {
+	s.t.Helper()
+	req, err := http.NewRequest(method, s.srv.URL+path, strings.NewReader(body))
+	require.NoError(s.t, err)
+	if apiKey != "" {
+		req.Header.Set("Authorization", "ApiKey "+apiKey)
+	}
+	res, err := http.DefaultClient.Do(req)
+	require.NoError(s.t, err)
+	defer res.Body.Close()
+	data, err := io.ReadAll(res.Body)
+	require.NoError(s.t, err)
+	if out != nil {
+		require.NoError(s.t, json.Unmarshal(data, out), string(data))
+	}
+	return res
+}
+
+func (s *testServer) createUser(name string) string {
+	s.t.Helper()
+	var user User
+	res := s.do("POST", "/v1/users", "", `{"name":"`+name+`"}`, &user)
+	require.Equal(s.t, 201, res.StatusCode)
+	return user.APIKey
+}
+
+var urlParam = regexp.MustCompile(`\{[^}]+\}`)
+
+// TestEveryRouteIsServed requests each route of each version and checks
+// that the router, rather than a handler, never answers: handlers reply
+// with problem+json even when the request is wrong.
+func TestEveryRouteIsServed(t *testing.T) {
+	s := newTestServer(t)
+	key := s.createUser("walker")
+	for _, v := range s.cfg.Versions() {
+		for _, rt := range v.Routes {
+			path := "/" + v.Name + urlParam.ReplaceAllString(rt.Pattern, "missing")
+			apiKey := ""
+			if rt.Auth {
+				apiKey = key
+			}
+			res := s.do(rt.Method, path, apiKey, "", nil)
+			assert.NotEqual(t, http.StatusMethodNotAllowed, res.StatusCode, "%s %s", rt.Method, path)
+			assert.NotContains(t, res.Header.Get("Content-Type"), "text/plain", "%s %s fell through to the router", rt.Method, path)
+			assert.Equal(t, rt.Deprecation != nil, res.Header.Get("Deprecation") != "", "%s %s", rt.Method, path)
+		}
+	}
+}
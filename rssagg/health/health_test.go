@@ -0,0 +1,72 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func ok(ctx context.Context) error { return nil }
+
+func failing(ctx context.Context) error { return errors.New("boom") }
+
+func TestRunAggregatesStatus(t *testing.T) {
+	r := NewRegistry(time.Second)
+	r.Register("database", true, ok)
+	r.Register("scraper", false, ok)
+	report := r.Run(context.Background())
+	assert.Equal(t, StatusOK, report.Status)
+	require.Len(t, report.Checks, 2)
+	assert.Equal(t, "database", report.Checks[0].Name)
+
+	r.Register("queue", false, failing)
+	report = r.Run(context.Background())
+	assert.Equal(t, StatusDegraded, report.Status)
+	assert.True(t, report.Healthy())
+	assert.Equal(t, "boom", report.Checks[1].Error)
+
+	r.Register("cache", true, failing)
+	report = r.Run(context.Background())
+	assert.Equal(t, StatusUnavailable, report.Status)
+	assert.False(t, report.Healthy())
+}
+
+func TestRunTimesOutAndMeasuresLatency(t *testing.T) {
+	r := NewRegistry(50 * time.Millisecond)
+	r.Register("slow", true, func(ctx context.Context) error {
+		time.Sleep(time.Second)
+		return nil
+	})
+	r.Register("sleepy", true, func(ctx context.Context) error {
+		time.Sleep(10 * time.Millisecond)
+		return nil
+	})
+	r.Register("panics", false, func(ctx context.Context) error { panic("oops") })
+
+	start := time.Now()
+	report := r.Run(context.Background())
+	assert.Less(t, time.Since(start), 500*time.Millisecond)
+
+	results := map[string]CheckResult{}
+	for _, c := range report.Checks {
+		results[c.Name] = c
+	}
+	assert.Equal(t, StatusUnavailable, results["slow"].Status)
+	assert.Contains(t, results["slow"].Error, "timed out")
+	assert.Equal(t, StatusOK, results["sleepy"].Status)
+	assert.GreaterOrEqual(t, results["sleepy"].LatencyMS, 10.0)
+	assert.Contains(t, results["panics"].Error, "oops")
+}
+
+func TestShuttingDown(t *testing.T) {
+	r := NewRegistry(time.Second)
+	r.Register("database", true, ok)
+	r.SetShuttingDown()
+	report := r.Run(context.Background())
+	assert.Equal(t, StatusShuttingDown, report.Status)
+	assert.False(t, report.Healthy())
+}
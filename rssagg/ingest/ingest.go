@@ -0,0 +1,309 @@
+package ingest
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"golang/rssagg/content"
+	"golang/rssagg/database"
+	"golang/rssagg/dedup"
+	"golang/rssagg/rules"
+	"golang/rssagg/scraper"
+	"golang/rssagg/search"
+	"log"
+	"net/url"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	extractInterval  = 30 * time.Second
+	extractBatchSize = 20
+	// duplicateWindow bounds how far back near-duplicates are looked for;
+	// posts sharing a link are matched at any age.
+	duplicateWindow = 7 * 24 * time.Hour
+)
+
+// Ingester stores freshly fetched feed items and keeps the search index
+// in sync with them.
+type Ingester struct {
+	db    *database.DB
+	index *search.Index
+	// extractor is nil when full articles are not fetched.
+	extractor *content.Extractor
+}
+
+func New(db *database.DB, index *search.Index, extractor *content.Extractor) *Ingester {
+	return &Ingester{
+		db:        db,
+		index:     index,
+		extractor: extractor,
+	}
+}
+
+// HandleFeed stores the items of parsed that are new for feed. It has the
+// signature of scraper.ItemHandler. Items whose content looks truncated
+// are queued for full-text extraction. An item the feed already published
+// under another GUID is skipped, and one that other feeds already carry
+// joins their cluster. The followers' rules run on every new post.
+func (in *Ingester) HandleFeed(ctx context.Context, feed database.Feed, parsed *scraper.ParsedFeed) error {
+	var feedRules []*rules.Rule
+	rulesLoaded := false
+	for _, item := range parsed.Items {
+		guid := item.GUID
+		if guid == "" {
+			sum := sha1.Sum([]byte(item.Title + "\x00" + item.Description))
+			guid = hex.EncodeToString(sum[:])
+		}
+		base := baseURL(item.Link, parsed.Link, feed.URL)
+		summary := content.Sanitize(item.Description, base)
+		text := content.PlainText(summary)
+		canonical, sig := fingerprint(item.Link, guid, item.Title, text)
+		cluster, seen, err := in.findCluster(ctx, feed.ID, canonical, sig)
+		if err != nil {
+			return err
+		}
+		if seen {
+			continue
+		}
+		status := database.ContentFromFeed
+		if in.extractor != nil && item.Link != "" && in.extractor.Truncated(text) {
+			status = database.ContentPending
+		}
+		post, created, err := in.db.CreatePost(ctx, database.CreatePostParams{
+			ID:             uuid.NewString(),
+			FeedID:         feed.ID,
+			GUID:           guid,
+			Title:          item.Title,
+			URL:            item.Link,
+			Description:    summary,
+			PublishedAt:    item.PublishedAt,
+			ContentHTML:    summary,
+			ContentText:    text,
+			ReadingMinutes: content.ReadingMinutes(text),
+			ContentStatus:  status,
+			CanonicalURL:   canonical,
+			MinHash:        sig.Bytes(),
+			BandKeys:       sig.BandKeys(),
+			ClusterID:      cluster,
+		})
+		if err != nil {
+			return err
+		}
+		if !created {
+			continue
+		}
+		in.index.Add(PostDocument(post))
+		if !rulesLoaded {
+			loaded, err := in.db.GetRulesForFeed(ctx, feed.ID)
+			if err != nil {
+				return err
+			}
+			feedRules, rulesLoaded = rules.CompileAll(loaded), true
+		}
+		// The post is stored either way; a failed rule must not fail the
+		// fetch and is not retried.
+		if err := rules.ApplyNew(ctx, in.db, feedRules, post); err != nil {
+			log.Printf("Couldn't apply rules to post %s: %v", post.ID, err)
+		}
+	}
+	return nil
+}
+
+// fingerprint returns what duplicate detection compares: the canonical
+// link (or GUID, when that is a permalink) and a MinHash of the text.
+func fingerprint(link, guid, title, text string) (string, dedup.Signature) {
+	canonical := dedup.CanonicalURL(link)
+	if canonical == "" {
+		canonical = dedup.CanonicalURL(guid)
+	}
+	return canonical, dedup.MinHash(title + "\n" + text)
+}
+
+// findCluster returns the cluster an incoming post belongs to, or "" when
+// it starts a new one. seen reports that the feed itself already has a
+// post with the same link.
+func (in *Ingester) findCluster(ctx context.Context, feedID, canonical string, sig dedup.Signature) (cluster string, seen bool, err error) {
+	candidates, err := in.db.FindDuplicates(ctx, database.FindDuplicatesParams{
+		CanonicalURL: canonical,
+		BandKeys:     sig.BandKeys(),
+		BandsSince:   time.Now().Add(-duplicateWindow),
+		Limit:        50,
+	})
+	if err != nil {
+		return "", false, err
+	}
+	for _, c := range candidates {
+		sameLink := canonical != "" && c.CanonicalURL == canonical
+		if c.FeedID == feedID {
+			if sameLink {
+				return "", true, nil
+			}
+			// Feeds often repeat a template; only other feeds count as
+			// near-duplicates.
+			continue
+		}
+		if cluster == "" && (sameLink || sig.NearDuplicate(dedup.ParseSignature(c.MinHash))) {
+			cluster = c.ClusterID
+			if cluster == "" {
+				cluster = c.ID
+			}
+		}
+	}
+	return cluster, false, nil
+}
+
+// Start extracts pending articles until ctx is cancelled. It does nothing
+// when the Ingester has no extractor.
+func (in *Ingester) Start(ctx context.Context) {
+	if in.extractor == nil {
+		return
+	}
+	ticker := time.NewTicker(extractInterval)
+	defer ticker.Stop()
+	for {
+		if _, err := in.ExtractPending(ctx); err != nil && ctx.Err() == nil {
+			log.Printf("Extracting articles: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// ExtractPending fetches the articles of one batch of pending posts and
+// returns how many were replaced by their full text.
+func (in *Ingester) ExtractPending(ctx context.Context) (int, error) {
+	posts, err := in.db.GetPostsPendingContent(ctx, extractBatchSize)
+	if err != nil {
+		return 0, err
+	}
+	n := 0
+	for _, p := range posts {
+		art, err := in.extractor.Fetch(ctx, p.URL)
+		var deferred *scraper.DeferredError
+		switch {
+		case ctx.Err() != nil:
+			return n, ctx.Err()
+		case errors.As(err, &deferred):
+			// The host asked us to back off; try again on a later run.
+			continue
+		case err != nil:
+			log.Printf("Extracting %s: %v", p.URL, err)
+			if err := in.db.SetPostContentStatus(ctx, p.ID, database.ContentFailed); err != nil {
+				return n, err
+			}
+			continue
+		}
+
+		// Keep the feed's version when the page has less to offer.
+		if content.WordCount(art.Text) <= content.WordCount(p.ContentText) {
+			if err := in.db.SetPostContentStatus(ctx, p.ID, database.ContentFromFeed); err != nil {
+				return n, err
+			}
+			continue
+		}
+		post, err := in.db.UpdatePostContent(ctx, database.UpdatePostContentParams{
+			ID:             p.ID,
+			ContentHTML:    art.HTML,
+			ContentText:    art.Text,
+			ReadingMinutes: content.ReadingMinutes(art.Text),
+			ContentStatus:  database.ContentExtracted,
+		})
+		if err != nil {
+			return n, err
+		}
+		in.index.Add(PostDocument(post))
+		n++
+	}
+	return n, nil
+}
+
+// BackfillContent sanitizes the descriptions of posts stored before
+// content was kept alongside them.
+func BackfillContent(ctx context.Context, db *database.DB) (int, error) {
+	n, after := 0, ""
+	for {
+		posts, err := db.GetPostsWithoutContent(ctx, after, 500)
+		if err != nil || len(posts) == 0 {
+			return n, err
+		}
+		for _, p := range posts {
+			html := content.Sanitize(p.Description, baseURL(p.URL))
+			text := content.PlainText(html)
+			if _, err := db.UpdatePostContent(ctx, database.UpdatePostContentParams{
+				ID:             p.ID,
+				ContentHTML:    html,
+				ContentText:    text,
+				ReadingMinutes: content.ReadingMinutes(text),
+				ContentStatus:  database.ContentFromFeed,
+			}); err != nil {
+				return n, err
+			}
+			n++
+		}
+		after = posts[len(posts)-1].ID
+	}
+}
+
+// BackfillFingerprints fingerprints posts stored before duplicate
+// detection. Each is left in a cluster of its own.
+func BackfillFingerprints(ctx context.Context, db *database.DB) (int, error) {
+	n, after := 0, ""
+	for {
+		posts, err := db.GetPostsWithoutFingerprint(ctx, after, 500)
+		if err != nil || len(posts) == 0 {
+			return n, err
+		}
+		for _, p := range posts {
+			canonical, sig := fingerprint(p.URL, p.GUID, p.Title, p.ContentText)
+			if err := db.SetPostFingerprint(ctx, database.SetPostFingerprintParams{
+				ID:           p.ID,
+				CanonicalURL: canonical,
+				MinHash:      sig.Bytes(),
+				BandKeys:     sig.BandKeys(),
+				ClusterID:    p.ID,
+			}); err != nil {
+				return n, err
+			}
+			n++
+		}
+		after = posts[len(posts)-1].ID
+	}
+}
+
+// RebuildIndex replaces the contents of index with every stored post.
+func RebuildIndex(ctx context.Context, db *database.DB, index *search.Index) (int, error) {
+	index.Reset()
+	n := 0
+	err := db.EachPost(ctx, func(p database.Post) error {
+		index.Add(PostDocument(p))
+		n++
+		return nil
+	})
+	return n, err
+}
+
+func PostDocument(p database.Post) search.Document {
+	return search.Document{
+		ID:          p.ID,
+		FeedID:      p.FeedID,
+		PublishedAt: p.PublishedAt,
+		Title:       p.Title,
+		Body:        p.ContentText,
+	}
+}
+
+// baseURL returns the first of candidates that is an absolute URL.
+func baseURL(candidates ...string) *url.URL {
+	for _, c := range candidates {
+		if u, err := url.Parse(c); err == nil && u.IsAbs() {
+			return u
+		}
+	}
+	return nil
+}
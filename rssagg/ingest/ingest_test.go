@@ -0,0 +1,287 @@
+package ingest
+
+import (
+	"context"
+	"golang/rssagg/content"
+	"golang/rssagg/database"
+	"golang/rssagg/dedup"
+	"golang/rssagg/scraper"
+	"golang/rssagg/search"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestDB(t *testing.T) *database.DB {
+	t.Helper()
+	db, err := database.Open(filepath.Join(t.TempDir(), "test.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+	_, err = db.Migrate(context.Background())
+	require.NoError(t, err)
+	return db
+}
+
+func TestHandleFeed(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+	feed, err := db.CreateFeed(ctx, database.CreateFeedParams{ID: "f1", Name: "feed", URL: "https://example.com/feed"})
+	require.NoError(t, err)
+
+	index := search.NewIndex()
+	in := New(db, index, nil)
+	parsed := &scraper.ParsedFeed{Items: []scraper.Item{
+		{GUID: "1", Title: "Hello world", Description: "<p>Some <b>bold</b> text</p><script>var x</script>"},
+		{Title: "No guid", Description: "fallback"},
+	}}
+	require.NoError(t, in.HandleFeed(ctx, feed, parsed))
+	require.NoError(t, in.HandleFeed(ctx, feed, parsed))
+	assert.Equal(t, 2, index.Len())
+
+	res, _, err := index.Search(search.Query{Text: "bold"})
+	require.NoError(t, err)
+	require.Len(t, res, 1)
+	res, _, err = index.Search(search.Query{Text: "var"})
+	require.NoError(t, err)
+	assert.Empty(t, res, "script contents are not indexed")
+
+	n, err := RebuildIndex(ctx, db, search.NewIndex())
+	require.NoError(t, err)
+	assert.Equal(t, 2, n)
+}
+
+func TestHandleFeedSanitizes(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+	feed, err := db.CreateFeed(ctx, database.CreateFeedParams{ID: "f1", Name: "feed", URL: "https://example.com/feed"})
+	require.NoError(t, err)
+
+	in := New(db, search.NewIndex(), nil)
+	parsed := &scraper.ParsedFeed{Items: []scraper.Item{{
+		GUID:        "1",
+		Link:        "https://example.com/posts/1",
+		Description: `<p onclick="x()">Hi <a href="/about">there</a></p><script>alert(1)</script>`,
+	}}}
+	require.NoError(t, in.HandleFeed(ctx, feed, parsed))
+
+	posts, err := db.GetPostsPendingContent(ctx, 10)
+	require.NoError(t, err)
+	assert.Empty(t, posts, "nothing is queued without an extractor")
+
+	var post database.Post
+	require.NoError(t, db.EachPost(ctx, func(p database.Post) error { post = p; return nil }))
+	want := `<p>Hi <a href="https://example.com/about" rel="nofollow noopener noreferrer">there</a></p>`
+	assert.Equal(t, want, post.Description)
+	assert.Equal(t, want, post.ContentHTML)
+	assert.Equal(t, "Hi there", post.ContentText)
+	assert.Equal(t, 1, post.ReadingMinutes)
+	assert.Equal(t, database.ContentFromFeed, post.ContentStatus)
+}
+
+func TestExtractPending(t *testing.T) {
+	srv := httptest.NewServer(http.FileServer(http.Dir("testdata")))
+	defer srv.Close()
+
+	db := newTestDB(t)
+	ctx := context.Background()
+	feed, err := db.CreateFeed(ctx, database.CreateFeedParams{ID: "f1", Name: "feed", URL: srv.URL + "/feed"})
+	require.NoError(t, err)
+
+	index := search.NewIndex()
+	in := New(db, index, content.NewExtractor(content.Config{MinWords: 50, AllowPrivate: true}))
+	parsed := &scraper.ParsedFeed{Items: []scraper.Item{
+		{GUID: "teaser", Title: "Shipping rssagg 2.0", Link: srv.URL + "/article.html", Description: "<p>Today we are shipping…</p>"},
+		{GUID: "gone", Title: "Missing", Link: srv.URL + "/missing.html", Description: "Short"},
+		{GUID: "full", Title: "Long", Link: srv.URL + "/long.html", Description: strings.Repeat("word ", 60)},
+	}}
+	require.NoError(t, in.HandleFeed(ctx, feed, parsed))
+
+	res, _, err := index.Search(search.Query{Text: "sanitized"})
+	require.NoError(t, err)
+	assert.Empty(t, res)
+
+	n, err := in.ExtractPending(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+
+	posts := map[string]database.Post{}
+	require.NoError(t, db.EachPost(ctx, func(p database.Post) error { posts[p.GUID] = p; return nil }))
+	teaser := posts["teaser"]
+	assert.Equal(t, database.ContentExtracted, teaser.ContentStatus)
+	assert.Equal(t, "<p>Today we are shipping…</p>", teaser.Description, "the summary is kept")
+	assert.Contains(t, teaser.ContentText, "The biggest change is full-text extraction")
+	assert.NotContains(t, teaser.ContentText, "Comments are closed")
+	assert.Equal(t, 1, teaser.ReadingMinutes)
+	assert.Equal(t, database.ContentFailed, posts["gone"].ContentStatus)
+	assert.Equal(t, database.ContentFromFeed, posts["full"].ContentStatus)
+
+	res, _, err = index.Search(search.Query{Text: "sanitized"})
+	require.NoError(t, err)
+	require.Len(t, res, 1, "the extracted text is indexed")
+	assert.Equal(t, teaser.ID, res[0].ID)
+
+	n, err = in.ExtractPending(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, n)
+}
+
+const story = `The city council on Tuesday approved a plan to add twelve kilometres of
+protected bike lanes, after a debate that lasted almost four hours and drew a record
+number of public comments. Supporters said the lanes would make the cafe district
+safer, while shop owners worried about losing parking spaces and passing trade.`
+
+func TestHandleFeedClustersDuplicates(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+	user, err := db.CreateUser(ctx, database.CreateUserParams{ID: "u1", Name: "alice"})
+	require.NoError(t, err)
+	feeds := map[string]database.Feed{}
+	for _, id := range []string{"wire", "paper", "blog", "sports"} {
+		feed, err := db.CreateFeed(ctx, database.CreateFeedParams{ID: id, Name: id, URL: "https://" + id + ".example/feed"})
+		require.NoError(t, err)
+		feeds[id] = feed
+		_, err = db.CreateFeedFollow(ctx, database.CreateFeedFollowParams{ID: id, UserID: user.ID, FeedID: id})
+		require.NoError(t, err)
+	}
+
+	in := New(db, search.NewIndex(), nil)
+	ingest := func(feed string, items ...scraper.Item) {
+		require.NoError(t, in.HandleFeed(ctx, feeds[feed], &scraper.ParsedFeed{Items: items}))
+	}
+	base := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	ingest("wire", scraper.Item{GUID: "w1", Title: "Bike lanes approved", Link: "https://news.example/2024/bike-lanes?utm_source=rss",
+		Description: story, PublishedAt: base})
+	// Same link, different text.
+	ingest("paper", scraper.Item{GUID: "p1", Title: "Council backs bike plan", Link: "http://www.news.example/2024/bike-lanes/",
+		Description: "Read the full story on our partner's site.", PublishedAt: base.Add(time.Hour)})
+	// Different link, lightly edited text.
+	ingest("blog", scraper.Item{GUID: "b1", Title: "Bike lanes approved", Link: "https://blog.example/posts/42",
+		Description: strings.Replace(story, "almost", "nearly", 1) + " (via the wire)", PublishedAt: base.Add(2 * time.Hour)})
+	ingest("sports", scraper.Item{GUID: "s1", Title: "Local team wins", Link: "https://sports.example/match",
+		Description: "The local team won the cup final on Saturday after extra time, to the delight of thousands of fans who had travelled to the capital.", PublishedAt: base.Add(3 * time.Hour)})
+	// The wire republishes its story under a new GUID.
+	ingest("wire", scraper.Item{GUID: "w1-updated", Title: "Bike lanes approved (updated)", Link: "https://news.example/2024/bike-lanes",
+		Description: story, PublishedAt: base.Add(4 * time.Hour)})
+
+	posts := map[string]database.Post{}
+	require.NoError(t, db.EachPost(ctx, func(p database.Post) error { posts[p.GUID] = p; return nil }))
+	require.Len(t, posts, 4, "the republished story is skipped")
+	wire := posts["w1"]
+	assert.Equal(t, wire.ID, wire.ClusterID)
+	assert.Equal(t, "news.example/2024/bike-lanes", wire.CanonicalURL)
+	assert.Equal(t, wire.ID, posts["p1"].ClusterID, "same canonical link")
+	assert.Equal(t, wire.ID, posts["b1"].ClusterID, "near-duplicate text")
+	assert.Equal(t, posts["s1"].ID, posts["s1"].ClusterID)
+
+	timeline, err := db.GetTimeline(ctx, database.GetTimelineParams{UserID: user.ID, Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, timeline, 2)
+	assert.Equal(t, "s1", timeline[0].Post.GUID)
+	assert.Equal(t, "w1", timeline[1].Post.GUID)
+	require.Len(t, timeline[1].AlsoIn, 2)
+	assert.Equal(t, "paper", timeline[1].AlsoIn[0].FeedID)
+	assert.Equal(t, "blog", timeline[1].AlsoIn[1].FeedID)
+}
+
+func TestBackfillFingerprints(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+	_, err := db.CreateFeed(ctx, database.CreateFeedParams{ID: "f1", Name: "feed", URL: "https://example.com/feed"})
+	require.NoError(t, err)
+	_, _, err = db.CreatePost(ctx, database.CreatePostParams{ID: "p1", FeedID: "f1", GUID: "1", URL: "https://example.com/1?utm_medium=x", ContentText: story})
+	require.NoError(t, err)
+	// Fingerprinting is new; forget it like a post from an older release.
+	require.NoError(t, db.SetPostFingerprint(ctx, database.SetPostFingerprintParams{ID: "p1"}))
+
+	n, err := BackfillFingerprints(ctx, db)
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+	p, err := db.GetPost(ctx, "p1")
+	require.NoError(t, err)
+	assert.Equal(t, "example.com/1", p.CanonicalURL)
+	assert.Equal(t, "p1", p.ClusterID)
+	assert.NotEmpty(t, p.MinHash)
+
+	// Later posts can join its cluster.
+	dups, err := db.FindDuplicates(ctx, database.FindDuplicatesParams{BandKeys: dedup.MinHash("\n" + story).BandKeys(), Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, dups, 1)
+
+	n, err = BackfillFingerprints(ctx, db)
+	require.NoError(t, err)
+	assert.Zero(t, n)
+}
+
+func TestBackfillContent(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+	_, err := db.CreateFeed(ctx, database.CreateFeedParams{ID: "f1", Name: "feed", URL: "https://example.com/feed"})
+	require.NoError(t, err)
+	// A post stored before content was kept.
+	_, _, err = db.CreatePost(ctx, database.CreatePostParams{
+		ID: "p1", FeedID: "f1", GUID: "1", URL: "https://example.com/1",
+		Description: `<p>Old <img src="/a.png" onerror="x()"></p>`,
+	})
+	require.NoError(t, err)
+
+	n, err := BackfillContent(ctx, db)
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+	p, err := db.GetPost(ctx, "p1")
+	require.NoError(t, err)
+	assert.Equal(t, `<p>Old <img src="https://example.com/a.png"></p>`, p.ContentHTML)
+	assert.Equal(t, "Old", p.ContentText)
+
+	n, err = BackfillContent(ctx, db)
+	require.NoError(t, err)
+	assert.Zero(t, n)
+}
+
+func TestHandleFeedAppliesRules(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+	feed, err := db.CreateFeed(ctx, database.CreateFeedParams{ID: "f1", Name: "feed", URL: "https://example.com/feed"})
+	require.NoError(t, err)
+	for _, id := range []string{"u1", "u2"} {
+		_, err = db.CreateUser(ctx, database.CreateUserParams{ID: id, Name: id})
+		require.NoError(t, err)
+	}
+	_, err = db.CreateFeedFollow(ctx, database.CreateFeedFollowParams{ID: "ff1", UserID: "u1", FeedID: feed.ID})
+	require.NoError(t, err)
+	_, err = db.CreateRule(ctx, "r1", "u1", database.RuleParams{FeedID: feed.ID, Action: database.RuleMarkRead, Enabled: true})
+	require.NoError(t, err)
+	_, err = db.CreateRule(ctx, "r2", "u1", database.RuleParams{
+		Field: database.RuleFieldTitle, Operator: database.RuleRegex, Pattern: `(?i)\bgo\b`, Action: database.RuleStar, Enabled: true,
+	})
+	require.NoError(t, err)
+	// u2 does not follow the feed, so their rule never runs.
+	_, err = db.CreateRule(ctx, "r3", "u2", database.RuleParams{FeedID: feed.ID, Action: database.RuleStar, Enabled: true})
+	require.NoError(t, err)
+
+	in := New(db, search.NewIndex(), nil)
+	require.NoError(t, in.HandleFeed(ctx, feed, &scraper.ParsedFeed{Items: []scraper.Item{
+		{GUID: "1", Title: "Learning Go"},
+		{GUID: "2", Title: "Something else"},
+	}}))
+
+	posts, err := db.GetTimeline(ctx, database.GetTimelineParams{UserID: "u1", Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, posts, 2)
+	for _, p := range posts {
+		assert.True(t, p.State.Read, p.Post.Title)
+		assert.Equal(t, p.Post.Title == "Learning Go", p.State.Starred, p.Post.Title)
+	}
+	unread, err := db.GetTimeline(ctx, database.GetTimelineParams{UserID: "u1", Unread: true, Limit: 10})
+	require.NoError(t, err)
+	assert.Empty(t, unread)
+
+	states, err := db.GetPostStates(ctx, "u2", []string{posts[0].Post.ID, posts[1].Post.ID})
+	require.NoError(t, err)
+	assert.Empty(t, states)
+}
@@ -1,48 +1,209 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	respondjson "golang/rssagg/RespondJSON"
+	"golang/rssagg/admin"
+	"golang/rssagg/api"
+	"golang/rssagg/content"
+	"golang/rssagg/database"
+	"golang/rssagg/digest"
+	"golang/rssagg/discover"
 	"golang/rssagg/handler"
+	"golang/rssagg/health"
+	"golang/rssagg/ingest"
+	"golang/rssagg/scraper"
+	"golang/rssagg/search"
+	"golang/rssagg/websub"
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+	// Digest time zones must load in containers without zoneinfo.
+	_ "time/tzdata"
 
 	"github.com/go-chi/chi"
 	"github.com/go-chi/cors"
 	"github.com/joho/godotenv"
 )
 
+const shutdownTimeout = 30 * time.Second
+
 func main() {
 	godotenv.Load(".env")
+	dbPath := os.Getenv("DB_PATH")
+	if dbPath == "" {
+		log.Fatal("DB_PATH is not set")
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if len(os.Args) > 1 && os.Args[1] == "admin" {
+		err := admin.Run(ctx, dbPath, os.Args[2:], os.Stdout, os.Stderr)
+		if errors.Is(err, admin.ErrUsage) {
+			os.Exit(2)
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "rssagg admin:", err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	port := os.Getenv("PORT")
 	if port == "" {
 		log.Fatal("PORT is not set")
 	}
 
-	router := chi.NewRouter()
+	db, err := database.Open(dbPath)
+	if err != nil {
+		log.Fatal("Can't open database: ", err)
+	}
+	defer db.Close()
+	if _, err := db.Migrate(ctx); err != nil {
+		log.Fatal("Can't migrate database: ", err)
+	}
+
+	if n, err := ingest.BackfillContent(ctx, db); err != nil {
+		log.Fatal("Can't backfill post content: ", err)
+	} else if n > 0 {
+		log.Printf("Backfilled content of %d posts", n)
+	}
+	if n, err := ingest.BackfillFingerprints(ctx, db); err != nil {
+		log.Fatal("Can't backfill post fingerprints: ", err)
+	} else if n > 0 {
+		log.Printf("Fingerprinted %d posts", n)
+	}
+
+	index := search.NewIndex()
+	n, err := ingest.RebuildIndex(ctx, db, index)
+	if err != nil {
+		log.Fatal("Can't build search index: ", err)
+	}
+	log.Printf("Indexed %d posts", n)
+
+	apiCfg := handler.APIConfig{
+		DB:         db,
+		Index:      index,
+		Health:     health.NewRegistry(2 * time.Second),
+		Discoverer: discover.New(discover.DefaultConfig()),
+		BaseURL:    os.Getenv("BASE_URL"),
+	}
+	apiCfg.Health.Register("database", true, db.Ping)
+
+	// Background workers stop when ctx is cancelled; main waits for them
+	// before closing the database.
+	var workers sync.WaitGroup
+	startWorker := func(run func(context.Context)) {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			run(ctx)
+		}()
+	}
+
+	// FULL_TEXT=true fetches the article page of posts whose feed only
+	// carries a teaser.
+	var extractor *content.Extractor
+	if fullText, _ := strconv.ParseBool(os.Getenv("FULL_TEXT")); fullText {
+		extractor = content.NewExtractor(content.DefaultConfig())
+	}
+	ingester := ingest.New(db, index, extractor)
+	startWorker(ingester.Start)
+	handleFeed := ingester.HandleFeed
+	// WebSub needs a callback URL that hubs can reach.
+	if apiCfg.BaseURL != "" {
+		apiCfg.WebSub = websub.NewSubscriber(db, websub.DefaultConfig(apiCfg.BaseURL+"/v1/websub/callback"), ingester.HandleFeed)
+		handleFeed = apiCfg.WebSub.HandleFeed
+		startWorker(apiCfg.WebSub.Start)
+	} else {
+		log.Printf("BASE_URL is not set, WebSub push subscriptions are disabled")
+	}
+
+	// SMTP_ADDR and SMTP_FROM enable email digests; webhook digests need
+	// no configuration.
+	apiCfg.Digests = digest.NewScheduler(db, digest.DefaultConfig(digest.SMTPConfig{
+		Addr:     os.Getenv("SMTP_ADDR"),
+		From:     os.Getenv("SMTP_FROM"),
+		Username: os.Getenv("SMTP_USERNAME"),
+		Password: os.Getenv("SMTP_PASSWORD"),
+	}))
+	if !apiCfg.Digests.EmailEnabled() {
+		log.Printf("SMTP_ADDR or SMTP_FROM is not set, email digests are disabled")
+	}
+	startWorker(apiCfg.Digests.Start)
 
+	// SIGHUP rebuilds the search index, e.g. after `rssagg admin feeds
+	// refresh` stored posts behind the server's back.
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	startWorker(func(ctx context.Context) {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-hup:
+				n, err := ingest.RebuildIndex(ctx, db, index)
+				if err != nil {
+					log.Printf("Rebuilding search index: %v", err)
+					continue
+				}
+				log.Printf("Rebuilt search index with %d posts", n)
+			}
+		}
+	})
+
+	scr := scraper.New(db, scraper.DefaultConfig(), handleFeed)
+	apiCfg.Health.Register("scraper", false, scr.Check)
+	startWorker(scr.Start)
+
+	router := chi.NewRouter()
+	router.Use(respondjson.Recoverer)
 	router.Use(cors.Handler(cors.Options{
 		AllowedOrigins:   []string{"https://*", "http://*"},
 		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
 		AllowedHeaders:   []string{"*"},
-		ExposedHeaders:   []string{"Link"},
+		ExposedHeaders:   []string{"Link", "ETag", "Deprecation", "Sunset"},
 		AllowCredentials: false,
 		MaxAge:           300,
 	}))
-	v1Router := chi.NewRouter()
-	v1Router.Get("/healthz", handler.HandlerReadiness)
-	v1Router.Get("/error", handler.HandlerError)
-
-	router.Mount("/v1", v1Router)
+	// Each version is served under its own prefix; v1 routes replaced in
+	// v2 carry Deprecation and Sunset headers until they are removed.
+	api.Mount(router, apiCfg.Versions()...)
 
 	srv := &http.Server{
-		Handler: router,
-		Addr:    ":" + port,
+		Handler:           router,
+		Addr:              ":" + port,
+		ReadHeaderTimeout: 5 * time.Second,
+		ReadTimeout:       30 * time.Second,
+		WriteTimeout:      60 * time.Second,
+		IdleTimeout:       120 * time.Second,
 	}
 
-	log.Printf("Server started on port %s", port)
-	err := srv.ListenAndServe()
-	if err != nil {
-		log.Fatal(err)
-	}
+	go func() {
+		log.Printf("Server started on port %s", port)
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatal(err)
+		}
+	}()
 
+	<-ctx.Done()
+	stop()
+	log.Printf("Shutting down, draining requests for up to %s", shutdownTimeout)
+	apiCfg.Health.SetShuttingDown()
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		log.Printf("Server shutdown: %v", err)
+	}
+	workers.Wait()
+	log.Printf("Server stopped")
 }
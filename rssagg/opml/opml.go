@@ -0,0 +1,165 @@
+// Package opml reads and writes OPML 2.0 subscription lists.
+package opml
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+
+	"golang.org/x/net/html/charset"
+)
+
+type Document struct {
+	XMLName xml.Name `xml:"opml"`
+	Version string   `xml:"version,attr"`
+	Head    Head     `xml:"head"`
+	Body    Body     `xml:"body"`
+}
+
+type Head struct {
+	Title       string `xml:"title,omitempty"`
+	DateCreated string `xml:"dateCreated,omitempty"`
+	OwnerName   string `xml:"ownerName,omitempty"`
+}
+
+type Body struct {
+	Outlines []Outline `xml:"outline"`
+}
+
+type Outline struct {
+	Text     string    `xml:"text,attr"`
+	Title    string    `xml:"title,attr,omitempty"`
+	Type     string    `xml:"type,attr,omitempty"`
+	XMLURL   string    `xml:"xmlUrl,attr,omitempty"`
+	HTMLURL  string    `xml:"htmlUrl,attr,omitempty"`
+	Category string    `xml:"category,attr,omitempty"`
+	Outlines []Outline `xml:"outline"`
+}
+
+// Subscription is a feed outline together with the folders it is nested in.
+type Subscription struct {
+	Title   string
+	XMLURL  string
+	HTMLURL string
+	Folder  []string
+}
+
+func Parse(r io.Reader) (*Document, error) {
+	dec := xml.NewDecoder(r)
+	dec.Strict = false
+	dec.CharsetReader = charset.NewReaderLabel
+	var doc Document
+	if err := dec.Decode(&doc); err != nil {
+		return nil, fmt.Errorf("invalid OPML: %w", err)
+	}
+	if doc.XMLName.Local != "opml" {
+		return nil, fmt.Errorf("invalid OPML: root element is <%s>", doc.XMLName.Local)
+	}
+	return &doc, nil
+}
+
+func Write(w io.Writer, doc *Document) error {
+	if doc.Version == "" {
+		doc.Version = "2.0"
+	}
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// Subscriptions flattens the outline tree. Outlines with an xmlUrl are
+// feeds; outlines without one are folders. A feed at the top level may
+// still be filed through its category attribute ("/Tech/Go").
+func (d *Document) Subscriptions() []Subscription {
+	var subs []Subscription
+	var walk func(outlines []Outline, folder []string)
+	walk = func(outlines []Outline, folder []string) {
+		for _, o := range outlines {
+			name := o.Title
+			if name == "" {
+				name = o.Text
+			}
+			if o.XMLURL == "" {
+				walk(o.Outlines, appendFolder(folder, name))
+				continue
+			}
+			f := folder
+			if len(f) == 0 && o.Category != "" {
+				f = SplitFolder(strings.Split(o.Category, ",")[0])
+			}
+			subs = append(subs, Subscription{
+				Title:   name,
+				XMLURL:  strings.TrimSpace(o.XMLURL),
+				HTMLURL: strings.TrimSpace(o.HTMLURL),
+				Folder:  f,
+			})
+			// Some readers nest feeds under feeds; keep those too.
+			walk(o.Outlines, folder)
+		}
+	}
+	walk(d.Body.Outlines, nil)
+	return subs
+}
+
+// New builds a document with one nested folder outline per distinct
+// folder path, in the order the folders first appear.
+func New(title string, subs []Subscription) *Document {
+	doc := &Document{Version: "2.0", Head: Head{Title: title}}
+	for _, s := range subs {
+		outlines := &doc.Body.Outlines
+		for _, name := range s.Folder {
+			outlines = &folderOutline(outlines, name).Outlines
+		}
+		*outlines = append(*outlines, Outline{
+			Text:    s.Title,
+			Title:   s.Title,
+			Type:    "rss",
+			XMLURL:  s.XMLURL,
+			HTMLURL: s.HTMLURL,
+		})
+	}
+	return doc
+}
+
+func folderOutline(outlines *[]Outline, name string) *Outline {
+	for i := range *outlines {
+		o := &(*outlines)[i]
+		if o.XMLURL == "" && o.Text == name {
+			return o
+		}
+	}
+	*outlines = append(*outlines, Outline{Text: name, Title: name})
+	return &(*outlines)[len(*outlines)-1]
+}
+
+// JoinFolder and SplitFolder convert between folder paths and the slash
+// separated form used for storage and the category attribute.
+func JoinFolder(folder []string) string {
+	return strings.Join(folder, "/")
+}
+
+func SplitFolder(s string) []string {
+	var folder []string
+	for _, part := range strings.Split(s, "/") {
+		folder = appendFolder(folder, part)
+	}
+	return folder
+}
+
+func appendFolder(folder []string, name string) []string {
+	name = strings.TrimSpace(strings.ReplaceAll(name, "/", "-"))
+	if name == "" {
+		return folder
+	}
+	out := make([]string, len(folder), len(folder)+1)
+	copy(out, folder)
+	return append(out, name)
+}
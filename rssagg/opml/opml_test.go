@@ -0,0 +1,64 @@
+package opml
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const sample = `<?xml version="1.0" encoding="ISO-8859-1"?>
+<opml version="2.0">
+  <head><title>mySubscriptions.opml</title></head>
+  <body>
+    <outline text="Tech">
+      <outline text="Go">
+        <outline text="The Go Blog" type="rss" xmlUrl="https://go.dev/blog/feed.atom" htmlUrl="https://go.dev/blog"/>
+      </outline>
+      <outline text="Hacker News" type="rss" xmlUrl="https://news.ycombinator.com/rss"/>
+    </outline>
+    <outline text="Caf&#233;" type="rss" xmlUrl="https://example.com/cafe.xml" category="/Food/Drinks"/>
+    <outline text="Top" type="rss" xmlUrl="https://example.com/top.xml"/>
+  </body>
+</opml>`
+
+func TestSubscriptions(t *testing.T) {
+	doc, err := Parse(strings.NewReader(sample))
+	require.NoError(t, err)
+	assert.Equal(t, []Subscription{
+		{Title: "The Go Blog", XMLURL: "https://go.dev/blog/feed.atom", HTMLURL: "https://go.dev/blog", Folder: []string{"Tech", "Go"}},
+		{Title: "Hacker News", XMLURL: "https://news.ycombinator.com/rss", Folder: []string{"Tech"}},
+		{Title: "Café", XMLURL: "https://example.com/cafe.xml", Folder: []string{"Food", "Drinks"}},
+		{Title: "Top", XMLURL: "https://example.com/top.xml"},
+	}, doc.Subscriptions())
+}
+
+func TestRoundTrip(t *testing.T) {
+	doc, err := Parse(strings.NewReader(sample))
+	require.NoError(t, err)
+	subs := doc.Subscriptions()
+
+	var buf bytes.Buffer
+	require.NoError(t, Write(&buf, New("export", subs)))
+	again, err := Parse(&buf)
+	require.NoError(t, err)
+	assert.Equal(t, subs, again.Subscriptions())
+
+	// Folders are written as nested outlines, not categories.
+	require.Len(t, again.Body.Outlines, 3)
+	assert.Equal(t, "Tech", again.Body.Outlines[0].Text)
+	assert.Equal(t, "Go", again.Body.Outlines[0].Outlines[0].Text)
+}
+
+func TestParseRejectsOtherDocuments(t *testing.T) {
+	_, err := Parse(strings.NewReader(`<rss version="2.0"></rss>`))
+	assert.Error(t, err)
+}
+
+func TestSplitFolder(t *testing.T) {
+	assert.Equal(t, []string{"a", "b"}, SplitFolder(" /a//b/ "))
+	assert.Nil(t, SplitFolder(""))
+	assert.Equal(t, "a/b", JoinFolder([]string{"a", "b"}))
+}
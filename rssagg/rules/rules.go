@@ -0,0 +1,191 @@
+// Package rules runs users' filtering rules against posts: a rule tests a
+// post's feed and text and tags, stars or marks matching posts read.
+package rules
+
+import (
+	"context"
+	"fmt"
+	"golang/rssagg/database"
+	"regexp"
+	"strings"
+)
+
+// MaxPatternLength bounds the text a rule matches on.
+const MaxPatternLength = 1000
+
+// retroBatchSize is how many posts Retro reads at a time.
+const retroBatchSize = 500
+
+// Rule is a compiled database.Rule.
+type Rule struct {
+	database.Rule
+	re     *regexp.Regexp
+	needle string
+}
+
+// Compile checks r and prepares it for matching.
+func Compile(r database.Rule) (*Rule, error) {
+	c := &Rule{Rule: r}
+	switch r.Field {
+	case "":
+	case database.RuleFieldTitle, database.RuleFieldBody, database.RuleFieldURL:
+		if r.Pattern == "" {
+			return nil, fmt.Errorf("pattern is required with field %q", r.Field)
+		}
+	default:
+		return nil, fmt.Errorf("unknown field %q", r.Field)
+	}
+	if len(r.Pattern) > MaxPatternLength {
+		return nil, fmt.Errorf("pattern is longer than %d bytes", MaxPatternLength)
+	}
+	if r.Field != "" {
+		switch r.Operator {
+		case database.RuleContains:
+			c.needle = strings.ToLower(r.Pattern)
+		case database.RuleRegex:
+			re, err := regexp.Compile(r.Pattern)
+			if err != nil {
+				return nil, fmt.Errorf("invalid regex: %w", err)
+			}
+			c.re = re
+		default:
+			return nil, fmt.Errorf("unknown operator %q", r.Operator)
+		}
+	}
+	switch r.Action {
+	case database.RuleTag:
+		if strings.TrimSpace(r.Tag) == "" {
+			return nil, fmt.Errorf("tag is required with action %q", r.Action)
+		}
+	case database.RuleMarkRead, database.RuleStar:
+	default:
+		return nil, fmt.Errorf("unknown action %q", r.Action)
+	}
+	return c, nil
+}
+
+// CompileAll compiles rules, dropping the ones that no longer compile.
+func CompileAll(rules []database.Rule) []*Rule {
+	out := make([]*Rule, 0, len(rules))
+	for _, r := range rules {
+		if c, err := Compile(r); err == nil {
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+// Match reports whether p satisfies every condition of r.
+func (r *Rule) Match(p database.Post) bool {
+	if r.FeedID != "" && r.FeedID != p.FeedID {
+		return false
+	}
+	if r.Field == "" {
+		return true
+	}
+	var text string
+	switch r.Field {
+	case database.RuleFieldTitle:
+		text = p.Title
+	case database.RuleFieldBody:
+		text = p.ContentText
+	case database.RuleFieldURL:
+		text = p.URL
+	}
+	if r.re != nil {
+		return r.re.MatchString(text)
+	}
+	return strings.Contains(strings.ToLower(text), r.needle)
+}
+
+// Plan returns the actions the user's rules would take on p that would
+// change state. Rules of other users are ignored, and each change is
+// planned once even when several rules ask for it.
+func Plan(rules []*Rule, userID string, p database.Post, state database.PostState) []database.PostAction {
+	var out []database.PostAction
+	for _, r := range rules {
+		if r.UserID != userID || !r.Match(p) {
+			continue
+		}
+		switch r.Action {
+		case database.RuleMarkRead:
+			if state.Read {
+				continue
+			}
+			state.Read = true
+		case database.RuleStar:
+			if state.Starred {
+				continue
+			}
+			state.Starred = true
+		case database.RuleTag:
+			if state.HasTag(r.Tag) {
+				continue
+			}
+			state.Tags = append(state.Tags, r.Tag)
+		}
+		out = append(out, database.PostAction{UserID: userID, PostID: p.ID, RuleID: r.ID, Action: r.Action, Tag: r.Tag})
+	}
+	return out
+}
+
+// ApplyNew runs rules against a post that was just stored. Its state is
+// still empty, so every matching rule acts.
+func ApplyNew(ctx context.Context, db *database.DB, rules []*Rule, p database.Post) error {
+	var actions []database.PostAction
+	seen := make(map[string]bool)
+	for _, r := range rules {
+		if !seen[r.UserID] {
+			seen[r.UserID] = true
+			actions = append(actions, Plan(rules, r.UserID, p, database.PostState{})...)
+		}
+	}
+	return db.ApplyPostActions(ctx, actions)
+}
+
+// Change is what rules do, or would do, to one existing post.
+type Change struct {
+	Post    database.Post
+	Actions []database.PostAction
+}
+
+// Retro runs the user's rules against the posts of the feeds they already
+// follow, restricted to feedID when it is set. With dryRun nothing is
+// written and the result only shows what would change.
+func Retro(ctx context.Context, db *database.DB, userID string, rules []*Rule, feedID string, dryRun bool) ([]Change, error) {
+	var changes []Change
+	after := ""
+	for {
+		posts, err := db.GetFollowedPosts(ctx, userID, feedID, after, retroBatchSize)
+		if err != nil {
+			return nil, err
+		}
+		if len(posts) == 0 {
+			return changes, nil
+		}
+		after = posts[len(posts)-1].ID
+
+		ids := make([]string, len(posts))
+		for i, p := range posts {
+			ids[i] = p.ID
+		}
+		states, err := db.GetPostStates(ctx, userID, ids)
+		if err != nil {
+			return nil, err
+		}
+		var batch []database.PostAction
+		for _, p := range posts {
+			actions := Plan(rules, userID, p, states[p.ID])
+			if len(actions) == 0 {
+				continue
+			}
+			changes = append(changes, Change{Post: p, Actions: actions})
+			batch = append(batch, actions...)
+		}
+		if !dryRun {
+			if err := db.ApplyPostActions(ctx, batch); err != nil {
+				return nil, err
+			}
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package rules
+
+import (
+	"context"
+	"golang/rssagg/database"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func mustCompile(t *testing.T, r database.Rule) *Rule {
+	t.Helper()
+	c, err := Compile(r)
+	require.NoError(t, err)
+	return c
+}
+
+func TestCompile(t *testing.T) {
+	for name, r := range map[string]database.Rule{
+		"unknown field":  {Field: "author", Operator: database.RuleContains, Pattern: "x", Action: database.RuleStar},
+		"no pattern":     {Field: database.RuleFieldTitle, Operator: database.RuleContains, Action: database.RuleStar},
+		"bad regex":      {Field: database.RuleFieldBody, Operator: database.RuleRegex, Pattern: "(", Action: database.RuleStar},
+		"tag without it": {FeedID: "f", Action: database.RuleTag},
+		"unknown action": {FeedID: "f", Action: "delete"},
+	} {
+		_, err := Compile(r)
+		assert.Error(t, err, name)
+	}
+}
+
+func TestMatch(t *testing.T) {
+	post := database.Post{FeedID: "f1", Title: "Go 1.22 released", URL: "https://go.dev/blog/go1.22", ContentText: "Loop variables are per-iteration now."}
+	for _, tc := range []struct {
+		rule database.Rule
+		want bool
+	}{
+		{database.Rule{Field: database.RuleFieldTitle, Operator: database.RuleContains, Pattern: "RELEASED"}, true},
+		{database.Rule{Field: database.RuleFieldTitle, Operator: database.RuleContains, Pattern: "rust"}, false},
+		{database.Rule{Field: database.RuleFieldBody, Operator: database.RuleRegex, Pattern: `per-\w+`}, true},
+		{database.Rule{Field: database.RuleFieldURL, Operator: database.RuleRegex, Pattern: `^https://go\.dev/`}, true},
+		{database.Rule{FeedID: "f1"}, true},
+		{database.Rule{FeedID: "f2", Field: database.RuleFieldTitle, Operator: database.RuleContains, Pattern: "Go"}, false},
+	} {
+		tc.rule.Action = database.RuleStar
+		assert.Equal(t, tc.want, mustCompile(t, tc.rule).Match(post), "%+v", tc.rule)
+	}
+}
+
+func TestPlanSkipsExistingState(t *testing.T) {
+	rules := []*Rule{
+		mustCompile(t, database.Rule{ID: "r1", UserID: "u1", FeedID: "f1", Action: database.RuleTag, Tag: "go"}),
+		mustCompile(t, database.Rule{ID: "r2", UserID: "u1", FeedID: "f1", Action: database.RuleTag, Tag: "go"}),
+		mustCompile(t, database.Rule{ID: "r3", UserID: "u1", FeedID: "f1", Action: database.RuleMarkRead}),
+		mustCompile(t, database.Rule{ID: "r4", UserID: "u1", FeedID: "f1", Action: database.RuleStar}),
+		mustCompile(t, database.Rule{ID: "r5", UserID: "u2", FeedID: "f1", Action: database.RuleStar}),
+	}
+	post := database.Post{ID: "p1", FeedID: "f1"}
+
+	actions := Plan(rules, "u1", post, database.PostState{})
+	require.Len(t, actions, 3, "the second go tag is a no-op")
+	assert.Equal(t, "r1", actions[0].RuleID)
+
+	actions = Plan(rules, "u1", post, database.PostState{Read: true, Tags: []string{"go"}})
+	require.Len(t, actions, 1)
+	assert.Equal(t, database.RuleStar, actions[0].Action)
+	assert.Equal(t, "r4", actions[0].RuleID)
+}
+
+func TestRetro(t *testing.T) {
+	ctx := context.Background()
+	db, err := database.Open(filepath.Join(t.TempDir(), "test.db"))
+	require.NoError(t, err)
+	defer db.Close()
+	_, err = db.Migrate(ctx)
+	require.NoError(t, err)
+
+	user, err := db.CreateUser(ctx, database.CreateUserParams{ID: "u1", Name: "alice"})
+	require.NoError(t, err)
+	for _, id := range []string{"f1", "f2"} {
+		_, err = db.CreateFeed(ctx, database.CreateFeedParams{ID: id, Name: id, URL: "https://example.com/" + id})
+		require.NoError(t, err)
+		_, err = db.CreateFeedFollow(ctx, database.CreateFeedFollowParams{ID: id, UserID: user.ID, FeedID: id})
+		require.NoError(t, err)
+	}
+	for id, title := range map[string]string{"p1": "Kubernetes news", "p2": "Gardening", "p3": "More kubernetes"} {
+		feed := "f1"
+		if id == "p3" {
+			feed = "f2"
+		}
+		_, _, err = db.CreatePost(ctx, database.CreatePostParams{ID: id, FeedID: feed, GUID: id, Title: title})
+		require.NoError(t, err)
+	}
+	rule, err := db.CreateRule(ctx, "r1", user.ID, database.RuleParams{
+		Field: database.RuleFieldTitle, Operator: database.RuleContains, Pattern: "kubernetes",
+		Action: database.RuleTag, Tag: "k8s", Enabled: true,
+	})
+	require.NoError(t, err)
+	rules := []*Rule{mustCompile(t, rule)}
+
+	changes, err := Retro(ctx, db, user.ID, rules, "", true)
+	require.NoError(t, err)
+	require.Len(t, changes, 2)
+	tagged, err := db.GetTimeline(ctx, database.GetTimelineParams{UserID: user.ID, Tag: "k8s", Limit: 10})
+	require.NoError(t, err)
+	assert.Empty(t, tagged, "a dry run writes nothing")
+
+	changes, err = Retro(ctx, db, user.ID, rules, "f1", false)
+	require.NoError(t, err)
+	require.Len(t, changes, 1)
+	assert.Equal(t, "p1", changes[0].Post.ID)
+	changes, err = Retro(ctx, db, user.ID, rules, "", false)
+	require.NoError(t, err)
+	require.Len(t, changes, 1, "p1 is already tagged")
+	assert.Equal(t, "p3", changes[0].Post.ID)
+
+	tagged, err = db.GetTimeline(ctx, database.GetTimelineParams{UserID: user.ID, Tag: "k8s", Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, tagged, 2)
+	assert.Equal(t, []string{"k8s"}, tagged[0].State.Tags)
+}
@@ -0,0 +1,203 @@
+package scraper
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const maxFeedSize = 10 << 20
+
+// StatusError is returned when a feed responds with an unexpected status.
+// RetryAfter is set when the server asked us to come back later.
+type StatusError struct {
+	StatusCode int
+	RetryAfter time.Duration
+}
+
+func (e *StatusError) Error() string {
+	if e.RetryAfter > 0 {
+		return fmt.Sprintf("unexpected status %d (retry after %s)", e.StatusCode, e.RetryAfter)
+	}
+	return fmt.Sprintf("unexpected status %d", e.StatusCode)
+}
+
+// DeferredError is returned without contacting the server when its host
+// is still blocked by an earlier Retry-After.
+type DeferredError struct {
+	Host  string
+	Until time.Time
+}
+
+func (e *DeferredError) Error() string {
+	return fmt.Sprintf("host %s is blocked until %s", e.Host, e.Until.Format(time.RFC3339))
+}
+
+type FetchResult struct {
+	NotModified bool
+	// URL is where the body came from after redirects.
+	URL          string
+	ContentType  string
+	Body         []byte
+	ETag         string
+	LastModified string
+	// Hub and Self are taken from the Link response header, which WebSub
+	// publishers may use instead of in-document links.
+	Hub  string
+	Self string
+}
+
+// Accept headers for feeds, which NewFetcher asks for, and web pages.
+const (
+	FeedAccept = "application/rss+xml, application/atom+xml, application/xml;q=0.9, text/xml;q=0.8, */*;q=0.5"
+	HTMLAccept = "text/html, application/xhtml+xml;q=0.9, */*;q=0.5"
+)
+
+// Fetcher performs conditional GETs and spaces out requests to the same host.
+type Fetcher struct {
+	client    *http.Client
+	limiter   *HostLimiter
+	userAgent string
+	accept    string
+}
+
+func NewFetcher(client *http.Client, limiter *HostLimiter, userAgent string) *Fetcher {
+	return &Fetcher{
+		client:    client,
+		limiter:   limiter,
+		userAgent: userAgent,
+		accept:    FeedAccept,
+	}
+}
+
+// WithAccept returns a copy of f that sends accept as its Accept header.
+// The copy shares f's client and host limiter.
+func (f *Fetcher) WithAccept(accept string) *Fetcher {
+	c := *f
+	c.accept = accept
+	return &c
+}
+
+// Fetch downloads rawURL, sending etag and lastModified as validators.
+// A 304 response yields NotModified with the validators carried over.
+func (f *Fetcher) Fetch(ctx context.Context, rawURL, etag, lastModified string) (*FetchResult, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	if until := f.limiter.BlockedUntil(u.Host); !until.IsZero() {
+		return nil, &DeferredError{Host: u.Host, Until: until}
+	}
+	if err := f.limiter.Wait(ctx, u.Host); err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", f.userAgent)
+	req.Header.Set("Accept", f.accept)
+	if etag != "" {
+		req.Header.Set("If-None-Match", etag)
+	}
+	if lastModified != "" {
+		req.Header.Set("If-Modified-Since", lastModified)
+	}
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotModified:
+		res := &FetchResult{NotModified: true, ETag: etag, LastModified: lastModified}
+		if v := resp.Header.Get("ETag"); v != "" {
+			res.ETag = v
+		}
+		if v := resp.Header.Get("Last-Modified"); v != "" {
+			res.LastModified = v
+		}
+		return res, nil
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable:
+		retryAfter := parseRetryAfter(resp.Header.Get("Retry-After"), time.Now())
+		if retryAfter > 0 {
+			f.limiter.Block(u.Host, time.Now().Add(retryAfter))
+		}
+		return nil, &StatusError{StatusCode: resp.StatusCode, RetryAfter: retryAfter}
+	case resp.StatusCode < 200 || resp.StatusCode > 299:
+		return nil, &StatusError{StatusCode: resp.StatusCode}
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxFeedSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(body) > maxFeedSize {
+		return nil, fmt.Errorf("feed is larger than %d bytes", maxFeedSize)
+	}
+	links := ParseLinkHeader(resp.Header.Values("Link"))
+	return &FetchResult{
+		URL:          resp.Request.URL.String(),
+		ContentType:  resp.Header.Get("Content-Type"),
+		Body:         body,
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+		Hub:          links["hub"],
+		Self:         links["self"],
+	}, nil
+}
+
+// ParseLinkHeader maps each rel of an RFC 8288 Link header to the first
+// target that declares it.
+func ParseLinkHeader(values []string) map[string]string {
+	out := map[string]string{}
+	for _, v := range values {
+		for _, link := range strings.Split(v, ",") {
+			parts := strings.Split(link, ";")
+			target := strings.TrimSpace(parts[0])
+			if !strings.HasPrefix(target, "<") || !strings.HasSuffix(target, ">") {
+				continue
+			}
+			target = target[1 : len(target)-1]
+			for _, param := range parts[1:] {
+				key, val, ok := strings.Cut(strings.TrimSpace(param), "=")
+				if !ok || !strings.EqualFold(strings.TrimSpace(key), "rel") {
+					continue
+				}
+				for _, rel := range strings.Fields(strings.Trim(strings.TrimSpace(val), `"`)) {
+					rel = strings.ToLower(rel)
+					if _, seen := out[rel]; !seen {
+						out[rel] = target
+					}
+				}
+			}
+		}
+	}
+	return out
+}
+
+// parseRetryAfter accepts both forms allowed by RFC 9110: delay-seconds
+// and an HTTP-date.
+func parseRetryAfter(v string, now time.Time) time.Duration {
+	if v == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(v); err == nil {
+		if secs < 0 {
+			return 0
+		}
+		return time.Duration(secs) * time.Second
+	}
+	if t, err := http.ParseTime(v); err == nil && t.After(now) {
+		return t.Sub(now)
+	}
+	return 0
+}
@@ -0,0 +1,74 @@
+package scraper
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// HostLimiter keeps at least interval between two requests to the same
+// host. Hosts can also be blocked for a while, e.g. after a Retry-After.
+type HostLimiter struct {
+	mu       sync.Mutex
+	interval time.Duration
+	next     map[string]time.Time
+	blocked  map[string]time.Time
+}
+
+func NewHostLimiter(interval time.Duration) *HostLimiter {
+	return &HostLimiter{
+		interval: interval,
+		next:     make(map[string]time.Time),
+		blocked:  make(map[string]time.Time),
+	}
+}
+
+// Wait blocks until host may be contacted again or ctx is done.
+func (l *HostLimiter) Wait(ctx context.Context, host string) error {
+	l.mu.Lock()
+	now := time.Now()
+	at := l.next[host]
+	if at.Before(now) {
+		at = now
+	}
+	l.next[host] = at.Add(l.interval)
+	l.mu.Unlock()
+
+	wait := time.Until(at)
+	if wait <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// Block prevents requests to host before until.
+func (l *HostLimiter) Block(host string, until time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if until.After(l.blocked[host]) {
+		l.blocked[host] = until
+	}
+}
+
+// BlockedUntil reports when host may be contacted again, or the zero time
+// if it is not blocked.
+func (l *HostLimiter) BlockedUntil(host string) time.Time {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	until, ok := l.blocked[host]
+	if !ok {
+		return time.Time{}
+	}
+	if !until.After(time.Now()) {
+		delete(l.blocked, host)
+		return time.Time{}
+	}
+	return until
+}
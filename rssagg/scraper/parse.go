@@ -0,0 +1,220 @@
+package scraper
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"strings"
+	"time"
+
+	"golang.org/x/net/html/charset"
+)
+
+const (
+	FormatRSS  = "rss"
+	FormatAtom = "atom"
+)
+
+// ParsedFeed is the format-independent view of an RSS or Atom document.
+type ParsedFeed struct {
+	// Format is FormatRSS or FormatAtom.
+	Format string
+	Title  string
+	Link   string
+	// Hub and Self come from rel="hub" and rel="self" links and are used
+	// for WebSub discovery.
+	Hub   string
+	Self  string
+	Items []Item
+}
+
+type Item struct {
+	GUID        string
+	Title       string
+	Link        string
+	Description string
+	PublishedAt time.Time
+}
+
+type rssDoc struct {
+	Channel struct {
+		Title string    `xml:"title"`
+		Links []rssLink `xml:"link"`
+		Items []rssItem `xml:"item"`
+	} `xml:"channel"`
+}
+
+// rssLink matches both the channel's <link> and embedded <atom:link>
+// elements, which carry hub and self references.
+type rssLink struct {
+	XMLName xml.Name
+	Href    string `xml:"href,attr"`
+	Rel     string `xml:"rel,attr"`
+	Value   string `xml:",chardata"`
+}
+
+type rssItem struct {
+	Title       string `xml:"title"`
+	Link        string `xml:"link"`
+	GUID        string `xml:"guid"`
+	Description string `xml:"description"`
+	Content     string `xml:"http://purl.org/rss/1.0/modules/content/ encoded"`
+	PubDate     string `xml:"pubDate"`
+	Date        string `xml:"http://purl.org/dc/elements/1.1/ date"`
+}
+
+type atomDoc struct {
+	Title   string      `xml:"title"`
+	Links   []atomLink  `xml:"link"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr"`
+	Type string `xml:"type,attr"`
+}
+
+type atomEntry struct {
+	ID        string     `xml:"id"`
+	Title     string     `xml:"title"`
+	Links     []atomLink `xml:"link"`
+	Summary   string     `xml:"summary"`
+	Content   string     `xml:"content"`
+	Published string     `xml:"published"`
+	Updated   string     `xml:"updated"`
+}
+
+// ParseFeed decodes an RSS 2.0 or Atom 1.0 document.
+func ParseFeed(data []byte) (*ParsedFeed, error) {
+	var root struct {
+		XMLName xml.Name
+	}
+	if err := decodeXML(data, &root); err != nil {
+		return nil, err
+	}
+
+	switch root.XMLName.Local {
+	case "rss":
+		var doc rssDoc
+		if err := decodeXML(data, &doc); err != nil {
+			return nil, err
+		}
+		feed := &ParsedFeed{Format: FormatRSS, Title: strings.TrimSpace(doc.Channel.Title)}
+		var atomLinks []atomLink
+		for _, l := range doc.Channel.Links {
+			if l.XMLName.Space == atomNS {
+				atomLinks = append(atomLinks, atomLink{Href: l.Href, Rel: l.Rel})
+			} else if feed.Link == "" {
+				feed.Link = strings.TrimSpace(l.Value)
+			}
+		}
+		feed.Hub = atomRel(atomLinks, "hub")
+		feed.Self = atomRel(atomLinks, "self")
+		for _, it := range doc.Channel.Items {
+			item := Item{
+				GUID:        strings.TrimSpace(it.GUID),
+				Title:       strings.TrimSpace(it.Title),
+				Link:        strings.TrimSpace(it.Link),
+				Description: it.Description,
+				PublishedAt: parseDate(it.PubDate, it.Date),
+			}
+			if it.Content != "" {
+				item.Description = it.Content
+			}
+			if item.GUID == "" {
+				item.GUID = item.Link
+			}
+			feed.Items = append(feed.Items, item)
+		}
+		return feed, nil
+	case "feed":
+		var doc atomDoc
+		if err := decodeXML(data, &doc); err != nil {
+			return nil, err
+		}
+		feed := &ParsedFeed{
+			Format: FormatAtom,
+			Title:  strings.TrimSpace(doc.Title),
+			Link:   atomAlternate(doc.Links),
+			Hub:    atomRel(doc.Links, "hub"),
+			Self:   atomRel(doc.Links, "self"),
+		}
+		for _, e := range doc.Entries {
+			item := Item{
+				GUID:        strings.TrimSpace(e.ID),
+				Title:       strings.TrimSpace(e.Title),
+				Link:        atomAlternate(e.Links),
+				Description: e.Summary,
+				PublishedAt: parseDate(e.Published, e.Updated),
+			}
+			if e.Content != "" {
+				item.Description = e.Content
+			}
+			if item.GUID == "" {
+				item.GUID = item.Link
+			}
+			feed.Items = append(feed.Items, item)
+		}
+		return feed, nil
+	default:
+		return nil, fmt.Errorf("unsupported feed format <%s>", root.XMLName.Local)
+	}
+}
+
+const atomNS = "http://www.w3.org/2005/Atom"
+
+func decodeXML(data []byte, v any) error {
+	dec := xml.NewDecoder(bytes.NewReader(data))
+	dec.Strict = false
+	dec.CharsetReader = charset.NewReaderLabel
+	return dec.Decode(v)
+}
+
+func atomAlternate(links []atomLink) string {
+	for _, l := range links {
+		if l.Rel == "" || l.Rel == "alternate" {
+			return strings.TrimSpace(l.Href)
+		}
+	}
+	return ""
+}
+
+func atomRel(links []atomLink, rel string) string {
+	for _, l := range links {
+		if l.Rel == rel {
+			return strings.TrimSpace(l.Href)
+		}
+	}
+	return ""
+}
+
+var dateLayouts = []string{
+	time.RFC1123Z,
+	time.RFC1123,
+	time.RFC3339,
+	time.RFC3339Nano,
+	"Mon, 2 Jan 2006 15:04:05 -0700",
+	"Mon, 2 Jan 2006 15:04:05 MST",
+	"2 Jan 2006 15:04:05 -0700",
+	time.RFC822Z,
+	time.RFC822,
+	"2006-01-02T15:04:05",
+	"2006-01-02",
+}
+
+// parseDate returns the first candidate that parses with a known layout.
+func parseDate(candidates ...string) time.Time {
+	for _, c := range candidates {
+		c = strings.TrimSpace(c)
+		if c == "" {
+			continue
+		}
+		for _, layout := range dateLayouts {
+			if t, err := time.Parse(layout, c); err == nil {
+				return t.UTC()
+			}
+		}
+	}
+	return time.Time{}
+}
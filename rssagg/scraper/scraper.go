@@ -0,0 +1,233 @@
+package scraper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"golang/rssagg/database"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+type Config struct {
+	// PollInterval is how often the scraper looks for feeds that are due.
+	PollInterval time.Duration
+	// RefreshInterval is the delay between two fetches of a healthy feed.
+	RefreshInterval time.Duration
+	// PushRefreshInterval replaces RefreshInterval while a feed has an
+	// active WebSub lease; polling then only guards against lost pushes.
+	PushRefreshInterval time.Duration
+	Concurrency         int
+	// HostInterval is the minimum delay between requests to one host.
+	HostInterval time.Duration
+	// MinBackoff and MaxBackoff bound the exponential backoff of failing feeds.
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+	// MaxErrors consecutive failures disable a feed. Zero never disables.
+	MaxErrors int
+	UserAgent string
+	// AllowPrivate lets the scraper reach loopback and private addresses.
+	// Feed URLs are chosen by users, so it is off by default.
+	AllowPrivate bool
+}
+
+func DefaultConfig() Config {
+	return Config{
+		PollInterval:        time.Minute,
+		RefreshInterval:     30 * time.Minute,
+		PushRefreshInterval: 12 * time.Hour,
+		Concurrency:         10,
+		HostInterval:        2 * time.Second,
+		MinBackoff:          5 * time.Minute,
+		MaxBackoff:          24 * time.Hour,
+		MaxErrors:           10,
+		UserAgent:           "rssagg/1.0 (+https://github.com/Blockchain-ZJY/golang)",
+	}
+}
+
+// ItemHandler receives the parsed content of a feed after a successful
+// (non-304) fetch.
+type ItemHandler func(ctx context.Context, feed database.Feed, parsed *ParsedFeed) error
+
+type Scraper struct {
+	db      *database.DB
+	fetcher *Fetcher
+	cfg     Config
+	handle  ItemHandler
+
+	mu      sync.Mutex
+	lastRun time.Time
+	lastErr error
+}
+
+func New(db *database.DB, cfg Config, handle ItemHandler) *Scraper {
+	client := PublicClient(30 * time.Second)
+	if cfg.AllowPrivate {
+		client = &http.Client{Timeout: 30 * time.Second}
+	}
+	return &Scraper{
+		db:      db,
+		fetcher: NewFetcher(client, NewHostLimiter(cfg.HostInterval), cfg.UserAgent),
+		cfg:     cfg,
+		handle:  handle,
+	}
+}
+
+// Start scrapes due feeds every PollInterval until ctx is cancelled.
+func (s *Scraper) Start(ctx context.Context) {
+	log.Printf("Scraping every %s with %d workers", s.cfg.PollInterval, s.cfg.Concurrency)
+	ticker := time.NewTicker(s.cfg.PollInterval)
+	defer ticker.Stop()
+	for {
+		err := s.ScrapeDue(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			log.Printf("Scraper: %v", err)
+		}
+		s.mu.Lock()
+		s.lastRun, s.lastErr = time.Now(), err
+		s.mu.Unlock()
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Check is a health check: it fails when the last pass failed or when no
+// pass finished for three poll intervals.
+func (s *Scraper) Check(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.lastRun.IsZero() {
+		return fmt.Errorf("no scrape pass has finished yet")
+	}
+	if s.lastErr != nil {
+		return fmt.Errorf("last pass failed: %v", s.lastErr)
+	}
+	if since := time.Since(s.lastRun); since > 3*s.cfg.PollInterval {
+		return fmt.Errorf("last pass finished %s ago", since.Round(time.Second))
+	}
+	return nil
+}
+
+// ScrapeDue fetches every feed whose next fetch time has passed.
+func (s *Scraper) ScrapeDue(ctx context.Context) error {
+	feeds, err := s.db.GetFeedsDue(ctx, time.Now(), s.cfg.Concurrency*10)
+	if err != nil {
+		return err
+	}
+
+	sem := make(chan struct{}, s.cfg.Concurrency)
+	var wg sync.WaitGroup
+	for _, feed := range feeds {
+		feed := feed
+		sem <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			if err := s.ScrapeFeed(ctx, feed); err != nil {
+				log.Printf("Feed %s (%s): %v", feed.Name, feed.URL, err)
+			}
+		}()
+	}
+	wg.Wait()
+	return nil
+}
+
+// ScrapeFeed fetches a single feed and updates its fetch state. Failures
+// are recorded on the feed and returned.
+func (s *Scraper) ScrapeFeed(ctx context.Context, feed database.Feed) error {
+	now := time.Now()
+	res, err := s.fetcher.Fetch(ctx, feed.URL, feed.ETag, feed.LastModified)
+	if err == nil && !res.NotModified {
+		var parsed *ParsedFeed
+		parsed, err = ParseFeed(res.Body)
+		if err != nil {
+			err = fmt.Errorf("parse feed: %w", err)
+		} else {
+			if res.Hub != "" {
+				parsed.Hub = res.Hub
+			}
+			if res.Self != "" {
+				parsed.Self = res.Self
+			}
+			if s.handle != nil {
+				err = s.handle(ctx, feed, parsed)
+			}
+		}
+	}
+	if ctx.Err() != nil {
+		// Shutting down; this is not the feed's fault.
+		return ctx.Err()
+	}
+
+	var deferred *DeferredError
+	if errors.As(err, &deferred) {
+		return s.db.DeferFeed(ctx, feed.ID, deferred.Until)
+	}
+	if err != nil {
+		if merr := s.markFailed(ctx, feed, now, err); merr != nil {
+			return merr
+		}
+		return err
+	}
+
+	return s.db.MarkFeedFetched(ctx, database.MarkFeedFetchedParams{
+		ID:           feed.ID,
+		FetchedAt:    now,
+		NextFetchAt:  s.nextFetch(feed, now),
+		ETag:         res.ETag,
+		LastModified: res.LastModified,
+	})
+}
+
+func (s *Scraper) nextFetch(feed database.Feed, now time.Time) time.Time {
+	if !feed.PushExpiresAt.After(now) {
+		return now.Add(s.cfg.RefreshInterval)
+	}
+	next := now.Add(s.cfg.PushRefreshInterval)
+	if next.After(feed.PushExpiresAt) {
+		next = feed.PushExpiresAt
+	}
+	return next
+}
+
+func (s *Scraper) markFailed(ctx context.Context, feed database.Feed, now time.Time, fetchErr error) error {
+	count := feed.ErrorCount + 1
+	delay := s.backoff(count)
+	var statusErr *StatusError
+	if errors.As(fetchErr, &statusErr) && statusErr.RetryAfter > delay {
+		delay = statusErr.RetryAfter
+	}
+	disable := s.cfg.MaxErrors > 0 && count >= s.cfg.MaxErrors
+	if disable {
+		log.Printf("Disabling feed %s after %d consecutive errors", feed.URL, count)
+	}
+	return s.db.MarkFeedFailed(ctx, database.MarkFeedFailedParams{
+		ID:          feed.ID,
+		FailedAt:    now,
+		NextFetchAt: now.Add(delay),
+		ErrorCount:  count,
+		Error:       fetchErr.Error(),
+		Disable:     disable,
+	})
+}
+
+// backoff doubles MinBackoff for every consecutive error, up to MaxBackoff.
+func (s *Scraper) backoff(errorCount int) time.Duration {
+	d := s.cfg.MinBackoff
+	for i := 1; i < errorCount && d < s.cfg.MaxBackoff; i++ {
+		d *= 2
+	}
+	if d > s.cfg.MaxBackoff {
+		d = s.cfg.MaxBackoff
+	}
+	return d
+}
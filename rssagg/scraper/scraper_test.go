@@ -0,0 +1,261 @@
+package scraper
+
+import (
+	"context"
+	"golang/rssagg/database"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testRSS = `<?xml version="1.0" encoding="UTF-8"?>
+<rss version="2.0"><channel>
+<title>Test feed</title><link>https://example.com/</link>
+<item><title>First</title><link>https://example.com/1</link><guid>1</guid>
+<description>hello</description><pubDate>Mon, 02 Jan 2006 15:04:05 -0700</pubDate></item>
+<item><title>Second</title><link>https://example.com/2</link></item>
+</channel></rss>`
+
+func newTestDB(t *testing.T) *database.DB {
+	t.Helper()
+	db, err := database.Open(filepath.Join(t.TempDir(), "test.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+	_, err = db.Migrate(context.Background())
+	require.NoError(t, err)
+	return db
+}
+
+func testConfig() Config {
+	cfg := DefaultConfig()
+	cfg.HostInterval = 0
+	cfg.MinBackoff = time.Minute
+	cfg.MaxBackoff = time.Hour
+	cfg.MaxErrors = 3
+	cfg.AllowPrivate = true
+	return cfg
+}
+
+func createFeed(t *testing.T, db *database.DB, url string) database.Feed {
+	t.Helper()
+	feed, err := db.CreateFeed(context.Background(), database.CreateFeedParams{ID: url, Name: "test", URL: url})
+	require.NoError(t, err)
+	return feed
+}
+
+func TestConditionalGet(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		w.Header().Set("Last-Modified", "Mon, 02 Jan 2006 15:04:05 GMT")
+		w.Write([]byte(testRSS))
+	}))
+	defer srv.Close()
+
+	db := newTestDB(t)
+	ctx := context.Background()
+	feed := createFeed(t, db, srv.URL)
+
+	var calls int
+	s := New(db, testConfig(), func(ctx context.Context, feed database.Feed, parsed *ParsedFeed) error {
+		calls++
+		assert.Len(t, parsed.Items, 2)
+		return nil
+	})
+
+	require.NoError(t, s.ScrapeFeed(ctx, feed))
+	feed, err := db.GetFeed(ctx, feed.ID)
+	require.NoError(t, err)
+	assert.Equal(t, `"v1"`, feed.ETag)
+	assert.Equal(t, "Mon, 02 Jan 2006 15:04:05 GMT", feed.LastModified)
+
+	require.NoError(t, s.ScrapeFeed(ctx, feed))
+	assert.Equal(t, 1, calls, "a 304 must not be handed to the item handler")
+	feed, err = db.GetFeed(ctx, feed.ID)
+	require.NoError(t, err)
+	assert.Equal(t, `"v1"`, feed.ETag)
+	assert.False(t, feed.LastFetchedAt.IsZero())
+}
+
+func TestRetryAfter(t *testing.T) {
+	var hits int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		w.Header().Set("Retry-After", "7200")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer srv.Close()
+
+	db := newTestDB(t)
+	ctx := context.Background()
+	feed := createFeed(t, db, srv.URL)
+	other := createFeed(t, db, srv.URL+"/other")
+	s := New(db, testConfig(), nil)
+
+	assert.Error(t, s.ScrapeFeed(ctx, feed))
+	feed, err := db.GetFeed(ctx, feed.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 1, feed.ErrorCount)
+	assert.Contains(t, feed.LastError, "429")
+	assert.True(t, feed.NextFetchAt.After(time.Now().Add(time.Hour)))
+
+	// The host is blocked, so the other feed is deferred without a request.
+	require.NoError(t, s.ScrapeFeed(ctx, other))
+	assert.Equal(t, int32(1), atomic.LoadInt32(&hits))
+	other, err = db.GetFeed(ctx, other.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 0, other.ErrorCount)
+	assert.True(t, other.NextFetchAt.After(time.Now().Add(time.Hour)))
+}
+
+func TestBackoffAndDisable(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+
+	db := newTestDB(t)
+	ctx := context.Background()
+	feed := createFeed(t, db, srv.URL)
+	s := New(db, testConfig(), nil)
+
+	for i := 1; i <= 3; i++ {
+		before := time.Now()
+		assert.Error(t, s.ScrapeFeed(ctx, feed))
+		var err error
+		feed, err = db.GetFeed(ctx, feed.ID)
+		require.NoError(t, err)
+		assert.Equal(t, i, feed.ErrorCount)
+		assert.Equal(t, "unexpected status 500", feed.LastError)
+		assert.WithinDuration(t, before.Add(s.backoff(i)), feed.NextFetchAt, 5*time.Second)
+	}
+	assert.True(t, feed.Disabled)
+
+	due, err := db.GetFeedsDue(ctx, time.Now().Add(48*time.Hour), 10)
+	require.NoError(t, err)
+	assert.Empty(t, due)
+}
+
+func TestPrivateFeed(t *testing.T) {
+	var hits int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+	}))
+	defer srv.Close()
+
+	db := newTestDB(t)
+	ctx := context.Background()
+	feed := createFeed(t, db, srv.URL)
+	cfg := testConfig()
+	cfg.AllowPrivate = false
+	s := New(db, cfg, nil)
+
+	assert.ErrorIs(t, s.ScrapeFeed(ctx, feed), ErrPrivateAddress)
+	assert.Equal(t, int32(0), atomic.LoadInt32(&hits))
+	feed, err := db.GetFeed(ctx, feed.ID)
+	require.NoError(t, err)
+	assert.Contains(t, feed.LastError, "not public")
+}
+
+func TestBackoff(t *testing.T) {
+	s := &Scraper{cfg: Config{MinBackoff: time.Minute, MaxBackoff: 10 * time.Minute}}
+	assert.Equal(t, time.Minute, s.backoff(1))
+	assert.Equal(t, 2*time.Minute, s.backoff(2))
+	assert.Equal(t, 8*time.Minute, s.backoff(4))
+	assert.Equal(t, 10*time.Minute, s.backoff(5))
+	assert.Equal(t, 10*time.Minute, s.backoff(50))
+}
+
+func TestHostLimiter(t *testing.T) {
+	l := NewHostLimiter(50 * time.Millisecond)
+	ctx := context.Background()
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		require.NoError(t, l.Wait(ctx, "example.com"))
+	}
+	assert.GreaterOrEqual(t, time.Since(start), 100*time.Millisecond)
+
+	start = time.Now()
+	require.NoError(t, l.Wait(ctx, "other.example.com"))
+	assert.Less(t, time.Since(start), 50*time.Millisecond)
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	assert.Equal(t, 120*time.Second, parseRetryAfter("120", now))
+	assert.Equal(t, time.Hour, parseRetryAfter(now.Add(time.Hour).Format(http.TimeFormat), now))
+	assert.Equal(t, time.Duration(0), parseRetryAfter("soon", now))
+}
+
+func TestParseAtom(t *testing.T) {
+	parsed, err := ParseFeed([]byte(`<?xml version="1.0" encoding="utf-8"?>
+<feed xmlns="http://www.w3.org/2005/Atom">
+<title>Atom feed</title><link rel="self" href="https://example.com/atom"/><link href="https://example.com/"/>
+<entry><id>urn:1</id><title>Entry</title><link href="https://example.com/e1"/>
+<updated>2024-01-02T03:04:05Z</updated><summary>sum</summary></entry>
+</feed>`))
+	require.NoError(t, err)
+	assert.Equal(t, "Atom feed", parsed.Title)
+	assert.Equal(t, "https://example.com/", parsed.Link)
+	require.Len(t, parsed.Items, 1)
+	assert.Equal(t, "urn:1", parsed.Items[0].GUID)
+	assert.Equal(t, "https://example.com/e1", parsed.Items[0].Link)
+	assert.Equal(t, time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC), parsed.Items[0].PublishedAt)
+}
+
+func TestParseRSSHubLinks(t *testing.T) {
+	parsed, err := ParseFeed([]byte(`<rss version="2.0" xmlns:atom="http://www.w3.org/2005/Atom"><channel>
+<title>t</title>
+<atom:link rel="hub" href="https://hub.example/"/>
+<link>https://example.com/</link>
+<atom:link rel="self" href="https://example.com/feed.xml" type="application/rss+xml"/>
+</channel></rss>`))
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.com/", parsed.Link)
+	assert.Equal(t, "https://hub.example/", parsed.Hub)
+	assert.Equal(t, "https://example.com/feed.xml", parsed.Self)
+}
+
+func TestParseLinkHeader(t *testing.T) {
+	links := ParseLinkHeader([]string{
+		`<https://hub.example/>; rel="hub", <https://example.com/feed>; rel=self`,
+		`<https://other-hub.example/>; rel="hub"`,
+	})
+	assert.Equal(t, "https://hub.example/", links["hub"])
+	assert.Equal(t, "https://example.com/feed", links["self"])
+}
+
+func TestPublicClient(t *testing.T) {
+	for addr, want := range map[string]bool{
+		"93.184.216.34":   true,
+		"2606:4700::1111": true,
+		"127.0.0.1":       false,
+		"10.1.2.3":        false,
+		"172.16.0.1":      false,
+		"192.168.1.1":     false,
+		"169.254.169.254": false,
+		"100.64.0.1":      false,
+		"0.0.0.0":         false,
+		"::1":             false,
+		"fe80::1":         false,
+		"fd00::1":         false,
+		"::ffff:10.0.0.1": false,
+	} {
+		assert.Equal(t, want, IsPublic(netip.MustParseAddr(addr)), addr)
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+	_, err := PublicClient(time.Second).Get(srv.URL)
+	assert.ErrorIs(t, err, ErrPrivateAddress)
+}
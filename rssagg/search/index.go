@@ -0,0 +1,329 @@
+package search
+
+import (
+	"errors"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+var ErrEmptyQuery = errors.New("query has no searchable terms")
+
+// Document is the searchable view of a post.
+type Document struct {
+	ID          string
+	FeedID      string
+	PublishedAt time.Time
+	Title       string
+	Body        string
+}
+
+type field int
+
+const (
+	fieldTitle field = iota
+	fieldBody
+	numFields
+)
+
+// Title matches count for more than body matches.
+var fieldWeights = [numFields]float64{2.5, 1}
+
+const (
+	bm25K1 = 1.2
+	bm25B  = 0.75
+)
+
+type hit struct {
+	positions [numFields][]int
+}
+
+type docInfo struct {
+	id          string
+	feedID      string
+	publishedAt time.Time
+	length      [numFields]int
+	terms       []string
+}
+
+// Index is an in-memory positional inverted index. It is safe for
+// concurrent use.
+type Index struct {
+	mu       sync.RWMutex
+	nextID   uint32
+	ids      map[string]uint32
+	docs     map[uint32]*docInfo
+	postings map[string]map[uint32]*hit
+	totalLen [numFields]int
+}
+
+func NewIndex() *Index {
+	return &Index{
+		ids:      make(map[string]uint32),
+		docs:     make(map[uint32]*docInfo),
+		postings: make(map[string]map[uint32]*hit),
+	}
+}
+
+// Add indexes doc, replacing any earlier version with the same ID.
+func (ix *Index) Add(doc Document) {
+	fields := [numFields][]Token{indexTokens(doc.Title), indexTokens(doc.Body)}
+
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	ix.remove(doc.ID)
+
+	ix.nextID++
+	n := ix.nextID
+	info := &docInfo{id: doc.ID, feedID: doc.FeedID, publishedAt: doc.PublishedAt}
+	seen := map[string]bool{}
+	for f, tokens := range fields {
+		// Unigrams share positions, so the length is the positions used.
+		if len(tokens) > 0 {
+			info.length[f] = tokens[len(tokens)-1].Pos + 1
+		}
+		ix.totalLen[f] += info.length[f]
+		for _, tok := range tokens {
+			docs := ix.postings[tok.Term]
+			if docs == nil {
+				docs = make(map[uint32]*hit)
+				ix.postings[tok.Term] = docs
+			}
+			h := docs[n]
+			if h == nil {
+				h = &hit{}
+				docs[n] = h
+			}
+			h.positions[f] = append(h.positions[f], tok.Pos)
+			if !seen[tok.Term] {
+				seen[tok.Term] = true
+				info.terms = append(info.terms, tok.Term)
+			}
+		}
+	}
+	ix.ids[doc.ID] = n
+	ix.docs[n] = info
+}
+
+func (ix *Index) Remove(id string) {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	ix.remove(id)
+}
+
+func (ix *Index) remove(id string) {
+	n, ok := ix.ids[id]
+	if !ok {
+		return
+	}
+	info := ix.docs[n]
+	for _, term := range info.terms {
+		delete(ix.postings[term], n)
+		if len(ix.postings[term]) == 0 {
+			delete(ix.postings, term)
+		}
+	}
+	for f := range info.length {
+		ix.totalLen[f] -= info.length[f]
+	}
+	delete(ix.docs, n)
+	delete(ix.ids, id)
+}
+
+// Reset drops every document.
+func (ix *Index) Reset() {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	ix.ids = make(map[string]uint32)
+	ix.docs = make(map[uint32]*docInfo)
+	ix.postings = make(map[string]map[uint32]*hit)
+	ix.totalLen = [numFields]int{}
+}
+
+func (ix *Index) Len() int {
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+	return len(ix.docs)
+}
+
+type Query struct {
+	// Text holds whitespace separated terms, all of which must match.
+	// "Quoted text" must match as a phrase.
+	Text    string
+	FeedIDs []string
+	// From is inclusive and To exclusive; zero values leave the range open.
+	From   time.Time
+	To     time.Time
+	Limit  int
+	Offset int
+}
+
+type Result struct {
+	ID    string
+	Score float64
+}
+
+// Search returns one page of matching documents ordered by relevance, and
+// the total number of matches.
+func (ix *Index) Search(q Query) ([]Result, int, error) {
+	clauses := parseQuery(q.Text)
+	if len(clauses) == 0 {
+		return nil, 0, ErrEmptyQuery
+	}
+
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+
+	// Start from the rarest clause to keep the candidate set small.
+	sort.SliceStable(clauses, func(i, j int) bool {
+		return len(ix.postings[clauses[i][0]]) < len(ix.postings[clauses[j][0]])
+	})
+
+	feeds := map[string]bool{}
+	for _, id := range q.FeedIDs {
+		feeds[id] = true
+	}
+
+	var results []Result
+	for n := range ix.postings[clauses[0][0]] {
+		info := ix.docs[n]
+		if len(feeds) > 0 && !feeds[info.feedID] {
+			continue
+		}
+		if !q.From.IsZero() && info.publishedAt.Before(q.From) {
+			continue
+		}
+		if !q.To.IsZero() && !info.publishedAt.Before(q.To) {
+			continue
+		}
+		if !ix.matchesAll(n, clauses) {
+			continue
+		}
+		results = append(results, Result{ID: info.id, Score: ix.score(n, clauses)})
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		pi, pj := ix.docs[ix.ids[results[i].ID]].publishedAt, ix.docs[ix.ids[results[j].ID]].publishedAt
+		if !pi.Equal(pj) {
+			return pi.After(pj)
+		}
+		return results[i].ID < results[j].ID
+	})
+
+	total := len(results)
+	if q.Offset >= total {
+		return []Result{}, total, nil
+	}
+	results = results[q.Offset:]
+	if q.Limit > 0 && len(results) > q.Limit {
+		results = results[:q.Limit]
+	}
+	return results, total, nil
+}
+
+func (ix *Index) matchesAll(n uint32, clauses [][]string) bool {
+	for _, clause := range clauses {
+		if !ix.matches(n, clause) {
+			return false
+		}
+	}
+	return true
+}
+
+// matches reports whether the clause terms occur consecutively in a single
+// field of document n.
+func (ix *Index) matches(n uint32, clause []string) bool {
+	hits := make([]*hit, len(clause))
+	for i, term := range clause {
+		h := ix.postings[term][n]
+		if h == nil {
+			return false
+		}
+		hits[i] = h
+	}
+	if len(clause) == 1 {
+		return true
+	}
+	for f := field(0); f < numFields; f++ {
+	start:
+		for _, p := range hits[0].positions[f] {
+			for i := 1; i < len(hits); i++ {
+				if !containsSorted(hits[i].positions[f], p+i) {
+					continue start
+				}
+			}
+			return true
+		}
+	}
+	return false
+}
+
+func containsSorted(positions []int, p int) bool {
+	i := sort.SearchInts(positions, p)
+	return i < len(positions) && positions[i] == p
+}
+
+// score is a BM25F-style sum over the query terms, with per-field length
+// normalization and weights.
+func (ix *Index) score(n uint32, clauses [][]string) float64 {
+	info := ix.docs[n]
+	total := float64(len(ix.docs))
+	var score float64
+	for _, clause := range clauses {
+		for _, term := range clause {
+			docs := ix.postings[term]
+			h := docs[n]
+			df := float64(len(docs))
+			idf := math.Log(1 + (total-df+0.5)/(df+0.5))
+			var tf float64
+			for f := field(0); f < numFields; f++ {
+				freq := float64(len(h.positions[f]))
+				if freq == 0 {
+					continue
+				}
+				avg := float64(ix.totalLen[f]) / total
+				norm := 1.0
+				if avg > 0 {
+					norm = 1 - bm25B + bm25B*float64(info.length[f])/avg
+				}
+				tf += fieldWeights[f] * freq / (freq + bm25K1*norm)
+			}
+			score += idf * tf * (bm25K1 + 1)
+		}
+	}
+	return score
+}
+
+// parseQuery splits text into clauses. Every clause is a list of terms
+// that must appear consecutively; single-term clauses are plain terms.
+func parseQuery(text string) [][]string {
+	var clauses [][]string
+	add := func(s string) {
+		tokens := Tokenize(s)
+		if len(tokens) == 0 {
+			return
+		}
+		clause := make([]string, len(tokens))
+		for i, t := range tokens {
+			clause[i] = t.Term
+		}
+		clauses = append(clauses, clause)
+	}
+
+	parts := strings.Split(text, `"`)
+	for i, part := range parts {
+		if i%2 == 1 {
+			add(part)
+			continue
+		}
+		for _, word := range strings.Fields(part) {
+			add(word)
+		}
+	}
+	return clauses
+}
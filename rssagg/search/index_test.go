@@ -0,0 +1,132 @@
+package search
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func terms(tokens []Token) []string {
+	out := make([]string, len(tokens))
+	for i, t := range tokens {
+		out[i] = t.Term
+	}
+	return out
+}
+
+func ids(results []Result) []string {
+	out := make([]string, len(results))
+	for i, r := range results {
+		out[i] = r.ID
+	}
+	return out
+}
+
+func TestTokenize(t *testing.T) {
+	assert.Equal(t, []string{"run", "dog", "quick"}, terms(Tokenize("Running dogs, QUICKLY!")))
+	assert.Equal(t, []string{"搜索", "索引", "引擎", "go"}, terms(Tokenize("搜索引擎 Go")))
+	assert.Equal(t, []string{"中", "go"}, terms(Tokenize("中 go")))
+	assert.Equal(t, []Token{{"读", 0}, {"读书", 0}, {"书", 0}, {"go", 1}}, indexTokens("读书 go"))
+	// Full-width latin is folded to ASCII.
+	assert.Equal(t, []string{"golang"}, terms(Tokenize("ＧＯＬＡＮＧ")))
+}
+
+func newTestIndex() *Index {
+	ix := NewIndex()
+	day := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	ix.Add(Document{ID: "a", FeedID: "f1", PublishedAt: day, Title: "Go generics explained", Body: "Type parameters arrived in Go 1.18."})
+	ix.Add(Document{ID: "b", FeedID: "f1", PublishedAt: day.AddDate(0, 0, 1), Title: "Weekly links", Body: "Someone explained generics in Rust and in Go."})
+	ix.Add(Document{ID: "c", FeedID: "f2", PublishedAt: day.AddDate(0, 0, 2), Title: "全文搜索引擎", Body: "我们用倒排索引实现搜索。"})
+	ix.Add(Document{ID: "d", FeedID: "f2", PublishedAt: day.AddDate(0, 0, 3), Title: "Cooking", Body: "Nothing about programming here."})
+	return ix
+}
+
+func TestSearchRanking(t *testing.T) {
+	ix := newTestIndex()
+	res, total, err := ix.Search(Query{Text: "generics go"})
+	require.NoError(t, err)
+	assert.Equal(t, 2, total)
+	assert.Equal(t, []string{"a", "b"}, ids(res), "title match ranks first")
+
+	// Stemming matches "explained" with "explain".
+	res, _, err = ix.Search(Query{Text: "explain"})
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"a", "b"}, ids(res))
+}
+
+func TestSearchPhrase(t *testing.T) {
+	ix := newTestIndex()
+	res, _, err := ix.Search(Query{Text: `"explained generics"`})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"b"}, ids(res))
+
+	res, _, err = ix.Search(Query{Text: `"generics explained"`})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a"}, ids(res))
+}
+
+func TestSearchChinese(t *testing.T) {
+	ix := newTestIndex()
+	res, _, err := ix.Search(Query{Text: "搜索"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"c"}, ids(res))
+
+	res, _, err = ix.Search(Query{Text: "倒排索引"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"c"}, ids(res))
+
+	// Both words occur, but not next to each other in this order.
+	res, _, err = ix.Search(Query{Text: "引擎搜索"})
+	require.NoError(t, err)
+	assert.Empty(t, res)
+
+	// One-character words match inside longer runs, at either end.
+	for _, q := range []string{"倒", "擎", "文"} {
+		res, _, err = ix.Search(Query{Text: q})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"c"}, ids(res), q)
+	}
+	res, _, err = ix.Search(Query{Text: "车"})
+	require.NoError(t, err)
+	assert.Empty(t, res)
+}
+
+func TestSearchFilters(t *testing.T) {
+	ix := newTestIndex()
+	res, _, err := ix.Search(Query{Text: "go", FeedIDs: []string{"f2"}})
+	require.NoError(t, err)
+	assert.Empty(t, res)
+
+	day := time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)
+	res, _, err = ix.Search(Query{Text: "go", From: day})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"b"}, ids(res))
+
+	res, _, err = ix.Search(Query{Text: "go", To: day})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a"}, ids(res))
+
+	res, total, err := ix.Search(Query{Text: "go", Limit: 1, Offset: 1})
+	require.NoError(t, err)
+	assert.Equal(t, 2, total)
+	assert.Equal(t, []string{"b"}, ids(res))
+}
+
+func TestRemoveAndReplace(t *testing.T) {
+	ix := newTestIndex()
+	ix.Remove("a")
+	res, _, err := ix.Search(Query{Text: "generics"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"b"}, ids(res))
+
+	ix.Add(Document{ID: "b", Title: "Replaced"})
+	res, _, err = ix.Search(Query{Text: "generics"})
+	require.NoError(t, err)
+	assert.Empty(t, res)
+	assert.Equal(t, 3, ix.Len())
+
+	_, _, err = ix.Search(Query{Text: " ,, "})
+	assert.ErrorIs(t, err, ErrEmptyQuery)
+}
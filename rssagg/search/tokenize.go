@@ -0,0 +1,97 @@
+package search
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/kljensen/snowball/english"
+	"golang.org/x/text/unicode/norm"
+)
+
+// Token is a normalized term and its position in the source text.
+type Token struct {
+	Term string
+	Pos  int
+}
+
+// Tokenize splits text into index terms. Latin-script words are lowercased
+// and reduced with the Snowball English stemmer. Han, kana and hangul have
+// no word separators, so each run of them is split into overlapping
+// bigrams ("搜索引擎" -> 搜索, 索引, 引擎), which lets phrase matching find
+// any substring of two or more characters.
+func Tokenize(text string) []Token {
+	return tokenize(text, false)
+}
+
+// indexTokens is Tokenize plus every character of a Han, kana or hangul
+// run as a unigram, so that one-character queries (书, 车) match inside
+// longer runs. A unigram shares the position of the bigram it starts, or
+// of the last bigram, so the positions of phrases are unchanged.
+func indexTokens(text string) []Token {
+	return tokenize(text, true)
+}
+
+func tokenize(text string, unigrams bool) []Token {
+	text = norm.NFKC.String(text)
+	var tokens []Token
+	pos := 0
+	emit := func(term string) {
+		tokens = append(tokens, Token{Term: term, Pos: pos})
+		pos++
+	}
+
+	var word []rune
+	var cjk []rune
+	flushWord := func() {
+		if len(word) == 0 {
+			return
+		}
+		w := strings.ToLower(string(word))
+		emit(english.Stem(w, true))
+		word = word[:0]
+	}
+	flushCJK := func() {
+		switch len(cjk) {
+		case 0:
+			return
+		case 1:
+			emit(string(cjk))
+		default:
+			for i := 0; i+1 < len(cjk); i++ {
+				if unigrams {
+					tokens = append(tokens, Token{Term: string(cjk[i]), Pos: pos})
+				}
+				emit(string(cjk[i : i+2]))
+			}
+			if last := len(cjk) - 1; unigrams && cjk[last] != cjk[last-1] {
+				tokens = append(tokens, Token{Term: string(cjk[last]), Pos: pos - 1})
+			}
+		}
+		cjk = cjk[:0]
+	}
+
+	for _, r := range text {
+		switch {
+		case isCJK(r):
+			flushWord()
+			cjk = append(cjk, r)
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			flushCJK()
+			word = append(word, r)
+		case r == '\'' && len(word) > 0:
+			// Keep contractions and possessives together so the stemmer
+			// can strip them.
+			word = append(word, r)
+		default:
+			flushWord()
+			flushCJK()
+		}
+	}
+	flushWord()
+	flushCJK()
+	return tokens
+}
+
+func isCJK(r rune) bool {
+	return unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul)
+}
@@ -0,0 +1,315 @@
+// Package websub implements the subscriber side of WebSub
+// (https://www.w3.org/TR/websub/).
+package websub
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"golang/rssagg/database"
+	"golang/rssagg/scraper"
+	"hash"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrUnknownSubscription means the callback does not belong to a
+	// subscription we asked for; hubs should be answered with 404.
+	ErrUnknownSubscription = errors.New("unknown subscription")
+	ErrBadSignature        = errors.New("invalid content signature")
+	ErrInvalidRequest      = errors.New("invalid hub request")
+)
+
+type Config struct {
+	// CallbackBase is the public URL the feed ID is appended to, e.g.
+	// https://agg.example.com/v1/websub/callback.
+	CallbackBase string
+	LeaseSeconds int
+	// RenewBefore is how long before a lease ends it gets renewed.
+	RenewBefore time.Duration
+	// RetryPending resends subscriptions the hub never verified.
+	RetryPending  time.Duration
+	CheckInterval time.Duration
+	// AllowPrivate lets the subscriber reach hubs on loopback and private
+	// addresses. Hub URLs come from feeds, so it is off by default.
+	AllowPrivate bool
+}
+
+func DefaultConfig(callbackBase string) Config {
+	return Config{
+		CallbackBase:  strings.TrimSuffix(callbackBase, "/"),
+		LeaseSeconds:  10 * 24 * 60 * 60,
+		RenewBefore:   24 * time.Hour,
+		RetryPending:  time.Hour,
+		CheckInterval: 10 * time.Minute,
+	}
+}
+
+type Subscriber struct {
+	db     *database.DB
+	client *http.Client
+	cfg    Config
+	handle scraper.ItemHandler
+}
+
+// NewSubscriber returns a subscriber that passes pushed content to handle.
+func NewSubscriber(db *database.DB, cfg Config, handle scraper.ItemHandler) *Subscriber {
+	client := scraper.PublicClient(30 * time.Second)
+	if cfg.AllowPrivate {
+		client = &http.Client{Timeout: 30 * time.Second}
+	}
+	return &Subscriber{
+		db:     db,
+		client: client,
+		cfg:    cfg,
+		handle: handle,
+	}
+}
+
+// HandleFeed is a scraper.ItemHandler: it hands the items on and then
+// subscribes to the feed's hub, if it advertises one.
+func (s *Subscriber) HandleFeed(ctx context.Context, feed database.Feed, parsed *scraper.ParsedFeed) error {
+	if err := s.handle(ctx, feed, parsed); err != nil {
+		return err
+	}
+	if err := s.Discover(ctx, feed, parsed); err != nil {
+		// Polling still works, so this is not a feed error.
+		log.Printf("WebSub: subscribing to %s: %v", feed.URL, err)
+	}
+	return nil
+}
+
+// Discover subscribes to the hub advertised by parsed unless an equivalent
+// subscription already exists.
+func (s *Subscriber) Discover(ctx context.Context, feed database.Feed, parsed *scraper.ParsedFeed) error {
+	if parsed.Hub == "" {
+		return nil
+	}
+	topic := parsed.Self
+	if topic == "" {
+		topic = feed.URL
+	}
+
+	secret := ""
+	sub, err := s.db.GetWebSubSubscription(ctx, feed.ID)
+	switch {
+	case errors.Is(err, database.ErrNotFound):
+	case err != nil:
+		return err
+	case sub.HubURL == parsed.Hub && sub.TopicURL == topic:
+		// Renewals and retries are driven by RenewExpiring.
+		return nil
+	default:
+		secret = sub.Secret
+	}
+	if secret == "" {
+		if secret, err = newSecret(); err != nil {
+			return err
+		}
+	}
+	return s.subscribe(ctx, feed.ID, parsed.Hub, topic, secret)
+}
+
+// Start renews leases and retries unverified subscriptions until ctx is
+// cancelled.
+func (s *Subscriber) Start(ctx context.Context) {
+	ticker := time.NewTicker(s.cfg.CheckInterval)
+	defer ticker.Stop()
+	for {
+		if err := s.RenewExpiring(ctx); err != nil && ctx.Err() == nil {
+			log.Printf("WebSub: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *Subscriber) RenewExpiring(ctx context.Context) error {
+	now := time.Now()
+	subs, err := s.db.GetWebSubSubscriptionsToRenew(ctx, now.Add(s.cfg.RenewBefore), now.Add(-s.cfg.RetryPending))
+	if err != nil {
+		return err
+	}
+	for _, sub := range subs {
+		if err := s.subscribe(ctx, sub.FeedID, sub.HubURL, sub.TopicURL, sub.Secret); err != nil {
+			log.Printf("WebSub: renewing %s at %s: %v", sub.TopicURL, sub.HubURL, err)
+		}
+	}
+	return nil
+}
+
+func (s *Subscriber) CallbackURL(feedID string) string {
+	return s.cfg.CallbackBase + "/" + url.PathEscape(feedID)
+}
+
+// subscribe records the request before sending it, because hubs may
+// verify the intent before they answer.
+func (s *Subscriber) subscribe(ctx context.Context, feedID, hub, topic, secret string) error {
+	params := database.RequestWebSubSubscriptionParams{
+		FeedID:   feedID,
+		HubURL:   hub,
+		TopicURL: topic,
+		Secret:   secret,
+	}
+	if err := s.db.RequestWebSubSubscription(ctx, params); err != nil {
+		return err
+	}
+
+	form := url.Values{
+		"hub.mode":          {"subscribe"},
+		"hub.topic":         {topic},
+		"hub.callback":      {s.CallbackURL(feedID)},
+		"hub.secret":        {secret},
+		"hub.lease_seconds": {strconv.Itoa(s.cfg.LeaseSeconds)},
+	}
+	err := s.post(ctx, hub, form)
+	if err != nil {
+		params.LastError = err.Error()
+		if rerr := s.db.RequestWebSubSubscription(ctx, params); rerr != nil {
+			return rerr
+		}
+	}
+	return err
+}
+
+func (s *Subscriber) post(ctx context.Context, hub string, form url.Values) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, hub, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	// The body is the hub's to choose and the error ends up in the API,
+	// so only the status is kept.
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("hub responded %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// VerifyIntent answers a hub's verification request for the callback of
+// feedID and returns the challenge to echo back.
+func (s *Subscriber) VerifyIntent(ctx context.Context, feedID string, q url.Values) (string, error) {
+	sub, err := s.db.GetWebSubSubscription(ctx, feedID)
+	if errors.Is(err, database.ErrNotFound) {
+		return "", ErrUnknownSubscription
+	}
+	if err != nil {
+		return "", err
+	}
+	if q.Get("hub.topic") != sub.TopicURL {
+		return "", ErrUnknownSubscription
+	}
+
+	switch q.Get("hub.mode") {
+	case "subscribe":
+		challenge := q.Get("hub.challenge")
+		if challenge == "" {
+			return "", ErrInvalidRequest
+		}
+		if sub.State == database.WebSubDenied {
+			return "", ErrUnknownSubscription
+		}
+		lease := s.cfg.LeaseSeconds
+		if v, err := strconv.Atoi(q.Get("hub.lease_seconds")); err == nil && v > 0 {
+			lease = v
+		}
+		if err := s.db.ActivateWebSubSubscription(ctx, feedID, time.Now().Add(time.Duration(lease)*time.Second)); err != nil {
+			return "", err
+		}
+		return challenge, nil
+	case "denied":
+		reason := q.Get("hub.reason")
+		if reason == "" {
+			reason = "denied by hub"
+		}
+		return "", s.db.DenyWebSubSubscription(ctx, feedID, reason)
+	default:
+		// We never unsubscribe, so an unsubscribe intent is not ours.
+		return "", ErrUnknownSubscription
+	}
+}
+
+// Receive ingests content pushed by the hub for feedID after checking its
+// X-Hub-Signature. Subscriptions that are not active are unknown.
+func (s *Subscriber) Receive(ctx context.Context, feedID, signature string, body []byte) error {
+	sub, err := s.db.GetWebSubSubscription(ctx, feedID)
+	if errors.Is(err, database.ErrNotFound) {
+		return ErrUnknownSubscription
+	}
+	if err != nil {
+		return err
+	}
+	// Content is only welcome while the hub has verified our intent and
+	// not denied it since.
+	if sub.State != database.WebSubActive {
+		return ErrUnknownSubscription
+	}
+	if !VerifySignature(sub.Secret, signature, body) {
+		return ErrBadSignature
+	}
+
+	feed, err := s.db.GetFeed(ctx, feedID)
+	if err != nil {
+		return err
+	}
+	parsed, err := scraper.ParseFeed(body)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidRequest, err)
+	}
+	return s.handle(ctx, feed, parsed)
+}
+
+// VerifySignature checks an X-Hub-Signature header ("sha256=<hex>").
+func VerifySignature(secret, header string, body []byte) bool {
+	method, sig, ok := strings.Cut(header, "=")
+	if !ok {
+		return false
+	}
+	var h func() hash.Hash
+	switch method {
+	case "sha1":
+		h = sha1.New
+	case "sha256":
+		h = sha256.New
+	case "sha384":
+		h = sha512.New384
+	case "sha512":
+		h = sha512.New
+	default:
+		return false
+	}
+	got, err := hex.DecodeString(sig)
+	if err != nil {
+		return false
+	}
+	mac := hmac.New(h, []byte(secret))
+	mac.Write(body)
+	return hmac.Equal(mac.Sum(nil), got)
+}
+
+func newSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,304 @@
+package websub_test
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	respondjson "golang/rssagg/RespondJSON"
+	"golang/rssagg/database"
+	"golang/rssagg/handler"
+	"golang/rssagg/ingest"
+	"golang/rssagg/scraper"
+	"golang/rssagg/search"
+	"golang/rssagg/websub"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testHub is a minimal stand-in WebSub hub. It accepts subscription
+// requests, verifies them asynchronously like a real hub and can
+// distribute content to verified subscribers.
+type testHub struct {
+	*httptest.Server
+	t     *testing.T
+	lease int
+
+	mu        sync.Mutex
+	requests  int
+	verified  map[string]hubSubscription // by callback
+	verifyErr chan error
+}
+
+type hubSubscription struct {
+	topic  string
+	secret string
+}
+
+func newTestHub(t *testing.T, lease int) *testHub {
+	h := &testHub{t: t, lease: lease, verified: map[string]hubSubscription{}, verifyErr: make(chan error, 10)}
+	h.Server = httptest.NewServer(http.HandlerFunc(h.serveHTTP))
+	t.Cleanup(h.Close)
+	return h
+}
+
+func (h *testHub) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil || r.Form.Get("hub.mode") != "subscribe" {
+		w.WriteHeader(400)
+		return
+	}
+	h.mu.Lock()
+	h.requests++
+	h.mu.Unlock()
+	sub := hubSubscription{topic: r.Form.Get("hub.topic"), secret: r.Form.Get("hub.secret")}
+	callback := r.Form.Get("hub.callback")
+	w.WriteHeader(202)
+	go func() { h.verifyErr <- h.verify(callback, sub) }()
+}
+
+func (h *testHub) verify(callback string, sub hubSubscription) error {
+	q := url.Values{
+		"hub.mode":          {"subscribe"},
+		"hub.topic":         {sub.topic},
+		"hub.challenge":     {"challenge-123"},
+		"hub.lease_seconds": {fmt.Sprint(h.lease)},
+	}
+	resp, err := http.Get(callback + "?" + q.Encode())
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != 200 || string(body) != "challenge-123" {
+		return fmt.Errorf("verification failed: %d %q", resp.StatusCode, body)
+	}
+	h.mu.Lock()
+	h.verified[callback] = sub
+	h.mu.Unlock()
+	return nil
+}
+
+func (h *testHub) waitVerified() {
+	select {
+	case err := <-h.verifyErr:
+		require.NoError(h.t, err)
+	case <-time.After(5 * time.Second):
+		h.t.Fatal("hub never verified the subscription")
+	}
+}
+
+// publish distributes content to every verified subscriber, signing it
+// with secret (or the subscription's own secret when empty).
+func (h *testHub) publish(body, secret string) []int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	var codes []int
+	for callback, sub := range h.verified {
+		if secret == "" {
+			secret = sub.secret
+		}
+		mac := hmac.New(sha256.New, []byte(secret))
+		mac.Write([]byte(body))
+		req, _ := http.NewRequest(http.MethodPost, callback, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/atom+xml")
+		req.Header.Set("X-Hub-Signature", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(h.t, err)
+		resp.Body.Close()
+		codes = append(codes, resp.StatusCode)
+	}
+	return codes
+}
+
+func atomFeed(hub, self string, entries ...string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, `<?xml version="1.0" encoding="utf-8"?><feed xmlns="http://www.w3.org/2005/Atom"><title>pushed</title>`)
+	fmt.Fprintf(&b, `<link rel="hub" href="%s"/><link rel="self" href="%s"/>`, hub, self)
+	for _, e := range entries {
+		fmt.Fprintf(&b, `<entry><id>%s</id><title>%s</title><updated>2024-01-01T00:00:00Z</updated></entry>`, e, e)
+	}
+	b.WriteString(`</feed>`)
+	return b.String()
+}
+
+type env struct {
+	db      *database.DB
+	index   *search.Index
+	hub     *testHub
+	sub     *websub.Subscriber
+	feed    database.Feed
+	topic   string
+	scraper *scraper.Scraper
+	cbURL   string
+}
+
+func setup(t *testing.T, lease int) *env {
+	db, err := database.Open(filepath.Join(t.TempDir(), "test.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+	_, err = db.Migrate(context.Background())
+	require.NoError(t, err)
+
+	e := &env{db: db, index: search.NewIndex(), hub: newTestHub(t, lease)}
+
+	router := chi.NewRouter()
+	callback := httptest.NewServer(router)
+	t.Cleanup(callback.Close)
+	e.cbURL = callback.URL + "/websub/callback"
+
+	publisher := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(atomFeed(e.hub.URL, e.topic, "polled")))
+	}))
+	t.Cleanup(publisher.Close)
+	e.topic = publisher.URL + "/canonical"
+
+	subCfg := websub.DefaultConfig(e.cbURL)
+	subCfg.AllowPrivate = true
+	e.sub = websub.NewSubscriber(db, subCfg, ingest.New(db, e.index, nil).HandleFeed)
+	apiCfg := handler.APIConfig{DB: db, Index: e.index, WebSub: e.sub}
+	router.Get("/websub/callback/{feedID}", respondjson.MakeHTTPHandler(apiCfg.HandlerWebSubVerify))
+	router.Post("/websub/callback/{feedID}", respondjson.MakeHTTPHandler(apiCfg.HandlerWebSubReceive))
+
+	e.feed, err = db.CreateFeed(context.Background(), database.CreateFeedParams{ID: "feed-1", Name: "pushed", URL: publisher.URL + "/feed"})
+	require.NoError(t, err)
+
+	cfg := scraper.DefaultConfig()
+	cfg.HostInterval = 0
+	cfg.AllowPrivate = true
+	e.scraper = scraper.New(db, cfg, e.sub.HandleFeed)
+	return e
+}
+
+func TestSubscribeVerifyAndReceive(t *testing.T) {
+	e := setup(t, 3600)
+	ctx := context.Background()
+
+	require.NoError(t, e.scraper.ScrapeFeed(ctx, e.feed))
+	e.hub.waitVerified()
+
+	sub, err := e.db.GetWebSubSubscription(ctx, e.feed.ID)
+	require.NoError(t, err)
+	assert.Equal(t, database.WebSubActive, sub.State)
+	assert.Equal(t, e.topic, sub.TopicURL, "rel=self is the topic")
+	assert.WithinDuration(t, time.Now().Add(time.Hour), sub.LeaseExpiresAt, time.Minute)
+
+	feed, err := e.db.GetFeed(ctx, e.feed.ID)
+	require.NoError(t, err)
+	assert.Equal(t, sub.LeaseExpiresAt, feed.PushExpiresAt)
+
+	// A later poll sees the same hub and does not subscribe again, and
+	// the next poll is pushed back to the end of the lease.
+	require.NoError(t, e.scraper.ScrapeFeed(ctx, feed))
+	assert.Equal(t, 1, e.hub.requests)
+	feed, err = e.db.GetFeed(ctx, e.feed.ID)
+	require.NoError(t, err)
+	assert.Equal(t, sub.LeaseExpiresAt, feed.NextFetchAt)
+
+	assert.Equal(t, []int{202}, e.hub.publish(atomFeed(e.hub.URL, e.topic, "pushed-1"), ""))
+	assert.Equal(t, 2, e.index.Len(), "pushed entry is ingested immediately")
+
+	assert.Equal(t, []int{202}, e.hub.publish(atomFeed(e.hub.URL, e.topic, "forged"), "wrong secret"))
+	assert.Equal(t, 2, e.index.Len(), "content with a bad signature is ignored")
+}
+
+func TestCallbackRejectsUnknownIntents(t *testing.T) {
+	e := setup(t, 3600)
+	ctx := context.Background()
+	require.NoError(t, e.scraper.ScrapeFeed(ctx, e.feed))
+	e.hub.waitVerified()
+
+	get := func(feedID string, q url.Values) int {
+		resp, err := http.Get(e.cbURL + "/" + feedID + "?" + q.Encode())
+		require.NoError(t, err)
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+	assert.Equal(t, 404, get(e.feed.ID, url.Values{"hub.mode": {"subscribe"}, "hub.topic": {"https://evil.example/"}, "hub.challenge": {"x"}}))
+	assert.Equal(t, 404, get(e.feed.ID, url.Values{"hub.mode": {"unsubscribe"}, "hub.topic": {e.topic}, "hub.challenge": {"x"}}))
+	assert.Equal(t, 404, get("other", url.Values{"hub.mode": {"subscribe"}, "hub.topic": {e.topic}, "hub.challenge": {"x"}}))
+
+	resp, err := http.Post(e.cbURL+"/other", "application/atom+xml", strings.NewReader("<feed/>"))
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, 410, resp.StatusCode)
+
+	// A denial sends the feed back to regular polling.
+	assert.Equal(t, 200, get(e.feed.ID, url.Values{"hub.mode": {"denied"}, "hub.topic": {e.topic}, "hub.reason": {"quota"}}))
+	sub, err := e.db.GetWebSubSubscription(ctx, e.feed.ID)
+	require.NoError(t, err)
+	assert.Equal(t, database.WebSubDenied, sub.State)
+	assert.Equal(t, "quota", sub.LastError)
+	feed, err := e.db.GetFeed(ctx, e.feed.ID)
+	require.NoError(t, err)
+	assert.True(t, feed.PushExpiresAt.IsZero())
+
+	// Correctly signed content for a denied subscription is refused.
+	before := e.index.Len()
+	assert.Equal(t, []int{410}, e.hub.publish(atomFeed(e.hub.URL, e.topic, "after-denial"), ""))
+	assert.Equal(t, before, e.index.Len())
+}
+
+func TestHubErrors(t *testing.T) {
+	e := setup(t, 3600)
+	ctx := context.Background()
+	hub := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "internal details", http.StatusInternalServerError)
+	}))
+	defer hub.Close()
+	parsed := &scraper.ParsedFeed{Hub: hub.URL, Self: e.topic}
+
+	// Only the status of a failing hub is recorded, never its body.
+	assert.Error(t, e.sub.Discover(ctx, e.feed, parsed))
+	sub, err := e.db.GetWebSubSubscription(ctx, e.feed.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "hub responded 500", sub.LastError)
+
+	// Hub URLs come from feeds, so private addresses are refused by default.
+	strict := websub.NewSubscriber(e.db, websub.DefaultConfig(e.cbURL), nil)
+	parsed.Hub = e.hub.URL
+	assert.ErrorIs(t, strict.Discover(ctx, e.feed, parsed), scraper.ErrPrivateAddress)
+	assert.Zero(t, e.hub.requests)
+}
+
+func TestRenewExpiringLease(t *testing.T) {
+	e := setup(t, 60)
+	ctx := context.Background()
+	require.NoError(t, e.scraper.ScrapeFeed(ctx, e.feed))
+	e.hub.waitVerified()
+	before, err := e.db.GetWebSubSubscription(ctx, e.feed.ID)
+	require.NoError(t, err)
+
+	// The lease ends within RenewBefore, so it is renewed with the same
+	// secret and stays active meanwhile.
+	require.NoError(t, e.sub.RenewExpiring(ctx))
+	assert.Equal(t, 2, e.hub.requests)
+	e.hub.waitVerified()
+
+	after, err := e.db.GetWebSubSubscription(ctx, e.feed.ID)
+	require.NoError(t, err)
+	assert.Equal(t, database.WebSubActive, after.State)
+	assert.Equal(t, before.Secret, after.Secret)
+}
+
+func TestVerifySignature(t *testing.T) {
+	body := []byte("hello")
+	mac := hmac.New(sha256.New, []byte("s3cret"))
+	mac.Write(body)
+	sig := hex.EncodeToString(mac.Sum(nil))
+	assert.True(t, websub.VerifySignature("s3cret", "sha256="+sig, body))
+	assert.False(t, websub.VerifySignature("other", "sha256="+sig, body))
+	assert.False(t, websub.VerifySignature("s3cret", "md5="+sig, body))
+	assert.False(t, websub.VerifySignature("s3cret", "", body))
+}